@@ -74,6 +74,22 @@ var inputControllersNeeded = &typesNeeded{
 		AcceptsIngressClassNameSpec:       false,
 		RBACVerbs:                         []string{"list", "watch"},
 	},
+	// ConfigMaps are only watched with --enable-controller-configmap, so the list and watch
+	// permissions on ConfigMaps are only used by the controllers which enable it.
+	typeNeeded{
+		Group:                             "\"\"",
+		Version:                           "v1",
+		Kind:                              "ConfigMap",
+		PackageImportAlias:                "corev1",
+		PackageAlias:                      "CoreV1",
+		Package:                           corev1,
+		Plural:                            "configmaps",
+		CacheType:                         "ConfigMap",
+		NeedsStatusPermissions:            false,
+		AcceptsIngressClassNameAnnotation: false,
+		AcceptsIngressClassNameSpec:       false,
+		RBACVerbs:                         []string{"list", "watch"},
+	},
 	typeNeeded{
 		Group:                             "networking.k8s.io",
 		Version:                           "v1",
@@ -189,6 +205,34 @@ var inputControllersNeeded = &typesNeeded{
 		AcceptsIngressClassNameSpec:       false,
 		RBACVerbs:                         []string{"get", "list", "watch"},
 	},
+	typeNeeded{
+		Group:                             "configuration.konghq.com",
+		Version:                           "v1beta1",
+		Kind:                              "KongConsumerGroup",
+		PackageImportAlias:                "kongv1beta1",
+		PackageAlias:                      "KongV1Beta1",
+		Package:                           kongv1beta1,
+		Plural:                            "kongconsumergroups",
+		CacheType:                         "ConsumerGroup",
+		NeedsStatusPermissions:            false,
+		AcceptsIngressClassNameAnnotation: true,
+		AcceptsIngressClassNameSpec:       false,
+		RBACVerbs:                         []string{"get", "list", "watch"},
+	},
+	typeNeeded{
+		Group:                             "configuration.konghq.com",
+		Version:                           "v1beta1",
+		Kind:                              "KongUpstreamPolicy",
+		PackageImportAlias:                "kongv1beta1",
+		PackageAlias:                      "KongV1Beta1",
+		Package:                           kongv1beta1,
+		Plural:                            "kongupstreampolicies",
+		CacheType:                         "UpstreamPolicy",
+		NeedsStatusPermissions:            false,
+		AcceptsIngressClassNameAnnotation: false,
+		AcceptsIngressClassNameSpec:       false,
+		RBACVerbs:                         []string{"get", "list", "watch"},
+	},
 	typeNeeded{
 		Group:                             "configuration.konghq.com",
 		Version:                           "v1beta1",
@@ -8,6 +8,7 @@ const (
 	ErrTextConsumerUsernameEmpty              = "username cannot be empty"
 	ErrTextFailedToRetrieveSecret             = "could not retrieve secrets from the kubernets API" //nolint:gosec
 	ErrTextPluginConfigInvalid                = "could not parse plugin configuration"
+	ErrTextPluginConfigMapsDisabled           = "plugin cannot use ConfigMaps in ConfigFrom when the ConfigMap controller is disabled"
 	ErrTextPluginConfigValidationFailed       = "unable to validate plugin schema"
 	ErrTextPluginConfigViolatesSchema         = "plugin failed schema validation: %s"
 	ErrTextPluginNameEmpty                    = "plugin name cannot be empty"
@@ -2,12 +2,14 @@ package admission
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/kong/go-kong/kong"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,11 +36,16 @@ type KongValidator interface {
 // KongHTTPValidator implements KongValidator interface to validate Kong
 // entities using the Admin API of Kong.
 type KongHTTPValidator struct {
-	ConsumerSvc   kong.AbstractConsumerService
-	PluginSvc     kong.AbstractPluginService
-	Logger        logrus.FieldLogger
-	SecretGetter  kongstate.SecretGetter
-	ManagerClient client.Client
+	ConsumerSvc     kong.AbstractConsumerService
+	PluginSvc       kong.AbstractPluginService
+	Logger          logrus.FieldLogger
+	SecretGetter    kongstate.SecretGetter
+	ConfigMapGetter kongstate.ConfigMapGetter
+	ManagerClient   client.Client
+
+	// ConfigMapsEnabled reports whether the ConfigMap controller is enabled. ConfigMaps
+	// aren't cached otherwise, so plugins can't be configured from them.
+	ConfigMapsEnabled bool
 
 	ingressClassMatcher func(*metav1.ObjectMeta, string, annotations.ClassMatching) bool
 }
@@ -53,14 +60,18 @@ func NewKongHTTPValidator(
 	logger logrus.FieldLogger,
 	managerClient client.Client,
 	ingressClass string,
+	configMapsEnabled bool,
 ) KongHTTPValidator {
 	matcher := annotations.IngressClassValidatorFuncFromObjectMeta(ingressClass)
 	return KongHTTPValidator{
-		ConsumerSvc:   consumerSvc,
-		PluginSvc:     pluginSvc,
-		Logger:        logger,
-		SecretGetter:  &managerClientSecretGetter{managerClient: managerClient},
-		ManagerClient: managerClient,
+		ConsumerSvc:     consumerSvc,
+		PluginSvc:       pluginSvc,
+		Logger:          logger,
+		SecretGetter:    &managerClientSecretGetter{managerClient: managerClient},
+		ConfigMapGetter: &managerClientConfigMapGetter{managerClient: managerClient},
+		ManagerClient:   managerClient,
+
+		ConfigMapsEnabled: configMapsEnabled,
 
 		ingressClassMatcher: matcher,
 	}
@@ -240,7 +251,11 @@ func (validator KongHTTPValidator) ValidatePlugin(
 		if len(plugin.Config) > 0 {
 			return false, ErrTextPluginUsesBothConfigTypes, nil
 		}
-		config, err := kongstate.SecretToConfiguration(validator.SecretGetter, (*k8sPlugin.ConfigFrom).SecretValue, k8sPlugin.Namespace)
+		if !validator.ConfigMapsEnabled && k8sPlugin.ConfigFrom.ConfigMapValue != (kongv1.ConfigMapValueFromSource{}) {
+			return false, ErrTextPluginConfigMapsDisabled, nil
+		}
+		config, err := kongstate.ConfigSourceToConfiguration(validator.SecretGetter, validator.ConfigMapGetter,
+			*k8sPlugin.ConfigFrom, k8sPlugin.Namespace)
 		if err != nil {
 			return false, ErrTextPluginSecretConfigUnretrievable, err
 		}
@@ -281,7 +296,31 @@ func (validator KongHTTPValidator) ValidateClusterPlugin(
 		RunOn:       k8sPlugin.RunOn,
 		Protocols:   k8sPlugin.Protocols,
 	}
-	if k8sPlugin.ConfigFrom != nil {
+	if k8sPlugin.ConfigFrom != nil && k8sPlugin.ConfigFrom.ConfigMapValue != (kongv1.NamespacedConfigMapValueFromSource{}) {
+		if !validator.ConfigMapsEnabled {
+			return false, ErrTextPluginConfigMapsDisabled, nil
+		}
+		// the Secret and the ConfigMap may live in different namespaces, so the configuration
+		// is resolved here and validated as if it was set inline
+		config, err := kongstate.RawConfigToConfiguration(k8sPlugin.Config)
+		if err != nil {
+			return false, ErrTextPluginConfigInvalid, err
+		}
+		if len(config) > 0 {
+			return false, ErrTextPluginUsesBothConfigTypes, nil
+		}
+		config, err = kongstate.NamespacedConfigSourceToConfiguration(validator.SecretGetter, validator.ConfigMapGetter,
+			*k8sPlugin.ConfigFrom)
+		if err != nil {
+			return false, ErrTextPluginSecretConfigUnretrievable, err
+		}
+		raw, err := json.Marshal(config)
+		if err != nil {
+			return false, ErrTextPluginConfigInvalid, err
+		}
+		derived.Config = apiextensionsv1.JSON{Raw: raw}
+		derived.ObjectMeta.Namespace = k8sPlugin.ConfigFrom.ConfigMapValue.Namespace
+	} else if k8sPlugin.ConfigFrom != nil {
 		ref := kongv1.ConfigSource{
 			SecretValue: kongv1.SecretValueFromSource{
 				Secret: k8sPlugin.ConfigFrom.SecretValue.Secret,
@@ -411,3 +450,15 @@ func (m *managerClientSecretGetter) GetSecret(namespace, name string) (*corev1.S
 		Name:      name,
 	}, secret)
 }
+
+type managerClientConfigMapGetter struct {
+	managerClient client.Client
+}
+
+func (m *managerClientConfigMapGetter) GetConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{}
+	return configMap, m.managerClient.Get(context.Background(), client.ObjectKey{
+		Namespace: namespace,
+		Name:      name,
+	}, configMap)
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/kong/go-kong/kong"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -32,12 +33,13 @@ func TestKongHTTPValidator_ValidatePlugin(t *testing.T) {
 		plugin configurationv1.KongPlugin
 	}
 	tests := []struct {
-		name        string
-		PluginSvc   kong.AbstractPluginService
-		args        args
-		wantOK      bool
-		wantMessage string
-		wantErr     bool
+		name               string
+		PluginSvc          kong.AbstractPluginService
+		configMapsDisabled bool
+		args               args
+		wantOK             bool
+		wantMessage        string
+		wantErr            bool
 	}{
 		{
 			name:      "plugin is valid",
@@ -105,6 +107,25 @@ func TestKongHTTPValidator_ValidatePlugin(t *testing.T) {
 			wantMessage: ErrTextPluginUsesBothConfigTypes,
 			wantErr:     false,
 		},
+		{
+			name:               "plugin ConfigFrom references a ConfigMap when the ConfigMap controller is disabled",
+			PluginSvc:          &fakePluginSvc{valid: true},
+			configMapsDisabled: true,
+			args: args{
+				plugin: configurationv1.KongPlugin{
+					PluginName: "key-auth",
+					ConfigFrom: &configurationv1.ConfigSource{
+						ConfigMapValue: configurationv1.ConfigMapValueFromSource{
+							Key:       "key-auth-config",
+							ConfigMap: "conf-configmap",
+						},
+					},
+				},
+			},
+			wantOK:      false,
+			wantMessage: ErrTextPluginConfigMapsDisabled,
+			wantErr:     false,
+		},
 		{
 			name:      "plugin ConfigFrom references non-existent Secret",
 			PluginSvc: &fakePluginSvc{},
@@ -139,6 +160,7 @@ func TestKongHTTPValidator_ValidatePlugin(t *testing.T) {
 			validator := KongHTTPValidator{
 				SecretGetter:        store,
 				PluginSvc:           tt.PluginSvc,
+				ConfigMapsEnabled:   !tt.configMapsDisabled,
 				ingressClassMatcher: fakeClassMatcher,
 			}
 			got, got1, err := validator.ValidatePlugin(context.Background(), tt.args.plugin)
@@ -157,17 +179,30 @@ func TestKongHTTPValidator_ValidatePlugin(t *testing.T) {
 }
 
 func TestKongHTTPValidator_ValidateClusterPlugin(t *testing.T) {
-	store, _ := store.NewFakeStore(store.FakeObjects{})
+	store, _ := store.NewFakeStore(store.FakeObjects{
+		ConfigMaps: []*corev1.ConfigMap{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "conf-configmap",
+					Namespace: "other",
+				},
+				Data: map[string]string{
+					"key-auth-config": `{"key_names": ["apikey"]}`,
+				},
+			},
+		},
+	})
 	type args struct {
 		plugin configurationv1.KongClusterPlugin
 	}
 	tests := []struct {
-		name        string
-		PluginSvc   kong.AbstractPluginService
-		args        args
-		wantOK      bool
-		wantMessage string
-		wantErr     bool
+		name               string
+		PluginSvc          kong.AbstractPluginService
+		configMapsDisabled bool
+		args               args
+		wantOK             bool
+		wantMessage        string
+		wantErr            bool
 	}{
 		{
 			name:      "plugin is valid",
@@ -236,6 +271,69 @@ func TestKongHTTPValidator_ValidateClusterPlugin(t *testing.T) {
 			wantMessage: ErrTextPluginUsesBothConfigTypes,
 			wantErr:     false,
 		},
+		{
+			name:      "plugin ConfigFrom references a ConfigMap",
+			PluginSvc: &fakePluginSvc{valid: true},
+			args: args{
+				plugin: configurationv1.KongClusterPlugin{
+					PluginName: "key-auth",
+					ConfigFrom: &configurationv1.NamespacedConfigSource{
+						ConfigMapValue: configurationv1.NamespacedConfigMapValueFromSource{
+							Key:       "key-auth-config",
+							ConfigMap: "conf-configmap",
+							Namespace: "other",
+						},
+					},
+				},
+			},
+			wantOK:      true,
+			wantMessage: "",
+			wantErr:     false,
+		},
+		{
+			name:               "plugin ConfigFrom references a ConfigMap when the ConfigMap controller is disabled",
+			PluginSvc:          &fakePluginSvc{valid: true},
+			configMapsDisabled: true,
+			args: args{
+				plugin: configurationv1.KongClusterPlugin{
+					PluginName: "key-auth",
+					ConfigFrom: &configurationv1.NamespacedConfigSource{
+						ConfigMapValue: configurationv1.NamespacedConfigMapValueFromSource{
+							Key:       "key-auth-config",
+							ConfigMap: "conf-configmap",
+							Namespace: "other",
+						},
+					},
+				},
+			},
+			wantOK:      false,
+			wantMessage: ErrTextPluginConfigMapsDisabled,
+			wantErr:     false,
+		},
+		{
+			name:      "plugin ConfigFrom references a ConfigMap and a non-existent Secret",
+			PluginSvc: &fakePluginSvc{valid: true},
+			args: args{
+				plugin: configurationv1.KongClusterPlugin{
+					PluginName: "key-auth",
+					ConfigFrom: &configurationv1.NamespacedConfigSource{
+						SecretValue: configurationv1.NamespacedSecretValueFromSource{
+							Key:       "key-auth-config",
+							Secret:    "conf-secret",
+							Namespace: "default",
+						},
+						ConfigMapValue: configurationv1.NamespacedConfigMapValueFromSource{
+							Key:       "key-auth-config",
+							ConfigMap: "conf-configmap",
+							Namespace: "other",
+						},
+					},
+				},
+			},
+			wantOK:      false,
+			wantMessage: ErrTextPluginSecretConfigUnretrievable,
+			wantErr:     true,
+		},
 		{
 			name:      "plugin ConfigFrom references non-existent Secret",
 			PluginSvc: &fakePluginSvc{},
@@ -270,7 +368,9 @@ func TestKongHTTPValidator_ValidateClusterPlugin(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			validator := KongHTTPValidator{
 				SecretGetter:        store,
+				ConfigMapGetter:     store,
 				PluginSvc:           tt.PluginSvc,
+				ConfigMapsEnabled:   !tt.configMapsDisabled,
 				ingressClassMatcher: fakeClassMatcher,
 			}
 			got, got1, err := validator.ValidateClusterPlugin(context.Background(), tt.args.plugin)
@@ -40,6 +40,7 @@ const (
 
 	ConfigurationKey     = "/override"
 	PluginsKey           = "/plugins"
+	ConsumerGroupsKey    = "/consumer-groups"
 	ProtocolKey          = "/protocol"
 	ProtocolsKey         = "/protocols"
 	ClientCertKey        = "/client-cert"
@@ -55,6 +56,58 @@ const (
 	ResponseBuffering    = "/response-buffering"
 	HostAliasesKey       = "/host-aliases"
 
+	// ConsolidatePluginsKey is an annotation used on a Service resource to collapse
+	// plugins attached to every route of the service into a single service plugin.
+	ConsolidatePluginsKey = "/consolidate-plugins"
+
+	// TagsKey is an annotation (or label) used on a credential Secret resource
+	// to set comma-separated Kong tags on the credential. It's also used on Ingress
+	// resources to set Kong tags on the routes generated from them, and on KongConsumer
+	// resources (as an annotation or label) to set Kong tags on the consumers.
+	TagsKey = "/tags"
+
+	// BinaryCredentialFieldsKey is an annotation used on a credential Secret resource
+	// to list the comma-separated fields holding binary values, which are base64 encoded.
+	BinaryCredentialFieldsKey = "/binary-credential-fields"
+
+	// CredentialTTLKey is an annotation used on a credential Secret resource
+	// to set the time to live of the credential, for credential types supporting it.
+	CredentialTTLKey = "/credential-ttl"
+
+	// UpstreamPolicyKey is an annotation used on a Service resource to attach
+	// a KongUpstreamPolicy configuring the Kong Upstream of the service.
+	UpstreamPolicyKey = "/upstream-policy"
+
+	// PluginEnabledKeyPrefix and PluginEnabledKeySuffix surround the name of a KongPlugin
+	// attached with the plugins annotation to form an annotation disabling the plugin on
+	// the annotated resource when set to "false", e.g. konghq.com/plugin-rate-limit-enabled.
+	PluginEnabledKeyPrefix = "/plugin-"
+	PluginEnabledKeySuffix = "-enabled"
+
+	// ApplyToKey is an annotation, or label, used on KongPlugin resources to attach the
+	// plugin to every service and route of its namespace when set to ApplyToAll, e.g. to
+	// migrate away from global KongPlugins or to enforce a baseline plugin in a namespace
+	// without creating cluster-scoped resources.
+	ApplyToKey = "/apply-to"
+	// ApplyToAll is the value of the apply-to annotation attaching a KongPlugin to every
+	// service and route of its namespace.
+	ApplyToAll = "all"
+
+	// HeadersKeyPrefix is followed by a header name to form an annotation used on Ingress
+	// resources to match the requests of the routes generated from them on the header
+	// comma-separated values, e.g. konghq.com/headers.x-custom: v1,v2.
+	HeadersKeyPrefix = "/headers."
+
+	// PathHandlingKey is an annotation used on Ingress resources to set the path_handling
+	// of the routes generated from them, i.e. how Kong combines the service path, the route
+	// path and the requested path.
+	PathHandlingKey = "/path-handling"
+
+	// WeightKey is an annotation used on Service resources to set the share of the traffic of
+	// a Kong service sent to them when the Kong service has several backends, e.g. for canary
+	// deployments. Backends setting their own weight, e.g. Gateway API backendRefs, ignore it.
+	WeightKey = "/weight"
+
 	// GatewayUnmanagedAnnotation is an annotation used on a Gateway resource to
 	// indicate that the Gateway should be reconciled according to unmanaged
 	// mode.
@@ -131,6 +184,30 @@ func ExtractKongPluginsFromAnnotations(anns map[string]string) []string {
 	return kongPluginCRs
 }
 
+// ExtractPluginEnabled extracts whether the KongPlugin attached with the plugins
+// annotation under pluginName is enabled on the annotated resource. Plugins are
+// enabled unless the annotation is set to "false".
+func ExtractPluginEnabled(anns map[string]string, pluginName string) bool {
+	return anns[AnnotationPrefix+PluginEnabledKeyPrefix+pluginName+PluginEnabledKeySuffix] != "false"
+}
+
+// ExtractConsumerGroups extracts the names of the KongConsumerGroups
+// a KongConsumer belongs to from the konghq.com/consumer-groups annotation.
+func ExtractConsumerGroups(anns map[string]string) []string {
+	var consumerGroups []string
+	v := anns[AnnotationPrefix+ConsumerGroupsKey]
+	if v == "" {
+		return consumerGroups
+	}
+	for _, consumerGroup := range strings.Split(v, ",") {
+		s := strings.TrimSpace(consumerGroup)
+		if s != "" {
+			consumerGroups = append(consumerGroups, s)
+		}
+	}
+	return consumerGroups
+}
+
 // ExtractConfigurationName extracts the name of the KongIngress object that holds
 // information about the configuration to use in Routes, Services and Upstreams.
 func ExtractConfigurationName(anns map[string]string) string {
@@ -194,6 +271,16 @@ func ExtractRegexPriority(anns map[string]string) string {
 	return anns[AnnotationPrefix+RegexPriorityKey]
 }
 
+// ExtractPathHandling extracts the path-handling annotation value.
+func ExtractPathHandling(anns map[string]string) string {
+	return anns[AnnotationPrefix+PathHandlingKey]
+}
+
+// ExtractWeight extracts the weight annotation value.
+func ExtractWeight(anns map[string]string) string {
+	return anns[AnnotationPrefix+WeightKey]
+}
+
 // ExtractHostHeader extracts the host-header annotation value.
 func ExtractHostHeader(anns map[string]string) string {
 	return anns[AnnotationPrefix+HostHeaderKey]
@@ -208,7 +295,9 @@ func ExtractMethods(anns map[string]string) []string {
 	return strings.Split(val, ",")
 }
 
-// ExtractSNIs extracts the route SNI match criteria annotation value.
+// ExtractSNIs extracts the SNIs annotation value. On Ingresses and Services, it holds
+// the route SNI match criteria, which only apply to routes using TLS protocols. On TLS Secrets,
+// it holds extra SNIs served by the certificate.
 func ExtractSNIs(anns map[string]string) ([]string, bool) {
 	val, exists := anns[AnnotationPrefix+SNIsKey]
 	if val == "" {
@@ -243,6 +332,74 @@ func ExtractHostAliases(anns map[string]string) ([]string, bool) {
 	return strings.Split(val, ","), true
 }
 
+// ExtractConsolidatePlugins extracts whether plugins attached to every route
+// of a service should be collapsed into a single service plugin.
+func ExtractConsolidatePlugins(anns map[string]string) bool {
+	return anns[AnnotationPrefix+ConsolidatePluginsKey] == "true"
+}
+
+// ExtractTags extracts the comma-separated Kong tags set with the tags annotation.
+func ExtractTags(anns map[string]string) []string {
+	var tags []string
+	for _, tag := range strings.Split(anns[AnnotationPrefix+TagsKey], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ExtractHeaders extracts the values of the headers annotations, keyed by header name.
+// Header values are split on commas; annotations without any value are ignored.
+func ExtractHeaders(anns map[string]string) map[string][]string {
+	headers := map[string][]string{}
+	for key, value := range anns {
+		name := strings.TrimPrefix(key, AnnotationPrefix+HeadersKeyPrefix)
+		if name == key || name == "" {
+			continue
+		}
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				headers[name] = append(headers[name], v)
+			}
+		}
+	}
+	return headers
+}
+
+// ExtractBinaryCredentialFields extracts the credential fields holding binary values.
+func ExtractBinaryCredentialFields(anns map[string]string) []string {
+	var fields []string
+	v := anns[AnnotationPrefix+BinaryCredentialFieldsKey]
+	if v == "" {
+		return fields
+	}
+	for _, field := range strings.Split(v, ",") {
+		s := strings.TrimSpace(field)
+		if s != "" {
+			fields = append(fields, s)
+		}
+	}
+	return fields
+}
+
+// ExtractCredentialTTL extracts the time to live of a credential.
+func ExtractCredentialTTL(anns map[string]string) string {
+	return anns[AnnotationPrefix+CredentialTTLKey]
+}
+
+// ExtractUpstreamPolicy extracts the name of the KongUpstreamPolicy
+// attached to a service.
+func ExtractUpstreamPolicy(anns map[string]string) string {
+	return anns[AnnotationPrefix+UpstreamPolicyKey]
+}
+
+// ExtractApplyToAll reports whether the apply-to annotation is set to ApplyToAll. It also
+// applies to labels, which share the format of annotations.
+func ExtractApplyToAll(anns map[string]string) bool {
+	return strings.TrimSpace(anns[AnnotationPrefix+ApplyToKey]) == ApplyToAll
+}
+
 // ExtractUnmanagedGatewayMode extracts the value of the unmanaged gateway
 // mode annotation.
 func ExtractUnmanagedGatewayMode(anns map[string]string) (string, bool) {
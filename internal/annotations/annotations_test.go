@@ -156,6 +156,41 @@ func TestExtractKongPluginsFromAnnotations(t *testing.T) {
 	}
 }
 
+func TestExtractConsumerGroups(t *testing.T) {
+	type args struct {
+		anns map[string]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "empty",
+			args: args{
+				anns: map[string]string{},
+			},
+			want: nil,
+		},
+		{
+			name: "non-empty",
+			args: args{
+				anns: map[string]string{
+					"konghq.com/consumer-groups": "gold, silver,",
+				},
+			},
+			want: []string{"gold", "silver"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractConsumerGroups(tt.args.anns); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractConsumerGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestExtractConfigurationName(t *testing.T) {
 	type args struct {
 		anns map[string]string
@@ -470,6 +505,43 @@ func TestExtractRegexPriority(t *testing.T) {
 	}
 }
 
+func TestExtractPathHandling(t *testing.T) {
+	type args struct {
+		anns map[string]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "empty",
+			want: "",
+		},
+		{
+			name: "non-empty",
+			args: args{
+				anns: map[string]string{
+					"konghq.com/path-handling": "v1",
+				},
+			},
+			want: "v1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractPathHandling(tt.args.anns); got != tt.want {
+				t.Errorf("ExtractPathHandling() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractWeight(t *testing.T) {
+	assert.Equal(t, "", ExtractWeight(nil))
+	assert.Equal(t, "80", ExtractWeight(map[string]string{"konghq.com/weight": "80"}))
+}
+
 func TestExtractHostHeader(t *testing.T) {
 	type args struct {
 		anns map[string]string
@@ -684,3 +756,115 @@ func TestExtractHostAliases(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractConsolidatePlugins(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		anns map[string]string
+		want bool
+	}{
+		{
+			name: "empty",
+			want: false,
+		},
+		{
+			name: "enabled",
+			anns: map[string]string{"konghq.com/consolidate-plugins": "true"},
+			want: true,
+		},
+		{
+			name: "disabled",
+			anns: map[string]string{"konghq.com/consolidate-plugins": "false"},
+			want: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExtractConsolidatePlugins(tt.anns))
+		})
+	}
+}
+
+func TestExtractPluginEnabled(t *testing.T) {
+	anns := map[string]string{
+		"konghq.com/plugin-rate-limit-enabled": "false",
+		"konghq.com/plugin-cors-enabled":       "true",
+	}
+	assert.False(t, ExtractPluginEnabled(anns, "rate-limit"))
+	assert.True(t, ExtractPluginEnabled(anns, "cors"))
+	assert.True(t, ExtractPluginEnabled(anns, "key-auth"))
+	assert.True(t, ExtractPluginEnabled(nil, "key-auth"))
+}
+
+func TestExtractTags(t *testing.T) {
+	assert.Nil(t, ExtractTags(nil))
+	assert.Equal(t, []string{"team-a", "prod"}, ExtractTags(map[string]string{"konghq.com/tags": " team-a,,prod "}))
+}
+
+func TestExtractHeaders(t *testing.T) {
+	assert.Empty(t, ExtractHeaders(nil))
+	assert.Equal(t, map[string][]string{
+		"x-custom": {"v1", "v2"},
+		"x-other":  {"v3"},
+	}, ExtractHeaders(map[string]string{
+		"konghq.com/headers.x-custom": "v1, v2,",
+		"konghq.com/headers.x-other":  "v3",
+		"konghq.com/headers.x-empty":  "",
+		"konghq.com/headers.":         "v4",
+		"konghq.com/tags":             "foo",
+	}))
+}
+
+func TestExtractBinaryCredentialFields(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		anns map[string]string
+		want []string
+	}{
+		{
+			name: "empty",
+			want: nil,
+		},
+		{
+			name: "comma-separated fields",
+			anns: map[string]string{"konghq.com/binary-credential-fields": "secret, key,,"},
+			want: []string{"secret", "key"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExtractBinaryCredentialFields(tt.anns))
+		})
+	}
+}
+
+func TestExtractCredentialTTL(t *testing.T) {
+	assert.Equal(t, "", ExtractCredentialTTL(nil))
+	assert.Equal(t, "24h", ExtractCredentialTTL(map[string]string{"konghq.com/credential-ttl": "24h"}))
+}
+
+func TestExtractUpstreamPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		anns map[string]string
+		want string
+	}{
+		{
+			name: "empty",
+			want: "",
+		},
+		{
+			name: "non-empty",
+			anns: map[string]string{"konghq.com/upstream-policy": "sticky-sessions"},
+			want: "sticky-sessions",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExtractUpstreamPolicy(tt.anns))
+		})
+	}
+}
+
+func TestExtractApplyToAll(t *testing.T) {
+	assert.False(t, ExtractApplyToAll(nil))
+	assert.False(t, ExtractApplyToAll(map[string]string{"konghq.com/apply-to": "some"}))
+	assert.True(t, ExtractApplyToAll(map[string]string{"konghq.com/apply-to": "all"}))
+}
@@ -71,6 +71,15 @@ func ToDeckContent(
 	})
 
 	for _, plugin := range k8sState.Plugins {
+		// the decK file format in use can't scope plugins to consumer groups, so such plugins
+		// would be applied globally
+		if plugin.ConsumerGroup != nil {
+			log.WithFields(logrus.Fields{
+				"plugin_name":    *plugin.Name,
+				"consumer_group": *plugin.ConsumerGroup,
+			}).Warn("plugins scoped to consumer groups are not supported yet, skipping plugin")
+			continue
+		}
 		plugin := file.FPlugin{
 			Plugin: plugin.Plugin,
 		}
@@ -0,0 +1,23 @@
+package deckgen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/kongstate"
+)
+
+func TestToDeckContent_ConsumerGroupPlugins(t *testing.T) {
+	state := &kongstate.KongState{
+		Plugins: []kongstate.Plugin{{
+			Plugin:        kong.Plugin{Name: kong.String("rate-limiting")},
+			ConsumerGroup: kong.String("gold"),
+		}},
+	}
+	content := ToDeckContent(context.Background(), logrus.New(), state, nil, nil)
+	assert.Empty(t, content.Plugins, "plugins scoped to consumer groups should not be generated as global plugins")
+}
@@ -10,9 +10,12 @@ import (
 	"github.com/kong/go-kong/kong"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/deckgen"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/kongstate"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/parser"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/sendconfig"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/metrics"
@@ -79,6 +82,89 @@ type KongClient struct {
 	// updates to the prometheus exporter.
 	prometheusMetrics *metrics.CtrlFuncMetrics
 
+	// enablePluginSchemaValidation indicates whether plugin configurations are
+	// validated against the Kong plugin schemas during parsing.
+	enablePluginSchemaValidation bool
+
+	// overridesConcurrency is the maximum number of Kong Services whose overrides
+	// are computed at the same time during parsing.
+	overridesConcurrency int
+
+	// storeRetryPolicy bounds the retries of the store lookups failing with a transient
+	// error during parsing.
+	storeRetryPolicy kongstate.StoreRetryPolicy
+
+	// consumerNamespaces selects the namespaces whose KongConsumers are
+	// translated into Kong consumers.
+	consumerNamespaces *kongstate.NamespaceFilter
+
+	// consumerSelector selects the KongConsumers translated into Kong consumers
+	// by their labels.
+	consumerSelector labels.Selector
+
+	// credentialTypeKey is the key of credential Secrets holding the credential type.
+	credentialTypeKey string
+
+	// instanceTag is the tag added to all the entities generated by this instance during parsing.
+	instanceTag string
+
+	// dropConflictingCredentials indicates whether credentials sharing a unique value
+	// with a credential of an older KongConsumer are dropped during parsing.
+	dropConflictingCredentials bool
+
+	// strictConsumerIdentifiers indicates whether KongConsumers with neither a username
+	// nor a custom ID are reported as errors during parsing.
+	strictConsumerIdentifiers bool
+
+	// allowedCredentialTypes are the types of the credentials provisioned during parsing,
+	// all the supported types being provisioned if it's empty.
+	allowedCredentialTypes []string
+
+	// indexCredentialSecrets indicates whether credential Secrets are listed once and
+	// served from an index during parsing, rather than looked up one by one.
+	indexCredentialSecrets bool
+
+	// validateCertificateSNIs indicates whether the SNIs of certificates are checked
+	// against the names of the certificates during parsing. When strictCertificateSNIs
+	// is set, SNIs which aren't covered by their certificate are dropped.
+	validateCertificateSNIs bool
+	strictCertificateSNIs   bool
+
+	// certificateExpiryWarningThreshold is the remaining validity below which a warning
+	// is logged for certificates during parsing.
+	certificateExpiryWarningThreshold time.Duration
+
+	// pluginNamespaceIsolation indicates whether KongPlugins are only attached to
+	// objects from their own namespace.
+	pluginNamespaceIsolation bool
+
+	// detectPluginOverlaps indicates whether plugins attached to both a route and its service
+	// are logged during parsing. When strictPluginOverlaps is set, they fail the update.
+	detectPluginOverlaps bool
+	strictPluginOverlaps bool
+
+	// maxPluginConfigSize is the size above which the serialized configuration of plugins
+	// is logged during parsing, 0 disabling the check. When strictPluginConfigSize is set,
+	// such plugins are dropped.
+	maxPluginConfigSize    int
+	strictPluginConfigSize bool
+
+	// deterministicPluginIDs indicates whether plugins get IDs derived from their
+	// name, attachments and configuration during parsing.
+	deterministicPluginIDs bool
+
+	// globalPluginSelector selects the global KongClusterPlugins translated into
+	// global Kong plugins by their labels.
+	globalPluginSelector labels.Selector
+
+	// stateTransformers are registered on the parser of every update, in order.
+	stateTransformers []stateTransformer
+
+	// warned keeps track of the deprecation warnings already logged and the
+	// KongConsumer events already emitted while parsing, so that they're not
+	// repeated on every update.
+	warned *kongstate.WarnedSet
+
 	// kubernetesObjectReportLock is a mutex for thread-safety of
 	// kubernetes object reporting functionality.
 	kubernetesObjectReportLock sync.RWMutex
@@ -104,6 +190,11 @@ type KongClient struct {
 	// whether a Kubernetes object has corresponding data-plane configuration that
 	// is actively configured (e.g. to know how to set the object status).
 	kubernetesObjectReportsFilter k8sobj.Set
+
+	// eventRecorder is used to emit Kubernetes events on objects which could
+	// not be translated into data-plane configuration. Events are not emitted
+	// when it's nil.
+	eventRecorder record.EventRecorder
 }
 
 // NewKongClient provides a new KongClient object after connecting to the
@@ -129,6 +220,9 @@ func NewKongClient(
 		prometheusMetrics:  metrics.NewCtrlFuncMetrics(),
 		cache:              &cache,
 		kongConfig:         kongConfig,
+		warned:             kongstate.NewWarnedSet(),
+
+		certificateExpiryWarningThreshold: kongstate.DefaultCertificateExpiryWarningThreshold,
 	}
 
 	// download the kong root configuration (and validate connectivity to the proxy API)
@@ -272,6 +366,319 @@ func (c *KongClient) AreCombinedServiceRoutesEnabled() bool {
 	return c.enableCombinedServiceRoutes
 }
 
+// EnablePluginSchemaValidation makes the client validate plugin configurations
+// against the plugin schemas of the Kong it manages while parsing, dropping
+// plugins with invalid configurations.
+func (c *KongClient) EnablePluginSchemaValidation() {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.enablePluginSchemaValidation = true
+}
+
+// IsPluginSchemaValidationEnabled determines whether plugin configurations are
+// validated against the Kong plugin schemas.
+func (c *KongClient) IsPluginSchemaValidationEnabled() bool {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.enablePluginSchemaValidation
+}
+
+// SetOverridesConcurrency sets the maximum number of Kong Services whose
+// KongIngress overrides are computed at the same time while parsing.
+func (c *KongClient) SetOverridesConcurrency(concurrency int) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.overridesConcurrency = concurrency
+}
+
+// getOverridesConcurrency returns the concurrency set with SetOverridesConcurrency.
+func (c *KongClient) getOverridesConcurrency() int {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.overridesConcurrency
+}
+
+// SetStoreRetryPolicy sets the policy of the retries of the credential Secret and plugin
+// lookups failing with a transient error while parsing.
+func (c *KongClient) SetStoreRetryPolicy(policy kongstate.StoreRetryPolicy) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.storeRetryPolicy = policy
+}
+
+// getStoreRetryPolicy returns the policy set with SetStoreRetryPolicy.
+func (c *KongClient) getStoreRetryPolicy() kongstate.StoreRetryPolicy {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.storeRetryPolicy
+}
+
+// SetConsumerNamespaceFilter makes the client ignore KongConsumers from
+// namespaces which don't pass the filter.
+func (c *KongClient) SetConsumerNamespaceFilter(filter *kongstate.NamespaceFilter) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.consumerNamespaces = filter
+}
+
+// getConsumerNamespaceFilter returns the filter set with SetConsumerNamespaceFilter, if any.
+func (c *KongClient) getConsumerNamespaceFilter() *kongstate.NamespaceFilter {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.consumerNamespaces
+}
+
+// SetConsumerSelector makes the client ignore KongConsumers whose labels
+// don't match the selector.
+func (c *KongClient) SetConsumerSelector(selector labels.Selector) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.consumerSelector = selector
+}
+
+// getConsumerSelector returns the selector set with SetConsumerSelector, if any.
+func (c *KongClient) getConsumerSelector() labels.Selector {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.consumerSelector
+}
+
+// EnableConflictingCredentialDropping makes the client drop credentials sharing a value
+// Kong requires to be unique with a credential of an older KongConsumer.
+func (c *KongClient) EnableConflictingCredentialDropping() {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.dropConflictingCredentials = true
+}
+
+// isConflictingCredentialDroppingEnabled reports whether EnableConflictingCredentialDropping was called.
+func (c *KongClient) isConflictingCredentialDroppingEnabled() bool {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.dropConflictingCredentials
+}
+
+// EnableStrictConsumerIdentifiers makes the client report KongConsumers with neither a
+// username nor a custom ID as configuration errors.
+func (c *KongClient) EnableStrictConsumerIdentifiers() {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.strictConsumerIdentifiers = true
+}
+
+// isStrictConsumerIdentifiersEnabled reports whether EnableStrictConsumerIdentifiers was called.
+func (c *KongClient) isStrictConsumerIdentifiersEnabled() bool {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.strictConsumerIdentifiers
+}
+
+// SetAllowedCredentialTypes restricts the KongConsumer credentials provisioned by the client
+// to credTypes. All the supported credential types are provisioned if it's empty.
+func (c *KongClient) SetAllowedCredentialTypes(credTypes []string) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.allowedCredentialTypes = credTypes
+}
+
+// getAllowedCredentialTypes returns the credential types set with SetAllowedCredentialTypes.
+func (c *KongClient) getAllowedCredentialTypes() []string {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.allowedCredentialTypes
+}
+
+// EnableCredentialSecretIndexing makes the client list credential Secrets once per
+// configuration update instead of looking each of them up in the store.
+func (c *KongClient) EnableCredentialSecretIndexing() {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.indexCredentialSecrets = true
+}
+
+// isCredentialSecretIndexingEnabled reports whether EnableCredentialSecretIndexing was called.
+func (c *KongClient) isCredentialSecretIndexingEnabled() bool {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.indexCredentialSecrets
+}
+
+// SetGlobalPluginSelector makes the client ignore global KongClusterPlugins whose labels
+// don't match the selector.
+func (c *KongClient) SetGlobalPluginSelector(selector labels.Selector) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.globalPluginSelector = selector
+}
+
+// getGlobalPluginSelector returns the selector set with SetGlobalPluginSelector, if any.
+func (c *KongClient) getGlobalPluginSelector() labels.Selector {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.globalPluginSelector
+}
+
+// SetCredentialTypeKey sets the key of credential Secrets holding the credential type.
+func (c *KongClient) SetCredentialTypeKey(key string) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.credentialTypeKey = key
+}
+
+// getCredentialTypeKey returns the key set with SetCredentialTypeKey.
+func (c *KongClient) getCredentialTypeKey() string {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.credentialTypeKey
+}
+
+// SetInstanceTag sets the tag added to all the entities generated by this controller
+// instance, so that instances sharing the same Kong can tell their entities apart.
+// An empty tag adds no tag.
+func (c *KongClient) SetInstanceTag(tag string) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.instanceTag = tag
+}
+
+// getInstanceTag returns the tag set with SetInstanceTag.
+func (c *KongClient) getInstanceTag() string {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.instanceTag
+}
+
+// SetCertificateSNIValidation sets whether the SNIs of certificates are checked against
+// the names of the certificates, and whether mismatching SNIs are dropped (strict mode)
+// or only logged.
+func (c *KongClient) SetCertificateSNIValidation(enabled, strict bool) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.validateCertificateSNIs = enabled
+	c.strictCertificateSNIs = strict
+}
+
+// getCertificateSNIValidation returns the settings set with SetCertificateSNIValidation.
+func (c *KongClient) getCertificateSNIValidation() (enabled, strict bool) {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.validateCertificateSNIs, c.strictCertificateSNIs
+}
+
+// SetCertificateExpiryWarningThreshold sets the remaining validity below which a warning
+// is logged for certificates.
+func (c *KongClient) SetCertificateExpiryWarningThreshold(threshold time.Duration) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.certificateExpiryWarningThreshold = threshold
+}
+
+// getCertificateExpiryWarningThreshold returns the threshold set with SetCertificateExpiryWarningThreshold.
+func (c *KongClient) getCertificateExpiryWarningThreshold() time.Duration {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.certificateExpiryWarningThreshold
+}
+
+// EnablePluginNamespaceIsolation makes the client only attach KongPlugins to objects
+// from their own namespace.
+func (c *KongClient) EnablePluginNamespaceIsolation() {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.pluginNamespaceIsolation = true
+}
+
+// isPluginNamespaceIsolationEnabled returns whether EnablePluginNamespaceIsolation was called.
+func (c *KongClient) isPluginNamespaceIsolationEnabled() bool {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.pluginNamespaceIsolation
+}
+
+// SetPluginOverlapDetection sets whether plugins attached to both a route and its service are
+// logged, and whether such overlaps fail configuration updates (strict mode).
+func (c *KongClient) SetPluginOverlapDetection(enabled, strict bool) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.detectPluginOverlaps = enabled
+	c.strictPluginOverlaps = strict
+}
+
+// getPluginOverlapDetection returns the settings set with SetPluginOverlapDetection.
+func (c *KongClient) getPluginOverlapDetection() (enabled, strict bool) {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.detectPluginOverlaps, c.strictPluginOverlaps
+}
+
+// SetPluginConfigSizeLimit sets the size in bytes above which the serialized configuration of
+// plugins is logged, 0 disabling the check, and whether such plugins are dropped (strict mode).
+func (c *KongClient) SetPluginConfigSizeLimit(maxSize int, strict bool) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.maxPluginConfigSize = maxSize
+	c.strictPluginConfigSize = strict
+}
+
+// getPluginConfigSizeLimit returns the settings set with SetPluginConfigSizeLimit.
+func (c *KongClient) getPluginConfigSizeLimit() (maxSize int, strict bool) {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.maxPluginConfigSize, c.strictPluginConfigSize
+}
+
+// EnableDeterministicPluginIDs makes the client derive the IDs of plugins from their
+// name, attachments and configuration.
+func (c *KongClient) EnableDeterministicPluginIDs() {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.deterministicPluginIDs = true
+}
+
+// areDeterministicPluginIDsEnabled returns whether EnableDeterministicPluginIDs was called.
+func (c *KongClient) areDeterministicPluginIDsEnabled() bool {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.deterministicPluginIDs
+}
+
+// stateTransformer is a parser.StateTransformer along with the name it was registered with.
+type stateTransformer struct {
+	name      string
+	transform parser.StateTransformer
+}
+
+// RegisterStateTransformer adds a transformer applied to the KongState built on every update,
+// once plugins are built. Transformers run in registration order; an error returned by any of
+// them aborts the update.
+func (c *KongClient) RegisterStateTransformer(name string, transformer parser.StateTransformer) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.stateTransformers = append(c.stateTransformers, stateTransformer{name: name, transform: transformer})
+}
+
+// getStateTransformers returns the transformers registered with RegisterStateTransformer.
+func (c *KongClient) getStateTransformers() []stateTransformer {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return append([]stateTransformer(nil), c.stateTransformers...)
+}
+
+// EnableEventRecording makes the client emit Kubernetes events on objects
+// which could not be translated into data-plane configuration.
+func (c *KongClient) EnableEventRecording(recorder record.EventRecorder) {
+	c.additionalFeaturesLock.Lock()
+	defer c.additionalFeaturesLock.Unlock()
+	c.eventRecorder = recorder
+}
+
+// getEventRecorder returns the event recorder configured with EnableEventRecording, if any.
+func (c *KongClient) getEventRecorder() record.EventRecorder {
+	c.additionalFeaturesLock.RLock()
+	defer c.additionalFeaturesLock.RUnlock()
+	return c.eventRecorder
+}
+
 // -----------------------------------------------------------------------------
 // Dataplane Client - Kong - Interface Implementation
 // -----------------------------------------------------------------------------
@@ -302,6 +709,67 @@ func (c *KongClient) Update(ctx context.Context) error {
 	if c.AreCombinedServiceRoutesEnabled() {
 		p.EnableCombinedServiceRoutes()
 	}
+	if c.kongConfig.CredentialSchemaStore != nil {
+		p.EnableCredentialSchemas(c.kongConfig.CredentialSchemaStore)
+	}
+	if recorder := c.getEventRecorder(); recorder != nil {
+		p.EnableEventRecording(recorder)
+	}
+	p.EnableCredentialMetrics(c.prometheusMetrics)
+	if concurrency := c.getOverridesConcurrency(); concurrency > 1 {
+		p.EnableParallelOverrides(concurrency)
+	}
+	if policy := c.getStoreRetryPolicy(); policy.Enabled() {
+		p.EnableStoreRetries(policy)
+	}
+	if filter := c.getConsumerNamespaceFilter(); filter != nil {
+		p.EnableConsumerNamespaceFilter(filter)
+	}
+	if selector := c.getConsumerSelector(); selector != nil {
+		p.EnableConsumerSelector(selector)
+	}
+	p.SetCredentialTypeKey(c.getCredentialTypeKey())
+	p.SetInstanceTag(c.getInstanceTag())
+	if c.isConflictingCredentialDroppingEnabled() {
+		p.EnableConflictingCredentialDropping()
+	}
+	if c.isStrictConsumerIdentifiersEnabled() {
+		p.EnableStrictConsumerIdentifiers()
+	}
+	if credTypes := c.getAllowedCredentialTypes(); len(credTypes) > 0 {
+		p.SetAllowedCredentialTypes(credTypes)
+	}
+	if c.isCredentialSecretIndexingEnabled() {
+		p.EnableCredentialSecretIndexing()
+	}
+	if enabled, strict := c.getCertificateSNIValidation(); enabled {
+		p.EnableCertificateSNIValidation(strict)
+	}
+	p.EnableCertificateMetrics(c.prometheusMetrics)
+	p.SetCertificateExpiryWarningThreshold(c.getCertificateExpiryWarningThreshold())
+	if c.isPluginNamespaceIsolationEnabled() {
+		p.EnablePluginNamespaceIsolation()
+	}
+	if enabled, strict := c.getPluginOverlapDetection(); enabled {
+		p.EnablePluginOverlapDetection(strict)
+	}
+	if maxSize, strict := c.getPluginConfigSizeLimit(); maxSize > 0 {
+		p.EnablePluginConfigSizeCheck(maxSize, strict)
+	}
+	if c.areDeterministicPluginIDsEnabled() {
+		p.EnableDeterministicPluginIDs()
+	}
+	if selector := c.getGlobalPluginSelector(); selector != nil {
+		p.EnableGlobalPluginSelector(selector)
+	}
+	for _, t := range c.getStateTransformers() {
+		p.RegisterStateTransformer(t.name, t.transform)
+	}
+	p.EnableWarningDeduplication(c.warned)
+	p.SetKongVersion(c.kongConfig.Version)
+	if c.IsPluginSchemaValidationEnabled() && c.kongConfig.PluginSchemaStore != nil {
+		p.EnablePluginSchemaValidation(c.kongConfig.PluginSchemaStore)
+	}
 
 	// parse the Kubernetes objects from the storer into Kong configuration
 	kongstate, err := p.Build()
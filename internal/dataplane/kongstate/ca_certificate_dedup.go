@@ -0,0 +1,132 @@
+package kongstate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"reflect"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+)
+
+// DedupCACertificates keeps a single CA certificate in the state for every certificate held by
+// several of them, e.g. when the same CA is stored in Secrets of different namespaces. The first
+// CA certificate is kept, and the references to the others held by services, mtls-auth credentials
+// and plugin configurations are replaced by references to it. Duplicates whose metadata differs
+// from the kept CA certificate are logged as warnings.
+// It must be called once the entities referencing CA certificates are filled.
+func (ks *KongState) DedupCACertificates(log logrus.FieldLogger) {
+	kept := make(map[string]int, len(ks.CACertificates))
+	replacements := make(map[string]string)
+	caCerts := ks.CACertificates[:0]
+	for _, caCert := range ks.CACertificates {
+		fingerprint := caCertificateFingerprint(caCert)
+		i, ok := kept[fingerprint]
+		if !ok {
+			kept[fingerprint] = len(caCerts)
+			caCerts = append(caCerts, caCert)
+			continue
+		}
+		log := log.WithFields(logrus.Fields{
+			"ca_certificate_id":      stringValue(caCert.ID),
+			"kept_ca_certificate_id": stringValue(caCerts[i].ID),
+		})
+		if !reflect.DeepEqual(caCert.Tags, caCerts[i].Tags) || !reflect.DeepEqual(caCert.CertDigest, caCerts[i].CertDigest) {
+			log.Warn("CA certificate duplicates another one with different metadata, only the first one is kept")
+		} else {
+			log.Debug("dropping duplicate CA certificate")
+		}
+		replacements[stringValue(caCert.ID)] = stringValue(caCerts[i].ID)
+	}
+	ks.CACertificates = caCerts
+	if len(replacements) == 0 {
+		return
+	}
+
+	for i := range ks.Services {
+		ks.Services[i].CACertificates = replaceCACertificateIDs(ks.Services[i].CACertificates, replacements)
+	}
+	for _, c := range ks.Consumers {
+		for _, cred := range c.MTLSAuths {
+			if cred.CACertificate == nil || cred.CACertificate.ID == nil {
+				continue
+			}
+			if id, ok := replacements[*cred.CACertificate.ID]; ok {
+				cred.CACertificate = &kong.CACertificate{ID: kong.String(id)}
+			}
+		}
+	}
+	for i := range ks.Plugins {
+		ids, ok := ks.Plugins[i].Config["ca_certificates"].([]interface{})
+		if !ok || !referencesAny(ids, replacements) {
+			continue
+		}
+		// plugins may be reused by the next fill, so their configuration is replaced rather than modified
+		config := ks.Plugins[i].Config.DeepCopy()
+		newIDs := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			if s, ok := id.(string); ok {
+				if replacement, ok := replacements[s]; ok {
+					id = replacement
+				}
+			}
+			newIDs = append(newIDs, id)
+		}
+		config["ca_certificates"] = newIDs
+		ks.Plugins[i].Config = config
+	}
+}
+
+// caCertificateFingerprint returns the SHA-256 fingerprint of the DER encoding of a CA
+// certificate, or of its PEM encoding if it can't be decoded.
+func caCertificateFingerprint(caCert kong.CACertificate) string {
+	cert := []byte(stringValue(caCert.Cert))
+	if block, _ := pem.Decode(cert); block != nil {
+		cert = block.Bytes
+	}
+	sum := sha256.Sum256(cert)
+	return hex.EncodeToString(sum[:])
+}
+
+// replaceCACertificateIDs returns ids with the CA certificate IDs found in replacements replaced.
+// Duplicates resulting from the replacements are removed.
+func replaceCACertificateIDs(ids []*string, replacements map[string]string) []*string {
+	if ids == nil {
+		return nil
+	}
+	res := make([]*string, 0, len(ids))
+	for _, id := range ids {
+		if id != nil {
+			if replacement, ok := replacements[*id]; ok {
+				id = kong.String(replacement)
+			}
+			if containsStringPointer(res, *id) {
+				continue
+			}
+		}
+		res = append(res, id)
+	}
+	return res
+}
+
+func containsStringPointer(list []*string, s string) bool {
+	for _, v := range list {
+		if v != nil && *v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// referencesAny reports whether one of the ids is a key of replacements.
+func referencesAny(ids []interface{}, replacements map[string]string) bool {
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			if _, ok := replacements[s]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package kongstate
+
+import (
+	"bytes"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKongState_DedupCACertificates(t *testing.T) {
+	caPEM := func(der string) string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte(der)}))
+	}
+	newState := func(duplicateTags []*string) KongState {
+		return KongState{
+			CACertificates: []kong.CACertificate{
+				{ID: kong.String("ca-a"), Cert: kong.String(caPEM("first CA"))},
+				// the same certificate, encoded differently
+				{ID: kong.String("ca-b"), Cert: kong.String(strings.TrimSpace(caPEM("first CA"))), Tags: duplicateTags},
+				{ID: kong.String("ca-c"), Cert: kong.String(caPEM("second CA"))},
+			},
+			Services: []Service{{
+				Service: kong.Service{
+					Name:           kong.String("foo"),
+					CACertificates: kong.StringSlice("ca-a", "ca-b"),
+				},
+			}},
+			Consumers: []Consumer{{
+				Consumer: kong.Consumer{Username: kong.String("foo")},
+				MTLSAuths: []*MTLSAuth{{MTLSAuth: kong.MTLSAuth{
+					SubjectName:   kong.String("foo@example.com"),
+					CACertificate: &kong.CACertificate{ID: kong.String("ca-b")},
+				}}},
+			}},
+			Plugins: []Plugin{{Plugin: kong.Plugin{
+				Name:   kong.String("mtls-auth"),
+				Config: kong.Configuration{"ca_certificates": []interface{}{"ca-b", "ca-c"}},
+			}}},
+		}
+	}
+
+	t.Run("references to duplicates point to the kept CA certificate", func(t *testing.T) {
+		var logs bytes.Buffer
+		log := logrus.New()
+		log.SetOutput(&logs)
+
+		state := newState(nil)
+		pluginConfig := state.Plugins[0].Config
+		state.DedupCACertificates(log)
+
+		require.Len(t, state.CACertificates, 2)
+		assert.Equal(t, "ca-a", *state.CACertificates[0].ID)
+		assert.Equal(t, "ca-c", *state.CACertificates[1].ID)
+		assert.Equal(t, kong.StringSlice("ca-a"), state.Services[0].CACertificates)
+		assert.Equal(t, "ca-a", *state.Consumers[0].MTLSAuths[0].CACertificate.ID)
+		assert.Equal(t, []interface{}{"ca-a", "ca-c"}, state.Plugins[0].Config["ca_certificates"])
+		assert.Equal(t, []interface{}{"ca-b", "ca-c"}, pluginConfig["ca_certificates"],
+			"the configuration of plugins should be replaced rather than modified")
+		assert.NotContains(t, logs.String(), "level=warning")
+	})
+
+	t.Run("duplicates with different metadata are logged", func(t *testing.T) {
+		var logs bytes.Buffer
+		log := logrus.New()
+		log.SetOutput(&logs)
+
+		state := newState(kong.StringSlice("team-b"))
+		state.DedupCACertificates(log)
+
+		require.Len(t, state.CACertificates, 2)
+		assert.Contains(t, logs.String(), "CA certificate duplicates another one with different metadata")
+		assert.Contains(t, logs.String(), "ca_certificate_id=ca-b")
+		assert.Contains(t, logs.String(), "kept_ca_certificate_id=ca-a")
+	})
+
+	t.Run("distinct CA certificates are kept", func(t *testing.T) {
+		state := newState(nil)
+		state.CACertificates = state.CACertificates[:1]
+		state.CACertificates = append(state.CACertificates, kong.CACertificate{
+			ID: kong.String("ca-c"), Cert: kong.String(caPEM("second CA")),
+		})
+		state.DedupCACertificates(logrus.New())
+		assert.Len(t, state.CACertificates, 2)
+		assert.Equal(t, kong.StringSlice("ca-a", "ca-b"), state.Services[0].CACertificates)
+	})
+}
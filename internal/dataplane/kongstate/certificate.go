@@ -0,0 +1,382 @@
+package kongstate
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+)
+
+// tlsSecret is a TLS Secret along with the SNIs its certificate was requested for.
+type tlsSecret struct {
+	secret      *corev1.Secret
+	cert, key   string
+	fingerprint string
+	snis        []string
+}
+
+// FillCertificates builds the certificates of TLS Secrets. Each secretsToSNIs map holds the SNIs
+// requested for TLS Secrets keyed by "namespace/name"; maps are given in order of precedence.
+// Secrets holding the same certificate are collapsed into a single certificate serving the SNIs
+// of all of them, which takes the ID of the oldest Secret. When an SNI is requested for different
+// certificates, a warning is logged and the SNI is served by the certificate from the map with the
+// highest precedence, or, within a map, by the certificate of the oldest Secret. SNIs listed in
+// the konghq.com/snis annotation of a Secret are requested along with the ones of the map.
+// Certificates are sorted by ID and their SNIs lexicographically, for a deterministic state.
+func (ks *KongState) FillCertificates(log logrus.FieldLogger, s store.Storer, secretsToSNIs ...map[string][]string) {
+	certs := make(map[string]*Certificate)
+	certAges := make(map[string]*corev1.Secret)
+	var fingerprints []string
+	snisSeen := make(map[string]string)
+	for _, secretToSNIs := range secretsToSNIs {
+		for _, ts := range getTLSSecrets(log, s, secretToSNIs) {
+			cert, ok := certs[ts.fingerprint]
+			if !ok {
+				cert = &Certificate{
+					Certificate: kong.Certificate{
+						ID:   kong.String(string(ts.secret.UID)),
+						Cert: kong.String(ts.cert),
+						Key:  kong.String(ts.key),
+					},
+				}
+				certs[ts.fingerprint] = cert
+				certAges[ts.fingerprint] = ts.secret
+				fingerprints = append(fingerprints, ts.fingerprint)
+			} else if secretOlder(ts.secret, certAges[ts.fingerprint]) {
+				// the ID of the oldest Secret is used to avoid pointless configuration updates,
+				// along with its certificate and key so that they match the ID
+				cert.ID = kong.String(string(ts.secret.UID))
+				cert.Cert = kong.String(ts.cert)
+				cert.Key = kong.String(ts.key)
+				certAges[ts.fingerprint] = ts.secret
+			}
+
+			for _, sni := range ts.snis {
+				served, ok := snisSeen[sni]
+				if !ok {
+					snisSeen[sni] = ts.fingerprint
+					cert.SNIs = append(cert.SNIs, kong.String(sni))
+					continue
+				}
+				if served != ts.fingerprint {
+					log.WithFields(logrus.Fields{
+						"served_secret_cert":    *certs[served].ID,
+						"requested_secret_cert": string(ts.secret.UID),
+						"secret_name":           ts.secret.Name,
+						"secret_namespace":      ts.secret.Namespace,
+						"sni":                   sni,
+					}).Warn("same SNI requested for multiple certs, can only serve one cert")
+				}
+			}
+		}
+	}
+
+	ks.Certificates = nil
+	for _, fingerprint := range fingerprints {
+		cert := certs[fingerprint]
+		sort.SliceStable(cert.SNIs, func(i, j int) bool {
+			return *cert.SNIs[i] < *cert.SNIs[j]
+		})
+		ks.Certificates = append(ks.Certificates, *cert)
+	}
+	// certificates are sorted by ID so that their order doesn't depend on the requesting resources
+	sort.SliceStable(ks.Certificates, func(i, j int) bool {
+		return *ks.Certificates[i].ID < *ks.Certificates[j].ID
+	})
+}
+
+// getTLSSecrets fetches the TLS Secrets referenced by the keys of secretToSNIs. Secrets are
+// returned from the oldest to the newest, Secrets of the same age being sorted by namespace
+// and name. Secrets which can't be fetched or don't hold a valid key pair are logged and skipped.
+// The SNIs of the konghq.com/snis annotation of a Secret are merged into the ones of secretToSNIs.
+func getTLSSecrets(log logrus.FieldLogger, s store.Storer, secretToSNIs map[string][]string) []tlsSecret {
+	var res []tlsSecret
+	for secretKey, snis := range secretToSNIs {
+		namespaceName := strings.SplitN(secretKey, "/", 2)
+		if len(namespaceName) != 2 {
+			log.WithField("secret", secretKey).Error("invalid secret reference, expected namespace/name")
+			continue
+		}
+		log := log.WithFields(logrus.Fields{
+			"secret_name":      namespaceName[1],
+			"secret_namespace": namespaceName[0],
+		})
+		secret, err := s.GetSecret(namespaceName[0], namespaceName[1])
+		if err != nil {
+			log.WithError(err).Error("failed to fetch secret")
+			continue
+		}
+		cert, key, fingerprint, err := certificateFromSecret(secret)
+		if err != nil {
+			log.WithError(err).Error("failed to construct certificate from secret")
+			continue
+		}
+		res = append(res, tlsSecret{
+			secret:      secret,
+			cert:        cert,
+			key:         key,
+			fingerprint: fingerprint,
+			snis:        mergeSNIs(snis, secret.Annotations),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return secretOlder(res[i].secret, res[j].secret)
+	})
+	return res
+}
+
+// mergeSNIs appends the SNIs of the konghq.com/snis annotation to snis, skipping empty
+// and duplicate ones.
+func mergeSNIs(snis []string, anns map[string]string) []string {
+	extra, _ := annotations.ExtractSNIs(anns)
+	if len(extra) == 0 {
+		return snis
+	}
+	seen := make(map[string]struct{}, len(snis)+len(extra))
+	merged := make([]string, 0, len(snis)+len(extra))
+	for _, sni := range append(append([]string{}, snis...), extra...) {
+		sni = strings.TrimSpace(sni)
+		if _, ok := seen[sni]; ok || sni == "" {
+			continue
+		}
+		seen[sni] = struct{}{}
+		merged = append(merged, sni)
+	}
+	return merged
+}
+
+// secretOlder reports whether Secret a was created before Secret b. Secrets created at
+// the same time are ordered by namespace and name.
+func secretOlder(a, b *corev1.Secret) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// certificateFromSecret extracts the PEM encoded certificate and key of a TLS Secret, along with
+// the SHA-256 fingerprint of its certificate chain.
+func certificateFromSecret(secret *corev1.Secret) (cert, key, fingerprint string, err error) {
+	certData, okcert := secret.Data[corev1.TLSCertKey]
+	keyData, okkey := secret.Data[corev1.TLSPrivateKeyKey]
+	if !okcert || !okkey {
+		return "", "", "", fmt.Errorf("no keypair could be found in"+
+			" secret '%v/%v'", secret.Namespace, secret.Name)
+	}
+
+	cert = strings.TrimSpace(string(certData))
+	key = strings.TrimSpace(string(keyData))
+
+	keyPair, err := tls.X509KeyPair([]byte(cert), []byte(key))
+	if err != nil {
+		return "", "", "", fmt.Errorf("parsing TLS key-pair in secret '%v/%v': %w",
+			secret.Namespace, secret.Name, err)
+	}
+	// the whole chain is hashed, so that Secrets holding the same leaf certificate with different
+	// intermediate certificates are kept apart
+	chain := sha256.New()
+	for _, der := range keyPair.Certificate {
+		chain.Write(der)
+	}
+	return cert, key, fmt.Sprintf("%x", chain.Sum(nil)), nil
+}
+
+// ValidateCertificateSNIs checks that the SNIs of every certificate are covered by the DNS names
+// of the certificate, or by its common name if it has no DNS names, and logs a warning for every
+// SNI which isn't. In strict mode, such SNIs are also removed from the certificates, so that Kong
+// doesn't serve mismatching certificates for them.
+func (ks *KongState) ValidateCertificateSNIs(log logrus.FieldLogger, strict bool) {
+	for i := range ks.Certificates {
+		cert := &ks.Certificates[i]
+		log := log.WithField("certificate_id", stringValue(cert.ID))
+		names, err := certificateDNSNames(stringValue(cert.Cert))
+		if err != nil {
+			log.WithError(err).Warn("failed to parse certificate, its SNIs can't be validated")
+			continue
+		}
+		var snis []*string
+		for _, sni := range cert.SNIs {
+			if sni == nil || *sni == "*" || hostnameCoveredByAny(*sni, names) {
+				snis = append(snis, sni)
+				continue
+			}
+			log.WithFields(logrus.Fields{
+				"sni":               *sni,
+				"certificate_names": names,
+			}).Warn("SNI is not covered by the names of its certificate")
+			if !strict {
+				snis = append(snis, sni)
+			}
+		}
+		cert.SNIs = snis
+	}
+}
+
+// DefaultCertificateExpiryWarningThreshold is the default remaining validity below which
+// CheckCertificateExpiry warns about certificates.
+const DefaultCertificateExpiryWarningThreshold = 30 * 24 * time.Hour
+
+// Certificate expiry statuses recorded in CertificateMetrics.
+const (
+	// CertificateExpired is recorded for certificates past their NotAfter date.
+	CertificateExpired = "Expired"
+	// CertificateExpiringSoon is recorded for certificates expiring within the warning threshold.
+	CertificateExpiringSoon = "ExpiringSoon"
+	// CertificateUnparsable is recorded for certificates whose PEM can't be parsed.
+	CertificateUnparsable = "Unparsable"
+)
+
+// CertificateMetrics records the certificates which are expired, about to expire or can't be parsed.
+type CertificateMetrics interface {
+	RecordCertificateExpiry(status string)
+}
+
+// CheckCertificateExpiry logs a warning for every certificate which is already expired or expires
+// within threshold of now, and for every certificate which can't be parsed. Each of them is also
+// recorded in metrics, if not nil, with its status.
+func (ks *KongState) CheckCertificateExpiry(
+	log logrus.FieldLogger,
+	metrics CertificateMetrics,
+	threshold time.Duration,
+	now time.Time,
+) {
+	record := func(status string) {
+		if metrics != nil {
+			metrics.RecordCertificateExpiry(status)
+		}
+	}
+	for _, cert := range ks.Certificates {
+		log := log.WithField("certificate_id", stringValue(cert.ID))
+		x509Cert, err := parseCertificatePEM(stringValue(cert.Cert))
+		if err != nil {
+			log.WithError(err).Warn("failed to parse certificate, its expiry can't be checked")
+			record(CertificateUnparsable)
+			continue
+		}
+		log = log.WithField("not_after", x509Cert.NotAfter.UTC().Format(time.RFC3339))
+		remaining := x509Cert.NotAfter.Sub(now)
+		switch {
+		case remaining <= 0:
+			log.Warn("certificate has expired")
+			record(CertificateExpired)
+		case remaining <= threshold:
+			log.WithField("expires_in", remaining.Round(time.Second).String()).Warn("certificate expires soon")
+			record(CertificateExpiringSoon)
+		}
+	}
+}
+
+// parseCertificatePEM parses the first certificate of a PEM bundle.
+func parseCertificatePEM(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// certificateNames holds the names parsed from a PEM encoded certificate, or the error
+// encountered while parsing it.
+type certificateNames struct {
+	cert  string
+	names []string
+	err   error
+}
+
+// Names returns the names the certificate covers, i.e. its subject alternative names (DNS names,
+// IP addresses, email addresses and URIs, in this order) followed by its common name, unless it's
+// one of them already. Unlike SNIs, which are the names the certificate is served for, they're read
+// from the certificate itself. The result is cached until Cert changes; Names isn't safe for
+// concurrent use.
+func (c *Certificate) Names() ([]string, error) {
+	certPEM := stringValue(c.Cert)
+	if c.names != nil && c.names.cert == certPEM {
+		return c.names.names, c.names.err
+	}
+	names, err := parseCertificateNames(certPEM)
+	c.names = &certificateNames{cert: certPEM, names: names, err: err}
+	return names, err
+}
+
+// parseCertificateNames returns the subject alternative names and the common name of the first
+// certificate of a PEM bundle, without duplicates.
+func parseCertificateNames(certPEM string) ([]string, error) {
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	seen := make(map[string]struct{})
+	add := func(name string) {
+		if _, ok := seen[name]; ok || name == "" {
+			return
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	for _, name := range cert.DNSNames {
+		add(name)
+	}
+	for _, ip := range cert.IPAddresses {
+		add(ip.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		add(email)
+	}
+	for _, uri := range cert.URIs {
+		add(uri.String())
+	}
+	add(cert.Subject.CommonName)
+	return names, nil
+}
+
+// certificateDNSNames returns the DNS names of the first certificate of a PEM bundle,
+// falling back to the common name for certificates without DNS names.
+func certificateDNSNames(certPEM string) ([]string, error) {
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames, nil
+	}
+	if cert.Subject.CommonName != "" {
+		return []string{cert.Subject.CommonName}, nil
+	}
+	return nil, nil
+}
+
+// hostnameCoveredByAny reports whether hostname is covered by any of the certificate names.
+// Wildcard names cover a single label, e.g. *.example.com covers foo.example.com but neither
+// example.com nor foo.bar.example.com. Wildcard hostnames are only covered by identical names.
+func hostnameCoveredByAny(hostname string, names []string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if hostname == name {
+			return true
+		}
+		if !strings.HasPrefix(name, "*.") {
+			continue
+		}
+		label, rest, found := strings.Cut(hostname, ".")
+		if found && label != "" && label != "*" && rest == strings.TrimPrefix(name, "*.") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,417 @@
+package kongstate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+)
+
+// selfSignedKeyPair returns a PEM encoded self-signed certificate and its key.
+func selfSignedKeyPair(t *testing.T, commonName string, dnsNames ...string) (cert, key []byte) {
+	return selfSignedKeyPairValidUntil(t, time.Now().Add(time.Hour), commonName, dnsNames...)
+}
+
+func selfSignedKeyPairValidUntil(t *testing.T, notAfter time.Time, commonName string, dnsNames ...string) (cert, key []byte) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func tlsSecretWithKeyPair(namespace, name string, created time.Time, cert, key []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			UID:               types.UID(namespace + "-" + name),
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       cert,
+			corev1.TLSPrivateKeyKey: key,
+		},
+	}
+}
+
+func TestKongState_FillCertificates_Deduplication(t *testing.T) {
+	cert, key := selfSignedKeyPair(t, "example.com")
+	now := time.Now()
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			tlsSecretWithKeyPair("foo", "tls", now, cert, key),
+			tlsSecretWithKeyPair("bar", "tls", now.Add(-time.Hour), cert, key),
+		},
+	})
+	require.NoError(t, err)
+
+	// two Ingresses in different namespaces referencing Secrets holding the same certificate
+	var state KongState
+	state.FillCertificates(logrus.New(), s, map[string][]string{
+		"foo/tls": {"foo.example.com"},
+		"bar/tls": {"bar.example.com", "foo.example.com"},
+	})
+
+	require.Len(t, state.Certificates, 1)
+	assert.Equal(t, kong.Certificate{
+		ID:   kong.String("bar-tls"),
+		Cert: kong.String(string(bytes.TrimSpace(cert))),
+		Key:  kong.String(string(bytes.TrimSpace(key))),
+		SNIs: kong.StringSlice("bar.example.com", "foo.example.com"),
+	}, state.Certificates[0].Certificate)
+}
+
+func TestKongState_FillCertificates_DeduplicationOfChains(t *testing.T) {
+	cert, key := selfSignedKeyPair(t, "example.com")
+	intermediate, _ := selfSignedKeyPair(t, "Intermediate CA")
+	now := time.Now()
+
+	t.Run("same leaf certificate with different chains", func(t *testing.T) {
+		s, err := store.NewFakeStore(store.FakeObjects{
+			Secrets: []*corev1.Secret{
+				tlsSecretWithKeyPair("foo", "tls", now, cert, key),
+				tlsSecretWithKeyPair("bar", "tls", now.Add(-time.Hour), append(append([]byte{}, cert...), intermediate...), key),
+			},
+		})
+		require.NoError(t, err)
+
+		var state KongState
+		state.FillCertificates(logrus.New(), s, map[string][]string{
+			"foo/tls": {"foo.example.com"},
+			"bar/tls": {"bar.example.com"},
+		})
+		assert.Len(t, state.Certificates, 2)
+	})
+
+	t.Run("older Secret from a map of lower precedence", func(t *testing.T) {
+		// the same chain, encoded differently
+		olderCert := append([]byte("subject=example.com\n"), cert...)
+		s, err := store.NewFakeStore(store.FakeObjects{
+			Secrets: []*corev1.Secret{
+				tlsSecretWithKeyPair("foo", "tls", now, cert, key),
+				tlsSecretWithKeyPair("bar", "tls", now.Add(-time.Hour), olderCert, key),
+			},
+		})
+		require.NoError(t, err)
+
+		var state KongState
+		state.FillCertificates(logrus.New(), s,
+			map[string][]string{"foo/tls": {"foo.example.com"}},
+			map[string][]string{"bar/tls": {"bar.example.com"}},
+		)
+		require.Len(t, state.Certificates, 1)
+		assert.Equal(t, "bar-tls", *state.Certificates[0].ID)
+		assert.Equal(t, string(bytes.TrimSpace(olderCert)), *state.Certificates[0].Cert,
+			"the certificate should be the one of the Secret whose ID is used")
+		assert.Equal(t, string(bytes.TrimSpace(key)), *state.Certificates[0].Key)
+	})
+}
+
+func TestKongState_FillCertificates_SNIConflict(t *testing.T) {
+	oldCert, oldKey := selfSignedKeyPair(t, "old.example.com")
+	newCert, newKey := selfSignedKeyPair(t, "new.example.com")
+	now := time.Now()
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			tlsSecretWithKeyPair("default", "old", now.Add(-time.Hour), oldCert, oldKey),
+			tlsSecretWithKeyPair("default", "new", now, newCert, newKey),
+		},
+	})
+	require.NoError(t, err)
+
+	servedSNIs := func(state KongState) map[string][]string {
+		res := make(map[string][]string)
+		for _, cert := range state.Certificates {
+			var snis []string
+			for _, sni := range cert.SNIs {
+				snis = append(snis, *sni)
+			}
+			res[*cert.ID] = snis
+		}
+		return res
+	}
+
+	t.Run("the oldest Secret serves the SNI", func(t *testing.T) {
+		var logs bytes.Buffer
+		log := logrus.New()
+		log.SetOutput(&logs)
+
+		for i := 0; i < 10; i++ {
+			var state KongState
+			state.FillCertificates(log, s, map[string][]string{
+				"default/new": {"example.com", "new.example.com"},
+				"default/old": {"example.com"},
+			})
+			assert.Equal(t, map[string][]string{
+				"default-old": {"example.com"},
+				"default-new": {"new.example.com"},
+			}, servedSNIs(state))
+		}
+		assert.Contains(t, logs.String(), "same SNI requested for multiple certs")
+		assert.Contains(t, logs.String(), "level=warning")
+	})
+
+	t.Run("Secrets with a higher precedence serve the SNI", func(t *testing.T) {
+		var state KongState
+		state.FillCertificates(logrus.New(), s,
+			map[string][]string{"default/new": {"example.com"}},
+			map[string][]string{"default/old": {"example.com"}},
+		)
+		assert.Equal(t, map[string][]string{
+			"default-new": {"example.com"},
+			"default-old": nil,
+		}, servedSNIs(state))
+	})
+}
+
+func TestKongState_FillCertificates_AnnotationSNIs(t *testing.T) {
+	cert, key := selfSignedKeyPair(t, "example.com")
+	secret := tlsSecretWithKeyPair("default", "tls", time.Now(), cert, key)
+	secret.Annotations = map[string]string{
+		"konghq.com/snis": "b.example.com, c.example.com,,a.example.com",
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{Secrets: []*corev1.Secret{secret}})
+	require.NoError(t, err)
+
+	var state KongState
+	state.FillCertificates(logrus.New(), s, map[string][]string{
+		"default/tls": {"a.example.com", "d.example.com"},
+	})
+	require.Len(t, state.Certificates, 1)
+	assert.Equal(t,
+		kong.StringSlice("a.example.com", "b.example.com", "c.example.com", "d.example.com"),
+		state.Certificates[0].SNIs,
+	)
+}
+
+func TestKongState_FillCertificates_Ordering(t *testing.T) {
+	fooCert, fooKey := selfSignedKeyPair(t, "foo.example.com")
+	barCert, barKey := selfSignedKeyPair(t, "bar.example.com")
+	bazCert, bazKey := selfSignedKeyPair(t, "baz.example.com")
+	now := time.Now()
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			tlsSecretWithKeyPair("default", "foo", now.Add(-2*time.Hour), fooCert, fooKey),
+			tlsSecretWithKeyPair("default", "bar", now, barCert, barKey),
+			tlsSecretWithKeyPair("default", "baz", now.Add(-time.Hour), bazCert, bazKey),
+		},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		var state KongState
+		state.FillCertificates(logrus.New(), s,
+			map[string][]string{
+				"default/foo": {"z.foo.example.com", "a.foo.example.com"},
+				"default/bar": {"bar.example.com"},
+			},
+			map[string][]string{
+				"default/baz": {"y.baz.example.com", "b.baz.example.com", "m.baz.example.com"},
+			},
+		)
+		var ids []string
+		for _, cert := range state.Certificates {
+			ids = append(ids, *cert.ID)
+		}
+		assert.Equal(t, []string{"default-bar", "default-baz", "default-foo"}, ids)
+		assert.Equal(t, kong.StringSlice("b.baz.example.com", "m.baz.example.com", "y.baz.example.com"),
+			state.Certificates[1].SNIs)
+		assert.Equal(t, kong.StringSlice("a.foo.example.com", "z.foo.example.com"), state.Certificates[2].SNIs)
+	}
+}
+
+func TestKongState_ValidateCertificateSNIs(t *testing.T) {
+	wildcardCert, _ := selfSignedKeyPair(t, "example", "*.example.com")
+	cnCert, _ := selfSignedKeyPair(t, "foo.example.org")
+
+	for _, tt := range []struct {
+		name        string
+		cert        []byte
+		snis        []string
+		strict      bool
+		wantSNIs    []*string
+		wantWarning bool
+	}{
+		{
+			name:     "wildcard name covers single label subdomains",
+			cert:     wildcardCert,
+			snis:     []string{"foo.example.com", "BAR.example.com", "*.example.com", "*"},
+			wantSNIs: kong.StringSlice("foo.example.com", "BAR.example.com", "*.example.com", "*"),
+		},
+		{
+			name:        "mismatching SNIs are kept and logged",
+			cert:        wildcardCert,
+			snis:        []string{"foo.example.com", "example.com", "foo.bar.example.com", "foo.example.org"},
+			wantSNIs:    kong.StringSlice("foo.example.com", "example.com", "foo.bar.example.com", "foo.example.org"),
+			wantWarning: true,
+		},
+		{
+			name:        "mismatching SNIs are dropped in strict mode",
+			cert:        wildcardCert,
+			snis:        []string{"foo.example.com", "example.com", "foo.bar.example.com"},
+			strict:      true,
+			wantSNIs:    kong.StringSlice("foo.example.com"),
+			wantWarning: true,
+		},
+		{
+			name:        "common name is used for certificates without DNS names",
+			cert:        cnCert,
+			snis:        []string{"foo.example.org", "bar.example.org"},
+			strict:      true,
+			wantSNIs:    kong.StringSlice("foo.example.org"),
+			wantWarning: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&logs)
+
+			state := KongState{
+				Certificates: []Certificate{{
+					Certificate: kong.Certificate{
+						ID:   kong.String("1"),
+						Cert: kong.String(string(tt.cert)),
+						SNIs: kong.StringSlice(tt.snis...),
+					},
+				}},
+			}
+			state.ValidateCertificateSNIs(log, tt.strict)
+			assert.Equal(t, tt.wantSNIs, state.Certificates[0].SNIs)
+			if tt.wantWarning {
+				assert.Contains(t, logs.String(), "SNI is not covered by the names of its certificate")
+			} else {
+				assert.Empty(t, logs.String())
+			}
+		})
+	}
+}
+
+type fakeCertificateMetrics map[string]int
+
+func (m fakeCertificateMetrics) RecordCertificateExpiry(status string) {
+	m[status]++
+}
+
+func TestKongState_CheckCertificateExpiry(t *testing.T) {
+	now := time.Now()
+	certificate := func(id string, notAfter time.Time) Certificate {
+		cert, _ := selfSignedKeyPairValidUntil(t, notAfter, id+".example.com")
+		return Certificate{Certificate: kong.Certificate{ID: kong.String(id), Cert: kong.String(string(cert))}}
+	}
+
+	for _, tt := range []struct {
+		name        string
+		cert        Certificate
+		wantLog     string
+		wantMetrics fakeCertificateMetrics
+	}{
+		{
+			name:        "expired certificate",
+			cert:        certificate("expired", now.Add(-time.Hour)),
+			wantLog:     "certificate has expired",
+			wantMetrics: fakeCertificateMetrics{CertificateExpired: 1},
+		},
+		{
+			name:        "certificate expiring within the threshold",
+			cert:        certificate("expiring", now.Add(7*24*time.Hour)),
+			wantLog:     "certificate expires soon",
+			wantMetrics: fakeCertificateMetrics{CertificateExpiringSoon: 1},
+		},
+		{
+			name:        "healthy certificate",
+			cert:        certificate("healthy", now.Add(90*24*time.Hour)),
+			wantMetrics: fakeCertificateMetrics{},
+		},
+		{
+			name:        "unparsable certificate",
+			cert:        Certificate{Certificate: kong.Certificate{ID: kong.String("invalid"), Cert: kong.String("garbage")}},
+			wantLog:     "failed to parse certificate, its expiry can't be checked",
+			wantMetrics: fakeCertificateMetrics{CertificateUnparsable: 1},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+			metrics := fakeCertificateMetrics{}
+
+			state := KongState{Certificates: []Certificate{tt.cert}}
+			state.CheckCertificateExpiry(log, metrics, DefaultCertificateExpiryWarningThreshold, now)
+
+			assert.Equal(t, tt.wantMetrics, metrics)
+			if tt.wantLog == "" {
+				assert.Empty(t, buf.String())
+				return
+			}
+			assert.Contains(t, buf.String(), tt.wantLog)
+			assert.Contains(t, buf.String(), "certificate_id="+*tt.cert.ID)
+		})
+	}
+
+	t.Run("metrics are optional", func(t *testing.T) {
+		state := KongState{Certificates: []Certificate{certificate("expired", now.Add(-time.Hour))}}
+		state.CheckCertificateExpiry(logrus.New(), nil, DefaultCertificateExpiryWarningThreshold, now)
+	})
+}
+
+func TestCertificate_Names(t *testing.T) {
+	t.Run("subject alternative names and common name", func(t *testing.T) {
+		certPEM, _ := selfSignedKeyPair(t, "example.com", "foo.example.com", "*.bar.example.com", "example.com")
+		cert := Certificate{Certificate: kong.Certificate{Cert: kong.String(string(certPEM))}}
+
+		names, err := cert.Names()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"foo.example.com", "*.bar.example.com", "example.com"}, names)
+
+		cached := cert.names
+		names, err = cert.Names()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"foo.example.com", "*.bar.example.com", "example.com"}, names)
+		assert.Same(t, cached, cert.names, "the names should be parsed only once")
+
+		otherPEM, _ := selfSignedKeyPair(t, "other.example.com")
+		cert.Cert = kong.String(string(otherPEM))
+		names, err = cert.Names()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"other.example.com"}, names, "the names should be parsed again when the certificate changes")
+	})
+
+	t.Run("malformed certificate", func(t *testing.T) {
+		cert := Certificate{Certificate: kong.Certificate{Cert: kong.String("-----BEGIN CERTIFICATE-----\nbm90IGEgY2VydA==\n-----END CERTIFICATE-----")}}
+		names, err := cert.Names()
+		assert.Error(t, err)
+		assert.Nil(t, names)
+
+		cert.Cert = kong.String("not a PEM")
+		_, err = cert.Names()
+		assert.Error(t, err)
+	})
+}
@@ -2,16 +2,15 @@ package kongstate
 
 import (
 	"fmt"
+	"sort"
 
-	"github.com/blang/semver/v4"
 	"github.com/kong/go-kong/kong"
 
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
 	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
 )
 
-var minMTLSCredentialVersion = semver.MustParse("2.3.2")
-
 // Consumer holds a Kong consumer and its plugins and credentials.
 type Consumer struct {
 	kong.Consumer
@@ -69,6 +68,19 @@ func (c *Consumer) SanitizedCopy() *Consumer {
 	}
 }
 
+// identifier returns the username of the consumer, or its custom ID if it has no username.
+// It returns nil for consumers with neither.
+func (c *Consumer) identifier() *string {
+	if c.Username != nil {
+		return c.Username
+	}
+	return c.CustomID
+}
+
+// SetCredential adds a credential of the given type to the consumer. Credentials are
+// accumulated: a credential of a type the consumer already holds is appended after the
+// existing ones, so credentials of the same type keep the order in which they are set
+// until SortCredentials is called.
 func (c *Consumer) SetCredential(credType string, credConfig interface{}) error {
 	switch credType {
 	case "key-auth", "keyauth_credential":
@@ -108,8 +120,9 @@ func (c *Consumer) SetCredential(credType string, credConfig interface{}) error
 		}
 		c.ACLGroups = append(c.ACLGroups, cred)
 	case "mtls-auth":
-		if util.GetKongVersion().LT(minMTLSCredentialVersion) {
-			return fmt.Errorf("controller cannot support mtls-auth below version %v", minMTLSCredentialVersion)
+		if !versionSupportsFeature(util.GetKongVersion(), FeatureMTLSAuthCredentials) {
+			return fmt.Errorf("controller cannot support mtls-auth below version %v",
+				featureMinVersions[FeatureMTLSAuthCredentials])
 		}
 		cred, err := NewMTLSAuth(credConfig)
 		if err != nil {
@@ -121,3 +134,35 @@ func (c *Consumer) SetCredential(credType string, credConfig interface{}) error
 	}
 	return nil
 }
+
+// SortCredentials sorts the credentials of each type by a stable key (the key, username,
+// group, client ID or subject name, depending on the type), so that the generated
+// configuration doesn't depend on the order in which credentials were set.
+func (c *Consumer) SortCredentials() {
+	sort.SliceStable(c.KeyAuths, func(i, j int) bool {
+		return stringValue(c.KeyAuths[i].Key) < stringValue(c.KeyAuths[j].Key)
+	})
+	sort.SliceStable(c.HMACAuths, func(i, j int) bool {
+		return stringValue(c.HMACAuths[i].Username) < stringValue(c.HMACAuths[j].Username)
+	})
+	sort.SliceStable(c.JWTAuths, func(i, j int) bool {
+		return stringValue(c.JWTAuths[i].Key) < stringValue(c.JWTAuths[j].Key)
+	})
+	sort.SliceStable(c.BasicAuths, func(i, j int) bool {
+		return stringValue(c.BasicAuths[i].Username) < stringValue(c.BasicAuths[j].Username)
+	})
+	sort.SliceStable(c.ACLGroups, func(i, j int) bool {
+		return stringValue(c.ACLGroups[i].Group) < stringValue(c.ACLGroups[j].Group)
+	})
+	sort.SliceStable(c.Oauth2Creds, func(i, j int) bool {
+		return stringValue(c.Oauth2Creds[i].ClientID) < stringValue(c.Oauth2Creds[j].ClientID)
+	})
+	sort.SliceStable(c.MTLSAuths, func(i, j int) bool {
+		return stringValue(c.MTLSAuths[i].SubjectName) < stringValue(c.MTLSAuths[j].SubjectName)
+	})
+}
+
+// consumerTags returns the Kong tags set with the tags label and annotation of a KongConsumer.
+func consumerTags(consumer *configurationv1.KongConsumer) []string {
+	return append(annotations.ExtractTags(consumer.Labels), annotations.ExtractTags(consumer.Annotations)...)
+}
@@ -0,0 +1,15 @@
+package kongstate
+
+import (
+	configurationv1beta1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1beta1"
+)
+
+// ConsumerGroup holds a Kong consumer group and its members.
+type ConsumerGroup struct {
+	Name *string
+	// Consumers holds the usernames (or custom IDs, for consumers without
+	// a username) of the consumers belonging to the group.
+	Consumers []string
+
+	K8sKongConsumerGroup configurationv1beta1.KongConsumerGroup
+}
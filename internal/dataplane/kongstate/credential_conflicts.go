@@ -0,0 +1,150 @@
+package kongstate
+
+import (
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// credentialIdentity identifies a credential by the field Kong requires to be unique
+// across all consumers for credentials of its type.
+type credentialIdentity struct {
+	credType, field, value string
+}
+
+// credentialSource is a credential provisioned for a consumer from a Secret.
+type credentialSource struct {
+	consumerKey string
+	secretName  string
+	identity    credentialIdentity
+}
+
+// lastCredentialIdentity returns the identity of the credential of type credType
+// most recently set on the consumer. It returns false for credential types without
+// a field unique across consumers, and for credentials with an empty unique field
+// (e.g. OAuth2 credentials with a client ID generated by Kong).
+func (c *Consumer) lastCredentialIdentity(credType string) (credentialIdentity, bool) {
+	var id credentialIdentity
+	switch credType {
+	case "key-auth", "keyauth_credential":
+		if len(c.KeyAuths) > 0 {
+			id = credentialIdentity{"key-auth", "key", stringValue(c.KeyAuths[len(c.KeyAuths)-1].Key)}
+		}
+	case "basic-auth", "basicauth_credential":
+		if len(c.BasicAuths) > 0 {
+			id = credentialIdentity{"basic-auth", "username", stringValue(c.BasicAuths[len(c.BasicAuths)-1].Username)}
+		}
+	case "hmac-auth", "hmacauth_credential":
+		if len(c.HMACAuths) > 0 {
+			id = credentialIdentity{"hmac-auth", "username", stringValue(c.HMACAuths[len(c.HMACAuths)-1].Username)}
+		}
+	case "jwt", "jwt_secret":
+		if len(c.JWTAuths) > 0 {
+			id = credentialIdentity{"jwt", "key", stringValue(c.JWTAuths[len(c.JWTAuths)-1].Key)}
+		}
+	case "oauth2":
+		if len(c.Oauth2Creds) > 0 {
+			id = credentialIdentity{"oauth2", "client_id", stringValue(c.Oauth2Creds[len(c.Oauth2Creds)-1].ClientID)}
+		}
+	}
+	return id, id.value != ""
+}
+
+// removeCredential removes from the consumer the last credential with the given identity.
+func (c *Consumer) removeCredential(id credentialIdentity) {
+	// lastMatch returns the index of the last of n credentials matching the identity, or -1
+	lastMatch := func(n int, value func(i int) *string) int {
+		for i := n - 1; i >= 0; i-- {
+			if stringValue(value(i)) == id.value {
+				return i
+			}
+		}
+		return -1
+	}
+	switch id.credType {
+	case "key-auth":
+		if i := lastMatch(len(c.KeyAuths), func(i int) *string { return c.KeyAuths[i].Key }); i >= 0 {
+			c.KeyAuths = append(c.KeyAuths[:i:i], c.KeyAuths[i+1:]...)
+		}
+	case "basic-auth":
+		if i := lastMatch(len(c.BasicAuths), func(i int) *string { return c.BasicAuths[i].Username }); i >= 0 {
+			c.BasicAuths = append(c.BasicAuths[:i:i], c.BasicAuths[i+1:]...)
+		}
+	case "hmac-auth":
+		if i := lastMatch(len(c.HMACAuths), func(i int) *string { return c.HMACAuths[i].Username }); i >= 0 {
+			c.HMACAuths = append(c.HMACAuths[:i:i], c.HMACAuths[i+1:]...)
+		}
+	case "jwt":
+		if i := lastMatch(len(c.JWTAuths), func(i int) *string { return c.JWTAuths[i].Key }); i >= 0 {
+			c.JWTAuths = append(c.JWTAuths[:i:i], c.JWTAuths[i+1:]...)
+		}
+	case "oauth2":
+		if i := lastMatch(len(c.Oauth2Creds), func(i int) *string { return c.Oauth2Creds[i].ClientID }); i >= 0 {
+			c.Oauth2Creds = append(c.Oauth2Creds[:i:i], c.Oauth2Creds[i+1:]...)
+		}
+	}
+}
+
+// handleConflictingCredentials detects credentials sharing a value Kong requires to be unique
+// (e.g. the key of key-auth credentials), which Kong would reject. Credentials of the oldest
+// consumer (by creation time, then namespace/name) take precedence. Every conflict is logged
+// with all the consumers and Secrets involved, without the conflicting value itself. If drop
+// is true, the conflicting credentials of the other consumers are removed from the index.
+// Sources of consumers missing from the index are ignored.
+func handleConflictingCredentials(
+	log logrus.FieldLogger,
+	consumerIndex map[string]Consumer,
+	sources []credentialSource,
+	drop bool,
+) {
+	age := make(map[string]int, len(consumerIndex))
+	for i, key := range consumerKeysByAge(consumerIndex) {
+		age[key] = i
+	}
+	bySource := make(map[credentialIdentity][]credentialSource)
+	var identities []credentialIdentity
+	for _, src := range sources {
+		if _, ok := consumerIndex[src.consumerKey]; !ok {
+			continue
+		}
+		if _, ok := bySource[src.identity]; !ok {
+			identities = append(identities, src.identity)
+		}
+		bySource[src.identity] = append(bySource[src.identity], src)
+	}
+
+	for _, id := range identities {
+		srcs := bySource[id]
+		if len(srcs) < 2 {
+			continue
+		}
+		// stable, so that the Secrets of a consumer keep the order in which they are listed
+		sort.SliceStable(srcs, func(i, j int) bool {
+			return age[srcs[i].consumerKey] < age[srcs[j].consumerKey]
+		})
+
+		consumers := make([]string, 0, len(srcs))
+		secrets := make([]string, 0, len(srcs))
+		for _, src := range srcs {
+			consumers = append(consumers, src.consumerKey)
+			secrets = append(secrets, src.secretName)
+		}
+		log := log.WithFields(logrus.Fields{
+			"credential_type": id.credType,
+			"field":           id.field,
+			"kongconsumers":   consumers,
+			"secrets":         secrets,
+		})
+		if !drop {
+			log.Errorf("multiple %s credentials use the same %s, Kong will reject them", id.credType, id.field)
+			continue
+		}
+		log.Errorf("multiple %s credentials use the same %s, only the one of the oldest KongConsumer (%s) will be applied",
+			id.credType, id.field, srcs[0].consumerKey)
+		for _, src := range srcs[1:] {
+			c := consumerIndex[src.consumerKey]
+			c.removeCredential(id)
+			consumerIndex[src.consumerKey] = c
+		}
+	}
+}
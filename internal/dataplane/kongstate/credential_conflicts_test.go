@@ -0,0 +1,94 @@
+package kongstate
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+func Test_FillConsumersAndCredentials_ConflictingCredentials(t *testing.T) {
+	now := time.Now()
+	consumer := func(name string, created time.Time, creds ...string) *configurationv1.KongConsumer {
+		return &configurationv1.KongConsumer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Username:    name,
+			Credentials: creds,
+		}
+	}
+	keyAuth := func(name, key string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+				"key":          []byte(key),
+			},
+		}
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongConsumers: []*configurationv1.KongConsumer{
+			consumer("alice", now, "alice-key", "alice-other-key"),
+			consumer("bob", now.Add(-time.Hour), "bob-key"),
+		},
+		Secrets: []*corev1.Secret{
+			keyAuth("alice-key", "shared-key"),
+			keyAuth("alice-other-key", "alice-key"),
+			keyAuth("bob-key", "shared-key"),
+		},
+	})
+	require.NoError(t, err)
+
+	keys := func(state KongState) map[string][]string {
+		res := map[string][]string{}
+		for _, c := range state.Consumers {
+			for _, cred := range c.KeyAuths {
+				res[*c.Username] = append(res[*c.Username], *cred.Key)
+			}
+		}
+		return res
+	}
+
+	t.Run("conflicts are logged", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log := logrus.New()
+		log.SetOutput(buf)
+
+		var state KongState
+		require.NoError(t, state.FillConsumersAndCredentials(log, s, nil, nil, nil, ConsumerFillOptions{}))
+		assert.Equal(t, map[string][]string{
+			"alice": {"alice-key", "shared-key"},
+			"bob":   {"shared-key"},
+		}, keys(state))
+		assert.Contains(t, buf.String(), "multiple key-auth credentials use the same key, Kong will reject them")
+		assert.Contains(t, buf.String(), `kongconsumers="[default/bob default/alice]"`)
+		assert.Contains(t, buf.String(), `secrets="[bob-key alice-key]"`)
+		assert.NotContains(t, buf.String(), "shared-key", "credential values must not be logged")
+	})
+
+	t.Run("credentials of newer consumers are dropped", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log := logrus.New()
+		log.SetOutput(buf)
+
+		var state KongState
+		require.NoError(t, state.FillConsumersAndCredentials(log, s, nil, nil, nil, ConsumerFillOptions{DropConflictingCredentials: true}))
+		assert.Equal(t, map[string][]string{
+			"alice": {"alice-key"},
+			"bob":   {"shared-key"},
+		}, keys(state))
+		assert.Contains(t, buf.String(),
+			"multiple key-auth credentials use the same key, only the one of the oldest KongConsumer (default/bob) will be applied")
+	})
+}
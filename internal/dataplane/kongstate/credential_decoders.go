@@ -0,0 +1,41 @@
+package kongstate
+
+import (
+	"strconv"
+	"strings"
+)
+
+// credentialDecoder converts the raw value of a credential Secret field into the value
+// expected by Kong for the field.
+type credentialDecoder func(value []byte) (interface{}, error)
+
+// credentialField identifies a field of a credential type.
+type credentialField struct {
+	credType string
+	name     string
+}
+
+// credentialDecoders holds the decoders of the credential fields whose value can't be derived
+// from their schema type alone. They take precedence over the field types, so that quirks of
+// a credential type are handled the same way whether its schema can be retrieved or not.
+// It's a variable so that tests can register their own decoders.
+var credentialDecoders = map[credentialField]credentialDecoder{
+	{credType: "oauth2", name: "redirect_uris"}: decodeCommaSeparatedList,
+	{credType: "oauth2", name: "hash_secret"}:   decodeBool,
+}
+
+// credentialFieldDecoder returns the decoder registered for a field of a credential type.
+func credentialFieldDecoder(credType, name string) (credentialDecoder, bool) {
+	decoder, ok := credentialDecoders[credentialField{credType: credType, name: name}]
+	return decoder, ok
+}
+
+// decodeCommaSeparatedList decodes a comma-separated list of values.
+func decodeCommaSeparatedList(value []byte) (interface{}, error) {
+	return strings.Split(string(value), ","), nil
+}
+
+// decodeBool decodes a boolean, accepting the values strconv.ParseBool accepts.
+func decodeBool(value []byte) (interface{}, error) {
+	return strconv.ParseBool(string(value))
+}
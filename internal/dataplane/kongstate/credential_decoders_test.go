@@ -0,0 +1,48 @@
+package kongstate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_credentialConfigFromSecretData_Decoders(t *testing.T) {
+	original := credentialDecoders
+	t.Cleanup(func() { credentialDecoders = original })
+	credentialDecoders = map[credentialField]credentialDecoder{
+		{credType: "key-auth", name: "key"}: func(value []byte) (interface{}, error) {
+			return strings.ToUpper(string(value)), nil
+		},
+		{credType: "oauth2", name: "hash_secret"}: decodeBool,
+	}
+	data := map[string][]byte{
+		"key":         []byte("secret"),
+		"hash_secret": []byte("true"),
+	}
+	// the schema types are overridden by the decoders
+	fieldTypes := map[string]string{"key": "string", "hash_secret": "string"}
+
+	t.Run("custom decoder is invoked for its credential type and field", func(t *testing.T) {
+		got := credentialConfigFromSecretData(logrus.New(), "key-auth", fieldTypes, data, nil)
+		assert.Equal(t, map[string]interface{}{
+			"key":         "SECRET",
+			"hash_secret": "true",
+		}, got)
+	})
+
+	t.Run("decoders of other credential types are not invoked", func(t *testing.T) {
+		got := credentialConfigFromSecretData(logrus.New(), "oauth2", fieldTypes, data, nil)
+		assert.Equal(t, map[string]interface{}{
+			"key":         "secret",
+			"hash_secret": true,
+		}, got)
+	})
+
+	t.Run("values which can't be decoded are dropped", func(t *testing.T) {
+		got := credentialConfigFromSecretData(logrus.New(), "oauth2", fieldTypes,
+			map[string][]byte{"hash_secret": []byte("maybe")}, nil)
+		assert.Empty(t, got)
+	})
+}
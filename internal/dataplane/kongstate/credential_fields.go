@@ -0,0 +1,267 @@
+package kongstate
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/validation/consumers/credentials"
+)
+
+// CredentialSchemaGetter retrieves the Kong schema of the entity backing a credential type.
+type CredentialSchemaGetter interface {
+	Schema(ctx context.Context, credType string) (map[string]interface{}, error)
+}
+
+// credentialSchemaTimeout bounds the time spent fetching the schema of a credential type.
+const credentialSchemaTimeout = 5 * time.Second
+
+// credentialSchemaCache wraps a CredentialSchemaGetter to fetch the schema of every credential
+// type at most once per util.SchemaCacheTTL, with a bounded context. Failures are cached too, so
+// that a slow or unavailable Admin API delays a fill at most once per credential type. It's safe
+// for concurrent use.
+type credentialSchemaCache struct {
+	schemas CredentialSchemaGetter
+	timeout time.Duration
+	ttl     time.Duration
+	now     func() time.Time
+
+	lock    sync.Mutex
+	results map[string]credentialSchemaResult
+}
+
+type credentialSchemaResult struct {
+	schema    map[string]interface{}
+	err       error
+	fetchedAt time.Time
+}
+
+func newCredentialSchemaCache(schemas CredentialSchemaGetter) *credentialSchemaCache {
+	return &credentialSchemaCache{
+		schemas: schemas,
+		timeout: credentialSchemaTimeout,
+		ttl:     util.SchemaCacheTTL,
+		now:     time.Now,
+		results: make(map[string]credentialSchemaResult),
+	}
+}
+
+// Schema returns the schema of credType, fetching it on the first call and once the cached
+// result expires.
+func (c *credentialSchemaCache) Schema(ctx context.Context, credType string) (map[string]interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if res, ok := c.results[credType]; ok && c.now().Sub(res.fetchedAt) < c.ttl {
+		return res.schema, res.err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	schema, err := c.schemas.Schema(ctx, credType)
+	c.results[credType] = credentialSchemaResult{schema: schema, err: err, fetchedAt: c.now()}
+	return schema, err
+}
+
+// defaultCredentialFieldTypes holds the types of credential fields which can't be
+// represented as plain strings. It's used when credential schemas can't be retrieved
+// from Kong.
+var defaultCredentialFieldTypes = map[string]string{
+	"redirect_uris":  "array",
+	"hash_secret":    "boolean",
+	"ca_certificate": "foreign",
+}
+
+// credentialFieldTypes returns the types of the top level fields of a credential type,
+// keyed by field name. Fields missing from the result should be treated as strings.
+func credentialFieldTypes(log logrus.FieldLogger, schemas CredentialSchemaGetter, credType string) map[string]string {
+	if schemas == nil {
+		return defaultCredentialFieldTypes
+	}
+	schema, err := schemas.Schema(context.Background(), credType)
+	if err != nil {
+		log.WithError(err).Warnf("failed to fetch schema for credential type %s, using default field types", credType)
+		return defaultCredentialFieldTypes
+	}
+	return fieldTypesFromSchema(schema)
+}
+
+// requiredCredentialFields returns the sorted names of the fields a credential type requires,
+// according to its schema, and which Kong can't fill in on its own, i.e. without a default
+// or an automatically generated value. The consumer the credential belongs to is set by the
+// controller, so it's left out. It returns nil if schemas is nil or the schema can't be
+// retrieved, in which case credentialFieldTypes already logged the failure.
+func requiredCredentialFields(schemas CredentialSchemaGetter, credType string) []string {
+	if schemas == nil {
+		return nil
+	}
+	schema, err := schemas.Schema(context.Background(), credType)
+	if err != nil {
+		return nil
+	}
+	var required []string
+	for name, def := range schemaFields(schema) {
+		if isRequired, _ := def["required"].(bool); !isRequired || name == "consumer" {
+			continue
+		}
+		if auto, _ := def["auto"].(bool); auto {
+			continue
+		}
+		if _, ok := def["default"]; ok {
+			continue
+		}
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return required
+}
+
+// missingCredentialFields returns the fields of required which are not set in credConfig.
+func missingCredentialFields(credConfig map[string]interface{}, required []string) []string {
+	var missing []string
+	for _, name := range required {
+		if _, ok := credConfig[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// fieldTypesFromSchema extracts the types of the top level fields from a Kong entity schema.
+// Kong schemas list fields as an array of single-key objects, e.g.
+// {"fields": [{"redirect_uris": {"type": "array", ...}}, ...]}.
+func fieldTypesFromSchema(schema map[string]interface{}) map[string]string {
+	res := map[string]string{}
+	for name, def := range schemaFields(schema) {
+		if t, ok := def["type"].(string); ok {
+			res[name] = t
+		}
+	}
+	return res
+}
+
+// credentialFieldValue converts a raw Secret value into the representation expected by
+// Kong for a credential field of the given schema type.
+func credentialFieldValue(fieldType string, value []byte) (interface{}, error) {
+	switch fieldType {
+	case "array", "set":
+		return decodeCommaSeparatedList(value)
+	case "boolean":
+		return decodeBool(value)
+	case "integer":
+		return strconv.Atoi(string(value))
+	case "number":
+		return strconv.ParseFloat(string(value), 64)
+	case "foreign":
+		// references to other entities are set by ID
+		return map[string]interface{}{"id": string(value)}, nil
+	case "record", "map":
+		var res map[string]interface{}
+		if err := json.Unmarshal(value, &res); err != nil {
+			return nil, fmt.Errorf("invalid JSON object: %w", err)
+		}
+		return res, nil
+	default:
+		return string(value), nil
+	}
+}
+
+// credentialConfigFromSecretData converts credential Secret data into a credential configuration,
+// decoding each value with the decoder registered for the field in credentialDecoders, or typing
+// it according to fieldTypes otherwise. Values of binaryFields are base64 encoded instead,
+// as they can't be represented as strings. Values which can't be converted to their field type
+// are logged and left out of the configuration. Keys are processed in sorted order, so that
+// the logs are stable between runs.
+func credentialConfigFromSecretData(
+	log logrus.FieldLogger,
+	credType string,
+	fieldTypes map[string]string,
+	data map[string][]byte,
+	binaryFields []string,
+) map[string]interface{} {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	credConfig := map[string]interface{}{}
+	for _, k := range keys {
+		v := data[k]
+		if containsString(binaryFields, k) {
+			credConfig[k] = base64.StdEncoding.EncodeToString(v)
+			continue
+		}
+		if !utf8.Valid(v) {
+			log.Warnf("credential field %s holds binary data which will be mangled, "+
+				"list it in the %s%s annotation to have it base64 encoded",
+				k, annotations.AnnotationPrefix, annotations.BinaryCredentialFieldsKey)
+		}
+		if decode, ok := credentialFieldDecoder(credType, k); ok {
+			value, err := decode(v)
+			if err != nil {
+				log.WithError(err).Errorf("failed to decode credential field %s, ignoring it", k)
+				continue
+			}
+			credConfig[k] = value
+			continue
+		}
+		value, err := credentialFieldValue(fieldTypes[k], v)
+		if err != nil {
+			log.WithError(err).Errorf("failed to parse credential field %s as %s, ignoring it", k, fieldTypes[k])
+			continue
+		}
+		credConfig[k] = value
+	}
+	return credConfig
+}
+
+// credentialType returns the type of the credential held by a Secret, read from the credTypeKey
+// Secret key, from the credentials.TypeLabel label if the Secret has no such key, or from the
+// Secret type (konghq.com/<credType>) if it has neither.
+func credentialType(secret *corev1.Secret, credTypeKey string) string {
+	if credType, ok := secret.Data[credTypeKey]; ok {
+		return string(credType)
+	}
+	if credType, ok := secret.Labels[credentials.TypeLabel]; ok {
+		return credType
+	}
+	if credType := string(secret.Type); strings.HasPrefix(credType, credentials.SecretTypePrefix) {
+		return strings.TrimPrefix(credType, credentials.SecretTypePrefix)
+	}
+	return ""
+}
+
+// caCertificateReference returns the ID of the CA certificate referenced by the configuration
+// of an mtls-auth credential, false for other credentials and mtls-auth credentials without one.
+func caCertificateReference(credType string, credConfig map[string]interface{}) (string, bool) {
+	if credType != "mtls-auth" {
+		return "", false
+	}
+	ref, ok := credConfig["ca_certificate"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	id, ok := ref["id"].(string)
+	return id, ok
+}
+
+// hasCACertificate reports whether the state holds the CA certificate with the given ID.
+func (ks *KongState) hasCACertificate(id string) bool {
+	for _, caCert := range ks.CACertificates {
+		if stringValue(caCert.ID) == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,478 @@
+package kongstate
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+type fakeCredentialSchemas map[string]map[string]interface{}
+
+func (f fakeCredentialSchemas) Schema(_ context.Context, credType string) (map[string]interface{}, error) {
+	schema, ok := f[credType]
+	if !ok {
+		return nil, fmt.Errorf("no schema for %s", credType)
+	}
+	return schema, nil
+}
+
+func schemaWithFields(fields map[string]string) map[string]interface{} {
+	var res []interface{}
+	for name, fieldType := range fields {
+		res = append(res, map[string]interface{}{
+			name: map[string]interface{}{"type": fieldType},
+		})
+	}
+	return map[string]interface{}{"fields": res}
+}
+
+func Test_credentialConfigFromSecretData(t *testing.T) {
+	schemas := fakeCredentialSchemas{
+		"oauth2": schemaWithFields(map[string]string{
+			"name":          "string",
+			"redirect_uris": "array",
+			"hash_secret":   "boolean",
+		}),
+		"key-auth": schemaWithFields(map[string]string{
+			"key":      "string",
+			"ttl":      "integer",
+			"tags":     "set",
+			"metadata": "record",
+		}),
+	}
+
+	for _, tt := range []struct {
+		name         string
+		schemas      CredentialSchemaGetter
+		credType     string
+		data         map[string][]byte
+		binaryFields []string
+		want         map[string]interface{}
+	}{
+		{
+			name:     "array and boolean fields of oauth2 credentials",
+			schemas:  schemas,
+			credType: "oauth2",
+			data: map[string][]byte{
+				"kongCredType":  []byte("oauth2"),
+				"name":          []byte("app"),
+				"redirect_uris": []byte("http://a.example.com,http://b.example.com"),
+				"hash_secret":   []byte("true"),
+			},
+			want: map[string]interface{}{
+				"kongCredType":  "oauth2",
+				"name":          "app",
+				"redirect_uris": []string{"http://a.example.com", "http://b.example.com"},
+				"hash_secret":   true,
+			},
+		},
+		{
+			name:     "integer, set and nested fields of key-auth credentials",
+			schemas:  schemas,
+			credType: "key-auth",
+			data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+				"key":          []byte("secret"),
+				"ttl":          []byte("3600"),
+				"tags":         []byte("a,b"),
+				"metadata":     []byte(`{"owner":{"team":"foo"}}`),
+			},
+			want: map[string]interface{}{
+				"kongCredType": "key-auth",
+				"key":          "secret",
+				"ttl":          3600,
+				"tags":         []string{"a", "b"},
+				"metadata": map[string]interface{}{
+					"owner": map[string]interface{}{"team": "foo"},
+				},
+			},
+		},
+		{
+			name:     "values which can't be parsed are dropped",
+			schemas:  schemas,
+			credType: "key-auth",
+			data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+				"key":          []byte("secret"),
+				"ttl":          []byte("forever"),
+			},
+			want: map[string]interface{}{
+				"kongCredType": "key-auth",
+				"key":          "secret",
+			},
+		},
+		{
+			name:     "unknown fields fall back to strings",
+			schemas:  schemas,
+			credType: "key-auth",
+			data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+				"key":          []byte("secret"),
+				"unknown":      []byte("true"),
+			},
+			want: map[string]interface{}{
+				"kongCredType": "key-auth",
+				"key":          "secret",
+				"unknown":      "true",
+			},
+		},
+		{
+			name:     "binary fields are base64 encoded",
+			schemas:  schemas,
+			credType: "key-auth",
+			data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+				"key":          {0xff, 0x00, 0xfe},
+				"ttl":          []byte("3600"),
+			},
+			binaryFields: []string{"key"},
+			want: map[string]interface{}{
+				"kongCredType": "key-auth",
+				"key":          "/wD+",
+				"ttl":          3600,
+			},
+		},
+		{
+			name:     "default field types are used without schemas",
+			credType: "oauth2",
+			data: map[string][]byte{
+				"kongCredType":  []byte("oauth2"),
+				"redirect_uris": []byte("http://example.com"),
+				"hash_secret":   []byte("false"),
+			},
+			want: map[string]interface{}{
+				"kongCredType":  "oauth2",
+				"redirect_uris": []string{"http://example.com"},
+				"hash_secret":   false,
+			},
+		},
+		{
+			name:     "default field types are used when the schema can't be fetched",
+			schemas:  schemas,
+			credType: "jwt",
+			data: map[string][]byte{
+				"kongCredType": []byte("jwt"),
+				"hash_secret":  []byte("true"),
+			},
+			want: map[string]interface{}{
+				"kongCredType": "jwt",
+				"hash_secret":  true,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldTypes := credentialFieldTypes(logrus.New(), tt.schemas, tt.credType)
+			got := credentialConfigFromSecretData(logrus.New(), tt.credType, fieldTypes, tt.data, tt.binaryFields)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_FillConsumersAndCredentials_BinaryCredentialValues(t *testing.T) {
+	secretValue := []byte{0x8b, 0x00, 0xc3, 0x28, 0xff, 'a'}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "hmac",
+					Namespace: "default",
+					Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.BinaryCredentialFieldsKey: "secret",
+					},
+				},
+				Data: map[string][]byte{
+					"kongCredType": []byte("hmac-auth"),
+					"username":     []byte("foo"),
+					"secret":       secretValue,
+				},
+			},
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:    "foo",
+				Credentials: []string{"hmac"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{}
+	require.NoError(t, state.FillConsumersAndCredentials(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{}))
+	require.Len(t, state.Consumers, 1)
+	require.Len(t, state.Consumers[0].HMACAuths, 1)
+	hmacAuth := state.Consumers[0].HMACAuths[0]
+	assert.Equal(t, "foo", *hmacAuth.Username)
+	decoded, err := base64.StdEncoding.DecodeString(*hmacAuth.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, secretValue, decoded, "the binary secret should survive the round trip")
+}
+
+func Test_FillConsumersAndCredentials_SecretTypeCredType(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "typed", Namespace: "default"},
+				Type:       "konghq.com/key-auth",
+				Data: map[string][]byte{
+					"key": []byte("foo-key"),
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "typed-with-key", Namespace: "default"},
+				Type:       "konghq.com/key-auth",
+				Data: map[string][]byte{
+					"kongCredType": []byte("basic-auth"),
+					"username":     []byte("foo"),
+					"password":     []byte("bar"),
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "opaque", Namespace: "default"},
+				Type:       corev1.SecretTypeOpaque,
+				Data: map[string][]byte{
+					"key": []byte("bar-key"),
+				},
+			},
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:    "foo",
+				Credentials: []string{"typed", "typed-with-key", "opaque"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{}
+	err = state.FillConsumersAndCredentials(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{})
+	assert.ErrorContains(t, err, "secret default/opaque", "secrets with no credential type should fail")
+	require.Len(t, state.Consumers, 1)
+
+	require.Len(t, state.Consumers[0].KeyAuths, 1, "the credential type should be inferred from the secret type")
+	assert.Equal(t, "foo-key", *state.Consumers[0].KeyAuths[0].Key)
+	require.Len(t, state.Consumers[0].BasicAuths, 1, "the kongCredType key should take precedence over the secret type")
+	assert.Equal(t, "foo", *state.Consumers[0].BasicAuths[0].Username)
+}
+
+func Test_FillConsumersAndCredentials_MTLSAuthCACertificate(t *testing.T) {
+	util.SetKongVersion(semver.MustParse("2.3.2")) // minimum version for mtls-auths with tags
+	mtlsSecret := func(name, caCertID string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data: map[string][]byte{
+				"kongCredType":   []byte("mtls-auth"),
+				"subject_name":   []byte(name + "@example.com"),
+				"ca_certificate": []byte(caCertID),
+			},
+		}
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			mtlsSecret("valid", "ca-1"),
+			mtlsSecret("dangling", "ca-2"),
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:    "foo",
+				Credentials: []string{"valid", "dangling"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{
+		CACertificates: []kong.CACertificate{{ID: kong.String("ca-1")}},
+	}
+	diagnostics, err := state.FillConsumersAndCredentialsWithDiagnostics(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{})
+	assert.ErrorContains(t, err, "CA certificate ca-2 does not exist")
+	require.Len(t, diagnostics["default/foo"], 1)
+	assert.Equal(t, "dangling", diagnostics["default/foo"][0].SecretName)
+	assert.Equal(t, CredentialDiagnosticMissingCACertificate, diagnostics["default/foo"][0].Reason)
+
+	require.Len(t, state.Consumers, 1)
+	require.Len(t, state.Consumers[0].MTLSAuths, 1, "the credential referencing a missing CA certificate should be skipped")
+	mtlsAuth := state.Consumers[0].MTLSAuths[0]
+	assert.Equal(t, "valid@example.com", *mtlsAuth.SubjectName)
+	require.NotNil(t, mtlsAuth.CACertificate)
+	assert.Equal(t, "ca-1", *mtlsAuth.CACertificate.ID)
+}
+
+func Test_FillConsumersAndCredentials_RequiredFields(t *testing.T) {
+	field := func(name, fieldType string, def map[string]interface{}) map[string]interface{} {
+		if def == nil {
+			def = map[string]interface{}{}
+		}
+		def["type"] = fieldType
+		return map[string]interface{}{name: def}
+	}
+	schemas := fakeCredentialSchemas{
+		"acl": {"fields": []interface{}{
+			field("id", "string", map[string]interface{}{"auto": true}),
+			field("consumer", "foreign", map[string]interface{}{"required": true}),
+			field("group", "string", map[string]interface{}{"required": true}),
+			field("tags", "set", nil),
+		}},
+		"basic-auth": {"fields": []interface{}{
+			field("consumer", "foreign", map[string]interface{}{"required": true}),
+			field("username", "string", map[string]interface{}{"required": true}),
+			field("password", "string", map[string]interface{}{"required": true}),
+		}},
+	}
+	secret := func(name string, data map[string]string) *corev1.Secret {
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string][]byte{},
+		}
+		for k, v := range data {
+			s.Data[k] = []byte(v)
+		}
+		return s
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			secret("single-field", map[string]string{"kongCredType": "acl", "group": "admins"}),
+			secret("explicit-empty", map[string]string{"kongCredType": "basic-auth", "username": "foo", "password": ""}),
+			secret("partial", map[string]string{"kongCredType": "basic-auth", "username": "bar"}),
+			secret("empty", map[string]string{"kongCredType": "acl"}),
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:    "foo",
+				Credentials: []string{"single-field", "explicit-empty", "partial", "empty"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{}
+	diagnostics, err := state.FillConsumersAndCredentialsWithDiagnostics(logrus.New(), s, schemas, nil, nil, ConsumerFillOptions{})
+	require.Error(t, err)
+
+	reasons := map[string]CredentialDiagnosticReason{}
+	for _, d := range diagnostics["default/foo"] {
+		reasons[d.SecretName] = d.Reason
+	}
+	assert.Equal(t, map[string]CredentialDiagnosticReason{
+		"partial": CredentialDiagnosticMissingRequiredFields,
+		"empty":   CredentialDiagnosticEmptySecret,
+	}, reasons)
+	assert.ErrorContains(t, err, "missing required fields: password")
+
+	require.Len(t, state.Consumers, 1)
+	require.Len(t, state.Consumers[0].ACLGroups, 1, "a credential with its single required field should be accepted")
+	assert.Equal(t, "admins", *state.Consumers[0].ACLGroups[0].Group)
+	require.Len(t, state.Consumers[0].BasicAuths, 1, "fields set to an empty value should count as present")
+	assert.Equal(t, "foo", *state.Consumers[0].BasicAuths[0].Username)
+}
+
+// countingCredentialSchemas wraps fakeCredentialSchemas to count the schema lookups of every
+// credential type, and checks that lookups are bounded in time.
+type countingCredentialSchemas struct {
+	fakeCredentialSchemas
+
+	lookups      map[string]int
+	withDeadline bool
+}
+
+func (c *countingCredentialSchemas) Schema(ctx context.Context, credType string) (map[string]interface{}, error) {
+	c.lookups[credType]++
+	_, c.withDeadline = ctx.Deadline()
+	return c.fakeCredentialSchemas.Schema(ctx, credType)
+}
+
+func Test_FillConsumersAndCredentials_SchemaLookups(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-key", Namespace: "default"},
+				Data:       map[string][]byte{"kongCredType": []byte("key-auth"), "key": []byte("foo")},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "bar-key", Namespace: "default"},
+				Data:       map[string][]byte{"kongCredType": []byte("key-auth"), "key": []byte("bar")},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-acl", Namespace: "default"},
+				Data:       map[string][]byte{"kongCredType": []byte("acl"), "group": []byte("admins")},
+			},
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:    "foo",
+				Credentials: []string{"foo-key", "foo-acl"},
+			},
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "bar", Namespace: "default"},
+				Username:    "bar",
+				Credentials: []string{"bar-key"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// the key-auth schema is available, the acl schema fails to be fetched
+	schemas := &countingCredentialSchemas{
+		fakeCredentialSchemas: fakeCredentialSchemas{"key-auth": schemaWithFields(map[string]string{"key": "string"})},
+		lookups:               map[string]int{},
+	}
+	state := KongState{}
+	require.NoError(t, state.FillConsumersAndCredentials(logrus.New(), s, schemas, nil, nil, ConsumerFillOptions{}))
+	assert.Equal(t, map[string]int{"key-auth": 1, "acl": 1}, schemas.lookups,
+		"schemas should be fetched once per credential type, even if they can't be")
+	assert.True(t, schemas.withDeadline, "schema lookups should be bounded in time")
+
+	require.Len(t, state.Consumers, 2)
+	var keyAuths, aclGroups int
+	for _, c := range state.Consumers {
+		keyAuths += len(c.KeyAuths)
+		aclGroups += len(c.ACLGroups)
+	}
+	assert.Equal(t, 2, keyAuths)
+	assert.Equal(t, 1, aclGroups)
+}
+
+func Test_credentialSchemaCache_Expiry(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	schemas := &countingCredentialSchemas{
+		fakeCredentialSchemas: fakeCredentialSchemas{"key-auth": schemaWithFields(map[string]string{"key": "string"})},
+		lookups:               map[string]int{},
+	}
+	cache := newCredentialSchemaCache(schemas)
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.Schema(context.Background(), "key-auth")
+		require.NoError(t, err)
+		_, err = cache.Schema(context.Background(), "acl")
+		require.Error(t, err)
+	}
+	assert.Equal(t, map[string]int{"key-auth": 1, "acl": 1}, schemas.lookups)
+
+	now = now.Add(util.SchemaCacheTTL)
+	_, err := cache.Schema(context.Background(), "key-auth")
+	require.NoError(t, err)
+	_, err = cache.Schema(context.Background(), "acl")
+	require.Error(t, err)
+	assert.Equal(t, map[string]int{"key-auth": 2, "acl": 2}, schemas.lookups,
+		"schemas and failures should be fetched again once they expire")
+}
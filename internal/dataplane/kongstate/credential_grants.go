@@ -0,0 +1,57 @@
+package kongstate
+
+import (
+	"strings"
+
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+// credentialSecretReference returns the namespace and name of a credential Secret referenced
+// by a KongConsumer of consumerNamespace. Credentials reference Secrets of the namespace of the
+// KongConsumer by name, and Secrets of other namespaces as namespace/name.
+func credentialSecretReference(consumerNamespace, ref string) (namespace, name string) {
+	if namespace, name, found := strings.Cut(ref, "/"); found {
+		return namespace, name
+	}
+	return consumerNamespace, ref
+}
+
+// credentialReferenceGranted reports whether one of policies, the ReferencePolicies (the former
+// name of Gateway API ReferenceGrants) of the cluster, allows KongConsumers of consumerNamespace
+// to reference the credential Secret secretNamespace/secretName. Only policies of the namespace
+// of the Secret grant references to it, as for other Gateway API references.
+func credentialReferenceGranted(
+	policies []*gatewayv1alpha2.ReferencePolicy,
+	consumerNamespace, secretNamespace, secretName string,
+) bool {
+	from := gatewayv1alpha2.ReferenceGrantFrom{
+		Group:     gatewayv1alpha2.Group(configurationv1.GroupVersion.Group),
+		Kind:      gatewayv1alpha2.Kind("KongConsumer"),
+		Namespace: gatewayv1alpha2.Namespace(consumerNamespace),
+	}
+	for _, policy := range policies {
+		if policy.Namespace != secretNamespace || !containsReferenceGrantFrom(policy.Spec.From, from) {
+			continue
+		}
+		for _, to := range policy.Spec.To {
+			if to.Group != "" || to.Kind != "Secret" {
+				continue
+			}
+			if to.Name == nil || string(*to.Name) == secretName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsReferenceGrantFrom(froms []gatewayv1alpha2.ReferenceGrantFrom, from gatewayv1alpha2.ReferenceGrantFrom) bool {
+	for _, f := range froms {
+		if f == from {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package kongstate
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+func Test_FillConsumersAndCredentials_CrossNamespaceSecrets(t *testing.T) {
+	keyAuthSecret := func(name string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "credentials"},
+			Data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+				"key":          []byte(name),
+			},
+		}
+	}
+	secretName := gatewayv1alpha2.ObjectName("granted")
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			keyAuthSecret("granted"),
+			keyAuthSecret("denied"),
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:    "foo",
+				Credentials: []string{"credentials/granted", "credentials/denied"},
+			},
+		},
+		ReferencePolicies: []*gatewayv1alpha2.ReferencePolicy{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "consumers", Namespace: "credentials"},
+				Spec: gatewayv1alpha2.ReferenceGrantSpec{
+					From: []gatewayv1alpha2.ReferenceGrantFrom{{
+						Group:     gatewayv1alpha2.Group("configuration.konghq.com"),
+						Kind:      gatewayv1alpha2.Kind("KongConsumer"),
+						Namespace: gatewayv1alpha2.Namespace("default"),
+					}},
+					To: []gatewayv1alpha2.ReferenceGrantTo{{
+						Group: gatewayv1alpha2.Group(""),
+						Kind:  gatewayv1alpha2.Kind("Secret"),
+						Name:  &secretName,
+					}},
+				},
+			},
+			{
+				// policies of other namespaces don't grant references to the Secrets
+				ObjectMeta: metav1.ObjectMeta{Name: "consumers", Namespace: "default"},
+				Spec: gatewayv1alpha2.ReferenceGrantSpec{
+					From: []gatewayv1alpha2.ReferenceGrantFrom{{
+						Group:     gatewayv1alpha2.Group("configuration.konghq.com"),
+						Kind:      gatewayv1alpha2.Kind("KongConsumer"),
+						Namespace: gatewayv1alpha2.Namespace("default"),
+					}},
+					To: []gatewayv1alpha2.ReferenceGrantTo{{
+						Group: gatewayv1alpha2.Group(""),
+						Kind:  gatewayv1alpha2.Kind("Secret"),
+					}},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	var state KongState
+	diagnostics, err := state.FillConsumersAndCredentialsWithDiagnostics(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{})
+	assert.ErrorContains(t, err, "reference to Secret credentials/denied is not allowed by any ReferencePolicy")
+	require.Len(t, diagnostics["default/foo"], 1)
+	assert.Equal(t, "credentials/denied", diagnostics["default/foo"][0].SecretName)
+	assert.Equal(t, CredentialDiagnosticReferenceNotGranted, diagnostics["default/foo"][0].Reason)
+
+	require.Len(t, state.Consumers, 1)
+	require.Len(t, state.Consumers[0].KeyAuths, 1, "only the granted credential should be provisioned")
+	assert.Equal(t, "granted", *state.Consumers[0].KeyAuths[0].Key)
+}
+
+func Test_credentialSecretReference(t *testing.T) {
+	namespace, name := credentialSecretReference("default", "foo")
+	assert.Equal(t, "default", namespace)
+	assert.Equal(t, "foo", name)
+
+	namespace, name = credentialSecretReference("default", "credentials/foo")
+	assert.Equal(t, "credentials", namespace)
+	assert.Equal(t, "foo", name)
+}
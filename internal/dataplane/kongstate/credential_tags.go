@@ -0,0 +1,118 @@
+package kongstate
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+)
+
+// maxTagLength is the maximum length of a tag accepted by Kong.
+const maxTagLength = 128
+
+// CredentialTagKeys holds the keys of the credential Secret labels and annotations whose
+// comma-separated values are set as Kong tags on the credential.
+var CredentialTagKeys = []string{annotations.AnnotationPrefix + annotations.TagsKey}
+
+// credentialTagsFromSecret returns the Kong tags of a credential, taken from the Secret
+// labels and annotations listed in CredentialTagKeys. Tags which Kong would reject are
+// logged and left out. The result is sorted and free of duplicates.
+func credentialTagsFromSecret(log logrus.FieldLogger, secret *corev1.Secret) []string {
+	seen := map[string]struct{}{}
+	for _, key := range CredentialTagKeys {
+		for _, values := range []map[string]string{secret.Labels, secret.Annotations} {
+			value, ok := values[key]
+			if !ok {
+				continue
+			}
+			for _, tag := range strings.Split(value, ",") {
+				tag = strings.TrimSpace(tag)
+				if tag == "" {
+					continue
+				}
+				if !isValidTag(tag) {
+					log.WithField("tag", tag).Warnf("invalid credential tag from %s, ignoring it: "+
+						"tags must be at most %d printable characters, excluding ',' and '/'", key, maxTagLength)
+					continue
+				}
+				seen[tag] = struct{}{}
+			}
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+
+	tags := make([]string, 0, len(seen))
+	for tag := range seen {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// isValidTag checks whether a tag is accepted by Kong.
+func isValidTag(tag string) bool {
+	if len(tag) > maxTagLength {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsPrint(r) || r == '/' || r == ',' {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeTags returns tags followed by the extra tags, the tags of an entity of the given kind
+// set by users. Duplicate tags are removed and extra tags which Kong would reject are logged
+// and dropped. tags is returned as is if there are no extra tags.
+func mergeTags(log logrus.FieldLogger, kind string, tags []*string, extra []string) []*string {
+	if len(extra) == 0 {
+		return tags
+	}
+
+	var res []*string
+	seen := make(map[string]struct{}, len(tags)+len(extra))
+	addTag := func(tag string) {
+		if _, ok := seen[tag]; ok {
+			return
+		}
+		seen[tag] = struct{}{}
+		res = append(res, kong.String(tag))
+	}
+	for _, tag := range tags {
+		if tag != nil {
+			addTag(*tag)
+		}
+	}
+	for _, tag := range extra {
+		if !isValidTag(tag) {
+			log.WithField("tag", tag).Warnf("invalid %s tag, ignoring it: tags must be at most %d printable characters, "+
+				"excluding ',' and '/'", kind, maxTagLength)
+			continue
+		}
+		addTag(tag)
+	}
+	return res
+}
+
+// addCredentialTags adds tags to a credential configuration, keeping the tags
+// already set from the credential Secret data.
+func addCredentialTags(credConfig map[string]interface{}, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	switch existing := credConfig["tags"].(type) {
+	case []string:
+		tags = append(existing, tags...)
+	case string:
+		tags = append(strings.Split(existing, ","), tags...)
+	}
+	credConfig["tags"] = tags
+}
@@ -0,0 +1,110 @@
+package kongstate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+func Test_credentialTagsFromSecret(t *testing.T) {
+	longTag := strings.Repeat("a", maxTagLength+1)
+
+	for _, tt := range []struct {
+		name        string
+		labels      map[string]string
+		anns        map[string]string
+		want        []string
+		wantWarning bool
+	}{
+		{
+			name: "no tags",
+		},
+		{
+			name: "tags from annotation",
+			anns: map[string]string{"konghq.com/tags": "team-a, prod"},
+			want: []string{"prod", "team-a"},
+		},
+		{
+			name:   "tags from label and annotation are merged",
+			labels: map[string]string{"konghq.com/tags": "team-a"},
+			anns:   map[string]string{"konghq.com/tags": "team-a,prod"},
+			want:   []string{"prod", "team-a"},
+		},
+		{
+			name:        "oversized tags are dropped",
+			anns:        map[string]string{"konghq.com/tags": "team-a," + longTag},
+			want:        []string{"team-a"},
+			wantWarning: true,
+		},
+		{
+			name:        "tags with invalid characters are dropped",
+			anns:        map[string]string{"konghq.com/tags": "team/a,prod"},
+			want:        []string{"prod"},
+			wantWarning: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "cred",
+					Namespace:   "default",
+					Labels:      tt.labels,
+					Annotations: tt.anns,
+				},
+			}
+			assert.Equal(t, tt.want, credentialTagsFromSecret(log, secret))
+			assert.Equal(t, tt.wantWarning, strings.Contains(buf.String(), "invalid credential tag"))
+		})
+	}
+}
+
+func Test_FillConsumersAndCredentials_Tags(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "fooCredSecret",
+					Namespace: "default",
+					Annotations: map[string]string{
+						"konghq.com/tags": "team-a,prod",
+					},
+				},
+				Data: map[string][]byte{
+					"kongCredType": []byte("key-auth"),
+					"key":          []byte("whatever"),
+				},
+			},
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "default",
+				},
+				Username:    "foo",
+				Credentials: []string{"fooCredSecret"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{}
+	require.NoError(t, state.FillConsumersAndCredentials(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{}))
+	require.Len(t, state.Consumers, 1)
+	require.Len(t, state.Consumers[0].KeyAuths, 1)
+	assert.Equal(t, kong.StringSlice("prod", "team-a"), state.Consumers[0].KeyAuths[0].Tags)
+}
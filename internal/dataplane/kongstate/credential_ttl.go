@@ -0,0 +1,73 @@
+package kongstate
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+)
+
+// credentialTTLFeatures holds the credential types supporting a time to live,
+// along with the feature the support depends on.
+var credentialTTLFeatures = map[string]FeatureName{
+	"key-auth":           FeatureKeyAuthTTL,
+	"keyauth_credential": FeatureKeyAuthTTL,
+}
+
+// parseCredentialTTL parses a credential time to live, given either as a number of seconds
+// or as a duration such as "24h", into a number of seconds.
+func parseCredentialTTL(value string) (int, error) {
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		d, durationErr := time.ParseDuration(value)
+		if durationErr != nil {
+			return 0, fmt.Errorf("expected a number of seconds or a duration: %w", durationErr)
+		}
+		if d%time.Second != 0 {
+			return 0, fmt.Errorf("duration %s is not a whole number of seconds", d)
+		}
+		seconds = int(d / time.Second)
+	}
+	if seconds <= 0 {
+		return 0, fmt.Errorf("time to live must be positive")
+	}
+	return seconds, nil
+}
+
+// addCredentialTTL sets the time to live requested with the konghq.com/credential-ttl
+// annotation of a credential Secret on the credential configuration. Values which can't be
+// parsed, credential types without a time to live and Kong versions not supporting it are
+// logged and the time to live is ignored. A ttl key set in the Secret data takes precedence.
+func (ks *KongState) addCredentialTTL(
+	log logrus.FieldLogger,
+	credConfig map[string]interface{},
+	credType, ttl string,
+) {
+	if ttl == "" {
+		return
+	}
+	log = log.WithField("credential_ttl", ttl)
+	feature, ok := credentialTTLFeatures[credType]
+	if !ok {
+		log.Warnf("credential type %s has no time to live, ignoring %s annotation",
+			credType, annotations.AnnotationPrefix+annotations.CredentialTTLKey)
+		return
+	}
+	if !ks.SupportsFeature(feature) {
+		log.Warnf("%s credential time to live requires Kong %s or newer, ignoring it",
+			credType, featureMinVersions[feature])
+		return
+	}
+	seconds, err := parseCredentialTTL(ttl)
+	if err != nil {
+		log.WithError(err).Warn("invalid credential time to live, ignoring it")
+		return
+	}
+	if _, ok := credConfig["ttl"]; ok {
+		return
+	}
+	credConfig["ttl"] = seconds
+}
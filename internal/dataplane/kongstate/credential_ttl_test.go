@@ -0,0 +1,95 @@
+package kongstate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+func Test_parseCredentialTTL(t *testing.T) {
+	for value, want := range map[string]int{"3600": 3600, "24h": 86400, "90s": 90} {
+		got, err := parseCredentialTTL(value)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, value)
+	}
+	for _, value := range []string{"0", "-5", "1.5s", "tomorrow"} {
+		_, err := parseCredentialTTL(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_FillConsumersAndCredentials_CredentialTTL(t *testing.T) {
+	newStore := func(t *testing.T, credType string, data map[string][]byte) store.Storer {
+		data["kongCredType"] = []byte(credType)
+		s, err := store.NewFakeStore(store.FakeObjects{
+			KongConsumers: []*configurationv1.KongConsumer{
+				{
+					ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+					Username:    "foo",
+					Credentials: []string{"cred"},
+				},
+			},
+			Secrets: []*corev1.Secret{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "cred",
+						Namespace:   "default",
+						Annotations: map[string]string{"konghq.com/credential-ttl": "1h"},
+					},
+					Data: data,
+				},
+			},
+		})
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("key-auth credentials get the time to live", func(t *testing.T) {
+		state := KongState{Version: semver.MustParse("3.0.0")}
+		require.NoError(t, state.FillConsumersAndCredentials(logrus.New(), newStore(t, "key-auth", map[string][]byte{
+			"key": []byte("little-rabbits-be-good"),
+		}), nil, nil, nil, ConsumerFillOptions{}))
+		require.Len(t, state.Consumers, 1)
+		require.Len(t, state.Consumers[0].KeyAuths, 1)
+		require.NotNil(t, state.Consumers[0].KeyAuths[0].TTL)
+		assert.Equal(t, 3600, *state.Consumers[0].KeyAuths[0].TTL)
+	})
+
+	t.Run("the time to live is ignored on older Kong versions", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log := logrus.New()
+		log.SetOutput(buf)
+
+		state := KongState{Version: semver.MustParse("2.3.0")}
+		require.NoError(t, state.FillConsumersAndCredentials(log, newStore(t, "key-auth", map[string][]byte{
+			"key": []byte("little-rabbits-be-good"),
+		}), nil, nil, nil, ConsumerFillOptions{}))
+		require.Len(t, state.Consumers[0].KeyAuths, 1)
+		assert.Nil(t, state.Consumers[0].KeyAuths[0].TTL)
+		assert.Contains(t, buf.String(), "key-auth credential time to live requires Kong 2.4.0 or newer")
+	})
+
+	t.Run("credential types without a time to live ignore it", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log := logrus.New()
+		log.SetOutput(buf)
+
+		state := KongState{Version: semver.MustParse("3.0.0")}
+		require.NoError(t, state.FillConsumersAndCredentials(log, newStore(t, "basic-auth", map[string][]byte{
+			"username": []byte("foo"),
+			"password": []byte("bar"),
+		}), nil, nil, nil, ConsumerFillOptions{}))
+		require.Len(t, state.Consumers, 1)
+		assert.Len(t, state.Consumers[0].BasicAuths, 1)
+		assert.Contains(t, buf.String(), "credential type basic-auth has no time to live")
+	})
+}
@@ -97,6 +97,8 @@ func NewJWTAuth(config interface{}) (*JWTAuth, error) {
 	return &res, nil
 }
 
+// NewBasicAuth decodes a basic-auth credential. The password is passed to Kong as is: Kong hashes
+// basic-auth passwords when storing them, and the credential has no field controlling the hashing.
 func NewBasicAuth(config interface{}) (*BasicAuth, error) {
 	var res BasicAuth
 	err := decodeCredential(config, &res.BasicAuth)
@@ -0,0 +1,126 @@
+package kongstate
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// DebugSnapshot is a summary of a KongState meant to be exposed on debugging endpoints. It's
+// built from the SanitizedCopy of the state and holds no credentials, certificates or plugin
+// configurations, only the names of the entities and how they relate to each other.
+type DebugSnapshot struct {
+	// Version is the Kong version the state targets, empty if it's unknown.
+	Version  string         `json:"version,omitempty"`
+	Stats    Stats          `json:"stats"`
+	Services []DebugService `json:"services"`
+	Plugins  []DebugPlugin  `json:"plugins"`
+	// Warnings holds the problems found by the last fills of the state.
+	Warnings []DebugWarning `json:"warnings"`
+}
+
+// DebugService is a Kong service and the names of its routes.
+type DebugService struct {
+	Name   string   `json:"name"`
+	Routes []string `json:"routes,omitempty"`
+}
+
+// DebugPlugin is a Kong plugin and the entities it's attached to, identified as in the plugin.
+// Global plugins are attached to no entity.
+type DebugPlugin struct {
+	Name          string `json:"name"`
+	Service       string `json:"service,omitempty"`
+	Route         string `json:"route,omitempty"`
+	Consumer      string `json:"consumer,omitempty"`
+	ConsumerGroup string `json:"consumer_group,omitempty"`
+}
+
+// DebugWarning is a problem found while filling the state, e.g. a credential which could not
+// be provisioned. The messages of the underlying errors are left out as they may quote the
+// values of Secrets.
+type DebugWarning struct {
+	// Kind, Namespace and Name identify the Kubernetes object the warning relates to.
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+	// Secret and CredType are set for warnings about the credentials of a KongConsumer.
+	Secret   string `json:"secret,omitempty"`
+	CredType string `json:"cred_type,omitempty"`
+}
+
+// DebugWarningUnresolvedPluginReference is the reason of the warnings about KongPlugins and
+// KongClusterPlugins referenced by Kubernetes objects which could not be translated.
+const DebugWarningUnresolvedPluginReference = "UnresolvedPluginReference"
+
+// DebugSnapshot returns a DebugSnapshot of the state. It's safe to serialize to JSON.
+func (ks *KongState) DebugSnapshot() DebugSnapshot {
+	sanitized := ks.SanitizedCopy()
+	snapshot := DebugSnapshot{
+		Stats:    sanitized.Stats(),
+		Services: make([]DebugService, 0, len(sanitized.Services)),
+		Plugins:  make([]DebugPlugin, 0, len(sanitized.Plugins)),
+		Warnings: ks.debugWarnings(),
+	}
+	if !ks.Version.Equals(semver.Version{}) {
+		snapshot.Version = ks.Version.String()
+	}
+	for _, s := range sanitized.Services {
+		service := DebugService{Name: stringValue(s.Name)}
+		for _, r := range s.Routes {
+			service.Routes = append(service.Routes, stringValue(r.Name))
+		}
+		snapshot.Services = append(snapshot.Services, service)
+	}
+	for _, p := range sanitized.Plugins {
+		plugin := DebugPlugin{
+			Name:          stringValue(p.Name),
+			ConsumerGroup: stringValue(p.ConsumerGroup),
+		}
+		if p.Service != nil {
+			plugin.Service = stringValue(p.Service.ID)
+		}
+		if p.Route != nil {
+			plugin.Route = stringValue(p.Route.ID)
+		}
+		if p.Consumer != nil {
+			plugin.Consumer = stringValue(p.Consumer.ID)
+		}
+		snapshot.Plugins = append(snapshot.Plugins, plugin)
+	}
+	return snapshot
+}
+
+// debugWarnings returns the problems found by the last fills of the state, KongConsumers sorted
+// by namespace/name first.
+func (ks *KongState) debugWarnings() []DebugWarning {
+	warnings := []DebugWarning{}
+	consumerKeys := make([]string, 0, len(ks.consumerDiagnostics))
+	for key := range ks.consumerDiagnostics {
+		consumerKeys = append(consumerKeys, key)
+	}
+	sort.Strings(consumerKeys)
+	for _, key := range consumerKeys {
+		namespace, name, _ := strings.Cut(key, "/")
+		for _, d := range ks.consumerDiagnostics[key] {
+			warnings = append(warnings, DebugWarning{
+				Kind:      "KongConsumer",
+				Namespace: namespace,
+				Name:      name,
+				Reason:    string(d.Reason),
+				Secret:    d.SecretName,
+				CredType:  d.CredType,
+			})
+		}
+	}
+	for _, ref := range ks.unresolvedPlugins {
+		warnings = append(warnings, DebugWarning{
+			Kind:      "KongPlugin",
+			Namespace: ref.Namespace,
+			Name:      ref.Name,
+			Reason:    DebugWarningUnresolvedPluginReference,
+		})
+	}
+	return warnings
+}
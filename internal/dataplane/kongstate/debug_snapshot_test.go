@@ -0,0 +1,116 @@
+package kongstate
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+func TestKongState_DebugSnapshot(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "alice-key", Namespace: "default"},
+				Data:       map[string][]byte{"kongCredType": []byte("key-auth"), "key": []byte("very-secret-key")},
+			},
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "alice", Namespace: "default"},
+				Username:    "alice",
+				Credentials: []string{"alice-key", "missing"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{
+		Services: []Service{{
+			Service: kong.Service{Name: kong.String("default.foo.80")},
+			Routes: []Route{
+				{Route: kong.Route{Name: kong.String("default.foo.00")}},
+				{Route: kong.Route{Name: kong.String("default.foo.01")}},
+			},
+		}},
+		Plugins: []Plugin{
+			{Plugin: kong.Plugin{
+				Name:   kong.String("openid-connect"),
+				Route:  &kong.Route{ID: kong.String("default.foo.00")},
+				Config: kong.Configuration{"client_secret": "very-secret-client-secret"},
+			}},
+			{Plugin: kong.Plugin{Name: kong.String("prometheus")}},
+		},
+		Version: semver.MustParse("2.8.0"),
+		unresolvedPlugins: []UnresolvedPluginReference{
+			{Namespace: "default", Name: "missing-plugin", Err: errors.New("not found")},
+		},
+	}
+	require.Error(t, state.FillConsumersAndCredentials(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{}))
+
+	snapshot := state.DebugSnapshot()
+	assert.Equal(t, DebugSnapshot{
+		Version: "2.8.0",
+		Stats: Stats{
+			Services:                 1,
+			Routes:                   2,
+			Consumers:                1,
+			Plugins:                  2,
+			RoutePlugins:             1,
+			GlobalPlugins:            1,
+			Credentials:              1,
+			ConsumersWithCredentials: 1,
+		},
+		Services: []DebugService{
+			{Name: "default.foo.80", Routes: []string{"default.foo.00", "default.foo.01"}},
+		},
+		Plugins: []DebugPlugin{
+			{Name: "openid-connect", Route: "default.foo.00"},
+			{Name: "prometheus"},
+		},
+		Warnings: []DebugWarning{
+			{
+				Kind:      "KongConsumer",
+				Namespace: "default",
+				Name:      "alice",
+				Reason:    string(CredentialDiagnosticSecretNotFound),
+				Secret:    "missing",
+			},
+			{
+				Kind:      "KongPlugin",
+				Namespace: "default",
+				Name:      "missing-plugin",
+				Reason:    DebugWarningUnresolvedPluginReference,
+			},
+		},
+	}, snapshot)
+
+	b, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "very-secret-key")
+	assert.NotContains(t, string(b), "very-secret-client-secret")
+
+	b, err = json.Marshal((&KongState{}).DebugSnapshot())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"stats": {
+			"services": 0, "routes": 0, "upstreams": 0, "targets": 0,
+			"certificates": 0, "snis": 0, "ca_certificates": 0,
+			"consumers": 0, "consumer_groups": 0, "vaults": 0,
+			"plugins": 0, "service_plugins": 0, "route_plugins": 0, "consumer_plugins": 0,
+			"consumer_group_plugins": 0, "global_plugins": 0,
+			"credentials": 0, "consumers_with_credentials": 0
+		},
+		"services": [], "plugins": [], "warnings": []
+	}`, string(b))
+}
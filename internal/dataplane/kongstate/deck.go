@@ -0,0 +1,127 @@
+package kongstate
+
+import (
+	"fmt"
+
+	"github.com/kong/deck/file"
+	"github.com/kong/go-kong/kong"
+)
+
+// deckFormatVersion is the version of the decK file format generated by ToDeckContent.
+const deckFormatVersion = "1.1"
+
+// ToDeckContent returns the state in the decK file format, e.g. to snapshot it as a kong.yaml
+// file for review. IDs, including the ones of plugins, are kept as they are. Fields not supported
+// by the Kong version of the state are left out: plugin ordering, key-auth time to live and mTLS
+// credentials. Plugins attached to consumer groups are left out as well, as the decK file format
+// has no representation for them. Unlike deckgen.ToDeckContent, no default value is filled in and
+// entities are kept in the order of the state.
+// An error is returned for entities decK can't identify, e.g. services without a name or an ID.
+func (ks *KongState) ToDeckContent() (*file.Content, error) {
+	content := &file.Content{FormatVersion: deckFormatVersion}
+
+	for _, s := range ks.Services {
+		if s.Name == nil && s.ID == nil {
+			return nil, fmt.Errorf("service without a name or an ID")
+		}
+		service := file.FService{Service: *s.Service.DeepCopy()}
+		for _, p := range s.Plugins {
+			service.Plugins = append(service.Plugins, ks.deckPlugin(p))
+		}
+		for _, r := range s.Routes {
+			if r.Name == nil && r.ID == nil {
+				return nil, fmt.Errorf("route without a name or an ID in service %s", stringValue(s.Name))
+			}
+			route := &file.FRoute{Route: *r.Route.DeepCopy()}
+			for _, p := range r.Plugins {
+				route.Plugins = append(route.Plugins, ks.deckPlugin(p))
+			}
+			service.Routes = append(service.Routes, route)
+		}
+		content.Services = append(content.Services, service)
+	}
+
+	for _, u := range ks.Upstreams {
+		if u.Name == nil && u.ID == nil {
+			return nil, fmt.Errorf("upstream without a name or an ID")
+		}
+		upstream := file.FUpstream{Upstream: *u.Upstream.DeepCopy()}
+		for _, t := range u.Targets {
+			upstream.Targets = append(upstream.Targets, &file.FTarget{Target: *t.Target.DeepCopy()})
+		}
+		content.Upstreams = append(content.Upstreams, upstream)
+	}
+
+	for _, c := range ks.Certificates {
+		cert := file.FCertificate{
+			ID:   c.ID,
+			Cert: c.Cert,
+			Key:  c.Key,
+			Tags: c.Tags,
+		}
+		for _, sni := range c.SNIs {
+			cert.SNIs = append(cert.SNIs, kong.SNI{Name: kong.String(*sni)})
+		}
+		content.Certificates = append(content.Certificates, cert)
+	}
+	for _, c := range ks.CACertificates {
+		content.CACertificates = append(content.CACertificates, file.FCACertificate{CACertificate: *c.DeepCopy()})
+	}
+
+	for _, p := range ks.Plugins {
+		if p.ConsumerGroup != nil {
+			continue
+		}
+		content.Plugins = append(content.Plugins, *ks.deckPlugin(p.Plugin))
+	}
+
+	for _, c := range ks.Consumers {
+		if c.Username == nil && c.CustomID == nil && c.ID == nil {
+			return nil, fmt.Errorf("consumer without a username, a custom ID or an ID")
+		}
+		consumer := file.FConsumer{Consumer: *c.Consumer.DeepCopy()}
+		for _, p := range c.Plugins {
+			consumer.Plugins = append(consumer.Plugins, ks.deckPlugin(p))
+		}
+		for _, v := range c.KeyAuths {
+			keyAuth := *v.KeyAuth.DeepCopy()
+			if !ks.SupportsFeature(FeatureKeyAuthTTL) {
+				keyAuth.TTL = nil
+			}
+			consumer.KeyAuths = append(consumer.KeyAuths, &keyAuth)
+		}
+		for _, v := range c.HMACAuths {
+			consumer.HMACAuths = append(consumer.HMACAuths, v.HMACAuth.DeepCopy())
+		}
+		for _, v := range c.BasicAuths {
+			consumer.BasicAuths = append(consumer.BasicAuths, v.BasicAuth.DeepCopy())
+		}
+		for _, v := range c.JWTAuths {
+			consumer.JWTAuths = append(consumer.JWTAuths, v.JWTAuth.DeepCopy())
+		}
+		for _, v := range c.ACLGroups {
+			consumer.ACLGroups = append(consumer.ACLGroups, v.ACLGroup.DeepCopy())
+		}
+		for _, v := range c.Oauth2Creds {
+			consumer.Oauth2Creds = append(consumer.Oauth2Creds, v.Oauth2Credential.DeepCopy())
+		}
+		if ks.SupportsFeature(FeatureMTLSAuthCredentials) {
+			for _, v := range c.MTLSAuths {
+				consumer.MTLSAuths = append(consumer.MTLSAuths, v.MTLSAuth.DeepCopy())
+			}
+		}
+		content.Consumers = append(content.Consumers, consumer)
+	}
+
+	return content, nil
+}
+
+// deckPlugin returns a decK file plugin for a plugin, without the fields not supported
+// by the Kong version of the state.
+func (ks *KongState) deckPlugin(p kong.Plugin) *file.FPlugin {
+	plugin := &file.FPlugin{Plugin: *p.DeepCopy()}
+	if !ks.SupportsFeature(FeaturePluginOrdering) {
+		plugin.Ordering = nil
+	}
+	return plugin
+}
@@ -0,0 +1,102 @@
+package kongstate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/kong/deck/file"
+	"github.com/kong/go-kong/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKongState_ToDeckContent(t *testing.T) {
+	state := func(version string) KongState {
+		return KongState{
+			Version: semver.MustParse(version),
+			Services: []Service{{
+				Service: kong.Service{Name: kong.String("default.foo.80"), Host: kong.String("foo.default.80.svc")},
+				Routes: []Route{{
+					Route: kong.Route{Name: kong.String("default.foo.00"), Paths: kong.StringSlice("/foo")},
+				}},
+			}},
+			Upstreams: []Upstream{{
+				Upstream: kong.Upstream{Name: kong.String("foo.default.80.svc")},
+				Targets:  []Target{{Target: kong.Target{Target: kong.String("10.0.0.1:80")}}},
+			}},
+			Certificates: []Certificate{{
+				Certificate: kong.Certificate{
+					ID:   kong.String("cert"),
+					Cert: kong.String("cert-pem"),
+					Key:  kong.String("key-pem"),
+					SNIs: kong.StringSlice("foo.example.com"),
+				},
+			}},
+			Plugins: []Plugin{
+				{Plugin: kong.Plugin{
+					ID:       kong.String("6d5a3e8f-3a68-5c6b-9a3e-5a2f1c1d4e7b"),
+					Name:     kong.String("rate-limiting"),
+					Route:    &kong.Route{ID: kong.String("default.foo.00")},
+					Config:   kong.Configuration{"minute": float64(10)},
+					Ordering: &kong.PluginOrdering{Before: kong.PluginOrderingPhase{"access": {"key-auth"}}},
+				}},
+				{
+					Plugin:        kong.Plugin{Name: kong.String("rate-limiting")},
+					ConsumerGroup: kong.String("gold"),
+				},
+			},
+			Consumers: []Consumer{{
+				Consumer: kong.Consumer{Username: kong.String("alice")},
+				KeyAuths: []*KeyAuth{{kong.KeyAuth{Key: kong.String("secret"), TTL: kong.Int(3600)}}},
+			}},
+		}
+	}
+
+	t.Run("entities are mapped into the decK file format", func(t *testing.T) {
+		ks := state("3.0.0")
+		content, err := ks.ToDeckContent()
+		require.NoError(t, err)
+
+		// the content survives serialization, as when writing a kong.yaml file
+		b, err := json.Marshal(content)
+		require.NoError(t, err)
+		var got file.Content
+		require.NoError(t, json.Unmarshal(b, &got))
+
+		assert.Equal(t, "1.1", got.FormatVersion)
+		require.Len(t, got.Services, 1)
+		assert.Equal(t, "default.foo.80", *got.Services[0].Name)
+		require.Len(t, got.Services[0].Routes, 1)
+		assert.Equal(t, kong.StringSlice("/foo"), got.Services[0].Routes[0].Paths)
+		require.Len(t, got.Upstreams, 1)
+		require.Len(t, got.Upstreams[0].Targets, 1)
+		assert.Equal(t, "10.0.0.1:80", *got.Upstreams[0].Targets[0].Target.Target)
+		require.Len(t, got.Certificates, 1)
+		assert.Equal(t, []kong.SNI{{Name: kong.String("foo.example.com")}}, got.Certificates[0].SNIs)
+		require.Len(t, got.Plugins, 1, "consumer group plugins can't be represented")
+		assert.Equal(t, "6d5a3e8f-3a68-5c6b-9a3e-5a2f1c1d4e7b", *got.Plugins[0].ID, "plugin IDs should be kept")
+		assert.Equal(t, "default.foo.00", *got.Plugins[0].Route.ID)
+		assert.NotNil(t, got.Plugins[0].Ordering)
+		require.Len(t, got.Consumers, 1)
+		require.Len(t, got.Consumers[0].KeyAuths, 1)
+		assert.Equal(t, kong.Int(3600), got.Consumers[0].KeyAuths[0].TTL)
+
+		assert.NotNil(t, ks.Plugins[0].Ordering, "the state must not be modified")
+	})
+
+	t.Run("fields not supported by the Kong version are left out", func(t *testing.T) {
+		ks := state("2.3.2")
+		content, err := ks.ToDeckContent()
+		require.NoError(t, err)
+		assert.Nil(t, content.Plugins[0].Ordering)
+		assert.Nil(t, content.Consumers[0].KeyAuths[0].TTL)
+	})
+
+	t.Run("entities decK can't identify are rejected", func(t *testing.T) {
+		ks := state("3.0.0")
+		ks.Services[0].Name = nil
+		_, err := ks.ToDeckContent()
+		assert.Error(t, err)
+	})
+}
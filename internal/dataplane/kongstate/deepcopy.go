@@ -0,0 +1,215 @@
+package kongstate
+
+import (
+	"github.com/blang/semver/v4"
+	"github.com/kong/go-kong/kong"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+)
+
+// DeepCopy returns a copy of the state sharing no memory with it, so that either of them can
+// be modified without affecting the other. Unlike SanitizedCopy, it keeps sensitive values.
+// The plugins built for every plugin reference by the last FillPlugins or FillPluginsIncremental
+// call are shared, as they're never modified once built, and so are the problems found by the
+// last fills.
+func (ks *KongState) DeepCopy() *KongState {
+	if ks == nil {
+		return nil
+	}
+	res := &KongState{
+		Version:            deepCopyVersion(ks.Version),
+		pluginsByReference: ks.pluginsByReference,
+
+		consumerDiagnostics: ks.consumerDiagnostics,
+		unresolvedPlugins:   ks.unresolvedPlugins,
+	}
+	if ks.Services != nil {
+		res.Services = make([]Service, len(ks.Services))
+		for i := range ks.Services {
+			res.Services[i] = *ks.Services[i].DeepCopy()
+		}
+	}
+	if ks.Upstreams != nil {
+		res.Upstreams = make([]Upstream, len(ks.Upstreams))
+		for i := range ks.Upstreams {
+			res.Upstreams[i] = *ks.Upstreams[i].DeepCopy()
+		}
+	}
+	if ks.Certificates != nil {
+		res.Certificates = make([]Certificate, len(ks.Certificates))
+		for i := range ks.Certificates {
+			res.Certificates[i] = Certificate{Certificate: *ks.Certificates[i].Certificate.DeepCopy()}
+		}
+	}
+	if ks.CACertificates != nil {
+		res.CACertificates = make([]kong.CACertificate, len(ks.CACertificates))
+		for i := range ks.CACertificates {
+			res.CACertificates[i] = *ks.CACertificates[i].DeepCopy()
+		}
+	}
+	if ks.Plugins != nil {
+		res.Plugins = make([]Plugin, len(ks.Plugins))
+		for i := range ks.Plugins {
+			res.Plugins[i] = *ks.Plugins[i].DeepCopy()
+		}
+	}
+	if ks.Consumers != nil {
+		res.Consumers = make([]Consumer, len(ks.Consumers))
+		for i := range ks.Consumers {
+			res.Consumers[i] = *ks.Consumers[i].DeepCopy()
+		}
+	}
+	if ks.ConsumerGroups != nil {
+		res.ConsumerGroups = make([]ConsumerGroup, len(ks.ConsumerGroups))
+		for i := range ks.ConsumerGroups {
+			res.ConsumerGroups[i] = *ks.ConsumerGroups[i].DeepCopy()
+		}
+	}
+	res.Vaults = append([]Vault(nil), ks.Vaults...)
+	return res
+}
+
+// DeepCopy returns a copy of the service, its routes and the Kubernetes objects it was built from.
+func (s *Service) DeepCopy() *Service {
+	res := &Service{
+		Service:   *s.Service.DeepCopy(),
+		Namespace: s.Namespace,
+		Plugins:   deepCopyKongPlugins(s.Plugins),
+	}
+	if s.Routes != nil {
+		res.Routes = make([]Route, len(s.Routes))
+		for i := range s.Routes {
+			res.Routes[i] = *s.Routes[i].DeepCopy()
+		}
+	}
+	if s.Backends != nil {
+		res.Backends = make([]ServiceBackend, len(s.Backends))
+		for i, backend := range s.Backends {
+			if backend.Weight != nil {
+				weight := *backend.Weight
+				backend.Weight = &weight
+			}
+			res.Backends[i] = backend
+		}
+	}
+	if s.K8sServices != nil {
+		res.K8sServices = make(map[string]*corev1.Service, len(s.K8sServices))
+		for key, svc := range s.K8sServices {
+			res.K8sServices[key] = svc.DeepCopy()
+		}
+	}
+	if s.Parent != nil {
+		res.Parent = s.Parent.DeepCopyObject().(client.Object)
+	}
+	return res
+}
+
+// DeepCopy returns a copy of the route.
+func (r *Route) DeepCopy() *Route {
+	return &Route{
+		Route:      *r.Route.DeepCopy(),
+		Ingress:    deepCopyK8sObjectInfo(r.Ingress),
+		Plugins:    deepCopyKongPlugins(r.Plugins),
+		PluginRefs: append([]string(nil), r.PluginRefs...),
+	}
+}
+
+// DeepCopy returns a copy of the upstream, its targets and its service.
+func (u *Upstream) DeepCopy() *Upstream {
+	res := &Upstream{
+		Upstream: *u.Upstream.DeepCopy(),
+		Service:  *u.Service.DeepCopy(),
+	}
+	if u.Targets != nil {
+		res.Targets = make([]Target, len(u.Targets))
+		for i := range u.Targets {
+			res.Targets[i] = Target{Target: *u.Targets[i].Target.DeepCopy()}
+		}
+	}
+	return res
+}
+
+// DeepCopy returns a copy of the plugin.
+func (p *Plugin) DeepCopy() *Plugin {
+	res := &Plugin{
+		Plugin:           *p.Plugin.DeepCopy(),
+		configFromSource: p.configFromSource,
+	}
+	if p.ConsumerGroup != nil {
+		res.ConsumerGroup = kong.String(*p.ConsumerGroup)
+	}
+	return res
+}
+
+// DeepCopy returns a copy of the consumer, its credentials and the KongConsumer it was built from.
+func (c *Consumer) DeepCopy() *Consumer {
+	res := &Consumer{
+		Consumer:        *c.Consumer.DeepCopy(),
+		Plugins:         deepCopyKongPlugins(c.Plugins),
+		K8sKongConsumer: *c.K8sKongConsumer.DeepCopy(),
+	}
+	for _, v := range c.KeyAuths {
+		res.KeyAuths = append(res.KeyAuths, &KeyAuth{KeyAuth: *v.KeyAuth.DeepCopy()})
+	}
+	for _, v := range c.HMACAuths {
+		res.HMACAuths = append(res.HMACAuths, &HMACAuth{HMACAuth: *v.HMACAuth.DeepCopy()})
+	}
+	for _, v := range c.JWTAuths {
+		res.JWTAuths = append(res.JWTAuths, &JWTAuth{JWTAuth: *v.JWTAuth.DeepCopy()})
+	}
+	for _, v := range c.BasicAuths {
+		res.BasicAuths = append(res.BasicAuths, &BasicAuth{BasicAuth: *v.BasicAuth.DeepCopy()})
+	}
+	for _, v := range c.ACLGroups {
+		res.ACLGroups = append(res.ACLGroups, &ACLGroup{ACLGroup: *v.ACLGroup.DeepCopy()})
+	}
+	for _, v := range c.Oauth2Creds {
+		res.Oauth2Creds = append(res.Oauth2Creds, &Oauth2Credential{Oauth2Credential: *v.Oauth2Credential.DeepCopy()})
+	}
+	for _, v := range c.MTLSAuths {
+		res.MTLSAuths = append(res.MTLSAuths, &MTLSAuth{MTLSAuth: *v.MTLSAuth.DeepCopy()})
+	}
+	return res
+}
+
+// DeepCopy returns a copy of the consumer group and the KongConsumerGroup it was built from.
+func (cg *ConsumerGroup) DeepCopy() *ConsumerGroup {
+	res := &ConsumerGroup{
+		Consumers:            append([]string(nil), cg.Consumers...),
+		K8sKongConsumerGroup: *cg.K8sKongConsumerGroup.DeepCopy(),
+	}
+	if cg.Name != nil {
+		res.Name = kong.String(*cg.Name)
+	}
+	return res
+}
+
+func deepCopyKongPlugins(plugins []kong.Plugin) []kong.Plugin {
+	if plugins == nil {
+		return nil
+	}
+	res := make([]kong.Plugin, len(plugins))
+	for i := range plugins {
+		res[i] = *plugins[i].DeepCopy()
+	}
+	return res
+}
+
+func deepCopyK8sObjectInfo(info util.K8sObjectInfo) util.K8sObjectInfo {
+	if info.Annotations != nil {
+		annotations := make(map[string]string, len(info.Annotations))
+		for k, v := range info.Annotations {
+			annotations[k] = v
+		}
+		info.Annotations = annotations
+	}
+	return info
+}
+
+func deepCopyVersion(v semver.Version) semver.Version {
+	v.Pre = append([]semver.PRVersion(nil), v.Pre...)
+	v.Build = append([]string(nil), v.Build...)
+	return v
+}
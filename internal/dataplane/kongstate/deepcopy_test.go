@@ -0,0 +1,188 @@
+package kongstate
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/kong/go-kong/kong"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+	configurationv1beta1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1beta1"
+)
+
+// deepCopyTestState returns a state with every entity type, and every field
+// holding memory which could be shared between copies, set.
+func deepCopyTestState() *KongState {
+	service := Service{
+		Service:   kong.Service{Name: kong.String("default.foo.80"), Tags: kong.StringSlice("a")},
+		Namespace: "default",
+		Routes: []Route{{
+			Route: kong.Route{Name: kong.String("default.foo.00"), Paths: kong.StringSlice("/foo")},
+			Ingress: util.K8sObjectInfo{
+				Name:        "foo",
+				Namespace:   "default",
+				Annotations: map[string]string{"konghq.com/strip-path": "true"},
+			},
+			Plugins: []kong.Plugin{{Name: kong.String("cors")}},
+		}},
+		Plugins:  []kong.Plugin{{Name: kong.String("key-auth"), Config: kong.Configuration{"key_names": []interface{}{"apikey"}}}},
+		Backends: []ServiceBackend{{Name: "foo", Namespace: "default", Weight: func() *int32 { w := int32(10); return &w }()}},
+		K8sServices: map[string]*corev1.Service{
+			"default/foo": {ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Labels: map[string]string{"app": "foo"}}},
+		},
+		Parent: &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Labels: map[string]string{"app": "foo"}}},
+	}
+	return &KongState{
+		Services: []Service{service},
+		Upstreams: []Upstream{{
+			Upstream: kong.Upstream{Name: kong.String("foo.default.80.svc"), Tags: kong.StringSlice("a")},
+			Targets:  []Target{{Target: kong.Target{Target: kong.String("10.0.0.1:80"), Weight: kong.Int(100)}}},
+			Service:  service,
+		}},
+		Certificates: []Certificate{{Certificate: kong.Certificate{
+			ID:   kong.String("cert"),
+			SNIs: kong.StringSlice("foo.example.com"),
+		}}},
+		CACertificates: []kong.CACertificate{{ID: kong.String("ca"), Cert: kong.String("cert")}},
+		Plugins: []Plugin{{
+			Plugin:        kong.Plugin{Name: kong.String("rate-limiting"), Config: kong.Configuration{"minute": float64(10)}},
+			ConsumerGroup: kong.String("gold"),
+		}},
+		Consumers: []Consumer{{
+			Consumer:    kong.Consumer{Username: kong.String("alice")},
+			Plugins:     []kong.Plugin{{Name: kong.String("acl")}},
+			KeyAuths:    []*KeyAuth{{kong.KeyAuth{Key: kong.String("key")}}},
+			HMACAuths:   []*HMACAuth{{kong.HMACAuth{Username: kong.String("alice")}}},
+			JWTAuths:    []*JWTAuth{{kong.JWTAuth{Key: kong.String("jwt")}}},
+			BasicAuths:  []*BasicAuth{{kong.BasicAuth{Username: kong.String("alice"), Password: kong.String("pass")}}},
+			ACLGroups:   []*ACLGroup{{kong.ACLGroup{Group: kong.String("admins")}}},
+			Oauth2Creds: []*Oauth2Credential{{kong.Oauth2Credential{Name: kong.String("app"), RedirectURIs: kong.StringSlice("http://example.com")}}},
+			MTLSAuths:   []*MTLSAuth{{kong.MTLSAuth{SubjectName: kong.String("alice@example.com")}}},
+			K8sKongConsumer: configurationv1.KongConsumer{
+				ObjectMeta:  metav1.ObjectMeta{Name: "alice", Namespace: "default", Labels: map[string]string{"team": "a"}},
+				Credentials: []string{"alice-key"},
+			},
+		}},
+		ConsumerGroups: []ConsumerGroup{{
+			Name:      kong.String("gold"),
+			Consumers: []string{"alice"},
+			K8sKongConsumerGroup: configurationv1beta1.KongConsumerGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold", Namespace: "default", Labels: map[string]string{"tier": "gold"}},
+			},
+		}},
+		Vaults:  []Vault{{Name: "env", Prefix: "env"}},
+		Version: semver.MustParse("3.0.0-rc.1+build"),
+	}
+}
+
+func TestKongState_DeepCopy(t *testing.T) {
+	var nilState *KongState
+	assert.Nil(t, nilState.DeepCopy())
+
+	for _, tt := range []struct {
+		name   string
+		mutate func(ks *KongState)
+	}{
+		{
+			name: "services",
+			mutate: func(ks *KongState) {
+				s := &ks.Services[0]
+				*s.Name = "mutated"
+				s.Tags[0] = kong.String("mutated")
+				*s.Backends[0].Weight = 0
+				s.K8sServices["default/foo"].Labels["app"] = "mutated"
+				s.Parent.SetLabels(map[string]string{"app": "mutated"})
+				*s.Plugins[0].Name = "mutated"
+				s.Plugins[0].Config["key_names"].([]interface{})[0] = "mutated"
+			},
+		},
+		{
+			name: "routes",
+			mutate: func(ks *KongState) {
+				r := &ks.Services[0].Routes[0]
+				*r.Name = "mutated"
+				*r.Paths[0] = "/mutated"
+				r.Ingress.Annotations["konghq.com/strip-path"] = "false"
+				*r.Plugins[0].Name = "mutated"
+			},
+		},
+		{
+			name: "upstreams and targets",
+			mutate: func(ks *KongState) {
+				u := &ks.Upstreams[0]
+				*u.Name = "mutated"
+				*u.Tags[0] = "mutated"
+				*u.Targets[0].Target.Target = "mutated"
+				*u.Targets[0].Weight = 0
+				*u.Service.Name = "mutated"
+				u.Service.K8sServices["default/foo"].Name = "mutated"
+			},
+		},
+		{
+			name: "certificates",
+			mutate: func(ks *KongState) {
+				*ks.Certificates[0].ID = "mutated"
+				*ks.Certificates[0].SNIs[0] = "mutated"
+				*ks.CACertificates[0].Cert = "mutated"
+			},
+		},
+		{
+			name: "plugins",
+			mutate: func(ks *KongState) {
+				p := &ks.Plugins[0]
+				*p.Name = "mutated"
+				p.Config["minute"] = float64(0)
+				*p.ConsumerGroup = "mutated"
+			},
+		},
+		{
+			name: "consumers and credentials",
+			mutate: func(ks *KongState) {
+				c := &ks.Consumers[0]
+				*c.Username = "mutated"
+				*c.Plugins[0].Name = "mutated"
+				*c.KeyAuths[0].Key = "mutated"
+				*c.HMACAuths[0].Username = "mutated"
+				*c.JWTAuths[0].Key = "mutated"
+				*c.BasicAuths[0].Password = "mutated"
+				*c.ACLGroups[0].Group = "mutated"
+				*c.Oauth2Creds[0].RedirectURIs[0] = "mutated"
+				*c.MTLSAuths[0].SubjectName = "mutated"
+				c.K8sKongConsumer.Labels["team"] = "mutated"
+				c.K8sKongConsumer.Credentials[0] = "mutated"
+			},
+		},
+		{
+			name: "consumer groups",
+			mutate: func(ks *KongState) {
+				cg := &ks.ConsumerGroups[0]
+				*cg.Name = "mutated"
+				cg.Consumers[0] = "mutated"
+				cg.K8sKongConsumerGroup.Labels["tier"] = "mutated"
+			},
+		},
+		{
+			name: "vaults and version",
+			mutate: func(ks *KongState) {
+				ks.Vaults[0].Prefix = "mutated"
+				ks.Version.Pre[0].VersionStr = "mutated"
+				ks.Version.Build[0] = "mutated"
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			original := deepCopyTestState()
+			copied := original.DeepCopy()
+			assert.Equal(t, deepCopyTestState(), copied, "the copy should be equal to the original")
+
+			tt.mutate(copied)
+			assert.Equal(t, deepCopyTestState(), original, "mutating the copy should not change the original")
+			assert.NotEqual(t, original, copied)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package kongstate
+
+import (
+	"github.com/blang/semver/v4"
+)
+
+// FeatureName identifies a Kong feature whose availability depends on the Kong version.
+type FeatureName string
+
+const (
+	// FeatureMTLSAuthCredentials is the support of mtls-auth consumer credentials.
+	FeatureMTLSAuthCredentials FeatureName = "MTLSAuthCredentials"
+	// FeaturePluginOrdering is the support of dynamic plugin ordering.
+	FeaturePluginOrdering FeatureName = "PluginOrdering"
+	// FeatureKeyAuthTTL is the support of key-auth credentials with a time to live.
+	FeatureKeyAuthTTL FeatureName = "KeyAuthTTL"
+	// FeatureConsumerGroupPlugins is the support of plugins scoped to consumer groups.
+	FeatureConsumerGroupPlugins FeatureName = "ConsumerGroupPlugins"
+	// FeatureRoutePathHandling is the support of the path_handling setting of routes.
+	FeatureRoutePathHandling FeatureName = "RoutePathHandling"
+	// FeatureRouteBuffering is the support of the request_buffering and response_buffering
+	// settings of routes.
+	FeatureRouteBuffering FeatureName = "RouteBuffering"
+	// FeatureUpstreamHealthcheckThreshold is the support of the healthchecks.threshold setting
+	// of upstreams.
+	FeatureUpstreamHealthcheckThreshold FeatureName = "UpstreamHealthcheckThreshold"
+)
+
+// featureMinVersions holds the lowest Kong version supporting each feature.
+var featureMinVersions = map[FeatureName]semver.Version{
+	FeatureMTLSAuthCredentials:          semver.MustParse("2.3.2"),
+	FeaturePluginOrdering:               semver.MustParse("3.0.0"),
+	FeatureKeyAuthTTL:                   semver.MustParse("2.4.0"),
+	FeatureConsumerGroupPlugins:         semver.MustParse("3.4.0"),
+	FeatureRoutePathHandling:            semver.MustParse("2.0.0"),
+	FeatureRouteBuffering:               semver.MustParse("2.3.0"),
+	FeatureUpstreamHealthcheckThreshold: semver.MustParse("2.0.0"),
+}
+
+// SupportsFeature reports whether the Kong version of the state supports a feature.
+func (ks *KongState) SupportsFeature(feature FeatureName) bool {
+	return versionSupportsFeature(ks.Version, feature)
+}
+
+// versionSupportsFeature reports whether a Kong version supports a feature.
+// Unknown features are never supported.
+func versionSupportsFeature(version semver.Version, feature FeatureName) bool {
+	minVersion, ok := featureMinVersions[feature]
+	if !ok {
+		return false
+	}
+	return version.GTE(minVersion)
+}
@@ -0,0 +1,65 @@
+package kongstate
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKongState_SupportsFeature(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		version string
+		feature FeatureName
+		want    bool
+	}{
+		{
+			name:    "plugin ordering below the minimum version",
+			version: "2.8.2",
+			feature: FeaturePluginOrdering,
+			want:    false,
+		},
+		{
+			name:    "plugin ordering at the minimum version",
+			version: "3.0.0",
+			feature: FeaturePluginOrdering,
+			want:    true,
+		},
+		{
+			name:    "plugin ordering above the minimum version",
+			version: "3.1.0",
+			feature: FeaturePluginOrdering,
+			want:    true,
+		},
+		{
+			name:    "mtls-auth credentials below the minimum version",
+			version: "2.3.1",
+			feature: FeatureMTLSAuthCredentials,
+			want:    false,
+		},
+		{
+			name:    "mtls-auth credentials at the minimum version",
+			version: "2.3.2",
+			feature: FeatureMTLSAuthCredentials,
+			want:    true,
+		},
+		{
+			name:    "unknown version",
+			version: "0.0.0",
+			feature: FeatureMTLSAuthCredentials,
+			want:    false,
+		},
+		{
+			name:    "unknown feature",
+			version: "3.0.0",
+			feature: FeatureName("Unknown"),
+			want:    false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := KongState{Version: semver.MustParse(tt.version)}
+			assert.Equal(t, tt.want, ks.SupportsFeature(tt.feature))
+		})
+	}
+}
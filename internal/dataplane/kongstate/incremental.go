@@ -0,0 +1,169 @@
+package kongstate
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+)
+
+// Kinds of the objects whose changes are tracked by FillPluginsIncremental.
+const (
+	ObjectKindKongPlugin        = "KongPlugin"
+	ObjectKindKongClusterPlugin = "KongClusterPlugin"
+	ObjectKindSecret            = "Secret"
+	ObjectKindConfigMap         = "ConfigMap"
+)
+
+// ObjectKey identifies a Kubernetes object which changed since the previous state was built.
+type ObjectKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// FillPluginsIncremental works like FillPlugins, but reuses the plugins prev built for plugin
+// references which are not affected by the changed objects. A plugin reference is affected if
+// the services, routes and consumers referencing or disabling it differ from prev, if the
+// KongPlugin or KongClusterPlugin it may resolve to changed, or if a Secret or a ConfigMap
+// changed in the namespace of the KongPlugin it resolved to, or anywhere for KongClusterPlugins
+// and unresolved references.
+// Changes of objects of any other kind can only affect plugins through their relations, which
+// are always recomputed, and so are global plugins.
+//
+// The resource versions of the Secrets and ConfigMaps plugin configurations were read from are
+// also compared with the store, so that plugins aren't reused with the configuration of a rotated
+// Secret.
+//
+// Provided that changed lists all the KongPlugins, KongClusterPlugins, Secrets and ConfigMaps
+// which changed since prev was filled with the same options, the result is the same as the
+// result of FillPlugins. If prev is nil or wasn't filled with FillPlugins or FillPluginsIncremental,
+// all plugins are built.
+func (ks *KongState) FillPluginsIncremental(
+	log logrus.FieldLogger,
+	s store.Storer,
+	warned *WarnedSet,
+	schemas PluginSchemaGetter,
+	isolateNamespaces bool,
+	globalPluginSelector labels.Selector,
+	prev *KongState,
+	changed ...ObjectKey,
+) PluginsSummary {
+	if prev == nil || prev.pluginsByReference == nil {
+		return ks.FillPlugins(log, s, warned, schemas, isolateNamespaces, globalPluginSelector)
+	}
+
+	changedPlugins := make(map[kongPluginReference]struct{})
+	changedClusterPlugins := make(map[string]struct{})
+	changedSecretNamespaces := make(map[string]struct{})
+	for _, key := range changed {
+		switch key.Kind {
+		case ObjectKindKongPlugin:
+			changedPlugins[kongPluginReference{Namespace: key.Namespace, Name: key.Name}] = struct{}{}
+		case ObjectKindKongClusterPlugin:
+			changedClusterPlugins[key.Name] = struct{}{}
+		case ObjectKindSecret, ObjectKindConfigMap:
+			changedSecretNamespaces[key.Namespace] = struct{}{}
+		}
+	}
+
+	reuse := func(pluginRef kongPluginReference, relations, disabled util.ForeignRelations) (referencedPlugins, bool) {
+		built, ok := prev.pluginsByReference[pluginRef]
+		if !ok || !reflect.DeepEqual(built.relations, relations) || !reflect.DeepEqual(built.disabled, disabled) {
+			return referencedPlugins{}, false
+		}
+		if _, ok := changedPlugins[pluginRef]; ok {
+			return referencedPlugins{}, false
+		}
+		if _, ok := changedClusterPlugins[pluginRef.Name]; ok {
+			return referencedPlugins{}, false
+		}
+		if built.namespace == "" && len(changedSecretNamespaces) > 0 {
+			return referencedPlugins{}, false
+		}
+		if _, ok := changedSecretNamespaces[built.namespace]; ok {
+			return referencedPlugins{}, false
+		}
+		// Secrets updated since prev was built invalidate it, even if they're not reported
+		for key, version := range built.secretVersions {
+			namespace, name, _ := strings.Cut(key, "/")
+			if secretVersion(s, namespace, name) != version {
+				return referencedPlugins{}, false
+			}
+		}
+		for key, version := range built.configMapVersions {
+			namespace, name, _ := strings.Cut(key, "/")
+			if configMapVersion(s, namespace, name) != version {
+				return referencedPlugins{}, false
+			}
+		}
+		return built, true
+	}
+
+	pluginRels := ks.getPluginRelations(log)
+	ks.addNamespaceWidePluginRelations(log, s, warned, pluginRels)
+	var unresolved []UnresolvedPluginReference
+	ks.Plugins, unresolved, ks.pluginsByReference = buildPluginsReusing(log, newLookupCache(s),
+		pluginRels, ks.getDisabledPluginAttachments(), warned, isolateNamespaces,
+		globalPluginSelector, reuse)
+	return ks.finishPlugins(log, schemas, unresolved)
+}
+
+// secretVersionRecorder wraps a store.Storer to record the resource versions of the Secrets
+// and ConfigMaps it serves, keyed by namespace/name.
+type secretVersionRecorder struct {
+	store.Storer
+	versions          map[string]string
+	configMapVersions map[string]string
+}
+
+// GetSecret returns the 'name' Secret resource in namespace.
+func (r *secretVersionRecorder) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	secret, err := r.Storer.GetSecret(namespace, name)
+	if r.versions == nil {
+		r.versions = make(map[string]string)
+	}
+	r.versions[namespace+"/"+name] = ""
+	if err == nil {
+		r.versions[namespace+"/"+name] = secret.ResourceVersion
+	}
+	return secret, err
+}
+
+// GetConfigMap returns the 'name' ConfigMap resource in namespace.
+func (r *secretVersionRecorder) GetConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	configMap, err := r.Storer.GetConfigMap(namespace, name)
+	if r.configMapVersions == nil {
+		r.configMapVersions = make(map[string]string)
+	}
+	r.configMapVersions[namespace+"/"+name] = ""
+	if err == nil {
+		r.configMapVersions[namespace+"/"+name] = configMap.ResourceVersion
+	}
+	return configMap, err
+}
+
+// secretVersion returns the resource version of a Secret, or an empty string
+// if it can't be read.
+func secretVersion(s store.Storer, namespace, name string) string {
+	secret, err := s.GetSecret(namespace, name)
+	if err != nil {
+		return ""
+	}
+	return secret.ResourceVersion
+}
+
+// configMapVersion returns the resource version of a ConfigMap, or an empty string
+// if it can't be read.
+func configMapVersion(s store.Storer, namespace, name string) string {
+	configMap, err := s.GetConfigMap(namespace, name)
+	if err != nil {
+		return ""
+	}
+	return configMap.ResourceVersion
+}
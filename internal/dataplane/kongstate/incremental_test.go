@@ -0,0 +1,178 @@
+package kongstate
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+// pluginWorld holds the objects plugins are built from in TestKongState_FillPluginsIncremental.
+type pluginWorld struct {
+	// plugins holds the configuration of KongPlugins keyed by namespace/name.
+	plugins map[string]int
+	// clusterPlugins holds the configuration of KongClusterPlugins keyed by name.
+	clusterPlugins map[string]int
+	// routePlugins holds the plugins annotation of routes keyed by namespace/name.
+	routePlugins map[string]string
+}
+
+func (w pluginWorld) copy() pluginWorld {
+	res := pluginWorld{plugins: map[string]int{}, clusterPlugins: map[string]int{}, routePlugins: map[string]string{}}
+	for k, v := range w.plugins {
+		res.plugins[k] = v
+	}
+	for k, v := range w.clusterPlugins {
+		res.clusterPlugins[k] = v
+	}
+	for k, v := range w.routePlugins {
+		res.routePlugins[k] = v
+	}
+	return res
+}
+
+func (w pluginWorld) store(t *testing.T) store.Storer {
+	var objects store.FakeObjects
+	for key, value := range w.plugins {
+		namespace, name, _ := strings.Cut(key, "/")
+		objects.KongPlugins = append(objects.KongPlugins, &configurationv1.KongPlugin{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			PluginName: "plugin-" + name,
+			Config:     apiextensionsv1.JSON{Raw: []byte(fmt.Sprintf(`{"value":%d}`, value))},
+		})
+	}
+	for name, value := range w.clusterPlugins {
+		labels := map[string]string{}
+		if name == "global" {
+			labels["global"] = "true"
+		}
+		objects.KongClusterPlugins = append(objects.KongClusterPlugins, &configurationv1.KongClusterPlugin{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      labels,
+				Annotations: map[string]string{annotations.IngressClassKey: annotations.DefaultIngressClass},
+			},
+			PluginName: "plugin-" + name,
+			Config:     apiextensionsv1.JSON{Raw: []byte(fmt.Sprintf(`{"value":%d}`, value))},
+		})
+	}
+	s, err := store.NewFakeStore(objects)
+	require.NoError(t, err)
+	return s
+}
+
+func (w pluginWorld) state() KongState {
+	var state KongState
+	for _, namespace := range []string{"ns1", "ns2"} {
+		service := Service{
+			Service:   kong.Service{Name: kong.String(namespace + ".svc.80")},
+			Namespace: namespace,
+		}
+		for _, route := range []string{"r1", "r2", "r3"} {
+			service.Routes = append(service.Routes, Route{
+				Route: kong.Route{Name: kong.String(namespace + "." + route)},
+				Ingress: util.K8sObjectInfo{
+					Name:      route,
+					Namespace: namespace,
+					Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.PluginsKey: w.routePlugins[namespace+"/"+route],
+					},
+				},
+			})
+		}
+		state.Services = append(state.Services, service)
+	}
+	return state
+}
+
+func TestKongState_FillPluginsIncremental(t *testing.T) {
+	pluginNames := []string{"a", "b", "c", "shared", "missing"}
+	world := pluginWorld{
+		plugins:        map[string]int{"ns1/a": 0, "ns1/b": 0, "ns2/a": 0, "ns2/c": 0},
+		clusterPlugins: map[string]int{"shared": 0, "global": 0},
+		routePlugins:   map[string]string{},
+	}
+	rng := rand.New(rand.NewSource(42)) //nolint:gosec
+	randomPlugins := func() string {
+		var names []string
+		for _, name := range pluginNames {
+			if rng.Intn(3) == 0 {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ",")
+	}
+	for _, namespace := range []string{"ns1", "ns2"} {
+		for _, route := range []string{"r1", "r2", "r3"} {
+			world.routePlugins[namespace+"/"+route] = randomPlugins()
+		}
+	}
+
+	prev := world.state()
+	prev.FillPlugins(logrus.New(), world.store(t), nil, nil, false, nil)
+
+	for i := 0; i < 100; i++ {
+		next := world.copy()
+		var changed []ObjectKey
+		for j := rng.Intn(4); j >= 0; j-- {
+			namespace := []string{"ns1", "ns2"}[rng.Intn(2)]
+			name := pluginNames[rng.Intn(len(pluginNames))]
+			switch rng.Intn(4) {
+			case 0: // update or create a KongPlugin
+				next.plugins[namespace+"/"+name] = rng.Intn(100)
+				changed = append(changed, ObjectKey{Kind: ObjectKindKongPlugin, Namespace: namespace, Name: name})
+			case 1: // delete a KongPlugin
+				delete(next.plugins, namespace+"/"+name)
+				changed = append(changed, ObjectKey{Kind: ObjectKindKongPlugin, Namespace: namespace, Name: name})
+			case 2: // update a KongClusterPlugin
+				name = []string{"shared", "global"}[rng.Intn(2)]
+				next.clusterPlugins[name] = rng.Intn(100)
+				changed = append(changed, ObjectKey{Kind: ObjectKindKongClusterPlugin, Name: name})
+			case 3: // change the plugins attached to a route
+				next.routePlugins[namespace+"/"+[]string{"r1", "r2", "r3"}[rng.Intn(3)]] = randomPlugins()
+			}
+		}
+		s := next.store(t)
+
+		full := next.state()
+		fullSummary := full.FillPlugins(logrus.New(), s, nil, nil, false, nil)
+		incremental := next.state()
+		incrementalSummary := incremental.FillPluginsIncremental(logrus.New(), s, nil, nil, false, nil, &prev, changed...)
+
+		require.Equal(t, full.Plugins, incremental.Plugins, "iteration %d, changes %v", i, changed)
+		require.Equal(t, fullSummary, incrementalSummary, "iteration %d, changes %v", i, changed)
+
+		world, prev = next, incremental
+	}
+}
+
+func TestKongState_FillPluginsIncremental_ReusesUnaffectedPlugins(t *testing.T) {
+	world := pluginWorld{
+		plugins:      map[string]int{"ns1/a": 1, "ns1/b": 1},
+		routePlugins: map[string]string{"ns1/r1": "a", "ns1/r2": "b"},
+	}
+	prev := world.state()
+	prev.FillPlugins(logrus.New(), world.store(t), nil, nil, false, nil)
+
+	// the store is updated, but only ns1/b is reported as changed
+	world.plugins = map[string]int{"ns1/a": 2, "ns1/b": 2}
+	state := world.state()
+	state.FillPluginsIncremental(logrus.New(), world.store(t), nil, nil, false, nil, &prev,
+		ObjectKey{Kind: ObjectKindKongPlugin, Namespace: "ns1", Name: "b"})
+
+	require.Len(t, state.Plugins, 2)
+	assert.Equal(t, kong.Configuration{"value": float64(1)}, state.Plugins[0].Config, "ns1/a is reused")
+	assert.Equal(t, kong.Configuration{"value": float64(2)}, state.Plugins[1].Config, "ns1/b is rebuilt")
+}
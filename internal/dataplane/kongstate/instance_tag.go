@@ -0,0 +1,58 @@
+package kongstate
+
+import (
+	"fmt"
+
+	"github.com/kong/go-kong/kong"
+)
+
+// ValidateInstanceTag returns an error if Kong would reject tag, the tag identifying the
+// entities generated by a controller instance.
+func ValidateInstanceTag(tag string) error {
+	if tag == "" || !isValidTag(tag) {
+		return fmt.Errorf("invalid tag %q: tags must be 1 to %d printable characters, excluding ',' and '/'",
+			tag, maxTagLength)
+	}
+	return nil
+}
+
+// AddInstanceTag adds tag to the services, routes, upstreams, plugins, consumers, certificates and
+// CA certificates of the state, so that the entities generated by a controller instance can be told
+// apart from the entities of other instances sharing the same Kong. Entities already tagged with
+// it are left unchanged.
+func (ks *KongState) AddInstanceTag(tag string) {
+	for i := range ks.Services {
+		ks.Services[i].Tags = withTag(ks.Services[i].Tags, tag)
+		for j := range ks.Services[i].Routes {
+			ks.Services[i].Routes[j].Tags = withTag(ks.Services[i].Routes[j].Tags, tag)
+		}
+	}
+	for i := range ks.Upstreams {
+		ks.Upstreams[i].Tags = withTag(ks.Upstreams[i].Tags, tag)
+	}
+	for i := range ks.Plugins {
+		ks.Plugins[i].Tags = withTag(ks.Plugins[i].Tags, tag)
+	}
+	for i := range ks.Consumers {
+		ks.Consumers[i].Tags = withTag(ks.Consumers[i].Tags, tag)
+	}
+	for i := range ks.Certificates {
+		ks.Certificates[i].Tags = withTag(ks.Certificates[i].Tags, tag)
+	}
+	for i := range ks.CACertificates {
+		ks.CACertificates[i].Tags = withTag(ks.CACertificates[i].Tags, tag)
+	}
+}
+
+// withTag returns tags with tag appended, unless it's already part of them.
+// The returned slice doesn't share memory with tags, as tags may be shared between entities.
+func withTag(tags []*string, tag string) []*string {
+	for _, t := range tags {
+		if t != nil && *t == tag {
+			return tags
+		}
+	}
+	res := make([]*string, 0, len(tags)+1)
+	res = append(res, tags...)
+	return append(res, kong.String(tag))
+}
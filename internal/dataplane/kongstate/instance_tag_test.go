@@ -0,0 +1,49 @@
+package kongstate
+
+import (
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKongState_AddInstanceTag(t *testing.T) {
+	sharedTags := kong.StringSlice("shared")
+	state := KongState{
+		Services: []Service{{
+			Service: kong.Service{Name: kong.String("foo"), Tags: sharedTags},
+			Routes: []Route{
+				{Route: kong.Route{Name: kong.String("foo-1"), Tags: sharedTags}},
+				{Route: kong.Route{Name: kong.String("foo-2"), Tags: kong.StringSlice("managed-by:instance-a")}},
+			},
+		}},
+		Upstreams:      []Upstream{{Upstream: kong.Upstream{Name: kong.String("foo")}}},
+		Plugins:        []Plugin{{Plugin: kong.Plugin{Name: kong.String("cors")}}},
+		Consumers:      []Consumer{{Consumer: kong.Consumer{Username: kong.String("alice")}}},
+		Certificates:   []Certificate{{Certificate: kong.Certificate{ID: kong.String("cert")}}},
+		CACertificates: []kong.CACertificate{{ID: kong.String("ca")}},
+	}
+
+	state.AddInstanceTag("managed-by:instance-a")
+	state.AddInstanceTag("managed-by:instance-a")
+
+	want := kong.StringSlice("shared", "managed-by:instance-a")
+	assert.Equal(t, want, state.Services[0].Tags, "service")
+	assert.Equal(t, want, state.Services[0].Routes[0].Tags, "route")
+	assert.Equal(t, kong.StringSlice("managed-by:instance-a"), state.Services[0].Routes[1].Tags,
+		"tags should not be duplicated")
+	assert.Equal(t, kong.StringSlice("managed-by:instance-a"), state.Upstreams[0].Tags, "upstream")
+	assert.Equal(t, kong.StringSlice("managed-by:instance-a"), state.Plugins[0].Tags, "plugin")
+	assert.Equal(t, kong.StringSlice("managed-by:instance-a"), state.Consumers[0].Tags, "consumer")
+	assert.Equal(t, kong.StringSlice("managed-by:instance-a"), state.Certificates[0].Tags, "certificate")
+	assert.Equal(t, kong.StringSlice("managed-by:instance-a"), state.CACertificates[0].Tags, "CA certificate")
+	assert.Equal(t, kong.StringSlice("shared"), sharedTags, "tags shared between entities should not be modified")
+}
+
+func TestValidateInstanceTag(t *testing.T) {
+	require.NoError(t, ValidateInstanceTag("managed-by:instance-a"))
+	assert.Error(t, ValidateInstanceTag(""))
+	assert.Error(t, ValidateInstanceTag("managed-by/instance-a"))
+	assert.Error(t, ValidateInstanceTag("a,b"))
+}
@@ -2,17 +2,26 @@ package kongstate
 
 import (
 	"fmt"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/blang/semver/v4"
 	"github.com/kong/go-kong/kong"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/validation/consumers/credentials"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
 )
 
 // KongState holds the configuration that should be applied to Kong.
@@ -23,194 +32,705 @@ type KongState struct {
 	CACertificates []kong.CACertificate
 	Plugins        []Plugin
 	Consumers      []Consumer
+	ConsumerGroups []ConsumerGroup
+	Vaults         []Vault
 	Version        semver.Version
+
+	// pluginsByReference holds the plugins built by the last FillPlugins or
+	// FillPluginsIncremental call for every plugin reference, so that they can
+	// be reused by the next FillPluginsIncremental call.
+	pluginsByReference map[kongPluginReference]referencedPlugins
+
+	// consumerDiagnostics and unresolvedPlugins hold the problems found by the last
+	// FillConsumersAndCredentials and FillPlugins calls, reported by DebugSnapshot.
+	consumerDiagnostics ConsumerDiagnostics
+	unresolvedPlugins   []UnresolvedPluginReference
 }
 
 // SanitizedCopy returns a shallow copy with sensitive values redacted best-effort.
 func (ks *KongState) SanitizedCopy() *KongState {
 	return &KongState{
-		Services:  ks.Services,
-		Upstreams: ks.Upstreams,
+		Services: ks.Services,
+		Upstreams: func() (res []Upstream) {
+			for _, v := range ks.Upstreams {
+				res = append(res, *v.SanitizedCopy())
+			}
+			return
+		}(),
 		Certificates: func() (res []Certificate) {
 			for _, v := range ks.Certificates {
 				res = append(res, *v.SanitizedCopy())
 			}
 			return
 		}(),
-		CACertificates: ks.CACertificates,
-		Plugins:        ks.Plugins,
+		CACertificates: func() (res []kong.CACertificate) {
+			for _, v := range ks.CACertificates {
+				res = append(res, sanitizedCACertificate(v, SensitiveTagPrefixes))
+			}
+			return
+		}(),
+		Plugins: func() (res []Plugin) {
+			for _, v := range ks.Plugins {
+				res = append(res, *v.SanitizedCopy(SensitivePluginConfigKeys))
+			}
+			return
+		}(),
 		Consumers: func() (res []Consumer) {
 			for _, v := range ks.Consumers {
 				res = append(res, *v.SanitizedCopy())
 			}
 			return
 		}(),
+		ConsumerGroups: ks.ConsumerGroups,
+		Vaults:         ks.Vaults,
 	}
 }
 
-func (ks *KongState) FillConsumersAndCredentials(log logrus.FieldLogger, s store.Storer) {
+// CredentialProvisionFailedReason is the reason of Kubernetes events emitted for KongConsumers
+// whose credentials could not be provisioned.
+const CredentialProvisionFailedReason = "CredentialProvisionFailed"
+
+// ConsumerIdentifierMissingReason is the reason of Kubernetes events emitted for KongConsumers
+// which are skipped as they have neither a username nor a custom ID.
+const ConsumerIdentifierMissingReason = "ConsumerIdentifierMissing"
+
+// CredentialDiagnosticReason is a short code describing why a KongConsumer credential
+// could not be provisioned.
+type CredentialDiagnosticReason string
+
+const (
+	// CredentialDiagnosticSecretNotFound means that the credential Secret could not be fetched.
+	CredentialDiagnosticSecretNotFound CredentialDiagnosticReason = "SecretNotFound"
+	// CredentialDiagnosticInvalidCredType means that the credential Secret specifies
+	// a missing or unsupported credential type.
+	CredentialDiagnosticInvalidCredType CredentialDiagnosticReason = "InvalidCredType"
+	// CredentialDiagnosticForbiddenCredType means that the credential type is not one of the
+	// credential types allowed in this cluster.
+	CredentialDiagnosticForbiddenCredType CredentialDiagnosticReason = "ForbiddenCredType"
+	// CredentialDiagnosticEmptySecret means that the credential Secret holds no credential fields.
+	CredentialDiagnosticEmptySecret CredentialDiagnosticReason = "EmptySecret"
+	// CredentialDiagnosticInvalidCredential means that the credential fields are invalid
+	// for the credential type.
+	CredentialDiagnosticInvalidCredential CredentialDiagnosticReason = "InvalidCredential"
+	// CredentialDiagnosticMissingCACertificate means that the CA certificate referenced by
+	// an mtls-auth credential is not part of the state.
+	CredentialDiagnosticMissingCACertificate CredentialDiagnosticReason = "MissingCACertificate"
+	// CredentialDiagnosticMissingRequiredFields means that the credential Secret lacks fields
+	// required by the schema of the credential type.
+	CredentialDiagnosticMissingRequiredFields CredentialDiagnosticReason = "MissingRequiredFields"
+	// CredentialDiagnosticReferenceNotGranted means that the credential Secret is in another namespace
+	// than the KongConsumer, and that no ReferencePolicy allows the KongConsumer to reference it.
+	CredentialDiagnosticReferenceNotGranted CredentialDiagnosticReason = "ReferenceNotGranted"
+)
+
+// CredentialOutcomeProvisioned is the outcome recorded in CredentialMetrics for credentials
+// which were provisioned. Credentials which were not are recorded with their CredentialDiagnosticReason.
+const CredentialOutcomeProvisioned = "Provisioned"
+
+// CredentialMetrics records the outcomes of KongConsumer credential provisioning.
+type CredentialMetrics interface {
+	RecordCredentialProvisioning(outcome, credType string)
+}
+
+// CredentialDiagnostic describes a KongConsumer credential that could not be provisioned.
+type CredentialDiagnostic struct {
+	SecretName string
+	CredType   string
+	Reason     CredentialDiagnosticReason
+	Message    string
+}
+
+// ConsumerDiagnostics holds credential provisioning diagnostics keyed by
+// the namespace/name of the KongConsumer they relate to.
+type ConsumerDiagnostics map[string][]CredentialDiagnostic
+
+// ConsumerFillOptions holds the settings of FillConsumersAndCredentials. Its zero value fills
+// the KongConsumers of all namespaces with all the supported credential types.
+type ConsumerFillOptions struct {
+	// Namespaces filters the namespaces KongConsumers are read from. nil allows all namespaces.
+	Namespaces *NamespaceFilter
+	// Selector skips KongConsumers whose labels don't match it, unless it's nil.
+	Selector labels.Selector
+	// CredTypeKey is the Secret key holding the type of a credential, kongCredType if empty.
+	// If a Secret has no such key, the type is read from its konghq.com/credential label, or
+	// from its type (e.g. konghq.com/key-auth) if it has neither.
+	CredTypeKey string
+	// DropConflictingCredentials makes only the credential of the oldest KongConsumer be kept
+	// when credentials of different KongConsumers share a value Kong requires to be unique.
+	// Such conflicts are logged either way.
+	DropConflictingCredentials bool
+	// StrictConsumerIdentifiers makes KongConsumers with neither a username nor a custom ID be
+	// reported in the error returned by the fill, on top of being skipped with a warning.
+	StrictConsumerIdentifiers bool
+	// AllowedCredTypes, if not nil, holds the only credential types which are provisioned.
+	// Credentials of other types are skipped and reported like the other credentials which
+	// can't be provisioned, even if they're supported, so that clusters can forbid some
+	// credential types.
+	AllowedCredTypes sets.String
+	// Warned, if not nil, keeps the Warning events about a KongConsumer from being emitted
+	// again by every fill, as long as the failure they report doesn't change. It should
+	// outlive the fill.
+	Warned *WarnedSet
+}
+
+// FillConsumersAndCredentials populates the state with KongConsumers and the credentials
+// referenced by them, according to opts. If schemas is not nil, it's used to determine the types
+// of credential fields, and credentials lacking fields the schema of their type requires are
+// skipped. Secret keys with an empty value set their field explicitly, so they count as present.
+// If recorder is not nil, a Warning event is emitted on the KongConsumer for every credential
+// that fails to be provisioned, unless opts.Warned holds the same failure. If credMetrics is not
+// nil, the outcome of every credential is recorded in it.
+// KongConsumers with neither a username nor a custom ID can't be configured in Kong and are
+// skipped with a warning, and a Warning event if recorder is not nil and opts.Warned doesn't
+// hold it already.
+// mtls-auth credentials referencing a CA certificate which is not in ks.CACertificates are
+// skipped, so the CA certificates must be filled beforehand.
+// Credentials reference Secrets of the namespace of their KongConsumer by name. Secrets of other
+// namespaces are referenced as namespace/name, and only fetched if a ReferencePolicy of their
+// namespace allows KongConsumers of the namespace of the KongConsumer to reference them.
+// Credentials which can't be provisioned are logged and skipped, and the returned error
+// aggregates the failures, so that callers can tell a degraded result from a complete one.
+func (ks *KongState) FillConsumersAndCredentials(
+	log logrus.FieldLogger,
+	s store.Storer,
+	schemas CredentialSchemaGetter,
+	recorder record.EventRecorder,
+	credMetrics CredentialMetrics,
+	opts ConsumerFillOptions,
+) error {
+	_, err := ks.FillConsumersAndCredentialsWithDiagnostics(log, s, schemas, recorder, credMetrics, opts)
+	return err
+}
+
+// FillConsumersAndCredentialsWithDiagnostics works like FillConsumersAndCredentials and
+// additionally returns diagnostics about the credentials that could not be provisioned,
+// e.g. to be reported in the KongConsumers' status conditions.
+func (ks *KongState) FillConsumersAndCredentialsWithDiagnostics(
+	log logrus.FieldLogger,
+	s store.Storer,
+	schemas CredentialSchemaGetter,
+	recorder record.EventRecorder,
+	credMetrics CredentialMetrics,
+	opts ConsumerFillOptions,
+) (ConsumerDiagnostics, error) {
+	credTypeKey := opts.CredTypeKey
+	if credTypeKey == "" {
+		credTypeKey = credentials.TypeKey
+	}
+	if schemas != nil {
+		// every schema is fetched once for the whole fill, even if it can't be
+		schemas = newCredentialSchemaCache(schemas)
+	}
+	diagnostics := ConsumerDiagnostics{}
+	var errs []error
 	consumerIndex := make(map[string]Consumer)
+	var credentialSources []credentialSource
+	var (
+		policies       []*gatewayv1alpha2.ReferencePolicy
+		policiesListed bool
+	)
+	// ReferencePolicies are only needed for cross-namespace credentials, which are uncommon
+	listReferencePolicies := func() []*gatewayv1alpha2.ReferencePolicy {
+		if !policiesListed {
+			policiesListed = true
+			var err error
+			if policies, err = s.ListReferencePolicies(); err != nil {
+				log.WithError(err).Error("failed to list ReferencePolicies, cross-namespace credentials are not allowed")
+			}
+		}
+		return policies
+	}
 
 	// build consumer index
 	for _, consumer := range s.ListKongConsumers() {
 		var c Consumer
+		if !opts.Namespaces.Allows(consumer.Namespace) {
+			log.WithFields(failureLogFields("KongConsumer", consumer.Namespace, consumer.Name,
+				logReasonFilteredNamespace)).Debug("skipping KongConsumer from a filtered out namespace")
+			continue
+		}
+		if opts.Selector != nil && !opts.Selector.Matches(labels.Set(consumer.Labels)) {
+			log.WithFields(failureLogFields("KongConsumer", consumer.Namespace, consumer.Name,
+				logReasonSelectorMismatch)).Debug("skipping KongConsumer not matching the consumer selector")
+			continue
+		}
+		consumerKey := consumer.Namespace + "/" + consumer.Name
 		if consumer.Username == "" && consumer.CustomID == "" {
+			log.WithFields(failureLogFields("KongConsumer", consumer.Namespace, consumer.Name,
+				logReasonMissingConsumerIdentifier)).Warn("skipping KongConsumer with neither a username nor a custom ID")
+			if recorder != nil && opts.Warned.ShouldWarn(consumerIdentifierWarningKey(consumerKey), ConsumerIdentifierMissingReason) {
+				recorder.Event(consumer, corev1.EventTypeWarning, ConsumerIdentifierMissingReason,
+					"KongConsumer has neither a username nor a custom ID and can't be configured in Kong")
+			}
+			if opts.StrictConsumerIdentifiers {
+				errs = append(errs, fmt.Errorf("KongConsumer %s/%s has neither a username nor a custom ID",
+					consumer.Namespace, consumer.Name))
+			}
 			continue
 		}
+		opts.Warned.ShouldWarn(consumerIdentifierWarningKey(consumerKey), "")
 		if consumer.Username != "" {
 			c.Username = kong.String(consumer.Username)
 		}
 		if consumer.CustomID != "" {
 			c.CustomID = kong.String(consumer.CustomID)
 		}
+		c.Tags = mergeTags(log.WithFields(objectLogFields("KongConsumer", consumer.Namespace, consumer.Name)),
+			"consumer", c.Tags, consumerTags(consumer))
 		c.K8sKongConsumer = *consumer
+		reportFailure := func(secretName, credType string, reason CredentialDiagnosticReason, err error) {
+			if opts.Warned.ShouldWarn(credentialWarningKey(consumerKey, secretName), err.Error()) {
+				recordCredentialProvisionFailure(recorder, consumer, secretName, err)
+			}
+			recordCredentialOutcome(credMetrics, string(reason), credType)
+			diagnostics[consumerKey] = append(diagnostics[consumerKey], CredentialDiagnostic{
+				SecretName: secretName,
+				CredType:   credType,
+				Reason:     reason,
+				Message:    err.Error(),
+			})
+			errs = append(errs, fmt.Errorf("failed to provision credential from secret %s/%s of KongConsumer %s: %w",
+				consumer.Namespace, secretName, consumer.Name, err))
+		}
 
-		log = log.WithFields(logrus.Fields{
-			"kongconsumer_name":      consumer.Name,
-			"kongconsumer_namespace": consumer.Namespace,
-		})
+		log := log.WithFields(objectLogFields("KongConsumer", consumer.Namespace, consumer.Name))
 		for _, cred := range consumer.Credentials {
-			log = log.WithFields(logrus.Fields{
-				"secret_name":      cred,
-				"secret_namespace": consumer.Namespace,
-			})
-			secret, err := s.GetSecret(consumer.Namespace, cred)
-			if err != nil {
-				log.WithError(err).Error("failed to fetch secret")
+			log := log.WithField("secret_name", cred)
+			secretNamespace, secretName := credentialSecretReference(consumer.Namespace, cred)
+			if secretNamespace != consumer.Namespace &&
+				!credentialReferenceGranted(listReferencePolicies(), consumer.Namespace, secretNamespace, secretName) {
+				err := fmt.Errorf("reference to Secret %s/%s is not allowed by any ReferencePolicy", secretNamespace, secretName)
+				log.WithField(logFieldReason, CredentialDiagnosticReferenceNotGranted).WithError(err).
+					Error("failed to provision credential")
+				reportFailure(cred, "", CredentialDiagnosticReferenceNotGranted, err)
 				continue
 			}
-			credConfig := map[string]interface{}{}
-			for k, v := range secret.Data {
-				// TODO populate these based on schema from Kong
-				// and remove this workaround
-				if k == "redirect_uris" {
-					credConfig[k] = strings.Split(string(v), ",")
-					continue
-				}
-				// TODO this is a kongCredType-agnostic mutation that should only apply to Oauth2 credentials.
-				// However, the credential-specific code after deals only in interface{}s, and we can't fix individual
-				// keys. To handle this properly we'd need to refactor the types used in all following code.
-				if k == "hash_secret" {
-					boolVal, err := strconv.ParseBool(string(v))
-					if err != nil {
-						log.WithError(err).Errorf("failed to parse hash_secret to bool. defaulting to false")
-						credConfig[k] = false
-					} else {
-						credConfig[k] = boolVal
-					}
-					continue
-				}
-				credConfig[k] = string(v)
-			}
-			credType, ok := credConfig["kongCredType"].(string)
-			if !ok {
-				err := fmt.Errorf("invalid credType: %v", credType)
-				log.WithError(err).Errorf("failed to provision credential")
+			secret, err := s.GetSecret(secretNamespace, secretName)
+			if err != nil {
+				log.WithField(logFieldReason, CredentialDiagnosticSecretNotFound).WithError(err).Error("failed to fetch secret")
+				reportFailure(cred, "", CredentialDiagnosticSecretNotFound, err)
+				continue
 			}
+			credType := credentialType(secret, credTypeKey)
 			if !credentials.SupportedTypes.Has(credType) {
 				err := fmt.Errorf("invalid credType: %v", credType)
-				log.WithError(err).Error("failed to provision credential")
+				log.WithField(logFieldReason, CredentialDiagnosticInvalidCredType).WithError(err).
+					Error("failed to provision credential")
+				reportFailure(cred, credType, CredentialDiagnosticInvalidCredType, err)
 				continue
 			}
-			if len(credConfig) <= 1 { // 1 key of credType itself
-				log.Error("failed to provision credential: empty secret")
+			if opts.AllowedCredTypes != nil && !opts.AllowedCredTypes.Has(credType) {
+				err := fmt.Errorf("credType %s is not allowed", credType)
+				log.WithFields(logrus.Fields{
+					logFieldReason:    CredentialDiagnosticForbiddenCredType,
+					"credential_type": credType,
+				}).Warn("skipping credential of a type which is not allowed in this cluster")
+				reportFailure(cred, credType, CredentialDiagnosticForbiddenCredType, err)
 				continue
 			}
+			fieldTypes := credentialFieldTypes(log, schemas, credType)
+			credConfig := credentialConfigFromSecretData(log, credType, fieldTypes, secret.Data,
+				annotations.ExtractBinaryCredentialFields(secret.Annotations))
+			delete(credConfig, credTypeKey)
+			if len(credConfig) == 0 {
+				log.WithField(logFieldReason, CredentialDiagnosticEmptySecret).Error("failed to provision credential: empty secret")
+				reportFailure(cred, credType, CredentialDiagnosticEmptySecret, fmt.Errorf("empty secret"))
+				continue
+			}
+			if missing := missingCredentialFields(credConfig, requiredCredentialFields(schemas, credType)); len(missing) > 0 {
+				err := fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+				log.WithField(logFieldReason, CredentialDiagnosticMissingRequiredFields).WithError(err).
+					Error("failed to provision credential")
+				reportFailure(cred, credType, CredentialDiagnosticMissingRequiredFields, err)
+				continue
+			}
+			if caCertID, ok := caCertificateReference(credType, credConfig); ok && !ks.hasCACertificate(caCertID) {
+				err := fmt.Errorf("CA certificate %s does not exist", caCertID)
+				log.WithField(logFieldReason, CredentialDiagnosticMissingCACertificate).WithError(err).
+					Error("failed to provision credential")
+				reportFailure(cred, credType, CredentialDiagnosticMissingCACertificate, err)
+				continue
+			}
+			addCredentialTags(credConfig, credentialTagsFromSecret(log, secret))
+			ks.addCredentialTTL(log, credConfig, credType, annotations.ExtractCredentialTTL(secret.Annotations))
 			err = c.SetCredential(credType, credConfig)
 			if err != nil {
-				log.WithError(err).Errorf("failed to provision credential")
+				log.WithField(logFieldReason, CredentialDiagnosticInvalidCredential).WithError(err).
+					Errorf("failed to provision credential")
+				reportFailure(cred, credType, CredentialDiagnosticInvalidCredential, err)
 				continue
 			}
+			recordCredentialOutcome(credMetrics, CredentialOutcomeProvisioned, credType)
+			opts.Warned.ShouldWarn(credentialWarningKey(consumerKey, cred), "")
+			if id, ok := c.lastCredentialIdentity(credType); ok {
+				credentialSources = append(credentialSources, credentialSource{
+					consumerKey: consumerKey,
+					secretName:  cred,
+					identity:    id,
+				})
+			}
 		}
+		c.SortCredentials()
 
-		consumerIndex[consumer.Namespace+"/"+consumer.Name] = c
+		consumerIndex[consumerKey] = c
 	}
+	errs = append(errs, dropConflictingConsumers(log, consumerIndex)...)
+	handleConflictingCredentials(log, consumerIndex, credentialSources, opts.DropConflictingCredentials)
 
-	// populate the consumer in the state
-	for _, c := range consumerIndex {
-		ks.Consumers = append(ks.Consumers, c)
+	// populate the consumer in the state, sorted by namespace/name
+	// to keep the generated configuration stable between runs
+	consumerKeys := make([]string, 0, len(consumerIndex))
+	for key := range consumerIndex {
+		consumerKeys = append(consumerKeys, key)
 	}
+	sort.Strings(consumerKeys)
+	for _, key := range consumerKeys {
+		ks.Consumers = append(ks.Consumers, consumerIndex[key])
+	}
+
+	ks.consumerDiagnostics = diagnostics
+	return diagnostics, utilerrors.NewAggregate(errs)
 }
 
-func (ks *KongState) FillOverrides(log logrus.FieldLogger, s store.Storer) {
-	for i := 0; i < len(ks.Services); i++ {
-		// Services
-		kongIngress, err := getKongIngressForServices(s, ks.Services[i].K8sServices)
-		if err != nil {
-			log.WithError(err).
-				Errorf("failed to fetch KongIngress resource for Services %s",
-					PrettyPrintServiceList(ks.Services[i].K8sServices),
-				)
+// dropConflictingConsumers removes from the index the consumers whose username or custom ID
+// is already used by another consumer, which Kong would reject. The oldest consumer (by
+// creation time, then namespace/name) is kept. Every conflict is logged with all the
+// consumers involved, and an error naming the kept consumer is returned for every dropped one.
+func dropConflictingConsumers(log logrus.FieldLogger, consumerIndex map[string]Consumer) []error {
+	keys := consumerKeysByAge(consumerIndex)
+
+	type identity struct{ field, value string }
+	// consumers holds, for every identity used by a kept consumer, that consumer
+	// followed by the dropped consumers using the same identity
+	consumers := map[identity][]string{}
+	var identities []identity
+	var errs []error
+	for _, key := range keys {
+		c := consumerIndex[key]
+		var ids []identity
+		if c.Username != nil {
+			ids = append(ids, identity{field: "username", value: *c.Username})
+		}
+		if c.CustomID != nil {
+			ids = append(ids, identity{field: "custom_id", value: *c.CustomID})
+		}
+
+		var conflicts []string
+		for _, id := range ids {
+			if _, ok := consumers[id]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("%s %q with KongConsumer %s", id.field, id.value, consumers[id][0]))
+				consumers[id] = append(consumers[id], key)
+			}
+		}
+		if len(conflicts) > 0 {
+			errs = append(errs, fmt.Errorf("KongConsumer %s was dropped as it shares its %s",
+				key, strings.Join(conflicts, " and its ")))
+			delete(consumerIndex, key)
 			continue
 		}
+		for _, id := range ids {
+			consumers[id] = []string{key}
+			identities = append(identities, id)
+		}
+	}
 
-		for _, svc := range ks.Services[i].K8sServices {
-			ks.Services[i].override(log, kongIngress, svc)
+	for _, id := range identities {
+		if len(consumers[id]) < 2 {
+			continue
 		}
+		log.WithFields(logrus.Fields{
+			"field":         id.field,
+			"value":         id.value,
+			"kongconsumers": consumers[id],
+		}).Errorf("multiple KongConsumers use the same %s, only the oldest one (%s) will be applied",
+			id.field, consumers[id][0])
+	}
+	return errs
+}
 
-		// Routes
-		for j := 0; j < len(ks.Services[i].Routes); j++ {
-			kongIngress, err := getKongIngressFromObjectMeta(s, ks.Services[i].Routes[j].Ingress)
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"resource_name":      ks.Services[i].Routes[j].Ingress.Name,
-					"resource_namespace": ks.Services[i].Routes[j].Ingress.Namespace,
-				}).WithError(err).Errorf("failed to fetch KongIngress resource")
-			}
+// consumerKeysByAge returns the keys of the index from the oldest to the newest consumer,
+// consumers created at the same time being ordered by namespace/name.
+func consumerKeysByAge(consumerIndex map[string]Consumer) []string {
+	keys := make([]string, 0, len(consumerIndex))
+	for key := range consumerIndex {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ti := consumerIndex[keys[i]].K8sKongConsumer.CreationTimestamp
+		tj := consumerIndex[keys[j]].K8sKongConsumer.CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// consumerIdentifierWarningKey identifies the missing identifier warning of a KongConsumer,
+// by namespace/name, in a WarnedSet.
+func consumerIdentifierWarningKey(consumerKey string) string {
+	return "kongconsumer-identifier/" + consumerKey
+}
+
+// credentialWarningKey identifies the provisioning failure warning of a credential in a
+// WarnedSet, by the namespace/name of its KongConsumer and its Secret reference.
+func credentialWarningKey(consumerKey, secretName string) string {
+	return "kongconsumer-credential/" + consumerKey + "/" + secretName
+}
+
+// recordCredentialProvisionFailure emits a Warning event on a KongConsumer whose credential
+// from the given Secret could not be provisioned. It's a no-op if recorder is nil.
+func recordCredentialProvisionFailure(
+	recorder record.EventRecorder,
+	consumer *configurationv1.KongConsumer,
+	secretName string,
+	err error,
+) {
+	if recorder == nil {
+		return
+	}
+	recorder.Eventf(consumer, corev1.EventTypeWarning, CredentialProvisionFailedReason,
+		"failed to provision credential from secret %s/%s: %v", consumer.Namespace, secretName, err)
+}
+
+// recordCredentialOutcome records the outcome of a credential provisioning.
+// It's a no-op if credMetrics is nil.
+func recordCredentialOutcome(credMetrics CredentialMetrics, outcome, credType string) {
+	if credMetrics == nil {
+		return
+	}
+	credMetrics.RecordCredentialProvisioning(outcome, credType)
+}
+
+// FillConsumerGroups populates the state with KongConsumerGroups and associates them with
+// the consumers already present in the state, based on the consumers' konghq.com/consumer-groups
+// annotation. It must be called after the consumers are filled.
+func (ks *KongState) FillConsumerGroups(log logrus.FieldLogger, s store.Storer) {
+	groupMembers := ks.getConsumerGroupMembers()
 
-			ks.Services[i].Routes[j].override(log, kongIngress)
+	consumerGroups := s.ListKongConsumerGroups()
+	sort.SliceStable(consumerGroups, func(i, j int) bool {
+		if consumerGroups[i].Namespace != consumerGroups[j].Namespace {
+			return consumerGroups[i].Namespace < consumerGroups[j].Namespace
 		}
+		return consumerGroups[i].Name < consumerGroups[j].Name
+	})
+	for _, cg := range consumerGroups {
+		groupKey := types.NamespacedName{Namespace: cg.Namespace, Name: cg.Name}
+		ks.ConsumerGroups = append(ks.ConsumerGroups, ConsumerGroup{
+			Name:                 kong.String(cg.Name),
+			Consumers:            groupMembers[groupKey],
+			K8sKongConsumerGroup: *cg,
+		})
+		delete(groupMembers, groupKey)
 	}
 
+	for groupKey, members := range groupMembers {
+		log.WithFields(logrus.Fields{
+			"kongconsumergroup": groupKey.String(),
+			"consumers":         members,
+		}).Warn("consumers reference a KongConsumerGroup which does not exist")
+	}
+}
+
+// getConsumerGroupMembers returns the identifiers of the consumers in the state keyed by the
+// namespace and name of the KongConsumerGroups they belong to.
+func (ks *KongState) getConsumerGroupMembers() map[types.NamespacedName][]string {
+	groupMembers := map[types.NamespacedName][]string{}
+	for _, c := range ks.Consumers {
+		identifier := c.identifier()
+		if identifier == nil {
+			continue
+		}
+		for _, groupName := range annotations.ExtractConsumerGroups(c.K8sKongConsumer.GetAnnotations()) {
+			groupKey := types.NamespacedName{Namespace: c.K8sKongConsumer.Namespace, Name: groupName}
+			groupMembers[groupKey] = append(groupMembers[groupKey], *identifier)
+		}
+	}
+	return groupMembers
+}
+
+// FillOverrides applies the KongIngress and KongUpstreamPolicy overrides of the services,
+// routes and upstreams of the state. Overrides which can't be fetched are logged and skipped,
+// and the returned error aggregates the failures.
+func (ks *KongState) FillOverrides(log logrus.FieldLogger, s store.Storer) error {
+	return ks.FillOverridesWithConcurrency(log, s, 1)
+}
+
+// FillOverridesWithConcurrency works like FillOverrides, computing the overrides of up to
+// concurrency services at the same time. The overrides of every service are independent and
+// written to the service itself, so the result doesn't depend on the concurrency.
+func (ks *KongState) FillOverridesWithConcurrency(log logrus.FieldLogger, s store.Storer, concurrency int) error {
+	// KongIngresses are usually shared by many routes, serve repeated lookups from memory
+	s = newLookupCache(s)
+
+	// Services
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(ks.Services) {
+		concurrency = len(ks.Services)
+	}
+	serviceIndexes := make(chan int)
+	var (
+		wg     sync.WaitGroup
+		errsMu sync.Mutex
+		errs   []error
+	)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range serviceIndexes {
+				if svcErrs := ks.Services[i].fillOverrides(log, s); len(svcErrs) > 0 {
+					errsMu.Lock()
+					errs = append(errs, svcErrs...)
+					errsMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range ks.Services {
+		serviceIndexes <- i
+	}
+	close(serviceIndexes)
+	wg.Wait()
+	ks.dropUnsupportedPathHandling(log)
+	ks.dropUnsupportedRouteBuffering(log)
+
 	// Upstreams
 	for i := 0; i < len(ks.Upstreams); i++ {
 		kongIngress, err := getKongIngressForServices(s, ks.Upstreams[i].Service.K8sServices)
 		if err != nil {
-			log.WithError(err).
+			log.WithFields(servicesFailureLogFields(ks.Upstreams[i].Service.K8sServices,
+				logReasonKongIngressFetchFailed)).WithError(err).
 				Errorf("failed to fetch KongIngress resource for Services %s",
 					PrettyPrintServiceList(ks.Upstreams[i].Service.K8sServices),
 				)
+			errs = append(errs, fmt.Errorf("failed to fetch KongIngress resource for Services %s: %w",
+				PrettyPrintServiceList(ks.Upstreams[i].Service.K8sServices), err))
 			continue
 		}
 
 		for _, svc := range ks.Upstreams[i].Service.K8sServices {
-			ks.Upstreams[i].override(kongIngress, svc)
+			ks.Upstreams[i].override(log, kongIngress, svc)
 		}
+
+		policy, err := getKongUpstreamPolicyForServices(s, ks.Upstreams[i].Service.K8sServices)
+		if err != nil {
+			log.WithFields(servicesFailureLogFields(ks.Upstreams[i].Service.K8sServices,
+				logReasonKongUpstreamPolicyFetchFailed)).WithError(err).
+				Errorf("failed to fetch KongUpstreamPolicy resource for Services %s",
+					PrettyPrintServiceList(ks.Upstreams[i].Service.K8sServices),
+				)
+			errs = append(errs, fmt.Errorf("failed to fetch KongUpstreamPolicy resource for Services %s: %w",
+				PrettyPrintServiceList(ks.Upstreams[i].Service.K8sServices), err))
+			continue
+		}
+		if policy == nil {
+			continue
+		}
+		if kongIngress != nil && kongIngress.Upstream != nil {
+			log.WithFields(objectLogFields("KongUpstreamPolicy", policy.Namespace, policy.Name)).
+				WithField("kongingress_name", kongIngress.Name).Warn("KongIngress upstream settings are deprecated, KongUpstreamPolicy settings take precedence over them")
+		}
+		ks.Upstreams[i].overrideByUpstreamPolicy(policy)
 	}
+	ks.dropUnsupportedHealthcheckThreshold(log)
+	return utilerrors.NewAggregate(errs)
+}
+
+// fillOverrides applies the KongIngress overrides of the service and of its routes,
+// returning the errors of the overrides which couldn't be fetched.
+func (s *Service) fillOverrides(log logrus.FieldLogger, storer store.Storer) []error {
+	var errs []error
+	kongIngress, err := getMergedKongIngressForServices(log, storer, s.K8sServices)
+	if err != nil {
+		// the routes of the service may still have their own overrides,
+		// so only the service ones are skipped
+		log.WithFields(servicesFailureLogFields(s.K8sServices, logReasonKongIngressFetchFailed)).WithError(err).
+			Errorf("failed to fetch KongIngress resource for Services %s",
+				PrettyPrintServiceList(s.K8sServices),
+			)
+		errs = append(errs, fmt.Errorf("failed to fetch KongIngress resource for Services %s: %w",
+			PrettyPrintServiceList(s.K8sServices), err))
+	} else {
+		for _, svc := range sortedServices(s.K8sServices) {
+			s.override(log, kongIngress, svc)
+		}
+	}
+
+	// Routes
+	for j := 0; j < len(s.Routes); j++ {
+		kongIngress, err := getKongIngressFromObjectMeta(storer, s.Routes[j].Ingress)
+		if err != nil {
+			ingress := s.Routes[j].Ingress
+			log.WithFields(failureLogFields(ingress.GroupVersionKind.Kind, ingress.Namespace, ingress.Name,
+				logReasonKongIngressFetchFailed)).WithError(err).Errorf("failed to fetch KongIngress resource")
+			errs = append(errs, fmt.Errorf("failed to fetch KongIngress resource for %s/%s: %w",
+				s.Routes[j].Ingress.Namespace, s.Routes[j].Ingress.Name, err))
+		}
+
+		s.Routes[j].override(log, kongIngress, s.Protocol)
+	}
+	return errs
+}
+
+// kongPluginReference identifies a KongPlugin, or a KongClusterPlugin when there's no
+// KongPlugin with the same name, referenced from an object in a namespace.
+type kongPluginReference struct {
+	Namespace string
+	Name      string
 }
 
-func (ks *KongState) getPluginRelations() map[string]util.ForeignRelations {
-	// KongPlugin key (KongPlugin's name:namespace) to corresponding associations
-	pluginRels := map[string]util.ForeignRelations{}
+// getPluginRelations returns the services, routes, consumers and consumer groups referencing every KongPlugin.
+// Services and routes without a name can't be referenced by plugins, so they're logged and skipped.
+func (ks *KongState) getPluginRelations(log logrus.FieldLogger) map[kongPluginReference]util.ForeignRelations {
+	// KongPlugin reference to corresponding associations
+	pluginRels := map[kongPluginReference]util.ForeignRelations{}
 	addConsumerRelation := func(namespace, pluginName, identifier string) {
-		pluginKey := namespace + ":" + pluginName
-		relations, ok := pluginRels[pluginKey]
+		pluginRef := kongPluginReference{Namespace: namespace, Name: pluginName}
+		relations, ok := pluginRels[pluginRef]
 		if !ok {
 			relations = util.ForeignRelations{}
 		}
 		relations.Consumer = append(relations.Consumer, identifier)
-		pluginRels[pluginKey] = relations
+		pluginRels[pluginRef] = relations
 	}
 	addRouteRelation := func(namespace, pluginName, identifier string) {
-		pluginKey := namespace + ":" + pluginName
-		relations, ok := pluginRels[pluginKey]
+		pluginRef := kongPluginReference{Namespace: namespace, Name: pluginName}
+		relations, ok := pluginRels[pluginRef]
 		if !ok {
 			relations = util.ForeignRelations{}
 		}
 		relations.Route = append(relations.Route, identifier)
-		pluginRels[pluginKey] = relations
+		pluginRels[pluginRef] = relations
+	}
+	addConsumerGroupRelation := func(namespace, pluginName, identifier string) {
+		pluginRef := kongPluginReference{Namespace: namespace, Name: pluginName}
+		relations, ok := pluginRels[pluginRef]
+		if !ok {
+			relations = util.ForeignRelations{}
+		}
+		relations.ConsumerGroup = append(relations.ConsumerGroup, identifier)
+		pluginRels[pluginRef] = relations
 	}
 	addServiceRelation := func(namespace, pluginName, identifier string) {
-		pluginKey := namespace + ":" + pluginName
-		relations, ok := pluginRels[pluginKey]
+		pluginRef := kongPluginReference{Namespace: namespace, Name: pluginName}
+		relations, ok := pluginRels[pluginRef]
 		if !ok {
 			relations = util.ForeignRelations{}
 		}
 		relations.Service = append(relations.Service, identifier)
-		pluginRels[pluginKey] = relations
+		pluginRels[pluginRef] = relations
 	}
 
 	for i := range ks.Services {
+		if ks.Services[i].Name == nil {
+			log.WithField("service_namespace", ks.Services[i].Namespace).
+				Error("skipping plugins of a service without a name")
+			continue
+		}
 		// service
 		for _, svc := range ks.Services[i].K8sServices {
 			pluginList := annotations.ExtractKongPluginsFromAnnotations(svc.GetAnnotations())
@@ -221,7 +741,20 @@ func (ks *KongState) getPluginRelations() map[string]util.ForeignRelations {
 		// route
 		for j := range ks.Services[i].Routes {
 			ingress := ks.Services[i].Routes[j].Ingress
+			if ks.Services[i].Routes[j].Name == nil {
+				log.WithFields(logrus.Fields{
+					"service_name":      *ks.Services[i].Name,
+					"ingress_name":      ingress.Name,
+					"ingress_namespace": ingress.Namespace,
+				}).Error("skipping plugins of a route without a name")
+				continue
+			}
 			pluginList := annotations.ExtractKongPluginsFromAnnotations(ingress.Annotations)
+			for _, pluginName := range ks.Services[i].Routes[j].PluginRefs {
+				if !containsString(pluginList, pluginName) {
+					pluginList = append(pluginList, pluginName)
+				}
+			}
 			for _, pluginName := range pluginList {
 				addRouteRelation(ingress.Namespace, pluginName, *ks.Services[i].Routes[j].Name)
 			}
@@ -229,116 +762,691 @@ func (ks *KongState) getPluginRelations() map[string]util.ForeignRelations {
 	}
 	// consumer
 	for _, c := range ks.Consumers {
+		identifier := c.identifier()
+		if identifier == nil {
+			continue
+		}
 		pluginList := annotations.ExtractKongPluginsFromAnnotations(c.K8sKongConsumer.GetAnnotations())
 		for _, pluginName := range pluginList {
-			addConsumerRelation(c.K8sKongConsumer.Namespace, pluginName, *c.Username)
+			addConsumerRelation(c.K8sKongConsumer.Namespace, pluginName, *identifier)
 		}
 	}
+	// consumer group
+	for _, cg := range ks.ConsumerGroups {
+		if cg.Name == nil {
+			continue
+		}
+		pluginList := annotations.ExtractKongPluginsFromAnnotations(cg.K8sKongConsumerGroup.GetAnnotations())
+		for _, pluginName := range pluginList {
+			addConsumerGroupRelation(cg.K8sKongConsumerGroup.Namespace, pluginName, *cg.Name)
+		}
+	}
+	ks.consolidateRoutePlugins(pluginRels, ks.getDisabledPluginAttachments())
 	return pluginRels
 }
 
-func buildPlugins(log logrus.FieldLogger, s store.Storer, pluginRels map[string]util.ForeignRelations) []Plugin {
-	var plugins []Plugin
+// getDisabledPluginAttachments returns the services, routes, consumers and consumer groups on which every
+// KongPlugin they reference is disabled with the konghq.com/plugin-<name>-enabled annotation.
+func (ks *KongState) getDisabledPluginAttachments() map[kongPluginReference]util.ForeignRelations {
+	disabled := map[kongPluginReference]util.ForeignRelations{}
+	addDisabled := func(namespace string, anns map[string]string, add func(*util.ForeignRelations)) {
+		for _, pluginName := range annotations.ExtractKongPluginsFromAnnotations(anns) {
+			if annotations.ExtractPluginEnabled(anns, pluginName) {
+				continue
+			}
+			pluginRef := kongPluginReference{Namespace: namespace, Name: pluginName}
+			relations := disabled[pluginRef]
+			add(&relations)
+			disabled[pluginRef] = relations
+		}
+	}
 
-	for pluginIdentifier, relations := range pluginRels {
-		identifier := strings.Split(pluginIdentifier, ":")
-		namespace, kongPluginName := identifier[0], identifier[1]
-		plugin, err := getPlugin(s, namespace, kongPluginName)
-		if err != nil {
+	for _, service := range ks.Services {
+		if service.Name == nil {
+			continue
+		}
+		for _, svc := range service.K8sServices {
+			addDisabled(svc.Namespace, svc.GetAnnotations(), func(r *util.ForeignRelations) {
+				r.Service = append(r.Service, *service.Name)
+			})
+		}
+		for _, route := range service.Routes {
+			if route.Name == nil {
+				continue
+			}
+			addDisabled(route.Ingress.Namespace, route.Ingress.Annotations, func(r *util.ForeignRelations) {
+				r.Route = append(r.Route, *route.Name)
+			})
+		}
+	}
+	for _, c := range ks.Consumers {
+		identifier := c.identifier()
+		if identifier == nil {
+			continue
+		}
+		addDisabled(c.K8sKongConsumer.Namespace, c.K8sKongConsumer.GetAnnotations(), func(r *util.ForeignRelations) {
+			r.Consumer = append(r.Consumer, *identifier)
+		})
+	}
+	for _, cg := range ks.ConsumerGroups {
+		if cg.Name == nil {
+			continue
+		}
+		name := *cg.Name
+		addDisabled(cg.K8sKongConsumerGroup.Namespace, cg.K8sKongConsumerGroup.GetAnnotations(), func(r *util.ForeignRelations) {
+			r.ConsumerGroup = append(r.ConsumerGroup, name)
+		})
+	}
+	return disabled
+}
+
+// PluginRelations returns the services, routes, consumers and consumer groups referencing every KongPlugin,
+// keyed by the "namespace/name" of the reference, as they're computed when filling the plugins
+// of the state. The relations are computed on every call, so callers are free to modify them.
+func (ks *KongState) PluginRelations(log logrus.FieldLogger) map[string]util.ForeignRelations {
+	pluginRels := ks.getPluginRelations(log)
+	res := make(map[string]util.ForeignRelations, len(pluginRels))
+	for pluginRef, relations := range pluginRels {
+		res[pluginRef.Namespace+"/"+pluginRef.Name] = util.ForeignRelations{
+			Consumer:      append([]string(nil), relations.Consumer...),
+			ConsumerGroup: append([]string(nil), relations.ConsumerGroup...),
+			Route:         append([]string(nil), relations.Route...),
+			Service:       append([]string(nil), relations.Service...),
+		}
+	}
+	return res
+}
+
+// dropUnsupportedPathHandling removes the path handling of routes if the Kong version of the
+// state doesn't support it, as Kong would reject such routes.
+func (ks *KongState) dropUnsupportedPathHandling(log logrus.FieldLogger) {
+	if ks.SupportsFeature(FeatureRoutePathHandling) {
+		return
+	}
+	for i := range ks.Services {
+		for j := range ks.Services[i].Routes {
+			route := &ks.Services[i].Routes[j]
+			if route.PathHandling == nil {
+				continue
+			}
 			log.WithFields(logrus.Fields{
-				"kongplugin_name":      kongPluginName,
-				"kongplugin_namespace": namespace,
-			}).WithError(err).Errorf("failed to fetch KongPlugin")
+				"kongroute":    stringValue(route.Name),
+				"kong_version": ks.Version.String(),
+			}).Warnf("route path handling requires Kong %s or newer, ignoring it", featureMinVersions[FeatureRoutePathHandling])
+			route.PathHandling = nil
+		}
+	}
+}
+
+// dropUnsupportedRouteBuffering removes the request and response buffering settings of routes
+// if the Kong version of the state doesn't support them, as Kong would reject such routes.
+// Routes get these settings by default, so they're kept if the Kong version is unknown, and
+// dropping them is logged once rather than for every route.
+func (ks *KongState) dropUnsupportedRouteBuffering(log logrus.FieldLogger) {
+	if ks.SupportsFeature(FeatureRouteBuffering) || ks.Version.Equals(semver.Version{}) {
+		return
+	}
+	dropped := 0
+	for i := range ks.Services {
+		for j := range ks.Services[i].Routes {
+			route := &ks.Services[i].Routes[j]
+			if route.RequestBuffering == nil && route.ResponseBuffering == nil {
+				continue
+			}
+			route.RequestBuffering = nil
+			route.ResponseBuffering = nil
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		log.WithFields(logrus.Fields{
+			"kongroutes":   dropped,
+			"kong_version": ks.Version.String(),
+		}).Warnf("route request and response buffering require Kong %s or newer, ignoring them",
+			featureMinVersions[FeatureRouteBuffering])
+	}
+}
+
+// dropUnsupportedHealthcheckThreshold removes the health check threshold of upstreams if the
+// Kong version of the state doesn't support it, as Kong would reject such upstreams.
+func (ks *KongState) dropUnsupportedHealthcheckThreshold(log logrus.FieldLogger) {
+	if ks.SupportsFeature(FeatureUpstreamHealthcheckThreshold) {
+		return
+	}
+	for i := range ks.Upstreams {
+		upstream := &ks.Upstreams[i]
+		if upstream.Healthchecks == nil || upstream.Healthchecks.Threshold == nil {
+			continue
+		}
+		log.WithFields(logrus.Fields{
+			"upstream_name": stringValue(upstream.Name),
+			"kong_version":  ks.Version.String(),
+		}).Warnf("upstream health check threshold requires Kong %s or newer, ignoring it",
+			featureMinVersions[FeatureUpstreamHealthcheckThreshold])
+		// the health checks may be shared with the KongUpstreamPolicy they come from
+		healthchecks := upstream.Healthchecks.DeepCopy()
+		healthchecks.Threshold = nil
+		upstream.Healthchecks = healthchecks
+	}
+}
+
+// dropUnsupportedPluginOrdering removes the ordering of plugins if the Kong version of the state
+// doesn't support dynamic plugin ordering, as Kong would reject such plugins.
+func (ks *KongState) dropUnsupportedPluginOrdering(log logrus.FieldLogger) {
+	if ks.SupportsFeature(FeaturePluginOrdering) {
+		return
+	}
+	for i := range ks.Plugins {
+		if ks.Plugins[i].Ordering == nil {
+			continue
+		}
+		log.WithFields(logrus.Fields{
+			"plugin_name":  *ks.Plugins[i].Name,
+			"kong_version": ks.Version.String(),
+		}).Warnf("plugin ordering requires Kong %s or newer, ignoring it", featureMinVersions[FeaturePluginOrdering])
+		ks.Plugins[i].Ordering = nil
+	}
+}
+
+// dropUnsupportedConsumerGroupPlugins removes the plugins scoped to consumer groups if the Kong
+// version of the state doesn't support them. Such plugins are dropped rather than unscoped, so
+// that they don't apply to consumers outside of the group.
+func (ks *KongState) dropUnsupportedConsumerGroupPlugins(log logrus.FieldLogger) {
+	if ks.SupportsFeature(FeatureConsumerGroupPlugins) {
+		return
+	}
+	var plugins []Plugin
+	for _, plugin := range ks.Plugins {
+		if plugin.ConsumerGroup == nil {
+			plugins = append(plugins, plugin)
 			continue
 		}
+		log.WithFields(logrus.Fields{
+			"plugin_name":    stringValue(plugin.Name),
+			"consumer_group": *plugin.ConsumerGroup,
+			"kong_version":   ks.Version.String(),
+		}).Warnf("plugins scoped to consumer groups require Kong %s or newer, dropping the plugin",
+			featureMinVersions[FeatureConsumerGroupPlugins])
+	}
+	ks.Plugins = plugins
+}
 
-		for _, rel := range relations.GetCombinations() {
-			plugin := *plugin.DeepCopy()
-			// ID is populated because that is read by decK and in_memory
-			// translator too
-			if rel.Service != "" {
-				plugin.Service = &kong.Service{ID: kong.String(rel.Service)}
+// consolidateRoutePlugins replaces the route relations of plugins attached to every route
+// of a service with a single service relation. This is only done for services whose
+// Kubernetes Services all have the konghq.com/consolidate-plugins annotation set to "true".
+// Plugins attached to some of the routes of a service only, or disabled on some of them, are
+// left untouched.
+func (ks *KongState) consolidateRoutePlugins(
+	pluginRels map[kongPluginReference]util.ForeignRelations,
+	disabled map[kongPluginReference]util.ForeignRelations,
+) {
+	for i := range ks.Services {
+		service := ks.Services[i]
+		if service.Name == nil || len(service.Routes) == 0 || len(service.K8sServices) == 0 {
+			continue
+		}
+		consolidate := true
+		for _, svc := range service.K8sServices {
+			if !annotations.ExtractConsolidatePlugins(svc.GetAnnotations()) {
+				consolidate = false
+				break
 			}
-			if rel.Route != "" {
-				plugin.Route = &kong.Route{ID: kong.String(rel.Route)}
+		}
+		if !consolidate {
+			continue
+		}
+
+		routeNames := make(map[string]struct{}, len(service.Routes))
+		for _, route := range service.Routes {
+			if route.Name == nil {
+				// unnamed routes have no plugins, so no plugin is attached to every route
+				routeNames = nil
+				break
 			}
-			if rel.Consumer != "" {
-				plugin.Consumer = &kong.Consumer{ID: kong.String(rel.Consumer)}
+			routeNames[*route.Name] = struct{}{}
+		}
+		if routeNames == nil {
+			continue
+		}
+		for pluginRef, relations := range pluginRels {
+			attached := make(map[string]struct{}, len(routeNames))
+			var otherRoutes []string
+			for _, route := range relations.Route {
+				if _, ok := routeNames[route]; ok {
+					attached[route] = struct{}{}
+				} else {
+					otherRoutes = append(otherRoutes, route)
+				}
+			}
+			if len(attached) != len(routeNames) {
+				continue
 			}
-			plugins = append(plugins, Plugin{plugin})
+			disabledOnRoute := false
+			for _, route := range disabled[pluginRef].Route {
+				if _, ok := routeNames[route]; ok {
+					disabledOnRoute = true
+					break
+				}
+			}
+			if disabledOnRoute {
+				continue
+			}
+			relations.Route = otherRoutes
+			hasServiceRelation := false
+			for _, s := range relations.Service {
+				if s == *service.Name {
+					hasServiceRelation = true
+					break
+				}
+			}
+			if !hasServiceRelation {
+				relations.Service = append(relations.Service, *service.Name)
+			}
+			pluginRels[pluginRef] = relations
+		}
+	}
+}
+
+// UnresolvedPluginReference describes a plugin referenced by services, routes or consumers
+// which could not be translated, e.g. because there's no KongPlugin or KongClusterPlugin
+// with the referenced name.
+type UnresolvedPluginReference struct {
+	// Namespace is the namespace of the objects referencing the plugin.
+	Namespace string
+	// Name is the referenced KongPlugin or KongClusterPlugin name.
+	Name string
+	// Targets holds the names of the Kong services, routes and consumers referencing the plugin.
+	Targets util.ForeignRelations
+	// Err is the reason why the plugin could not be translated.
+	Err error
+}
+
+// buildPlugins returns the plugins configured by KongPlugins and KongClusterPlugins, along
+// with the plugin references which could not be resolved.
+// To keep the generated configuration stable between runs, plugins attached to entities
+// come first, sorted by plugin name, then service, route and consumer ID, followed by the
+// global plugins sorted by plugin name.
+// If isolateNamespaces is set, KongPlugins are only attached to objects from their own namespace:
+// references resolved to a KongPlugin from another namespace are logged and reported as unresolved.
+func buildPlugins(
+	log logrus.FieldLogger,
+	s store.Storer,
+	pluginRels map[kongPluginReference]util.ForeignRelations,
+	warned *WarnedSet,
+	isolateNamespaces bool,
+) ([]Plugin, []UnresolvedPluginReference) {
+	plugins, unresolved, _ := buildPluginsReusing(log, s, pluginRels, nil, warned, isolateNamespaces, nil, nil)
+	return plugins, unresolved
+}
+
+// buildPluginsReusing works like buildPlugins, except that the plugins of the references for
+// which reuse returns true are taken from reuse rather than built. reuse may be nil. Plugins
+// are disabled on the services, routes and consumers listed for their reference in disabled.
+// Only the global KongClusterPlugins matching globalPluginSelector are used, unless it's nil.
+// It also returns the plugins of every reference, built or reused.
+func buildPluginsReusing(
+	log logrus.FieldLogger,
+	s store.Storer,
+	pluginRels map[kongPluginReference]util.ForeignRelations,
+	disabled map[kongPluginReference]util.ForeignRelations,
+	warned *WarnedSet,
+	isolateNamespaces bool,
+	globalPluginSelector labels.Selector,
+	reuse func(pluginRef kongPluginReference, relations, disabled util.ForeignRelations) (referencedPlugins, bool),
+) ([]Plugin, []UnresolvedPluginReference, map[kongPluginReference]referencedPlugins) {
+	var plugins []Plugin
+	var unresolved []UnresolvedPluginReference
+	byReference := make(map[kongPluginReference]referencedPlugins, len(pluginRels))
+
+	// iterate over sorted plugin references to keep the order of plugins stable between runs
+	pluginRefs := make([]kongPluginReference, 0, len(pluginRels))
+	for pluginRef := range pluginRels {
+		pluginRefs = append(pluginRefs, pluginRef)
+	}
+	sort.Slice(pluginRefs, func(i, j int) bool {
+		if pluginRefs[i].Namespace != pluginRefs[j].Namespace {
+			return pluginRefs[i].Namespace < pluginRefs[j].Namespace
+		}
+		return pluginRefs[i].Name < pluginRefs[j].Name
+	})
+	for _, pluginRef := range pluginRefs {
+		relations, disabledOn := pluginRels[pluginRef], disabled[pluginRef]
+		built, ok := referencedPlugins{}, false
+		if reuse != nil {
+			built, ok = reuse(pluginRef, relations, disabledOn)
+		}
+		if !ok {
+			built = buildReferencedPlugins(log, s, pluginRef, relations, disabledOn, isolateNamespaces)
+		}
+		byReference[pluginRef] = built
+		for _, plugin := range built.plugins {
+			plugin.Plugin = *plugin.Plugin.DeepCopy()
+			plugins = append(plugins, plugin)
+		}
+		if built.unresolved != nil {
+			unresolved = append(unresolved, *built.unresolved)
 		}
 	}
+	sortPlugins(plugins)
 
-	globalPlugins, err := globalPlugins(log, s)
+	globalPlugins, err := globalPlugins(log, s, warned, globalPluginSelector)
 	if err != nil {
-		log.WithError(err).Error("failed to fetch global plugins")
+		log.WithFields(logrus.Fields{
+			logFieldKind:   "KongClusterPlugin",
+			logFieldReason: logReasonPluginFetchFailed,
+		}).WithError(err).Error("failed to fetch global plugins")
 	}
 	plugins = append(plugins, globalPlugins...)
 
-	return plugins
+	return plugins, unresolved, byReference
+}
+
+// referencedPlugins holds the plugins built from a plugin reference.
+type referencedPlugins struct {
+	// relations are the relations the plugins were built for, and disabled the ones
+	// the plugins are disabled on.
+	relations util.ForeignRelations
+	disabled  util.ForeignRelations
+	// namespace is the namespace of the KongPlugin the plugins were built from,
+	// empty for KongClusterPlugins and unresolved references.
+	namespace string
+	// secretVersions holds the resource versions of the Secrets read to build the
+	// plugins, keyed by namespace/name; Secrets which couldn't be read have an empty version.
+	secretVersions map[string]string
+	// configMapVersions holds the resource versions of the ConfigMaps read to build
+	// the plugins, in the same way as secretVersions.
+	configMapVersions map[string]string
+	plugins           []Plugin
+	// unresolved is set if the reference could not be resolved.
+	unresolved *UnresolvedPluginReference
+}
+
+// buildReferencedPlugins builds the plugins generated by a plugin reference for each of
+// its relations, in the same way as buildPlugins. Plugins attached to any of the services,
+// routes or consumers of disabled are disabled.
+func buildReferencedPlugins(
+	log logrus.FieldLogger,
+	s store.Storer,
+	pluginRef kongPluginReference,
+	relations util.ForeignRelations,
+	disabled util.ForeignRelations,
+	isolateNamespaces bool,
+) referencedPlugins {
+	res := referencedPlugins{relations: relations, disabled: disabled}
+	secrets := &secretVersionRecorder{Storer: s}
+	plugin, pluginNamespace, err := getPlugin(secrets, pluginRef.Namespace, pluginRef.Name)
+	res.secretVersions = secrets.versions
+	res.configMapVersions = secrets.configMapVersions
+	if err == nil && isolateNamespaces && pluginNamespace != "" && pluginNamespace != pluginRef.Namespace {
+		err = fmt.Errorf("KongPlugin %s/%s can't be attached to objects from namespace %s",
+			pluginNamespace, pluginRef.Name, pluginRef.Namespace)
+		log.WithFields(failureLogFields("KongPlugin", pluginNamespace, pluginRef.Name, logReasonCrossNamespaceReference)).
+			WithField("referrer_namespace", pluginRef.Namespace).
+			WithError(err).Error("dropping cross-namespace KongPlugin attachment")
+		res.unresolved = &UnresolvedPluginReference{
+			Namespace: pluginRef.Namespace,
+			Name:      pluginRef.Name,
+			Targets:   relations,
+			Err:       err,
+		}
+		return res
+	}
+	if err != nil {
+		log.WithFields(failureLogFields("KongPlugin", pluginRef.Namespace, pluginRef.Name, logReasonPluginFetchFailed)).
+			WithError(err).Errorf("failed to fetch KongPlugin")
+		res.unresolved = &UnresolvedPluginReference{
+			Namespace: pluginRef.Namespace,
+			Name:      pluginRef.Name,
+			Targets:   relations,
+			Err:       err,
+		}
+		return res
+	}
+	res.namespace = pluginNamespace
+
+	for _, rel := range relations.GetCombinations() {
+		plugin := plugin
+		plugin.Plugin = *plugin.Plugin.DeepCopy()
+		// ID is populated because that is read by decK and in_memory
+		// translator too
+		if rel.Service != "" {
+			plugin.Service = &kong.Service{ID: kong.String(rel.Service)}
+		}
+		if rel.Route != "" {
+			plugin.Route = &kong.Route{ID: kong.String(rel.Route)}
+		}
+		if rel.Consumer != "" {
+			plugin.Consumer = &kong.Consumer{ID: kong.String(rel.Consumer)}
+		}
+		if rel.ConsumerGroup != "" {
+			plugin.ConsumerGroup = kong.String(rel.ConsumerGroup)
+		}
+		if disabledOn(disabled, rel) {
+			plugin.Enabled = kong.Bool(false)
+		}
+		plugin.Config = resolvePluginPlaceholders(log.WithFields(objectLogFields("KongPlugin", pluginRef.Namespace, pluginRef.Name)),
+			plugin.Config, pluginPlaceholderValues(pluginRef.Namespace, rel))
+		res.plugins = append(res.plugins, plugin)
+	}
+	return res
+}
+
+// disabledOn reports whether any of the entities of rel is listed in disabled.
+func disabledOn(disabled util.ForeignRelations, rel util.Rel) bool {
+	contains := func(ids []string, id string) bool {
+		for _, v := range ids {
+			if id != "" && v == id {
+				return true
+			}
+		}
+		return false
+	}
+	return contains(disabled.Service, rel.Service) ||
+		contains(disabled.Route, rel.Route) ||
+		contains(disabled.Consumer, rel.Consumer) ||
+		contains(disabled.ConsumerGroup, rel.ConsumerGroup)
 }
 
-func globalPlugins(log logrus.FieldLogger, s store.Storer) ([]Plugin, error) {
+// sortPlugins sorts plugins by plugin name, then by the IDs of the service, route
+// and consumer they're attached to, then by consumer group.
+func sortPlugins(plugins []Plugin) {
+	sortKey := func(p Plugin) []string {
+		var service, route, consumer string
+		if p.Service != nil {
+			service = stringValue(p.Service.ID)
+		}
+		if p.Route != nil {
+			route = stringValue(p.Route.ID)
+		}
+		if p.Consumer != nil {
+			consumer = stringValue(p.Consumer.ID)
+		}
+		return []string{stringValue(p.Name), service, route, consumer, stringValue(p.ConsumerGroup)}
+	}
+	sort.SliceStable(plugins, func(i, j int) bool {
+		a, b := sortKey(plugins[i]), sortKey(plugins[j])
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+}
+
+// globalKongPluginsWarningKey identifies the deprecated global KongPlugins warning in a WarnedSet.
+const globalKongPluginsWarningKey = "global-kongplugins"
+
+func globalPlugins(
+	log logrus.FieldLogger,
+	s store.Storer,
+	warned *WarnedSet,
+	selector labels.Selector,
+) ([]Plugin, error) {
 	// removed as of 0.10.0
 	// only retrieved now to warn users
 	globalPlugins, err := s.ListGlobalKongPlugins()
 	if err != nil {
 		return nil, fmt.Errorf("error listing global KongPlugins: %w", err)
 	}
-	if len(globalPlugins) > 0 {
-		log.Warning("global KongPlugins found. These are no longer applied and",
+	globalPluginNames := make([]string, 0, len(globalPlugins))
+	for _, p := range globalPlugins {
+		globalPluginNames = append(globalPluginNames, p.Namespace+"/"+p.Name)
+	}
+	sort.Strings(globalPluginNames)
+	if warned.ShouldWarn(globalKongPluginsWarningKey, strings.Join(globalPluginNames, ",")) {
+		log.WithField("kongplugins", globalPluginNames).Warning("global KongPlugins found. These are no longer applied and",
 			" must be replaced with KongClusterPlugins.",
 			" Please run \"kubectl get kongplugin -l global=true --all-namespaces\" to list existing plugins")
 	}
 	res := make(map[string]Plugin)
-	var duplicates []string // keep track of duplicate
-	// TODO respect the oldest CRD
-	// Current behavior is to skip creating the plugin but in case
-	// of duplicate plugin definitions, we should respect the oldest one
-	// This is important since if a user comes in to k8s and creates a new
-	// CRD, the user now deleted an older plugin
 
 	globalClusterPlugins, err := s.ListGlobalKongClusterPlugins()
 	if err != nil {
 		return nil, fmt.Errorf("error listing global KongClusterPlugins: %w", err)
 	}
+	// in case of duplicate plugin definitions, respect the oldest one. Users
+	// creating a new global plugin with the same name should not cause an
+	// existing, working plugin to be removed from the configuration.
+	sortClusterPluginsByAge(globalClusterPlugins)
+	winners := make(map[string]*configurationv1.KongClusterPlugin)
 	for i := 0; i < len(globalClusterPlugins); i++ {
 		k8sPlugin := *globalClusterPlugins[i]
 		pluginName := k8sPlugin.PluginName
 		// empty pluginName skip it
 		if pluginName == "" {
-			log.WithFields(logrus.Fields{
-				"kongclusterplugin_name": k8sPlugin.Name,
-			}).Errorf("invalid KongClusterPlugin: empty plugin property")
+			log.WithFields(failureLogFields("KongClusterPlugin", "", k8sPlugin.Name, logReasonEmptyPluginName)).
+				Errorf("invalid KongClusterPlugin: empty plugin property")
+			continue
+		}
+		if selector != nil && !selector.Matches(labels.Set(k8sPlugin.Labels)) {
+			log.WithFields(failureLogFields("KongClusterPlugin", "", k8sPlugin.Name, logReasonSelectorMismatch)).
+				Warn("skipping global KongClusterPlugin not matching the global plugin selector")
 			continue
 		}
-		if _, ok := res[pluginName]; ok {
-			log.Error("multiple KongPlugin definitions found with"+
-				" 'global' label for '", pluginName,
-				"', the plugin will not be applied")
-			duplicates = append(duplicates, pluginName)
+		if winner, ok := winners[pluginName]; ok {
+			log.WithFields(failureLogFields("KongClusterPlugin", "", k8sPlugin.Name, logReasonDuplicateGlobalPlugin)).
+				WithField("applied_kongclusterplugin_name", winner.Name).Warnf("multiple KongClusterPlugin definitions found with 'global' label for '%s',"+
+				" only the oldest one will be applied", pluginName)
 			continue
 		}
 		if plugin, err := kongPluginFromK8SClusterPlugin(s, k8sPlugin); err == nil {
 			res[pluginName] = Plugin{
-				Plugin: plugin,
+				Plugin:           plugin,
+				configFromSource: k8sPlugin.ConfigFrom != nil,
 			}
+			winners[pluginName] = globalClusterPlugins[i]
 		} else {
-			log.WithFields(logrus.Fields{
-				"kongclusterplugin_name": k8sPlugin.Name,
-			}).WithError(err).Error("failed to generate configuration from KongClusterPlugin")
+			log.WithFields(failureLogFields("KongClusterPlugin", "", k8sPlugin.Name, logReasonInvalidConfiguration)).
+				WithError(err).Error("failed to generate configuration from KongClusterPlugin")
 		}
 	}
-	for _, plugin := range duplicates {
-		delete(res, plugin)
+	pluginNames := make([]string, 0, len(res))
+	for pluginName := range res {
+		pluginNames = append(pluginNames, pluginName)
 	}
+	sort.Strings(pluginNames)
 	var plugins []Plugin
-	for _, p := range res {
-		plugins = append(plugins, p)
+	for _, pluginName := range pluginNames {
+		plugins = append(plugins, res[pluginName])
 	}
 	return plugins, nil
 }
 
-func (ks *KongState) FillPlugins(log logrus.FieldLogger, s store.Storer) {
-	ks.Plugins = buildPlugins(log, s, ks.getPluginRelations())
+// sortClusterPluginsByAge sorts KongClusterPlugins from the oldest to the newest,
+// using namespace/name as a tie-breaker for plugins created at the same time.
+func sortClusterPluginsByAge(plugins []*configurationv1.KongClusterPlugin) {
+	sort.SliceStable(plugins, func(i, j int) bool {
+		ti, tj := plugins[i].CreationTimestamp, plugins[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		if plugins[i].Namespace != plugins[j].Namespace {
+			return plugins[i].Namespace < plugins[j].Namespace
+		}
+		return plugins[i].Name < plugins[j].Name
+	})
+}
+
+// FillPlugins builds the plugins referenced by the KongState entities along with the global
+// KongClusterPlugins. Deprecation warnings already recorded in warned are not logged again;
+// warned may be nil. If schemas is not nil, plugins whose configuration is invalid for
+// the Kong version of the state are dropped. If isolateNamespaces is set, KongPlugins are only
+// attached to objects from their own namespace. KongPlugins annotated or labeled with
+// konghq.com/apply-to: all are attached to every service and route of their namespace. If globalPluginSelector is not nil, global
+// KongClusterPlugins whose labels don't match it are logged and skipped. It returns a summary
+// of the plugins of the state.
+func (ks *KongState) FillPlugins(
+	log logrus.FieldLogger,
+	s store.Storer,
+	warned *WarnedSet,
+	schemas PluginSchemaGetter,
+	isolateNamespaces bool,
+	globalPluginSelector labels.Selector,
+) PluginsSummary {
+	pluginRels := ks.getPluginRelations(log)
+	ks.addNamespaceWidePluginRelations(log, s, warned, pluginRels)
+	var unresolved []UnresolvedPluginReference
+	ks.Plugins, unresolved, ks.pluginsByReference = buildPluginsReusing(log, newLookupCache(s),
+		pluginRels, ks.getDisabledPluginAttachments(), warned, isolateNamespaces,
+		globalPluginSelector, nil)
+	return ks.finishPlugins(log, schemas, unresolved)
+}
+
+// finishPlugins drops the plugin settings unsupported by the Kong version of the state and,
+// if schemas is not nil, the plugins with an invalid configuration. Plugins referencing an
+// anonymous consumer missing from the state are logged. It returns a summary of the plugins
+// of the state.
+func (ks *KongState) finishPlugins(
+	log logrus.FieldLogger,
+	schemas PluginSchemaGetter,
+	unresolved []UnresolvedPluginReference,
+) PluginsSummary {
+	ks.dropUnsupportedPluginOrdering(log)
+	ks.dropUnsupportedConsumerGroupPlugins(log)
+	if schemas != nil {
+		ks.validatePlugins(log, schemas)
+	}
+	ks.warnMissingAnonymousConsumers(log)
+	ks.unresolvedPlugins = unresolved
+	return summarizePlugins(ks.Plugins, unresolved)
+}
+
+// PluginsSummary reports the plugins built by FillPlugins, e.g. to be exposed as metrics.
+type PluginsSummary struct {
+	// ServicePlugins, RoutePlugins, ConsumerPlugins and ConsumerGroupPlugins are the numbers
+	// of plugins attached to services, routes, consumers and consumer groups. A plugin attached
+	// to several entities, e.g. to a route and a consumer, is counted in each of them.
+	ServicePlugins       int
+	RoutePlugins         int
+	ConsumerPlugins      int
+	ConsumerGroupPlugins int
+	// GlobalPlugins is the number of plugins not attached to any entity.
+	GlobalPlugins int
+	// Skipped holds the plugin references which could not be resolved.
+	Skipped []UnresolvedPluginReference
+}
+
+// summarizePlugins counts plugins by the entities they're attached to.
+func summarizePlugins(plugins []Plugin, unresolved []UnresolvedPluginReference) PluginsSummary {
+	summary := PluginsSummary{Skipped: unresolved}
+	for _, p := range plugins {
+		if p.Service == nil && p.Route == nil && p.Consumer == nil && p.ConsumerGroup == nil {
+			summary.GlobalPlugins++
+			continue
+		}
+		if p.Service != nil {
+			summary.ServicePlugins++
+		}
+		if p.Route != nil {
+			summary.RoutePlugins++
+		}
+		if p.Consumer != nil {
+			summary.ConsumerPlugins++
+		}
+		if p.ConsumerGroup != nil {
+			summary.ConsumerGroupPlugins++
+		}
+	}
+	return summary
 }
@@ -1,20 +1,32 @@
 package kongstate
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/blang/semver/v4"
 	"github.com/kong/go-kong/kong"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
 	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+	configurationv1beta1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1beta1"
 )
 
 func TestKongState_SanitizedCopy(t *testing.T) {
@@ -34,6 +46,7 @@ func TestKongState_SanitizedCopy(t *testing.T) {
 				Consumers: []Consumer{{
 					KeyAuths: []*KeyAuth{{kong.KeyAuth{ID: kong.String("1"), Key: kong.String("secret")}}},
 				}},
+				ConsumerGroups: []ConsumerGroup{{Name: kong.String("1"), Consumers: []string{"foo"}}},
 			},
 			want: KongState{
 				Services:       []Service{{Service: kong.Service{ID: kong.String("1")}}},
@@ -44,6 +57,7 @@ func TestKongState_SanitizedCopy(t *testing.T) {
 				Consumers: []Consumer{{
 					KeyAuths: []*KeyAuth{{kong.KeyAuth{ID: kong.String("1"), Key: redactedString}}},
 				}},
+				ConsumerGroups: []ConsumerGroup{{Name: kong.String("1"), Consumers: []string{"foo"}}},
 			},
 		},
 	} {
@@ -61,11 +75,11 @@ func Test_getPluginRelations(t *testing.T) {
 	tests := []struct {
 		name string
 		args args
-		want map[string]util.ForeignRelations
+		want map[kongPluginReference]util.ForeignRelations
 	}{
 		{
 			name: "empty state",
-			want: map[string]util.ForeignRelations{},
+			want: map[kongPluginReference]util.ForeignRelations{},
 		},
 		{
 			name: "single consumer annotation",
@@ -88,9 +102,44 @@ func Test_getPluginRelations(t *testing.T) {
 					},
 				},
 			},
-			want: map[string]util.ForeignRelations{
-				"ns1:foo": {Consumer: []string{"foo-consumer"}},
-				"ns1:bar": {Consumer: []string{"foo-consumer"}},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "ns1", Name: "foo"}: {Consumer: []string{"foo-consumer"}},
+				{Namespace: "ns1", Name: "bar"}: {Consumer: []string{"foo-consumer"}},
+			},
+		},
+		{
+			name: "consumer with a custom ID only",
+			args: args{
+				state: KongState{
+					Consumers: []Consumer{
+						{
+							Consumer: kong.Consumer{
+								CustomID: kong.String("foo-custom-id"),
+							},
+							K8sKongConsumer: configurationv1.KongConsumer{
+								ObjectMeta: metav1.ObjectMeta{
+									Namespace: "ns1",
+									Annotations: map[string]string{
+										annotations.AnnotationPrefix + annotations.PluginsKey: "foo",
+									},
+								},
+							},
+						},
+						{
+							K8sKongConsumer: configurationv1.KongConsumer{
+								ObjectMeta: metav1.ObjectMeta{
+									Namespace: "ns1",
+									Annotations: map[string]string{
+										annotations.AnnotationPrefix + annotations.PluginsKey: "bar",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "ns1", Name: "foo"}: {Consumer: []string{"foo-custom-id"}},
 			},
 		},
 		{
@@ -116,9 +165,9 @@ func Test_getPluginRelations(t *testing.T) {
 					},
 				},
 			},
-			want: map[string]util.ForeignRelations{
-				"ns1:foo": {Service: []string{"foo-service"}},
-				"ns1:bar": {Service: []string{"foo-service"}},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "ns1", Name: "foo"}: {Service: []string{"foo-service"}},
+				{Namespace: "ns1", Name: "bar"}: {Service: []string{"foo-service"}},
 			},
 		},
 		{
@@ -148,9 +197,9 @@ func Test_getPluginRelations(t *testing.T) {
 					},
 				},
 			},
-			want: map[string]util.ForeignRelations{
-				"ns2:foo": {Route: []string{"foo-route"}},
-				"ns2:bar": {Route: []string{"foo-route"}},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "ns2", Name: "foo"}: {Route: []string{"foo-route"}},
+				{Namespace: "ns2", Name: "bar"}: {Route: []string{"foo-route"}},
 			},
 		},
 		{
@@ -192,10 +241,10 @@ func Test_getPluginRelations(t *testing.T) {
 					},
 				},
 			},
-			want: map[string]util.ForeignRelations{
-				"ns2:foo": {Route: []string{"foo-route"}},
-				"ns2:bar": {Route: []string{"foo-route", "bar-route"}},
-				"ns2:baz": {Route: []string{"bar-route"}},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "ns2", Name: "foo"}: {Route: []string{"foo-route"}},
+				{Namespace: "ns2", Name: "bar"}: {Route: []string{"foo-route", "bar-route"}},
+				{Namespace: "ns2", Name: "baz"}: {Route: []string{"bar-route"}},
 			},
 		},
 		{
@@ -288,19 +337,214 @@ func Test_getPluginRelations(t *testing.T) {
 					},
 				},
 			},
-			want: map[string]util.ForeignRelations{
-				"ns1:foo":    {Consumer: []string{"foo-consumer"}, Service: []string{"foo-service"}},
-				"ns1:bar":    {Consumer: []string{"foo-consumer"}, Service: []string{"foo-service"}},
-				"ns1:foobar": {Consumer: []string{"bar-consumer"}},
-				"ns2:foo":    {Consumer: []string{"foo-consumer"}, Route: []string{"foo-route"}},
-				"ns2:bar":    {Consumer: []string{"foo-consumer"}, Route: []string{"foo-route", "bar-route"}},
-				"ns2:baz":    {Route: []string{"bar-route"}},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "ns1", Name: "foo"}:    {Consumer: []string{"foo-consumer"}, Service: []string{"foo-service"}},
+				{Namespace: "ns1", Name: "bar"}:    {Consumer: []string{"foo-consumer"}, Service: []string{"foo-service"}},
+				{Namespace: "ns1", Name: "foobar"}: {Consumer: []string{"bar-consumer"}},
+				{Namespace: "ns2", Name: "foo"}:    {Consumer: []string{"foo-consumer"}, Route: []string{"foo-route"}},
+				{Namespace: "ns2", Name: "bar"}:    {Consumer: []string{"foo-consumer"}, Route: []string{"foo-route", "bar-route"}},
+				{Namespace: "ns2", Name: "baz"}:    {Route: []string{"bar-route"}},
+			},
+		},
+		{
+			name: "plugins on all routes of a service are consolidated when enabled",
+			args: args{
+				state: KongState{
+					Services: []Service{
+						{
+							Service: kong.Service{
+								Name: kong.String("foo-service"),
+							},
+							K8sServices: map[string]*corev1.Service{
+								"foo-service": {
+									ObjectMeta: metav1.ObjectMeta{
+										Namespace: "ns2",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.ConsolidatePluginsKey: "true",
+										},
+									},
+								},
+							},
+							Routes: []Route{
+								{
+									Route: kong.Route{
+										Name: kong.String("foo-route"),
+									},
+									Ingress: util.K8sObjectInfo{
+										Name:      "some-ingress",
+										Namespace: "ns2",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.PluginsKey: "foo,bar",
+										},
+									},
+								},
+								{
+									Route: kong.Route{
+										Name: kong.String("bar-route"),
+									},
+									Ingress: util.K8sObjectInfo{
+										Name:      "other-ingress",
+										Namespace: "ns2",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.PluginsKey: "bar,foo",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "ns2", Name: "foo"}: {Service: []string{"foo-service"}},
+				{Namespace: "ns2", Name: "bar"}: {Service: []string{"foo-service"}},
+			},
+		},
+		{
+			name: "plugins on some routes of a service are not consolidated",
+			args: args{
+				state: KongState{
+					Services: []Service{
+						{
+							Service: kong.Service{
+								Name: kong.String("foo-service"),
+							},
+							K8sServices: map[string]*corev1.Service{
+								"foo-service": {
+									ObjectMeta: metav1.ObjectMeta{
+										Namespace: "ns2",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.ConsolidatePluginsKey: "true",
+										},
+									},
+								},
+							},
+							Routes: []Route{
+								{
+									Route: kong.Route{
+										Name: kong.String("foo-route"),
+									},
+									Ingress: util.K8sObjectInfo{
+										Name:      "some-ingress",
+										Namespace: "ns2",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.PluginsKey: "foo,bar",
+										},
+									},
+								},
+								{
+									Route: kong.Route{
+										Name: kong.String("bar-route"),
+									},
+									Ingress: util.K8sObjectInfo{
+										Name:      "other-ingress",
+										Namespace: "ns2",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.PluginsKey: "bar",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "ns2", Name: "foo"}: {Route: []string{"foo-route"}},
+				{Namespace: "ns2", Name: "bar"}: {Service: []string{"foo-service"}},
+			},
+		},
+		{
+			name: "plugins on all routes of a service are not consolidated by default",
+			args: args{
+				state: KongState{
+					Services: []Service{
+						{
+							Service: kong.Service{
+								Name: kong.String("foo-service"),
+							},
+							K8sServices: map[string]*corev1.Service{
+								"foo-service": {
+									ObjectMeta: metav1.ObjectMeta{
+										Namespace: "ns2",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.PluginsKey: "",
+										},
+									},
+								},
+							},
+							Routes: []Route{
+								{
+									Route: kong.Route{
+										Name: kong.String("foo-route"),
+									},
+									Ingress: util.K8sObjectInfo{
+										Name:      "some-ingress",
+										Namespace: "ns2",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.PluginsKey: "foo,bar",
+										},
+									},
+								},
+								{
+									Route: kong.Route{
+										Name: kong.String("bar-route"),
+									},
+									Ingress: util.K8sObjectInfo{
+										Name:      "other-ingress",
+										Namespace: "ns2",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.PluginsKey: "bar,foo",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "ns2", Name: "foo"}: {Route: []string{"foo-route", "bar-route"}},
+				{Namespace: "ns2", Name: "bar"}: {Route: []string{"foo-route", "bar-route"}},
+			},
+		},
+		{
+			name: "plugins referenced by HTTPRoute filters are attached to the generated routes",
+			args: args{
+				state: KongState{
+					Services: []Service{
+						{
+							Service: kong.Service{
+								Name: kong.String("default.httproute.basic-httproute.0"),
+							},
+							Routes: []Route{
+								{
+									Route: kong.Route{
+										Name: kong.String("httproute.default.basic-httproute.0.0"),
+									},
+									Ingress: util.K8sObjectInfo{
+										Name:      "basic-httproute",
+										Namespace: "default",
+										Annotations: map[string]string{
+											annotations.AnnotationPrefix + annotations.PluginsKey: "foo",
+										},
+									},
+									PluginRefs: []string{"foo", "bar"},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: map[kongPluginReference]util.ForeignRelations{
+				{Namespace: "default", Name: "foo"}: {Route: []string{"httproute.default.basic-httproute.0.0"}},
+				{Namespace: "default", Name: "bar"}: {Route: []string{"httproute.default.basic-httproute.0.0"}},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.args.state.getPluginRelations(); !reflect.DeepEqual(got, tt.want) {
+			if got := tt.args.state.getPluginRelations(logrus.New()); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("getPluginRelations() = %v, want %v", got, tt.want)
 			}
 		})
@@ -382,7 +626,7 @@ func Test_FillConsumersAndCredentials(t *testing.T) {
 		state := KongState{
 			Version: semver.MustParse("2.3.2"),
 		}
-		state.FillConsumersAndCredentials(logrus.New(), store)
+		require.NoError(t, state.FillConsumersAndCredentials(logrus.New(), store, nil, nil, nil, ConsumerFillOptions{}))
 		assert.Equal(t, want.Consumers[0].Consumer.Username, state.Consumers[0].Consumer.Username)
 		assert.Equal(t, want.Consumers[0].Consumer.CustomID, state.Consumers[0].Consumer.CustomID)
 		assert.Equal(t, want.Consumers[0].KeyAuths[0].Key, state.Consumers[0].KeyAuths[0].Key)
@@ -392,3 +636,1944 @@ func Test_FillConsumersAndCredentials(t *testing.T) {
 		assert.Equal(t, want.Consumers[0].Oauth2Creds[0].RedirectURIs, state.Consumers[0].Oauth2Creds[0].RedirectURIs)
 	})
 }
+
+func Test_globalPlugins(t *testing.T) {
+	now := time.Now()
+	clusterPlugin := func(name string, created time.Time) *configurationv1.KongClusterPlugin {
+		return &configurationv1.KongClusterPlugin{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(created),
+				Labels: map[string]string{
+					"global": "true",
+				},
+				Annotations: map[string]string{
+					annotations.IngressClassKey: annotations.DefaultIngressClass,
+				},
+			},
+			PluginName: "rate-limiting",
+			Config: apiextensionsv1.JSON{
+				Raw: []byte(`{"source":"` + name + `"}`),
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name       string
+		plugins    []*configurationv1.KongClusterPlugin
+		wantSource string
+	}{
+		{
+			name: "two duplicates, the oldest is applied",
+			plugins: []*configurationv1.KongClusterPlugin{
+				clusterPlugin("newer", now),
+				clusterPlugin("older", now.Add(-time.Hour)),
+			},
+			wantSource: "older",
+		},
+		{
+			name: "three duplicates with shuffled creation timestamps, the oldest is applied",
+			plugins: []*configurationv1.KongClusterPlugin{
+				clusterPlugin("middle", now.Add(-time.Hour)),
+				clusterPlugin("newest", now),
+				clusterPlugin("oldest", now.Add(-2*time.Hour)),
+			},
+			wantSource: "oldest",
+		},
+		{
+			name: "duplicates created at the same time are resolved by name",
+			plugins: []*configurationv1.KongClusterPlugin{
+				clusterPlugin("b", now),
+				clusterPlugin("c", now),
+				clusterPlugin("a", now),
+			},
+			wantSource: "a",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := store.NewFakeStore(store.FakeObjects{
+				KongClusterPlugins: tt.plugins,
+			})
+			require.NoError(t, err)
+
+			plugins, err := globalPlugins(logrus.New(), s, nil, nil)
+			require.NoError(t, err)
+			require.Len(t, plugins, 1)
+			assert.Equal(t, "rate-limiting", *plugins[0].Name)
+			assert.Equal(t, kong.Configuration{"source": tt.wantSource}, plugins[0].Config)
+		})
+	}
+}
+
+func Test_globalPlugins_WarnsOnceAboutGlobalKongPlugins(t *testing.T) {
+	kongPlugin := func(name string) *configurationv1.KongPlugin {
+		return &configurationv1.KongPlugin{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					"global": "true",
+				},
+				Annotations: map[string]string{
+					annotations.IngressClassKey: annotations.DefaultIngressClass,
+				},
+			},
+			PluginName: "rate-limiting",
+		}
+	}
+	const warning = "global KongPlugins found"
+
+	buf := new(bytes.Buffer)
+	log := logrus.New()
+	log.SetOutput(buf)
+	warned := NewWarnedSet()
+
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{kongPlugin("foo")},
+	})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = globalPlugins(log, s, warned, nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, strings.Count(buf.String(), warning), "the warning should be logged once for the same plugins")
+
+	s, err = store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{kongPlugin("foo"), kongPlugin("bar")},
+	})
+	require.NoError(t, err)
+	_, err = globalPlugins(log, s, warned, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(buf.String(), warning), "the warning should be logged again when the plugins change")
+}
+
+func Test_globalPlugins_Selector(t *testing.T) {
+	now := time.Now()
+	clusterPlugin := func(name, pluginName, team string, created time.Time) *configurationv1.KongClusterPlugin {
+		return &configurationv1.KongClusterPlugin{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(created),
+				Labels: map[string]string{
+					"global": "true",
+					"team":   team,
+				},
+				Annotations: map[string]string{
+					annotations.IngressClassKey: annotations.DefaultIngressClass,
+				},
+			},
+			PluginName: pluginName,
+			Config: apiextensionsv1.JSON{
+				Raw: []byte(`{"source":"` + name + `"}`),
+			},
+		}
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongClusterPlugins: []*configurationv1.KongClusterPlugin{
+			clusterPlugin("app-limits", "rate-limiting", "app", now.Add(-time.Hour)),
+			clusterPlugin("platform-limits", "rate-limiting", "platform", now),
+			clusterPlugin("app-metrics", "prometheus", "app", now),
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("all global plugins are used without a selector", func(t *testing.T) {
+		plugins, err := globalPlugins(logrus.New(), s, nil, nil)
+		require.NoError(t, err)
+		require.Len(t, plugins, 2)
+		assert.Equal(t, kong.Configuration{"source": "app-metrics"}, plugins[0].Config)
+		assert.Equal(t, kong.Configuration{"source": "app-limits"}, plugins[1].Config)
+	})
+
+	t.Run("global plugins not matching the selector are skipped", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		log := logrus.New()
+		log.SetOutput(buf)
+
+		plugins, err := globalPlugins(log, s, nil, labels.SelectorFromSet(labels.Set{"team": "platform"}))
+		require.NoError(t, err)
+		require.Len(t, plugins, 1)
+		assert.Equal(t, kong.Configuration{"source": "platform-limits"}, plugins[0].Config,
+			"skipped plugins should not shadow allowed plugins of the same name")
+		assert.Equal(t, 2, strings.Count(buf.String(), "skipping global KongClusterPlugin not matching the global plugin selector"))
+	})
+}
+
+func Test_FillConsumersAndCredentials_RecordsEvents(t *testing.T) {
+	consumer := &configurationv1.KongConsumer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"kubernetes.io/ingress.class": annotations.DefaultIngressClass,
+			},
+		},
+		Username: "foo",
+	}
+
+	for _, tt := range []struct {
+		name        string
+		secrets     []*corev1.Secret
+		credentials []string
+		wantEvent   string
+	}{
+		{
+			name:        "missing secret",
+			credentials: []string{"missing"},
+			wantEvent: "Warning " + CredentialProvisionFailedReason +
+				" failed to provision credential from secret default/missing: Secret default/missing not found",
+		},
+		{
+			name: "invalid credType",
+			secrets: []*corev1.Secret{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "invalid",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"kongCredType": []byte("invalid-auth"),
+						"key":          []byte("whatever"),
+					},
+				},
+			},
+			credentials: []string{"invalid"},
+			wantEvent: "Warning " + CredentialProvisionFailedReason +
+				" failed to provision credential from secret default/invalid: invalid credType: invalid-auth",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := consumer.DeepCopy()
+			c.Credentials = tt.credentials
+			s, err := store.NewFakeStore(store.FakeObjects{
+				Secrets:       tt.secrets,
+				KongConsumers: []*configurationv1.KongConsumer{c},
+			})
+			require.NoError(t, err)
+
+			recorder := record.NewFakeRecorder(10)
+			state := KongState{}
+			assert.Error(t, state.FillConsumersAndCredentials(logrus.New(), s, nil, recorder, nil, ConsumerFillOptions{}))
+
+			require.Len(t, recorder.Events, 1)
+			assert.Equal(t, tt.wantEvent, <-recorder.Events)
+		})
+	}
+}
+
+func Test_FillConsumersAndCredentials_DeduplicatesEvents(t *testing.T) {
+	storeWithCredType := func(credType string) store.Storer {
+		s, err := store.NewFakeStore(store.FakeObjects{
+			Secrets: []*corev1.Secret{{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-key", Namespace: "default"},
+				Data:       map[string][]byte{"kongCredType": []byte(credType), "key": []byte("foo")},
+			}},
+			KongConsumers: []*configurationv1.KongConsumer{
+				{ObjectMeta: metav1.ObjectMeta{Name: "anonymous", Namespace: "default"}},
+				{
+					ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+					Username:    "foo",
+					Credentials: []string{"foo-key"},
+				},
+			},
+		})
+		require.NoError(t, err)
+		return s
+	}
+	recorder := record.NewFakeRecorder(10)
+	opts := ConsumerFillOptions{Warned: NewWarnedSet()}
+	fill := func(s store.Storer) error {
+		state := KongState{}
+		return state.FillConsumersAndCredentials(logrus.New(), s, nil, recorder, nil, opts)
+	}
+
+	invalid := storeWithCredType("invalid-auth")
+	assert.Error(t, fill(invalid))
+	assert.Error(t, fill(invalid))
+	require.Len(t, recorder.Events, 2, "every failure should be reported once")
+	assert.Contains(t, <-recorder.Events, ConsumerIdentifierMissingReason)
+	assert.Contains(t, <-recorder.Events, "invalid credType: invalid-auth")
+
+	assert.NoError(t, fill(storeWithCredType("key-auth")))
+	assert.Error(t, fill(invalid))
+	require.Len(t, recorder.Events, 1, "failures should be reported again once they were fixed")
+	assert.Contains(t, <-recorder.Events, "invalid credType: invalid-auth")
+}
+
+func Test_FillConsumersAndCredentials_MissingConsumerIdentifiers(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongConsumers: []*configurationv1.KongConsumer{
+			{ObjectMeta: metav1.ObjectMeta{Name: "anonymous", Namespace: "default"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}, Username: "foo"},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name    string
+		strict  bool
+		wantErr string
+	}{
+		{
+			name: "consumers are skipped with a warning",
+		},
+		{
+			name:    "consumers are reported as errors in strict mode",
+			strict:  true,
+			wantErr: "KongConsumer default/anonymous has neither a username nor a custom ID",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&logs)
+			recorder := record.NewFakeRecorder(10)
+
+			state := KongState{}
+			err := state.FillConsumersAndCredentials(log, s, nil, recorder, nil, ConsumerFillOptions{StrictConsumerIdentifiers: tt.strict})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			require.Len(t, state.Consumers, 1)
+			assert.Equal(t, "foo", *state.Consumers[0].Username)
+			assert.Contains(t, logs.String(), "skipping KongConsumer with neither a username nor a custom ID")
+			assert.Contains(t, logs.String(), "name=anonymous")
+			assert.Contains(t, logs.String(), "namespace=default")
+			require.Len(t, recorder.Events, 1)
+			assert.Equal(t, "Warning "+ConsumerIdentifierMissingReason+
+				" KongConsumer has neither a username nor a custom ID and can't be configured in Kong", <-recorder.Events)
+		})
+	}
+}
+
+func Test_FillConsumersAndCredentials_AllowedCredTypes(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "key", Namespace: "default"},
+				Data: map[string][]byte{
+					"kongCredType": []byte("key-auth"),
+					"key":          []byte("little-rabbits-be-good"),
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "oauth", Namespace: "default"},
+				Data: map[string][]byte{
+					"kongCredType":  []byte("oauth2"),
+					"name":          []byte("foo"),
+					"client_id":     []byte("foo"),
+					"client_secret": []byte("bar"),
+					"redirect_uris": []byte("https://example.com"),
+				},
+			},
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:    "foo",
+				Credentials: []string{"key", "oauth"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+
+	credMetrics := fakeCredentialMetrics{}
+	state := KongState{}
+	diagnostics, err := state.FillConsumersAndCredentialsWithDiagnostics(log, s, nil, nil, credMetrics, ConsumerFillOptions{
+		AllowedCredTypes: sets.NewString("key-auth", "jwt"),
+	})
+	require.Error(t, err)
+	require.Len(t, state.Consumers, 1)
+	assert.Len(t, state.Consumers[0].KeyAuths, 1, "credentials of allowed types should be provisioned")
+	assert.Empty(t, state.Consumers[0].Oauth2Creds, "credentials of other types should be skipped")
+	assert.Contains(t, logs.String(), "skipping credential of a type which is not allowed in this cluster")
+	assert.Contains(t, logs.String(), "credential_type=oauth2")
+	assert.Contains(t, logs.String(), "secret_name=oauth")
+	assert.Contains(t, logs.String(), "reason=ForbiddenCredType")
+	assert.Equal(t, ConsumerDiagnostics{
+		"default/foo": {
+			{
+				SecretName: "oauth",
+				CredType:   "oauth2",
+				Reason:     CredentialDiagnosticForbiddenCredType,
+				Message:    "credType oauth2 is not allowed",
+			},
+		},
+	}, diagnostics)
+	assert.Equal(t, fakeCredentialMetrics{
+		CredentialOutcomeProvisioned + "/key-auth":                1,
+		string(CredentialDiagnosticForbiddenCredType) + "/oauth2": 1,
+	}, credMetrics)
+}
+
+func Test_FillConsumersAndCredentials_ConsumerTags(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "default",
+					Labels:    map[string]string{"konghq.com/tags": "team-a"},
+					Annotations: map[string]string{
+						"konghq.com/tags": "team-a, tier:gold,managed-by:instance-a,invalid/tag",
+					},
+				},
+				Username: "foo",
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default"},
+				Username:   "bar",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+
+	state := KongState{}
+	require.NoError(t, state.FillConsumersAndCredentials(log, s, nil, nil, nil, ConsumerFillOptions{}))
+	// tags managed by the controller are merged with the tags of the KongConsumers
+	state.AddInstanceTag("managed-by:instance-a")
+
+	tags := map[string][]*string{}
+	for _, c := range state.Consumers {
+		tags[*c.Username] = c.Tags
+	}
+	assert.Equal(t, kong.StringSlice("team-a", "tier:gold", "managed-by:instance-a"), tags["foo"])
+	assert.Equal(t, kong.StringSlice("managed-by:instance-a"), tags["bar"])
+	assert.Contains(t, logs.String(), "invalid consumer tag, ignoring it")
+	assert.Contains(t, logs.String(), "tag=invalid/tag")
+}
+
+func TestKongState_StableOrdering(t *testing.T) {
+	const runs = 10
+	objectMeta := func(namespace, name string, labels map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				annotations.IngressClassKey: annotations.DefaultIngressClass,
+			},
+		}
+	}
+	global := map[string]string{"global": "true"}
+
+	var consumers []*configurationv1.KongConsumer
+	for _, name := range []string{"d", "b", "e", "a", "c"} {
+		consumers = append(consumers, &configurationv1.KongConsumer{
+			ObjectMeta: objectMeta("default", name, nil),
+			Username:   name,
+		})
+	}
+	var plugins []*configurationv1.KongPlugin
+	for _, name := range []string{"p3", "p1", "p2"} {
+		plugins = append(plugins, &configurationv1.KongPlugin{
+			ObjectMeta: objectMeta("default", name, nil),
+			PluginName: name,
+		})
+	}
+	var clusterPlugins []*configurationv1.KongClusterPlugin
+	for _, name := range []string{"g3", "g1", "g2"} {
+		clusterPlugins = append(clusterPlugins, &configurationv1.KongClusterPlugin{
+			ObjectMeta: objectMeta("", name, global),
+			PluginName: name,
+		})
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongConsumers:      consumers,
+		KongPlugins:        plugins,
+		KongClusterPlugins: clusterPlugins,
+	})
+	require.NoError(t, err)
+	pluginRels := map[kongPluginReference]util.ForeignRelations{
+		{Namespace: "default", Name: "p3"}: {Route: []string{"r1"}},
+		{Namespace: "default", Name: "p1"}: {Route: []string{"r1"}},
+		{Namespace: "default", Name: "p2"}: {Route: []string{"r1"}},
+	}
+
+	for i := 0; i < runs; i++ {
+		state := KongState{}
+		require.NoError(t, state.FillConsumersAndCredentials(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{}))
+		var gotConsumers []string
+		for _, c := range state.Consumers {
+			gotConsumers = append(gotConsumers, *c.Username)
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, gotConsumers)
+
+		var gotPlugins []string
+		plugins, _ := buildPlugins(logrus.New(), s, pluginRels, nil, false)
+		for _, p := range plugins {
+			gotPlugins = append(gotPlugins, *p.Name)
+		}
+		assert.Equal(t, []string{"p1", "p2", "p3", "g1", "g2", "g3"}, gotPlugins)
+	}
+}
+
+func Test_FillConsumersAndCredentialsWithDiagnostics(t *testing.T) {
+	secrets := []*corev1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "valid", Namespace: "default"},
+			Data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+				"key":          []byte("whatever"),
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "unsupported", Namespace: "default"},
+			Data: map[string][]byte{
+				"kongCredType": []byte("foo-auth"),
+				"key":          []byte("whatever"),
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default"},
+			Data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+			},
+		},
+	}
+	consumers := []*configurationv1.KongConsumer{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: "default",
+				Annotations: map[string]string{
+					annotations.IngressClassKey: annotations.DefaultIngressClass,
+				},
+			},
+			Username:    "foo",
+			Credentials: []string{"valid", "unsupported", "empty", "missing"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "bar",
+				Namespace: "default",
+				Annotations: map[string]string{
+					annotations.IngressClassKey: annotations.DefaultIngressClass,
+				},
+			},
+			Username:    "bar",
+			Credentials: []string{"valid"},
+		},
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets:       secrets,
+		KongConsumers: consumers,
+	})
+	require.NoError(t, err)
+
+	state := KongState{}
+	diagnostics, err := state.FillConsumersAndCredentialsWithDiagnostics(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{})
+	assert.Equal(t, ConsumerDiagnostics{
+		"default/foo": {
+			{
+				SecretName: "unsupported",
+				CredType:   "foo-auth",
+				Reason:     CredentialDiagnosticInvalidCredType,
+				Message:    "invalid credType: foo-auth",
+			},
+			{
+				SecretName: "empty",
+				CredType:   "key-auth",
+				Reason:     CredentialDiagnosticEmptySecret,
+				Message:    "empty secret",
+			},
+			{
+				SecretName: "missing",
+				Reason:     CredentialDiagnosticSecretNotFound,
+				Message:    "Secret default/missing not found",
+			},
+		},
+	}, diagnostics)
+	require.Len(t, state.Consumers, 2)
+
+	var agg utilerrors.Aggregate
+	require.ErrorAs(t, err, &agg)
+	assert.Len(t, agg.Errors(), 3, "every failed credential should be reported")
+	assert.ErrorContains(t, err,
+		"failed to provision credential from secret default/missing of KongConsumer foo: Secret default/missing not found")
+}
+
+func TestKongState_FillConsumerGroups(t *testing.T) {
+	consumerGroup := func(name string) *configurationv1beta1.KongConsumerGroup {
+		return &configurationv1beta1.KongConsumerGroup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					annotations.IngressClassKey: annotations.DefaultIngressClass,
+				},
+			},
+		}
+	}
+	consumer := func(username, groups string) Consumer {
+		return Consumer{
+			Consumer: kong.Consumer{
+				Username: kong.String(username),
+			},
+			K8sKongConsumer: configurationv1.KongConsumer{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      username,
+					Namespace: "default",
+					Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.ConsumerGroupsKey: groups,
+					},
+				},
+			},
+		}
+	}
+
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongConsumerGroups: []*configurationv1beta1.KongConsumerGroup{
+			consumerGroup("silver"),
+			consumerGroup("gold"),
+			consumerGroup("bronze"),
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{
+		Consumers: []Consumer{
+			consumer("foo", "gold,silver"),
+			consumer("bar", "silver,platinum"),
+		},
+	}
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+	state.FillConsumerGroups(log, s)
+
+	require.Len(t, state.ConsumerGroups, 3)
+	t.Run("group with no members", func(t *testing.T) {
+		assert.Equal(t, "bronze", *state.ConsumerGroups[0].Name)
+		assert.Empty(t, state.ConsumerGroups[0].Consumers)
+	})
+	t.Run("consumer belonging to multiple groups", func(t *testing.T) {
+		assert.Equal(t, "gold", *state.ConsumerGroups[1].Name)
+		assert.Equal(t, []string{"foo"}, state.ConsumerGroups[1].Consumers)
+		assert.Equal(t, "silver", *state.ConsumerGroups[2].Name)
+		assert.Equal(t, []string{"foo", "bar"}, state.ConsumerGroups[2].Consumers)
+	})
+	t.Run("missing group", func(t *testing.T) {
+		assert.Contains(t, logs.String(), "consumers reference a KongConsumerGroup which does not exist")
+		assert.Contains(t, logs.String(), "kongconsumergroup=default/platinum")
+	})
+}
+
+func TestKongState_FillPlugins_Ordering(t *testing.T) {
+	ordering := &kong.PluginOrdering{
+		Before: kong.PluginOrderingPhase{
+			"access": []string{"key-auth"},
+		},
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "terminate",
+					Namespace: "default",
+					Annotations: map[string]string{
+						annotations.IngressClassKey: annotations.DefaultIngressClass,
+					},
+				},
+				PluginName: "request-termination",
+				Ordering:   ordering,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name         string
+		version      semver.Version
+		wantOrdering *kong.PluginOrdering
+	}{
+		{
+			name:         "ordering is kept for Kong versions supporting it",
+			version:      semver.MustParse("3.0.0"),
+			wantOrdering: ordering,
+		},
+		{
+			name:    "ordering is dropped for Kong versions not supporting it",
+			version: semver.MustParse("2.8.0"),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			state := KongState{
+				Version: tt.version,
+				Services: []Service{{
+					Service: kong.Service{Name: kong.String("foo-service")},
+					Routes: []Route{{
+						Route: kong.Route{Name: kong.String("foo-route")},
+						Ingress: util.K8sObjectInfo{
+							Name:      "foo-ingress",
+							Namespace: "default",
+							Annotations: map[string]string{
+								annotations.AnnotationPrefix + annotations.PluginsKey: "terminate",
+							},
+						},
+					}},
+				}},
+			}
+			state.FillPlugins(logrus.New(), s, nil, nil, false, nil)
+			require.Len(t, state.Plugins, 1)
+			assert.Equal(t, "foo-route", *state.Plugins[0].Route.ID)
+			assert.Equal(t, tt.wantOrdering, state.Plugins[0].Ordering)
+		})
+	}
+}
+
+func TestKongState_FillOverrides_RouteOverridesSurviveServiceFailure(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongIngresses: []*configurationv1.KongIngress{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "route-kongingress",
+					Namespace: "default",
+				},
+				Route: &configurationv1.KongIngressRoute{
+					Methods: kong.StringSlice("GET"),
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{
+		Services: []Service{{
+			Service: kong.Service{
+				Name:     kong.String("foo-service"),
+				Protocol: kong.String("http"),
+			},
+			K8sServices: map[string]*corev1.Service{
+				"foo-service": {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo-service",
+						Namespace: "default",
+						Annotations: map[string]string{
+							annotations.AnnotationPrefix + annotations.ConfigurationKey: "missing-kongingress",
+						},
+					},
+				},
+			},
+			Routes: []Route{{
+				Route: kong.Route{Name: kong.String("foo-route")},
+				Ingress: util.K8sObjectInfo{
+					Name:      "foo-ingress",
+					Namespace: "default",
+					Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.ConfigurationKey: "route-kongingress",
+					},
+				},
+			}},
+		}},
+	}
+	err = state.FillOverrides(logrus.New(), s)
+	assert.ErrorContains(t, err, "failed to fetch KongIngress resource for Services")
+	assert.Equal(t, kong.StringSlice("GET"), state.Services[0].Routes[0].Methods)
+}
+
+func TestKongState_FillOverrides_UpstreamPolicy(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongIngresses: []*configurationv1.KongIngress{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kongingress",
+					Namespace: "default",
+				},
+				Upstream: &configurationv1.KongIngressUpstream{
+					Algorithm: kong.String("round-robin"),
+					Slots:     kong.Int(100),
+				},
+			},
+		},
+		KongUpstreamPolicies: []*configurationv1beta1.KongUpstreamPolicy{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "policy",
+					Namespace: "default",
+				},
+				Spec: configurationv1beta1.KongUpstreamPolicySpec{
+					Algorithm: kong.String("consistent-hashing"),
+					HashOn: &configurationv1beta1.KongUpstreamHash{
+						Cookie:     kong.String("session"),
+						CookiePath: kong.String("/"),
+					},
+					HashOnFallback: &configurationv1beta1.KongUpstreamHash{
+						Header: kong.String("x-user"),
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name        string
+		anns        map[string]string
+		want        kong.Upstream
+		wantWarning bool
+	}{
+		{
+			name: "KongUpstreamPolicy only",
+			anns: map[string]string{
+				annotations.AnnotationPrefix + annotations.UpstreamPolicyKey: "policy",
+			},
+			want: kong.Upstream{
+				Name:               kong.String("foo-upstream"),
+				Algorithm:          kong.String("consistent-hashing"),
+				HashOn:             kong.String("cookie"),
+				HashOnCookie:       kong.String("session"),
+				HashOnCookiePath:   kong.String("/"),
+				HashFallback:       kong.String("header"),
+				HashFallbackHeader: kong.String("x-user"),
+			},
+		},
+		{
+			name: "KongIngress only",
+			anns: map[string]string{
+				annotations.AnnotationPrefix + annotations.ConfigurationKey: "kongingress",
+			},
+			want: kong.Upstream{
+				Name:      kong.String("foo-upstream"),
+				Algorithm: kong.String("round-robin"),
+				Slots:     kong.Int(100),
+			},
+		},
+		{
+			name: "KongUpstreamPolicy takes precedence over KongIngress",
+			anns: map[string]string{
+				annotations.AnnotationPrefix + annotations.ConfigurationKey:  "kongingress",
+				annotations.AnnotationPrefix + annotations.UpstreamPolicyKey: "policy",
+			},
+			want: kong.Upstream{
+				Name:               kong.String("foo-upstream"),
+				Algorithm:          kong.String("consistent-hashing"),
+				Slots:              kong.Int(100),
+				HashOn:             kong.String("cookie"),
+				HashOnCookie:       kong.String("session"),
+				HashOnCookiePath:   kong.String("/"),
+				HashFallback:       kong.String("header"),
+				HashFallbackHeader: kong.String("x-user"),
+			},
+			wantWarning: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			state := KongState{
+				Upstreams: []Upstream{{
+					Upstream: kong.Upstream{Name: kong.String("foo-upstream")},
+					Service: Service{
+						K8sServices: map[string]*corev1.Service{
+							"foo-service": {
+								ObjectMeta: metav1.ObjectMeta{
+									Name:        "foo-service",
+									Namespace:   "default",
+									Annotations: tt.anns,
+								},
+							},
+						},
+					},
+				}},
+			}
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+
+			require.NoError(t, state.FillOverrides(log, s))
+			assert.Equal(t, tt.want, state.Upstreams[0].Upstream)
+			assert.Equal(t, tt.wantWarning, strings.Contains(buf.String(), "KongIngress upstream settings are deprecated"))
+		})
+	}
+}
+
+func TestKongState_FillOverrides_UpstreamPolicyHealthchecks(t *testing.T) {
+	policy := &configurationv1beta1.KongUpstreamPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "default"},
+		Spec: configurationv1beta1.KongUpstreamPolicySpec{
+			Healthchecks: &kong.Healthcheck{
+				Active: &kong.ActiveHealthcheck{
+					Type:     kong.String("http"),
+					HTTPPath: kong.String("/healthz"),
+				},
+				Threshold: kong.Float64(50),
+			},
+			TargetWeight: kong.Int(10),
+		},
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongUpstreamPolicies: []*configurationv1beta1.KongUpstreamPolicy{policy},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		version       string
+		wantThreshold *float64
+	}{
+		{version: "1.5.0", wantThreshold: nil},
+		{version: "2.0.0", wantThreshold: kong.Float64(50)},
+		{version: "3.4.0", wantThreshold: kong.Float64(50)},
+	} {
+		t.Run(tt.version, func(t *testing.T) {
+			state := KongState{
+				Version: semver.MustParse(tt.version),
+				Upstreams: []Upstream{{
+					Upstream: kong.Upstream{Name: kong.String("foo-upstream")},
+					Targets: []Target{
+						{Target: kong.Target{Target: kong.String("10.0.0.1:80")}},
+						{Target: kong.Target{Target: kong.String("10.0.0.2:80"), Weight: kong.Int(50)}},
+					},
+					Service: Service{
+						K8sServices: map[string]*corev1.Service{
+							"foo-service": {
+								ObjectMeta: metav1.ObjectMeta{
+									Name:      "foo-service",
+									Namespace: "default",
+									Annotations: map[string]string{
+										annotations.AnnotationPrefix + annotations.UpstreamPolicyKey: "policy",
+									},
+								},
+							},
+						},
+					},
+				}},
+			}
+
+			require.NoError(t, state.FillOverrides(logrus.New(), s))
+			upstream := state.Upstreams[0]
+			require.NotNil(t, upstream.Healthchecks)
+			assert.Equal(t, policy.Spec.Healthchecks.Active, upstream.Healthchecks.Active)
+			assert.Equal(t, tt.wantThreshold, upstream.Healthchecks.Threshold)
+			assert.Equal(t, kong.Float64(50), policy.Spec.Healthchecks.Threshold, "the policy should not be modified")
+			assert.Equal(t, kong.Int(10), upstream.Targets[0].Weight, "targets without a weight should get the policy one")
+			assert.Equal(t, kong.Int(50), upstream.Targets[1].Weight, "weights of targets should take precedence")
+		})
+	}
+}
+
+type fakeCredentialMetrics map[string]int
+
+func (f fakeCredentialMetrics) RecordCredentialProvisioning(outcome, credType string) {
+	f[outcome+"/"+credType]++
+}
+
+func Test_FillConsumersAndCredentials_RecordsMetrics(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "valid", Namespace: "default"},
+				Data: map[string][]byte{
+					"kongCredType": []byte("key-auth"),
+					"key":          []byte("whatever"),
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "unsupported", Namespace: "default"},
+				Data: map[string][]byte{
+					"kongCredType": []byte("foo-auth"),
+					"key":          []byte("whatever"),
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default"},
+				Data: map[string][]byte{
+					"kongCredType": []byte("basic-auth"),
+				},
+			},
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:    "foo",
+				Credentials: []string{"valid", "unsupported", "empty", "missing"},
+			},
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "bar", Namespace: "default"},
+				Username:    "bar",
+				Credentials: []string{"valid"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	credMetrics := fakeCredentialMetrics{}
+	state := KongState{}
+	assert.Error(t, state.FillConsumersAndCredentials(logrus.New(), s, nil, nil, credMetrics, ConsumerFillOptions{}))
+	assert.Equal(t, fakeCredentialMetrics{
+		CredentialOutcomeProvisioned + "/key-auth":                2,
+		string(CredentialDiagnosticInvalidCredType) + "/foo-auth": 1,
+		string(CredentialDiagnosticEmptySecret) + "/basic-auth":   1,
+		string(CredentialDiagnosticSecretNotFound) + "/":          1,
+	}, credMetrics)
+}
+
+func Test_FillConsumersAndCredentials_MultipleCredentialsOfTheSameType(t *testing.T) {
+	keyAuthSecret := func(name, key string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+				"key":          []byte(key),
+			},
+		}
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			keyAuthSecret("first", "first-key"),
+			keyAuthSecret("second", "second-key"),
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:    "foo",
+				Credentials: []string{"second", "first"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{}
+	require.NoError(t, state.FillConsumersAndCredentials(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{}))
+	require.Len(t, state.Consumers, 1)
+	var keys []string
+	for _, keyAuth := range state.Consumers[0].KeyAuths {
+		keys = append(keys, *keyAuth.Key)
+	}
+	assert.Equal(t, []string{"first-key", "second-key"}, keys,
+		"both key-auth credentials should be kept, sorted by key")
+}
+
+func Test_FillConsumersAndCredentials_CredentialTypeSource(t *testing.T) {
+	consumer := &configurationv1.KongConsumer{
+		ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+		Username:    "foo",
+		Credentials: []string{"cred"},
+	}
+	for _, tt := range []struct {
+		name        string
+		secret      *corev1.Secret
+		credTypeKey string
+	}{
+		{
+			name: "default key",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "cred", Namespace: "default"},
+				Data: map[string][]byte{
+					"kongCredType": []byte("key-auth"),
+					"key":          []byte("secret"),
+				},
+			},
+		},
+		{
+			name: "custom key",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "cred", Namespace: "default"},
+				Data: map[string][]byte{
+					"type": []byte("key-auth"),
+					"key":  []byte("secret"),
+				},
+			},
+			credTypeKey: "type",
+		},
+		{
+			name: "label",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cred",
+					Namespace: "default",
+					Labels:    map[string]string{"konghq.com/credential": "key-auth"},
+				},
+				Data: map[string][]byte{
+					"key": []byte("secret"),
+				},
+			},
+		},
+		{
+			name: "key takes precedence over the label",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cred",
+					Namespace: "default",
+					Labels:    map[string]string{"konghq.com/credential": "basic-auth"},
+				},
+				Data: map[string][]byte{
+					"type": []byte("key-auth"),
+					"key":  []byte("secret"),
+				},
+			},
+			credTypeKey: "type",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := store.NewFakeStore(store.FakeObjects{
+				Secrets:       []*corev1.Secret{tt.secret},
+				KongConsumers: []*configurationv1.KongConsumer{consumer},
+			})
+			require.NoError(t, err)
+
+			state := KongState{}
+			diagnostics, err := state.FillConsumersAndCredentialsWithDiagnostics(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{CredTypeKey: tt.credTypeKey})
+			require.NoError(t, err)
+			assert.Empty(t, diagnostics)
+			require.Len(t, state.Consumers, 1)
+			require.Len(t, state.Consumers[0].KeyAuths, 1)
+			assert.Equal(t, "secret", *state.Consumers[0].KeyAuths[0].Key)
+		})
+	}
+}
+
+func Test_FillConsumersAndCredentials_StableCredentialOrdering(t *testing.T) {
+	credSecret := func(name string, data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string][]byte{},
+		}
+		for k, v := range data {
+			secret.Data[k] = []byte(v)
+		}
+		return secret
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			credSecret("key-c", map[string]string{"kongCredType": "key-auth", "key": "c"}),
+			credSecret("key-a", map[string]string{"kongCredType": "key-auth", "key": "a"}),
+			credSecret("key-b", map[string]string{"kongCredType": "key-auth", "key": "b"}),
+			credSecret("acl-admins", map[string]string{"kongCredType": "acl", "group": "admins"}),
+			credSecret("acl-users", map[string]string{"kongCredType": "acl", "group": "users"}),
+			credSecret("basic-bob", map[string]string{"kongCredType": "basic-auth", "username": "bob", "password": "x"}),
+			credSecret("basic-alice", map[string]string{"kongCredType": "basic-auth", "username": "alice", "password": "x"}),
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Username:   "foo",
+				Credentials: []string{
+					"key-c", "acl-users", "basic-bob", "key-a", "acl-admins", "basic-alice", "key-b",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		state := KongState{}
+		require.NoError(t, state.FillConsumersAndCredentials(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{}))
+		require.Len(t, state.Consumers, 1)
+		consumer := state.Consumers[0]
+
+		var keys, groups, usernames []string
+		for _, cred := range consumer.KeyAuths {
+			keys = append(keys, *cred.Key)
+		}
+		for _, cred := range consumer.ACLGroups {
+			groups = append(groups, *cred.Group)
+		}
+		for _, cred := range consumer.BasicAuths {
+			usernames = append(usernames, *cred.Username)
+		}
+		assert.Equal(t, []string{"a", "b", "c"}, keys)
+		assert.Equal(t, []string{"admins", "users"}, groups)
+		assert.Equal(t, []string{"alice", "bob"}, usernames)
+	}
+}
+
+// stateForOverrides returns a store with a few KongIngresses and a KongState
+// with services and routes using them.
+func stateForOverrides(t testing.TB, services, routesPerService int) (store.Storer, func() KongState) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongIngresses: []*configurationv1.KongIngress{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "proxy", Namespace: "default"},
+				Proxy: &configurationv1.KongIngressService{
+					Retries:        kong.Int(3),
+					ConnectTimeout: kong.Int(1000),
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "get", Namespace: "default"},
+				Route: &configurationv1.KongIngressRoute{
+					Methods: kong.StringSlice("GET"),
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "post", Namespace: "default"},
+				Route: &configurationv1.KongIngressRoute{
+					Methods:   kong.StringSlice("POST"),
+					StripPath: kong.Bool(true),
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	build := func() KongState {
+		var state KongState
+		for i := 0; i < services; i++ {
+			name := fmt.Sprintf("service-%d", i)
+			anns := map[string]string{}
+			if i%2 == 0 {
+				anns[annotations.AnnotationPrefix+annotations.ConfigurationKey] = "proxy"
+			}
+			service := Service{
+				Service: kong.Service{Name: kong.String(name), Protocol: kong.String("http")},
+				K8sServices: map[string]*corev1.Service{
+					name: {ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: anns}},
+				},
+			}
+			for j := 0; j < routesPerService; j++ {
+				kongIngress := "get"
+				if j%2 == 1 {
+					kongIngress = "post"
+				}
+				service.Routes = append(service.Routes, Route{
+					Route: kong.Route{Name: kong.String(fmt.Sprintf("%s-route-%d", name, j))},
+					Ingress: util.K8sObjectInfo{
+						Name:      fmt.Sprintf("%s-ingress-%d", name, j),
+						Namespace: "default",
+						Annotations: map[string]string{
+							annotations.AnnotationPrefix + annotations.ConfigurationKey: kongIngress,
+						},
+					},
+				})
+			}
+			state.Services = append(state.Services, service)
+		}
+		return state
+	}
+	return s, build
+}
+
+func TestKongState_FillOverridesWithConcurrency(t *testing.T) {
+	s, build := stateForOverrides(t, 50, 10)
+
+	serial := build()
+	require.NoError(t, serial.FillOverrides(logrus.New(), s))
+	require.Equal(t, 3, *serial.Services[0].Retries, "overrides should be applied")
+
+	for _, concurrency := range []int{0, 2, 8, 100} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			parallel := build()
+			require.NoError(t, parallel.FillOverridesWithConcurrency(logrus.New(), s, concurrency))
+			assert.Equal(t, serial, parallel)
+		})
+	}
+}
+
+func BenchmarkKongState_FillOverridesWithConcurrency(b *testing.B) {
+	s, build := stateForOverrides(b, 500, 20)
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency %d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				state := build()
+				b.StartTimer()
+				require.NoError(b, state.FillOverridesWithConcurrency(log, s, concurrency))
+			}
+		})
+	}
+}
+
+func Test_buildPlugins_Ordering(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{ObjectMeta: metav1.ObjectMeta{Name: "a-rate-limiting", Namespace: "default"}, PluginName: "rate-limiting"},
+			{ObjectMeta: metav1.ObjectMeta{Name: "z-key-auth", Namespace: "default"}, PluginName: "key-auth"},
+		},
+		KongClusterPlugins: []*configurationv1.KongClusterPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "global-cors",
+					Labels: map[string]string{"global": "true"},
+					Annotations: map[string]string{
+						annotations.IngressClassKey: annotations.DefaultIngressClass,
+					},
+				},
+				PluginName: "cors",
+			},
+		},
+	})
+	require.NoError(t, err)
+	pluginRels := map[kongPluginReference]util.ForeignRelations{
+		{Namespace: "default", Name: "a-rate-limiting"}: {
+			Route:    []string{"r2", "r1"},
+			Consumer: []string{"c2", "c1"},
+		},
+		{Namespace: "default", Name: "z-key-auth"}: {
+			Service: []string{"s2", "s1"},
+			Route:   []string{"r1"},
+		},
+	}
+	want := []string{
+		"key-auth route:r1",
+		"key-auth service:s1",
+		"key-auth service:s2",
+		"rate-limiting route:r1 consumer:c1",
+		"rate-limiting route:r1 consumer:c2",
+		"rate-limiting route:r2 consumer:c1",
+		"rate-limiting route:r2 consumer:c2",
+		"cors",
+	}
+
+	for i := 0; i < 10; i++ {
+		var got []string
+		plugins, _ := buildPlugins(logrus.New(), s, pluginRels, nil, false)
+		for _, p := range plugins {
+			got = append(got, pluginKey(p))
+		}
+		assert.Equal(t, want, got)
+	}
+}
+
+func Test_FillConsumersAndCredentials_NamespaceFilter(t *testing.T) {
+	var consumers []*configurationv1.KongConsumer
+	for _, namespace := range []string{"default", "staging", "prod"} {
+		consumers = append(consumers, &configurationv1.KongConsumer{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: namespace},
+			Username:   namespace + "-foo",
+		})
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{KongConsumers: consumers})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name   string
+		filter *NamespaceFilter
+		want   []string
+	}{
+		{
+			name: "no filter",
+			want: []string{"default-foo", "prod-foo", "staging-foo"},
+		},
+		{
+			name:   "denylist excludes a namespace",
+			filter: &NamespaceFilter{Deny: []string{"staging"}},
+			want:   []string{"default-foo", "prod-foo"},
+		},
+		{
+			name:   "allowlist includes a single namespace",
+			filter: &NamespaceFilter{Allow: []string{"prod"}},
+			want:   []string{"prod-foo"},
+		},
+		{
+			name:   "denylist takes precedence over allowlist",
+			filter: &NamespaceFilter{Allow: []string{"prod", "staging"}, Deny: []string{"staging"}},
+			want:   []string{"prod-foo"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+			log.SetLevel(logrus.DebugLevel)
+
+			state := KongState{}
+			require.NoError(t, state.FillConsumersAndCredentials(log, s, nil, nil, nil, ConsumerFillOptions{Namespaces: tt.filter}))
+			var got []string
+			for _, c := range state.Consumers {
+				got = append(got, *c.Username)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, 3-len(tt.want), strings.Count(buf.String(), "skipping KongConsumer from a filtered out namespace"))
+		})
+	}
+}
+
+func Test_FillConsumersAndCredentials_Selector(t *testing.T) {
+	var consumers []*configurationv1.KongConsumer
+	for name, rollout := range map[string]string{"bar": "canary", "baz": "stable", "foo": ""} {
+		consumer := &configurationv1.KongConsumer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Username:   name,
+		}
+		if rollout != "" {
+			consumer.Labels = map[string]string{"rollout": rollout}
+		}
+		consumers = append(consumers, consumer)
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{KongConsumers: consumers})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name     string
+		selector labels.Selector
+		want     []string
+	}{
+		{
+			name: "nil selector matches all KongConsumers",
+			want: []string{"bar", "baz", "foo"},
+		},
+		{
+			name:     "selector matches a subset of KongConsumers",
+			selector: labels.SelectorFromSet(labels.Set{"rollout": "canary"}),
+			want:     []string{"bar"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+			log.SetLevel(logrus.DebugLevel)
+
+			state := KongState{}
+			require.NoError(t, state.FillConsumersAndCredentials(log, s, nil, nil, nil, ConsumerFillOptions{Selector: tt.selector}))
+			var got []string
+			for _, c := range state.Consumers {
+				got = append(got, *c.Username)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, 3-len(tt.want), strings.Count(buf.String(), "skipping KongConsumer not matching the consumer selector"))
+		})
+	}
+}
+
+func Test_FillConsumersAndCredentials_ConflictingConsumers(t *testing.T) {
+	now := time.Now()
+	consumer := func(namespace, name, username, customID string, created time.Time) *configurationv1.KongConsumer {
+		return &configurationv1.KongConsumer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Username: username,
+			CustomID: customID,
+		}
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongConsumers: []*configurationv1.KongConsumer{
+			consumer("team-a", "alice", "alice", "", now.Add(-time.Hour)),
+			consumer("team-b", "alice", "alice", "alice-b", now),
+			consumer("team-c", "other", "other", "alice-b", now.Add(time.Hour)),
+			consumer("team-d", "bob", "", "bob", now),
+		},
+	})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	log := logrus.New()
+	log.SetOutput(buf)
+
+	state := KongState{}
+	err = state.FillConsumersAndCredentials(log, s, nil, nil, nil, ConsumerFillOptions{})
+	assert.EqualError(t, err, `KongConsumer team-b/alice was dropped as it shares its username "alice" with KongConsumer team-a/alice`)
+	var got []string
+	for _, c := range state.Consumers {
+		got = append(got, c.K8sKongConsumer.Namespace+"/"+c.K8sKongConsumer.Name)
+	}
+	assert.Equal(t, []string{"team-a/alice", "team-c/other", "team-d/bob"}, got,
+		"the newer consumer sharing a username should be dropped, without affecting consumers conflicting with it only")
+	assert.Contains(t, buf.String(), "multiple KongConsumers use the same username, only the oldest one (team-a/alice) will be applied")
+	assert.Contains(t, buf.String(), "kongconsumers=\"[team-a/alice team-b/alice]\"")
+}
+
+func Test_FillConsumersAndCredentials_ConflictingCustomIDs(t *testing.T) {
+	now := time.Now()
+	consumer := func(name string, created time.Time) *configurationv1.KongConsumer {
+		return &configurationv1.KongConsumer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Username: name,
+			CustomID: "shared-id",
+		}
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongConsumers: []*configurationv1.KongConsumer{
+			consumer("newer", now),
+			consumer("older", now.Add(-time.Hour)),
+		},
+	})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	log := logrus.New()
+	log.SetOutput(buf)
+
+	state := KongState{}
+	diagnostics, err := state.FillConsumersAndCredentialsWithDiagnostics(log, s, nil, nil, nil, ConsumerFillOptions{})
+	assert.EqualError(t, err, `KongConsumer default/newer was dropped as it shares its custom_id "shared-id" with KongConsumer default/older`)
+	assert.Empty(t, diagnostics, "conflicts are not about credentials")
+	require.Len(t, state.Consumers, 1)
+	assert.Equal(t, "older", *state.Consumers[0].Username, "the oldest consumer should be kept")
+	assert.Contains(t, buf.String(), "multiple KongConsumers use the same custom_id, only the oldest one (default/older) will be applied")
+}
+
+func Test_buildPlugins_PluginNamesWithColons(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "rate:limiting:v2", Namespace: "ns:1"},
+				PluginName: "rate-limiting",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{
+		Services: []Service{{
+			Service: kong.Service{Name: kong.String("foo-service")},
+			Routes: []Route{{
+				Route: kong.Route{Name: kong.String("foo-route")},
+				Ingress: util.K8sObjectInfo{
+					Name:      "foo-ingress",
+					Namespace: "ns:1",
+					Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.PluginsKey: "rate:limiting:v2",
+					},
+				},
+			}},
+		}},
+	}
+
+	pluginRels := state.getPluginRelations(logrus.New())
+	assert.Equal(t, map[kongPluginReference]util.ForeignRelations{
+		{Namespace: "ns:1", Name: "rate:limiting:v2"}: {Route: []string{"foo-route"}},
+	}, pluginRels)
+
+	plugins, unresolved := buildPlugins(logrus.New(), s, pluginRels, nil, false)
+	assert.Empty(t, unresolved)
+	require.Len(t, plugins, 1)
+	assert.Equal(t, "rate-limiting", *plugins[0].Name)
+	assert.Equal(t, "foo-route", *plugins[0].Route.ID)
+}
+
+func Test_buildPlugins_KongClusterPluginAttachedToRoute(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongClusterPlugins: []*configurationv1.KongClusterPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "shared-rate-limiting",
+					Annotations: map[string]string{
+						annotations.IngressClassKey: annotations.DefaultIngressClass,
+					},
+				},
+				PluginName: "rate-limiting",
+				Config: apiextensionsv1.JSON{
+					Raw: []byte(`{"minute": 10}`),
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	route := func(name string, anns map[string]string) Route {
+		return Route{
+			Route: kong.Route{Name: kong.String(name)},
+			Ingress: util.K8sObjectInfo{
+				Name:        name,
+				Namespace:   "default",
+				Annotations: anns,
+			},
+		}
+	}
+	state := KongState{
+		Services: []Service{{
+			Service: kong.Service{Name: kong.String("foo-service")},
+			Routes: []Route{
+				route("limited", map[string]string{
+					annotations.AnnotationPrefix + annotations.PluginsKey: "shared-rate-limiting",
+				}),
+				route("unlimited", nil),
+			},
+		}},
+	}
+
+	plugins, unresolved := buildPlugins(logrus.New(), s, state.getPluginRelations(logrus.New()), nil, false)
+	assert.Empty(t, unresolved)
+	require.Len(t, plugins, 1, "the KongClusterPlugin should only be attached to the route referencing it")
+	assert.Equal(t, kong.Plugin{
+		Name:   kong.String("rate-limiting"),
+		Route:  &kong.Route{ID: kong.String("limited")},
+		Config: kong.Configuration{"minute": float64(10)},
+	}, plugins[0].Plugin)
+}
+
+func Test_buildPlugins_UnresolvedReferences(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "existing",
+					Namespace: "default",
+				},
+				PluginName: "key-auth",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	pluginRels := map[kongPluginReference]util.ForeignRelations{
+		{Namespace: "default", Name: "existing"}: {Route: []string{"foo-route"}},
+		{Namespace: "default", Name: "missing"}: {
+			Route:   []string{"foo-route"},
+			Service: []string{"bar-service"},
+		},
+	}
+
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+
+	plugins, unresolved := buildPlugins(log, s, pluginRels, nil, false)
+	require.Len(t, plugins, 1)
+	assert.Equal(t, "key-auth", *plugins[0].Name)
+
+	require.Len(t, unresolved, 1)
+	assert.Equal(t, "default", unresolved[0].Namespace)
+	assert.Equal(t, "missing", unresolved[0].Name)
+	assert.Equal(t, util.ForeignRelations{
+		Route:   []string{"foo-route"},
+		Service: []string{"bar-service"},
+	}, unresolved[0].Targets)
+	assert.Error(t, unresolved[0].Err)
+	assert.Contains(t, logs.String(), "failed to fetch KongPlugin", "the unresolved reference should still be logged")
+}
+
+func TestKongState_FillPlugins_Summary(t *testing.T) {
+	kongPlugin := func(name, pluginName string) *configurationv1.KongPlugin {
+		return &configurationv1.KongPlugin{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			PluginName: pluginName,
+		}
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			kongPlugin("auth", "key-auth"),
+			kongPlugin("limit", "rate-limiting"),
+			kongPlugin("cors", "cors"),
+		},
+		KongClusterPlugins: []*configurationv1.KongClusterPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "metrics",
+					Labels: map[string]string{"global": "true"},
+					Annotations: map[string]string{
+						annotations.IngressClassKey: annotations.DefaultIngressClass,
+					},
+				},
+				PluginName: "prometheus",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	pluginsAnnotation := func(plugins string) map[string]string {
+		return map[string]string{annotations.AnnotationPrefix + annotations.PluginsKey: plugins}
+	}
+	route := func(name, plugins string) Route {
+		return Route{
+			Route: kong.Route{Name: kong.String(name)},
+			Ingress: util.K8sObjectInfo{
+				Name:        name,
+				Namespace:   "default",
+				Annotations: pluginsAnnotation(plugins),
+			},
+		}
+	}
+	state := KongState{
+		Services: []Service{{
+			Service: kong.Service{Name: kong.String("foo-service")},
+			K8sServices: map[string]*corev1.Service{
+				"foo-service": {
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace:   "default",
+						Annotations: pluginsAnnotation("auth"),
+					},
+				},
+			},
+			Routes: []Route{
+				route("foo-route", "limit,cors,missing"),
+				route("bar-route", "cors"),
+			},
+		}},
+		Consumers: []Consumer{{
+			Consumer: kong.Consumer{Username: kong.String("alice")},
+			K8sKongConsumer: configurationv1.KongConsumer{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "alice",
+					Namespace:   "default",
+					Annotations: pluginsAnnotation("limit"),
+				},
+			},
+		}},
+	}
+
+	summary := state.FillPlugins(logrus.New(), s, nil, nil, false, nil)
+	// auth is attached to the service, cors to both routes, limit to the combination of
+	// foo-route and alice, and metrics is global
+	assert.Equal(t, 1, summary.ServicePlugins)
+	assert.Equal(t, 3, summary.RoutePlugins)
+	assert.Equal(t, 1, summary.ConsumerPlugins)
+	assert.Equal(t, 1, summary.GlobalPlugins)
+	require.Len(t, summary.Skipped, 1)
+	assert.Equal(t, "missing", summary.Skipped[0].Name)
+	assert.Len(t, state.Plugins, 5, "the state plugins should still be set")
+}
+
+func TestKongState_PluginRelations(t *testing.T) {
+	state := KongState{
+		Services: []Service{{
+			Service:   kong.Service{Name: kong.String("foo-service")},
+			Namespace: "ns1",
+			K8sServices: map[string]*corev1.Service{
+				"foo": {ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "ns1",
+					Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.PluginsKey: "cors",
+					},
+				}},
+			},
+			Routes: []Route{
+				{
+					Route: kong.Route{Name: kong.String("foo-route")},
+					Ingress: util.K8sObjectInfo{Name: "foo", Namespace: "ns1", Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.PluginsKey: "key-auth,cors",
+					}},
+				},
+				{
+					Route: kong.Route{Name: kong.String("bar-route")},
+					Ingress: util.K8sObjectInfo{Name: "bar", Namespace: "ns1", Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.PluginsKey: "key-auth",
+					}},
+				},
+			},
+		}},
+	}
+
+	want := map[string]util.ForeignRelations{
+		"ns1/cors":     {Service: []string{"foo-service"}, Route: []string{"foo-route"}},
+		"ns1/key-auth": {Route: []string{"foo-route", "bar-route"}},
+	}
+	rels := state.PluginRelations(logrus.New())
+	assert.Equal(t, want, rels)
+
+	// modifying the returned relations doesn't affect later calls
+	rels["ns1/key-auth"].Route[0] = "modified"
+	delete(rels, "ns1/cors")
+	assert.Equal(t, want, state.PluginRelations(logrus.New()))
+}
+
+func Test_getPluginRelations_SkipsEntitiesWithoutName(t *testing.T) {
+	pluginsAnnotation := map[string]string{annotations.AnnotationPrefix + annotations.PluginsKey: "foo"}
+	state := KongState{
+		Services: []Service{
+			{
+				Namespace: "ns1",
+				K8sServices: map[string]*corev1.Service{
+					"unnamed": {ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Annotations: pluginsAnnotation}},
+				},
+				Routes: []Route{{
+					Route:   kong.Route{Name: kong.String("route-of-unnamed-service")},
+					Ingress: util.K8sObjectInfo{Name: "ingress", Namespace: "ns1", Annotations: pluginsAnnotation},
+				}},
+			},
+			{
+				Service: kong.Service{Name: kong.String("foo-service")},
+				Routes: []Route{
+					{
+						Ingress: util.K8sObjectInfo{Name: "unnamed-route", Namespace: "ns1", Annotations: pluginsAnnotation},
+					},
+					{
+						Route:   kong.Route{Name: kong.String("foo-route")},
+						Ingress: util.K8sObjectInfo{Name: "ingress", Namespace: "ns1", Annotations: pluginsAnnotation},
+					},
+				},
+			},
+		},
+	}
+
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+
+	var got map[kongPluginReference]util.ForeignRelations
+	require.NotPanics(t, func() { got = state.getPluginRelations(log) })
+	assert.Equal(t, map[kongPluginReference]util.ForeignRelations{
+		{Namespace: "ns1", Name: "foo"}: {Route: []string{"foo-route"}},
+	}, got)
+	assert.Contains(t, logs.String(), "skipping plugins of a service without a name")
+	assert.Contains(t, logs.String(), "skipping plugins of a route without a name")
+	assert.Contains(t, logs.String(), "unnamed-route")
+}
+
+// pluginNamespaceStorer resolves every KongPlugin reference to the KongPlugin with the same
+// name in namespace, regardless of the namespace of the referencing object.
+type pluginNamespaceStorer struct {
+	store.Storer
+	namespace string
+}
+
+func (s pluginNamespaceStorer) GetKongPlugin(_, name string) (*configurationv1.KongPlugin, error) {
+	return s.Storer.GetKongPlugin(s.namespace, name)
+}
+
+func Test_buildPlugins_NamespaceIsolation(t *testing.T) {
+	fakeStore, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "rate-limiting", Namespace: "team-a"},
+				PluginName: "rate-limiting",
+			},
+		},
+	})
+	require.NoError(t, err)
+	s := pluginNamespaceStorer{Storer: fakeStore, namespace: "team-a"}
+
+	for _, tt := range []struct {
+		name              string
+		referrerNamespace string
+		isolateNamespaces bool
+		wantPlugins       int
+		wantLog           bool
+	}{
+		{
+			name:              "same namespace attachment is allowed in strict mode",
+			referrerNamespace: "team-a",
+			isolateNamespaces: true,
+			wantPlugins:       1,
+		},
+		{
+			name:              "cross-namespace attachment is dropped in strict mode",
+			referrerNamespace: "team-b",
+			isolateNamespaces: true,
+			wantLog:           true,
+		},
+		{
+			name:              "cross-namespace attachment is allowed by default",
+			referrerNamespace: "team-b",
+			wantPlugins:       1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&logs)
+
+			pluginRels := map[kongPluginReference]util.ForeignRelations{
+				{Namespace: tt.referrerNamespace, Name: "rate-limiting"}: {Service: []string{"foo-service"}},
+			}
+			plugins, unresolved := buildPlugins(log, s, pluginRels, nil, tt.isolateNamespaces)
+			assert.Len(t, plugins, tt.wantPlugins)
+			if tt.wantLog {
+				require.Len(t, unresolved, 1)
+				assert.Equal(t, tt.referrerNamespace, unresolved[0].Namespace)
+				assert.Contains(t, logs.String(), "dropping cross-namespace KongPlugin attachment")
+			} else {
+				assert.Empty(t, unresolved)
+				assert.NotContains(t, logs.String(), "cross-namespace")
+			}
+		})
+	}
+}
+
+func TestKongState_FillPlugins_DisabledAttachment(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "rate-limit", Namespace: "default"},
+				PluginName: "rate-limiting",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	route := func(name string, anns map[string]string) Route {
+		anns[annotations.AnnotationPrefix+annotations.PluginsKey] = "rate-limit"
+		return Route{
+			Route:   kong.Route{Name: kong.String(name)},
+			Ingress: util.K8sObjectInfo{Name: name, Namespace: "default", Annotations: anns},
+		}
+	}
+	state := KongState{
+		Services: []Service{{
+			Service: kong.Service{Name: kong.String("foo-service")},
+			Routes: []Route{
+				route("enabled-route", map[string]string{}),
+				route("disabled-route", map[string]string{"konghq.com/plugin-rate-limit-enabled": "false"}),
+			},
+		}},
+	}
+	state.FillPlugins(logrus.New(), s, nil, nil, false, nil)
+
+	enabled := map[string]*bool{}
+	for _, plugin := range state.Plugins {
+		enabled[*plugin.Route.ID] = plugin.Enabled
+	}
+	assert.Equal(t, map[string]*bool{
+		"enabled-route":  nil,
+		"disabled-route": kong.Bool(false),
+	}, enabled)
+}
+
+func TestKongState_FillPlugins_ConsumerGroups(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold-limits", Namespace: "default"},
+				PluginName: "rate-limiting-advanced",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	newState := func(version string) KongState {
+		return KongState{
+			Version: semver.MustParse(version),
+			ConsumerGroups: []ConsumerGroup{{
+				Name: kong.String("gold"),
+				K8sKongConsumerGroup: configurationv1beta1.KongConsumerGroup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "gold",
+						Namespace: "default",
+						Annotations: map[string]string{
+							annotations.AnnotationPrefix + annotations.PluginsKey: "gold-limits",
+						},
+					},
+				},
+			}},
+		}
+	}
+
+	t.Run("plugins are scoped to consumer groups", func(t *testing.T) {
+		state := newState("3.4.0")
+		assert.Equal(t, map[string]util.ForeignRelations{
+			"default/gold-limits": {ConsumerGroup: []string{"gold"}},
+		}, state.PluginRelations(logrus.New()))
+
+		summary := state.FillPlugins(logrus.New(), s, nil, nil, false, nil)
+		require.Len(t, state.Plugins, 1)
+		assert.Equal(t, "rate-limiting-advanced", *state.Plugins[0].Name)
+		assert.Equal(t, kong.String("gold"), state.Plugins[0].ConsumerGroup)
+		assert.Nil(t, state.Plugins[0].Consumer)
+		assert.Equal(t, 1, summary.ConsumerGroupPlugins)
+		assert.Equal(t, 0, summary.GlobalPlugins)
+		assert.NoError(t, state.Validate())
+	})
+
+	t.Run("plugins are dropped on older Kong versions", func(t *testing.T) {
+		var logs bytes.Buffer
+		log := logrus.New()
+		log.SetOutput(&logs)
+
+		state := newState("3.3.0")
+		state.FillPlugins(log, s, nil, nil, false, nil)
+		assert.Empty(t, state.Plugins)
+		assert.Contains(t, logs.String(), "plugins scoped to consumer groups require Kong 3.4.0 or newer")
+	})
+}
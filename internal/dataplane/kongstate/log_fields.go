@@ -0,0 +1,64 @@
+package kongstate
+
+import (
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Log fields set by FillConsumersAndCredentials, FillOverrides and the plugin builders on the
+// entries about a Kubernetes object, so that the entries can be matched in the same way
+// whatever the method which logged them. Entries about a failure also carry logFieldReason.
+const (
+	logFieldNamespace = "namespace"
+	logFieldName      = "name"
+	logFieldKind      = "kind"
+	logFieldReason    = "reason"
+)
+
+// Reasons logged with logFieldReason, in addition to the CredentialDiagnosticReasons.
+const (
+	logReasonFilteredNamespace             = "FilteredNamespace"
+	logReasonSelectorMismatch              = "SelectorMismatch"
+	logReasonMissingConsumerIdentifier     = "MissingConsumerIdentifier"
+	logReasonKongIngressFetchFailed        = "KongIngressFetchFailed"
+	logReasonKongUpstreamPolicyFetchFailed = "KongUpstreamPolicyFetchFailed"
+	logReasonPluginFetchFailed             = "PluginFetchFailed"
+	logReasonCrossNamespaceReference       = "CrossNamespaceReference"
+	logReasonEmptyPluginName               = "EmptyPluginName"
+	logReasonInvalidConfiguration          = "InvalidConfiguration"
+	logReasonDuplicateGlobalPlugin         = "DuplicateGlobalPlugin"
+)
+
+// objectLogFields returns the log fields identifying a Kubernetes object. The namespace
+// is omitted for cluster-scoped objects.
+func objectLogFields(kind, namespace, name string) logrus.Fields {
+	fields := logrus.Fields{
+		logFieldKind: kind,
+		logFieldName: name,
+	}
+	if namespace != "" {
+		fields[logFieldNamespace] = namespace
+	}
+	return fields
+}
+
+// failureLogFields returns the log fields identifying a Kubernetes object along with
+// the reason of a failure about it.
+func failureLogFields(kind, namespace, name, reason string) logrus.Fields {
+	fields := objectLogFields(kind, namespace, name)
+	fields[logFieldReason] = reason
+	return fields
+}
+
+// servicesFailureLogFields returns the log fields of a failure about the Kubernetes Services
+// backing a Kong Service. The first Service in namespace/name order identifies the group,
+// and all of them are listed in the services field.
+func servicesFailureLogFields(services map[string]*corev1.Service, reason string) logrus.Fields {
+	var namespace, name string
+	if sorted := sortedServices(services); len(sorted) > 0 {
+		namespace, name = sorted[0].Namespace, sorted[0].Name
+	}
+	fields := failureLogFields("Service", namespace, name, reason)
+	fields["services"] = PrettyPrintServiceList(services)
+	return fields
+}
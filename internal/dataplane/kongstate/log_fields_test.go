@@ -0,0 +1,197 @@
+package kongstate
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+// logFieldsByReason returns the fields of the entries logged in JSON to logs which have a reason,
+// keyed by reason.
+func logFieldsByReason(t *testing.T, logs *bytes.Buffer) map[string]map[string]interface{} {
+	res := map[string]map[string]interface{}{}
+	dec := json.NewDecoder(logs)
+	for dec.More() {
+		var entry map[string]interface{}
+		require.NoError(t, dec.Decode(&entry))
+		if reason, ok := entry[logFieldReason].(string); ok {
+			res[reason] = entry
+		}
+	}
+	return res
+}
+
+func newJSONLogger(logs *bytes.Buffer) *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(logs)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetLevel(logrus.DebugLevel)
+	return log
+}
+
+// assertObjectLogFields asserts that the entry logged for reason identifies the object.
+func assertObjectLogFields(t *testing.T, entries map[string]map[string]interface{}, reason, kind, namespace, name string) {
+	t.Helper()
+	entry, ok := entries[reason]
+	if !assert.Truef(t, ok, "no entry logged with reason %s", reason) {
+		return
+	}
+	assert.Equal(t, kind, entry[logFieldKind], reason)
+	assert.Equal(t, name, entry[logFieldName], reason)
+	if namespace == "" {
+		assert.NotContains(t, entry, logFieldNamespace, reason)
+	} else {
+		assert.Equal(t, namespace, entry[logFieldNamespace], reason)
+	}
+}
+
+func TestLogFields_FillConsumersAndCredentials(t *testing.T) {
+	secret := func(name string, data map[string]string) *corev1.Secret {
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string][]byte{},
+		}
+		for k, v := range data {
+			s.Data[k] = []byte(v)
+		}
+		return s
+	}
+	consumer := func(name string, credentials ...string) *configurationv1.KongConsumer {
+		return &configurationv1.KongConsumer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Annotations: map[string]string{annotations.IngressClassKey: annotations.DefaultIngressClass},
+			},
+			Username:    name,
+			Credentials: credentials,
+		}
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			secret("invalid-type", map[string]string{"kongCredType": "foo-auth", "key": "foo"}),
+			secret("empty", map[string]string{"kongCredType": "key-auth"}),
+			secret("invalid", map[string]string{"kongCredType": "key-auth", "foo": "bar"}),
+		},
+		KongConsumers: []*configurationv1.KongConsumer{
+			consumer("missing-secret", "missing"),
+			consumer("invalid-type", "invalid-type"),
+			consumer("empty", "empty"),
+			consumer("invalid", "invalid"),
+		},
+	})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	var state KongState
+	require.Error(t, state.FillConsumersAndCredentials(newJSONLogger(&logs), s, nil, nil, nil, ConsumerFillOptions{}))
+
+	entries := logFieldsByReason(t, &logs)
+	for reason, consumerName := range map[CredentialDiagnosticReason]string{
+		CredentialDiagnosticSecretNotFound:    "missing-secret",
+		CredentialDiagnosticInvalidCredType:   "invalid-type",
+		CredentialDiagnosticEmptySecret:       "empty",
+		CredentialDiagnosticInvalidCredential: "invalid",
+	} {
+		assertObjectLogFields(t, entries, string(reason), "KongConsumer", "default", consumerName)
+	}
+}
+
+func TestLogFields_FillOverrides(t *testing.T) {
+	k8sService := func(name string, anns map[string]string) map[string]*corev1.Service {
+		return map[string]*corev1.Service{
+			"default/" + name: {ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: anns}},
+		}
+	}
+	withKongIngress := Service{
+		Service: kong.Service{Name: kong.String("default.with-kongingress.80")},
+		K8sServices: k8sService("with-kongingress", map[string]string{
+			annotations.AnnotationPrefix + annotations.ConfigurationKey: "missing",
+		}),
+	}
+	withPolicy := Service{
+		Service: kong.Service{Name: kong.String("default.with-policy.80")},
+		K8sServices: k8sService("with-policy", map[string]string{
+			annotations.AnnotationPrefix + annotations.UpstreamPolicyKey: "missing",
+		}),
+	}
+	state := KongState{
+		Services:  []Service{withKongIngress},
+		Upstreams: []Upstream{{Service: withPolicy}},
+	}
+	s, err := store.NewFakeStore(store.FakeObjects{})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	require.Error(t, state.FillOverrides(newJSONLogger(&logs), s))
+
+	entries := logFieldsByReason(t, &logs)
+	assertObjectLogFields(t, entries, logReasonKongIngressFetchFailed, "Service", "default", "with-kongingress")
+	assertObjectLogFields(t, entries, logReasonKongUpstreamPolicyFetchFailed, "Service", "default", "with-policy")
+}
+
+func TestLogFields_BuildPlugins(t *testing.T) {
+	ingressClass := map[string]string{annotations.IngressClassKey: annotations.DefaultIngressClass}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "rate-limiting", Namespace: "team-a"},
+				PluginName: "rate-limiting",
+			},
+		},
+		KongClusterPlugins: []*configurationv1.KongClusterPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "no-name",
+					Labels:      map[string]string{"global": "true"},
+					Annotations: ingressClass,
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "missing-config",
+					Labels:      map[string]string{"global": "true"},
+					Annotations: ingressClass,
+				},
+				PluginName: "key-auth",
+				ConfigFrom: &configurationv1.NamespacedConfigSource{
+					SecretValue: configurationv1.NamespacedSecretValueFromSource{
+						Namespace: "default",
+						Secret:    "missing",
+						Key:       "config",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	pluginRels := map[kongPluginReference]util.ForeignRelations{
+		{Namespace: "default", Name: "missing"}: {Service: []string{"foo-service"}},
+	}
+	buildPlugins(newJSONLogger(&logs), s, pluginRels, nil, false)
+	crossNamespace := pluginNamespaceStorer{Storer: s, namespace: "team-a"}
+	pluginRels = map[kongPluginReference]util.ForeignRelations{
+		{Namespace: "team-b", Name: "rate-limiting"}: {Service: []string{"foo-service"}},
+	}
+	buildPlugins(newJSONLogger(&logs), crossNamespace, pluginRels, nil, true)
+
+	entries := logFieldsByReason(t, &logs)
+	assertObjectLogFields(t, entries, logReasonPluginFetchFailed, "KongPlugin", "default", "missing")
+	assertObjectLogFields(t, entries, logReasonCrossNamespaceReference, "KongPlugin", "team-a", "rate-limiting")
+	assertObjectLogFields(t, entries, logReasonEmptyPluginName, "KongClusterPlugin", "", "no-name")
+	assertObjectLogFields(t, entries, logReasonInvalidConfiguration, "KongClusterPlugin", "", "missing-config")
+}
@@ -0,0 +1,97 @@
+package kongstate
+
+import (
+	"sync"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+// lookupCache wraps a store.Storer to memoize KongIngress, KongPlugin and KongClusterPlugin
+// lookups, keyed by namespace/name. Failed lookups are memoized too. It must only be used
+// for a single Fill pass so that objects updated in between reconciliations aren't served
+// stale: a new lookupCache should be created with newLookupCache on every pass.
+// It's safe for concurrent use.
+type lookupCache struct {
+	store.Storer
+
+	// lock guards the maps below. It's not held during store lookups, so concurrent
+	// lookups of the same object may all hit the store.
+	lock sync.Mutex
+
+	kongIngresses      map[string]kongIngressLookup
+	kongPlugins        map[string]kongPluginLookup
+	kongClusterPlugins map[string]kongClusterPluginLookup
+}
+
+type kongIngressLookup struct {
+	kongIngress *configurationv1.KongIngress
+	err         error
+}
+
+type kongPluginLookup struct {
+	kongPlugin *configurationv1.KongPlugin
+	err        error
+}
+
+type kongClusterPluginLookup struct {
+	kongClusterPlugin *configurationv1.KongClusterPlugin
+	err               error
+}
+
+// newLookupCache creates a lookupCache serving lookups from s.
+func newLookupCache(s store.Storer) *lookupCache {
+	return &lookupCache{
+		Storer:             s,
+		kongIngresses:      make(map[string]kongIngressLookup),
+		kongPlugins:        make(map[string]kongPluginLookup),
+		kongClusterPlugins: make(map[string]kongClusterPluginLookup),
+	}
+}
+
+// GetKongIngress returns the 'name' KongIngress resource in namespace.
+func (c *lookupCache) GetKongIngress(namespace, name string) (*configurationv1.KongIngress, error) {
+	key := namespace + "/" + name
+	c.lock.Lock()
+	res, ok := c.kongIngresses[key]
+	c.lock.Unlock()
+	if ok {
+		return res.kongIngress, res.err
+	}
+	kongIngress, err := c.Storer.GetKongIngress(namespace, name)
+	c.lock.Lock()
+	c.kongIngresses[key] = kongIngressLookup{kongIngress: kongIngress, err: err}
+	c.lock.Unlock()
+	return kongIngress, err
+}
+
+// GetKongPlugin returns the 'name' KongPlugin resource in namespace.
+func (c *lookupCache) GetKongPlugin(namespace, name string) (*configurationv1.KongPlugin, error) {
+	key := namespace + "/" + name
+	c.lock.Lock()
+	res, ok := c.kongPlugins[key]
+	c.lock.Unlock()
+	if ok {
+		return res.kongPlugin, res.err
+	}
+	kongPlugin, err := c.Storer.GetKongPlugin(namespace, name)
+	c.lock.Lock()
+	c.kongPlugins[key] = kongPluginLookup{kongPlugin: kongPlugin, err: err}
+	c.lock.Unlock()
+	return kongPlugin, err
+}
+
+// GetKongClusterPlugin returns the 'name' KongClusterPlugin resource.
+func (c *lookupCache) GetKongClusterPlugin(name string) (*configurationv1.KongClusterPlugin, error) {
+	c.lock.Lock()
+	res, ok := c.kongClusterPlugins[name]
+	c.lock.Unlock()
+	if ok {
+		return res.kongClusterPlugin, res.err
+	}
+	kongClusterPlugin, err := c.Storer.GetKongClusterPlugin(name)
+	c.lock.Lock()
+	c.kongClusterPlugins[name] = kongClusterPluginLookup{kongClusterPlugin: kongClusterPlugin, err: err}
+	c.lock.Unlock()
+	return kongClusterPlugin, err
+}
@@ -0,0 +1,104 @@
+package kongstate
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+// countingStorer counts the KongIngress lookups made against the wrapped store.Storer.
+type countingStorer struct {
+	store.Storer
+	kongIngressLookups int
+}
+
+func (s *countingStorer) GetKongIngress(namespace, name string) (*configurationv1.KongIngress, error) {
+	s.kongIngressLookups++
+	return s.Storer.GetKongIngress(namespace, name)
+}
+
+// stateWithSharedKongIngress returns a store with a single KongIngress and a KongState
+// with a service whose routes all use it.
+func stateWithSharedKongIngress(t testing.TB, routes int) (*countingStorer, KongState) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongIngresses: []*configurationv1.KongIngress{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default"},
+				Route: &configurationv1.KongIngressRoute{
+					Methods: kong.StringSlice("GET"),
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	service := Service{
+		Service: kong.Service{Name: kong.String("foo-service")},
+		K8sServices: map[string]*corev1.Service{
+			"foo-service": {ObjectMeta: metav1.ObjectMeta{Name: "foo-service", Namespace: "default"}},
+		},
+	}
+	for i := 0; i < routes; i++ {
+		service.Routes = append(service.Routes, Route{
+			Route: kong.Route{Name: kong.String(fmt.Sprintf("route-%d", i))},
+			Ingress: util.K8sObjectInfo{
+				Name:      fmt.Sprintf("ingress-%d", i),
+				Namespace: "default",
+				Annotations: map[string]string{
+					annotations.AnnotationPrefix + annotations.ConfigurationKey: "shared",
+				},
+			},
+		})
+	}
+	return &countingStorer{Storer: s}, KongState{Services: []Service{service}}
+}
+
+func TestKongState_FillOverrides_MemoizesKongIngressLookups(t *testing.T) {
+	s, state := stateWithSharedKongIngress(t, 100)
+
+	require.NoError(t, state.FillOverrides(logrus.New(), s))
+	assert.Equal(t, 1, s.kongIngressLookups, "the shared KongIngress should be fetched once per pass")
+	for _, route := range state.Services[0].Routes {
+		assert.Equal(t, kong.StringSlice("GET"), route.Methods)
+	}
+
+	require.NoError(t, state.FillOverrides(logrus.New(), s))
+	assert.Equal(t, 2, s.kongIngressLookups, "lookups should not be memoized across passes")
+}
+
+func BenchmarkKongIngressLookups(b *testing.B) {
+	for _, bb := range []struct {
+		name     string
+		memoized bool
+	}{
+		{name: "store"},
+		{name: "lookupCache", memoized: true},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			s, state := stateWithSharedKongIngress(b, 1000)
+			routes := state.Services[0].Routes
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var storer store.Storer = s
+				if bb.memoized {
+					storer = newLookupCache(s)
+				}
+				for _, route := range routes {
+					_, _ = getKongIngressFromObjectMeta(storer, route.Ingress)
+				}
+			}
+			b.ReportMetric(float64(s.kongIngressLookups)/float64(b.N), "store-calls/op")
+		})
+	}
+}
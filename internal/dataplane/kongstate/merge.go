@@ -0,0 +1,164 @@
+package kongstate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/kong/go-kong/kong"
+)
+
+// Merge appends the entities of other to the state, so that the partial states built by
+// several controller shards can be applied together. Entities are identified by their ID,
+// or by their name when they have no ID; plugins are identified by their name and the
+// entities they're attached to, and vaults by their prefix. If other holds an entity with
+// the same identity as an entity of the state, an error listing all such collisions is
+// returned and the state is left untouched. The version of the merged state is the lowest
+// of both versions, unset versions being ignored. Merging a nil state is a no-op.
+func (ks *KongState) Merge(other *KongState) error {
+	if other == nil {
+		return nil
+	}
+
+	var collisions []string
+	checkCollisions := func(kind string, existing, added []string) {
+		seen := make(map[string]struct{}, len(existing))
+		for _, key := range existing {
+			seen[key] = struct{}{}
+		}
+		for _, key := range added {
+			if _, ok := seen[key]; ok && key != "" {
+				collisions = append(collisions, fmt.Sprintf("%s %s", kind, key))
+			}
+		}
+	}
+	checkCollisions("service", serviceKeys(ks.Services), serviceKeys(other.Services))
+	checkCollisions("upstream", upstreamKeys(ks.Upstreams), upstreamKeys(other.Upstreams))
+	checkCollisions("certificate", certificateKeys(ks.Certificates), certificateKeys(other.Certificates))
+	checkCollisions("CA certificate", caCertificateKeys(ks.CACertificates), caCertificateKeys(other.CACertificates))
+	checkCollisions("plugin", mergePluginKeys(ks.Plugins), mergePluginKeys(other.Plugins))
+	checkCollisions("consumer", consumerKeys(ks.Consumers), consumerKeys(other.Consumers))
+	checkCollisions("consumer group", consumerGroupKeys(ks.ConsumerGroups), consumerGroupKeys(other.ConsumerGroups))
+	checkCollisions("vault", vaultKeys(ks.Vaults), vaultKeys(other.Vaults))
+	if len(collisions) > 0 {
+		return fmt.Errorf("cannot merge states, conflicting entities: %s", strings.Join(collisions, ", "))
+	}
+
+	ks.Services = append(ks.Services, other.Services...)
+	ks.Upstreams = append(ks.Upstreams, other.Upstreams...)
+	ks.Certificates = append(ks.Certificates, other.Certificates...)
+	ks.CACertificates = append(ks.CACertificates, other.CACertificates...)
+	ks.Plugins = append(ks.Plugins, other.Plugins...)
+	ks.Consumers = append(ks.Consumers, other.Consumers...)
+	ks.ConsumerGroups = append(ks.ConsumerGroups, other.ConsumerGroups...)
+	ks.Vaults = append(ks.Vaults, other.Vaults...)
+	ks.Version = minVersion(ks.Version, other.Version)
+	// the plugins built for the plugin references of the state no longer match its entities
+	ks.pluginsByReference = nil
+	if len(other.consumerDiagnostics) > 0 {
+		diagnostics := make(ConsumerDiagnostics, len(ks.consumerDiagnostics)+len(other.consumerDiagnostics))
+		for _, d := range []ConsumerDiagnostics{ks.consumerDiagnostics, other.consumerDiagnostics} {
+			for key, v := range d {
+				diagnostics[key] = append(diagnostics[key], v...)
+			}
+		}
+		ks.consumerDiagnostics = diagnostics
+	}
+	if len(other.unresolvedPlugins) > 0 {
+		// the slice may be shared with deep copies of the state
+		unresolved := make([]UnresolvedPluginReference, 0, len(ks.unresolvedPlugins)+len(other.unresolvedPlugins))
+		ks.unresolvedPlugins = append(append(unresolved, ks.unresolvedPlugins...), other.unresolvedPlugins...)
+	}
+	return nil
+}
+
+// minVersion returns the lowest of two versions, ignoring unset (zero) versions.
+func minVersion(a, b semver.Version) semver.Version {
+	var unset semver.Version
+	switch {
+	case a.Equals(unset):
+		return b
+	case b.Equals(unset):
+		return a
+	case b.LT(a):
+		return b
+	default:
+		return a
+	}
+}
+
+// entityKey identifies an entity by its ID, or by the first non-empty name if it has no ID.
+// Entities with neither an ID nor a name get an empty key, which never collides.
+func entityKey(id *string, names ...*string) string {
+	if id := stringValue(id); id != "" {
+		return "id:" + id
+	}
+	for _, name := range names {
+		if name := stringValue(name); name != "" {
+			return "name:" + name
+		}
+	}
+	return ""
+}
+
+func serviceKeys(services []Service) (res []string) {
+	for _, s := range services {
+		res = append(res, entityKey(s.ID, s.Name))
+	}
+	return
+}
+
+func upstreamKeys(upstreams []Upstream) (res []string) {
+	for _, u := range upstreams {
+		res = append(res, entityKey(u.ID, u.Name))
+	}
+	return
+}
+
+func certificateKeys(certs []Certificate) (res []string) {
+	for _, c := range certs {
+		res = append(res, entityKey(c.ID))
+	}
+	return
+}
+
+func caCertificateKeys(certs []kong.CACertificate) (res []string) {
+	for _, c := range certs {
+		res = append(res, entityKey(c.ID))
+	}
+	return
+}
+
+func mergePluginKeys(plugins []Plugin) (res []string) {
+	for _, p := range plugins {
+		if id := stringValue(p.ID); id != "" {
+			res = append(res, "id:"+id)
+			continue
+		}
+		res = append(res, pluginKey(p))
+	}
+	return
+}
+
+func consumerKeys(consumers []Consumer) (res []string) {
+	for _, c := range consumers {
+		res = append(res, entityKey(c.ID, c.Username, c.CustomID))
+	}
+	return
+}
+
+func consumerGroupKeys(groups []ConsumerGroup) (res []string) {
+	for _, g := range groups {
+		res = append(res, entityKey(nil, g.Name))
+	}
+	return
+}
+
+func vaultKeys(vaults []Vault) (res []string) {
+	for _, v := range vaults {
+		if v.Prefix != "" {
+			res = append(res, "prefix:"+v.Prefix)
+		}
+	}
+	return
+}
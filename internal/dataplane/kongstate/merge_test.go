@@ -0,0 +1,86 @@
+package kongstate
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/kong/go-kong/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKongState_Merge(t *testing.T) {
+	shardA := func() KongState {
+		return KongState{
+			Services:  []Service{{Service: kong.Service{Name: kong.String("a.svc.80")}}},
+			Upstreams: []Upstream{{Upstream: kong.Upstream{Name: kong.String("a.svc.80.svc")}}},
+			Plugins: []Plugin{{Plugin: kong.Plugin{
+				Name:    kong.String("key-auth"),
+				Service: &kong.Service{ID: kong.String("a.svc.80")},
+			}}},
+			Consumers:    []Consumer{{Consumer: kong.Consumer{Username: kong.String("alice")}}},
+			Certificates: []Certificate{{Certificate: kong.Certificate{ID: kong.String("cert-a")}}},
+			Version:      semver.MustParse("3.0.0"),
+		}
+	}
+	shardB := func() KongState {
+		return KongState{
+			Services:  []Service{{Service: kong.Service{Name: kong.String("b.svc.80")}}},
+			Upstreams: []Upstream{{Upstream: kong.Upstream{Name: kong.String("b.svc.80.svc")}}},
+			Plugins: []Plugin{{Plugin: kong.Plugin{
+				Name:    kong.String("key-auth"),
+				Service: &kong.Service{ID: kong.String("b.svc.80")},
+			}}},
+			Consumers:    []Consumer{{Consumer: kong.Consumer{Username: kong.String("bob")}}},
+			Certificates: []Certificate{{Certificate: kong.Certificate{ID: kong.String("cert-b")}}},
+			Version:      semver.MustParse("2.8.0"),
+		}
+	}
+
+	t.Run("clean merge", func(t *testing.T) {
+		state, other := shardA(), shardB()
+		require.NoError(t, state.Merge(&other))
+		assert.Len(t, state.Services, 2)
+		assert.Len(t, state.Upstreams, 2)
+		assert.Len(t, state.Plugins, 2)
+		assert.Len(t, state.Consumers, 2)
+		assert.Len(t, state.Certificates, 2)
+		assert.Equal(t, "b.svc.80", *state.Services[1].Name)
+	})
+
+	t.Run("collisions are reported and leave the state untouched", func(t *testing.T) {
+		state, other := shardA(), shardA()
+		other.Services[0].Name = kong.String("c.svc.80")
+		err := state.Merge(&other)
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "service name:")
+		assert.Contains(t, err.Error(), "upstream name:a.svc.80.svc")
+		assert.Contains(t, err.Error(), "plugin key-auth service:a.svc.80")
+		assert.Contains(t, err.Error(), "consumer name:alice")
+		assert.Contains(t, err.Error(), "certificate id:cert-a")
+		assert.Equal(t, shardA(), state)
+	})
+
+	t.Run("version is the lowest of both versions", func(t *testing.T) {
+		state, other := shardA(), shardB()
+		require.NoError(t, state.Merge(&other))
+		assert.Equal(t, semver.MustParse("2.8.0"), state.Version)
+
+		state, other = shardB(), shardA()
+		require.NoError(t, state.Merge(&other))
+		assert.Equal(t, semver.MustParse("2.8.0"), state.Version)
+	})
+
+	t.Run("empty receiver takes the other state", func(t *testing.T) {
+		var state KongState
+		other := shardA()
+		require.NoError(t, state.Merge(&other))
+		assert.Equal(t, shardA(), state)
+	})
+
+	t.Run("nil other is a no-op", func(t *testing.T) {
+		state := shardA()
+		require.NoError(t, state.Merge(nil))
+		assert.Equal(t, shardA(), state)
+	})
+}
@@ -0,0 +1,30 @@
+package kongstate
+
+// NamespaceFilter selects the namespaces whose objects are translated into Kong configuration.
+// A nil NamespaceFilter allows all namespaces.
+type NamespaceFilter struct {
+	// Allow lists the allowed namespaces. All namespaces are allowed if it's empty.
+	Allow []string
+	// Deny lists the denied namespaces. It takes precedence over Allow.
+	Deny []string
+}
+
+// Allows reports whether objects from the namespace pass the filter.
+func (f *NamespaceFilter) Allows(namespace string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Allow) > 0 && !containsString(f.Allow, namespace) {
+		return false
+	}
+	return !containsString(f.Deny, namespace)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
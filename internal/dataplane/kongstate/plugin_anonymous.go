@@ -0,0 +1,37 @@
+package kongstate
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// anonymousConsumerField is the configuration field of authentication plugins, e.g. key-auth
+// or basic-auth, naming the consumer used when authentication fails.
+const anonymousConsumerField = "anonymous"
+
+// warnMissingAnonymousConsumers logs the plugins whose anonymous consumer is not part of the
+// state. Kong accepts the ID or the username of a consumer there, so both are looked up.
+// As consumers may also be created outside of the controller, the plugins are kept.
+func (ks *KongState) warnMissingAnonymousConsumers(log logrus.FieldLogger) {
+	consumers := make(map[string]struct{}, 2*len(ks.Consumers))
+	for _, c := range ks.Consumers {
+		if c.ID != nil {
+			consumers[*c.ID] = struct{}{}
+		}
+		if c.Username != nil {
+			consumers[*c.Username] = struct{}{}
+		}
+	}
+	for _, plugin := range ks.Plugins {
+		anonymous, ok := plugin.Config[anonymousConsumerField].(string)
+		if !ok || anonymous == "" {
+			continue
+		}
+		if _, ok := consumers[anonymous]; ok {
+			continue
+		}
+		log.WithFields(logrus.Fields{
+			"plugin_name":        stringValue(plugin.Name),
+			"anonymous_consumer": anonymous,
+		}).Warn("plugin references an anonymous consumer which does not exist in the configuration")
+	}
+}
@@ -0,0 +1,74 @@
+package kongstate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+func TestKongState_FillPlugins_MissingAnonymousConsumer(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		anonymous string
+		wantWarn  bool
+	}{
+		{name: "anonymous consumer referenced by username exists", anonymous: "guest"},
+		{name: "anonymous consumer referenced by ID exists", anonymous: "guest-id"},
+		{name: "anonymous consumer doesn't exist", anonymous: "nobody", wantWarn: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := store.NewFakeStore(store.FakeObjects{
+				KongPlugins: []*configurationv1.KongPlugin{{
+					ObjectMeta: metav1.ObjectMeta{Name: "key-auth", Namespace: "default"},
+					PluginName: "key-auth",
+					Config: apiextensionsv1.JSON{
+						Raw: []byte(`{"anonymous":"` + tt.anonymous + `"}`),
+					},
+				}},
+			})
+			require.NoError(t, err)
+
+			state := KongState{
+				Services: []Service{{
+					Service: kong.Service{Name: kong.String("foo-service")},
+					K8sServices: map[string]*corev1.Service{
+						"foo-service": {
+							ObjectMeta: metav1.ObjectMeta{
+								Namespace: "default",
+								Annotations: map[string]string{
+									annotations.AnnotationPrefix + annotations.PluginsKey: "key-auth",
+								},
+							},
+						},
+					},
+				}},
+				Consumers: []Consumer{{
+					Consumer: kong.Consumer{ID: kong.String("guest-id"), Username: kong.String("guest")},
+				}},
+			}
+			var logs bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&logs)
+			state.FillPlugins(log, s, nil, nil, false, nil)
+
+			require.Len(t, state.Plugins, 1, "the plugin should be kept")
+			if tt.wantWarn {
+				assert.Contains(t, logs.String(), "plugin references an anonymous consumer which does not exist")
+				assert.Contains(t, logs.String(), "anonymous_consumer="+tt.anonymous)
+			} else {
+				assert.NotContains(t, logs.String(), "anonymous consumer")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package kongstate
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+)
+
+// namespaceWideKongPluginsWarningKey identifies the namespace-wide KongPlugins notice in a WarnedSet.
+const namespaceWideKongPluginsWarningKey = "namespace-wide-kongplugins"
+
+// addNamespaceWidePluginRelations attaches the KongPlugins annotated or labeled with
+// konghq.com/apply-to: all to every service of their namespace, and to the routes of their
+// namespace whose service belongs to another namespace, in addition to the relations of
+// pluginRels. This bridges the gap left by global KongPlugins, which are no
+// longer applied, until they're replaced by KongClusterPlugins. The KongPlugins applied this
+// way are logged, unless warned already recorded them.
+func (ks *KongState) addNamespaceWidePluginRelations(
+	log logrus.FieldLogger,
+	s store.Storer,
+	warned *WarnedSet,
+	pluginRels map[kongPluginReference]util.ForeignRelations,
+) {
+	plugins, err := s.ListKongPlugins()
+	if err != nil {
+		log.WithError(err).Error("failed to list KongPlugins, namespace-wide KongPlugins will not be applied")
+		return
+	}
+	var pluginRefs []kongPluginReference
+	for _, p := range plugins {
+		if annotations.ExtractApplyToAll(p.Annotations) || annotations.ExtractApplyToAll(p.Labels) {
+			pluginRefs = append(pluginRefs, kongPluginReference{Namespace: p.Namespace, Name: p.Name})
+		}
+	}
+	sort.Slice(pluginRefs, func(i, j int) bool {
+		if pluginRefs[i].Namespace != pluginRefs[j].Namespace {
+			return pluginRefs[i].Namespace < pluginRefs[j].Namespace
+		}
+		return pluginRefs[i].Name < pluginRefs[j].Name
+	})
+
+	pluginNames := make([]string, 0, len(pluginRefs))
+	for _, ref := range pluginRefs {
+		pluginNames = append(pluginNames, ref.Namespace+"/"+ref.Name)
+	}
+	if warned.ShouldWarn(namespaceWideKongPluginsWarningKey, strings.Join(pluginNames, ",")) {
+		log.WithField("kongplugins", pluginNames).Warnf("KongPlugins annotated or labeled with %s%s: %s are applied"+
+			" to all the services and routes of their namespace. This is a migration aid for global KongPlugins,"+
+			" consider replacing them with KongClusterPlugins",
+			annotations.AnnotationPrefix, annotations.ApplyToKey, annotations.ApplyToAll)
+	}
+
+	serviceNames := make(map[string][]string)
+	routeNames := make(map[string][]string)
+	for _, service := range ks.Services {
+		if service.Name == nil {
+			continue
+		}
+		namespaces := make(map[string]struct{}, len(service.K8sServices))
+		for _, svc := range service.K8sServices {
+			namespaces[svc.Namespace] = struct{}{}
+			if !containsString(serviceNames[svc.Namespace], *service.Name) {
+				serviceNames[svc.Namespace] = append(serviceNames[svc.Namespace], *service.Name)
+			}
+		}
+		// routes of the namespace of their service get the plugins of the service already
+		for _, route := range service.Routes {
+			if route.Name == nil {
+				continue
+			}
+			if _, ok := namespaces[route.Ingress.Namespace]; !ok {
+				routeNames[route.Ingress.Namespace] = append(routeNames[route.Ingress.Namespace], *route.Name)
+			}
+		}
+	}
+	for _, ref := range pluginRefs {
+		relations := pluginRels[ref]
+		for _, name := range serviceNames[ref.Namespace] {
+			if !containsString(relations.Service, name) {
+				relations.Service = append(relations.Service, name)
+			}
+		}
+		for _, name := range routeNames[ref.Namespace] {
+			if !containsString(relations.Route, name) {
+				relations.Route = append(relations.Route, name)
+			}
+		}
+		if len(relations.Service) > 0 || len(relations.Route) > 0 {
+			pluginRels[ref] = relations
+		}
+	}
+}
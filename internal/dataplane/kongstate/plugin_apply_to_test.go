@@ -0,0 +1,131 @@
+package kongstate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+func TestKongState_FillPlugins_ApplyToAll(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "rate-limit",
+					Namespace:   "default",
+					Annotations: map[string]string{"konghq.com/apply-to": "all"},
+				},
+				PluginName: "rate-limiting",
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "cors", Namespace: "default"},
+				PluginName: "cors",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	service := func(name, namespace string) Service {
+		return Service{
+			Service: kong.Service{Name: kong.String(namespace + "." + name + ".80")},
+			K8sServices: map[string]*corev1.Service{
+				namespace + "/" + name: {ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}},
+			},
+		}
+	}
+	state := KongState{
+		Services: []Service{
+			service("foo", "default"),
+			service("bar", "default"),
+			service("baz", "other"),
+		},
+	}
+
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+	warned := NewWarnedSet()
+	state.FillPlugins(log, s, warned, nil, false, nil)
+
+	var services []string
+	for _, plugin := range state.Plugins {
+		assert.Equal(t, "rate-limiting", *plugin.Name)
+		services = append(services, *plugin.Service.ID)
+	}
+	assert.ElementsMatch(t, []string{"default.foo.80", "default.bar.80"}, services,
+		"the plugin should only be attached to the services of its namespace")
+	assert.Equal(t, 1, strings.Count(logs.String(), "level=warning"))
+	assert.Contains(t, logs.String(), "default/rate-limit")
+
+	logs.Reset()
+	state.FillPlugins(log, s, warned, nil, false, nil)
+	assert.Len(t, state.Plugins, 2)
+	assert.Empty(t, logs.String(), "namespace-wide KongPlugins should only be logged once")
+}
+
+func TestKongState_FillPlugins_ApplyToAllRoutes(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "correlation-id",
+					Namespace: "team-a",
+					Labels:    map[string]string{"konghq.com/apply-to": "all"},
+				},
+				PluginName: "correlation-id",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	route := func(name, namespace string) Route {
+		return Route{
+			Route:   kong.Route{Name: kong.String(name)},
+			Ingress: util.K8sObjectInfo{Name: name, Namespace: namespace},
+		}
+	}
+	service := func(name, namespace string, routes ...Route) Service {
+		return Service{
+			Service: kong.Service{Name: kong.String(namespace + "." + name + ".80")},
+			K8sServices: map[string]*corev1.Service{
+				namespace + "/" + name: {ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}},
+			},
+			Routes: routes,
+		}
+	}
+	state := KongState{
+		Services: []Service{
+			service("foo", "team-a", route("team-a.foo.00", "team-a")),
+			service("bar", "team-a", route("team-a.bar.00", "team-a")),
+			// e.g. an HTTPRoute of team-a with a backend of team-b, allowed by a ReferencePolicy
+			service("shared", "team-b", route("httproute.team-a.shared.0.0", "team-a"), route("team-b.shared.00", "team-b")),
+		},
+	}
+
+	state.FillPlugins(logrus.New(), s, nil, nil, false, nil)
+
+	var services, routes []string
+	for _, plugin := range state.Plugins {
+		assert.Equal(t, "correlation-id", *plugin.Name)
+		if plugin.Service != nil {
+			services = append(services, *plugin.Service.ID)
+		}
+		if plugin.Route != nil {
+			routes = append(routes, *plugin.Route.ID)
+		}
+	}
+	assert.ElementsMatch(t, []string{"team-a.foo.80", "team-a.bar.80"}, services)
+	assert.Equal(t, []string{"httproute.team-a.shared.0.0"}, routes,
+		"only the routes of the namespace whose service belongs to another namespace should get the plugin")
+}
@@ -0,0 +1,55 @@
+package kongstate
+
+import (
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CheckPluginConfigSizes logs the plugins whose configuration, serialized to JSON, is larger than
+// maxSize bytes, along with the entities they're attached to, as Kong may reject configurations
+// holding such plugins. In strict mode, such plugins are also dropped, so that they don't fail
+// the whole configuration update. A maxSize which isn't positive disables the check.
+func (ks *KongState) CheckPluginConfigSizes(log logrus.FieldLogger, maxSize int, strict bool) {
+	if maxSize <= 0 {
+		return
+	}
+	plugins := ks.Plugins[:0]
+	for _, plugin := range ks.Plugins {
+		config, err := json.Marshal(plugin.Config)
+		if err != nil || len(config) <= maxSize {
+			// configurations which can't be serialized are reported when sending them to Kong
+			plugins = append(plugins, plugin)
+			continue
+		}
+		log := log.WithFields(pluginTargetLogFields(plugin)).WithFields(logrus.Fields{
+			"config_size":     len(config),
+			"max_config_size": maxSize,
+		})
+		if strict {
+			log.Error("plugin configuration is too large, the plugin will not be applied")
+			continue
+		}
+		log.Warn("plugin configuration is too large, Kong may reject it")
+		plugins = append(plugins, plugin)
+	}
+	ks.Plugins = plugins
+}
+
+// pluginTargetLogFields returns the log fields naming a plugin and the entities it's attached to.
+func pluginTargetLogFields(plugin Plugin) logrus.Fields {
+	fields := logrus.Fields{"plugin_name": stringValue(plugin.Name)}
+	if plugin.Service != nil {
+		fields["service_name"] = stringValue(plugin.Service.ID)
+	}
+	if plugin.Route != nil {
+		fields["route_name"] = stringValue(plugin.Route.ID)
+	}
+	if plugin.Consumer != nil {
+		fields["consumer"] = stringValue(plugin.Consumer.ID)
+	}
+	if plugin.ConsumerGroup != nil {
+		fields["consumer_group"] = *plugin.ConsumerGroup
+	}
+	return fields
+}
@@ -0,0 +1,79 @@
+package kongstate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKongState_CheckPluginConfigSizes(t *testing.T) {
+	plugins := func() []Plugin {
+		return []Plugin{
+			{Plugin: kong.Plugin{
+				Name:   kong.String("request-transformer"),
+				Route:  &kong.Route{ID: kong.String("default.foo.00")},
+				Config: kong.Configuration{"add": map[string]interface{}{"body": []interface{}{strings.Repeat("a", 100)}}},
+			}},
+			{Plugin: kong.Plugin{
+				Name:    kong.String("cors"),
+				Service: &kong.Service{ID: kong.String("default.foo.80")},
+				Config:  kong.Configuration{"origins": []interface{}{"*"}},
+			}},
+		}
+	}
+
+	for _, tt := range []struct {
+		name        string
+		maxSize     int
+		strict      bool
+		wantPlugins []string
+		wantLog     string
+	}{
+		{
+			name:        "check is disabled without a limit",
+			wantPlugins: []string{"request-transformer", "cors"},
+		},
+		{
+			name:        "oversized configurations are logged",
+			maxSize:     64,
+			wantPlugins: []string{"request-transformer", "cors"},
+			wantLog:     "plugin configuration is too large, Kong may reject it",
+		},
+		{
+			name:        "oversized configurations are dropped in strict mode",
+			maxSize:     64,
+			strict:      true,
+			wantPlugins: []string{"cors"},
+			wantLog:     "plugin configuration is too large, the plugin will not be applied",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&logs)
+
+			state := KongState{Plugins: plugins()}
+			state.CheckPluginConfigSizes(log, tt.maxSize, tt.strict)
+
+			names := make([]string, 0, len(state.Plugins))
+			for _, p := range state.Plugins {
+				names = append(names, *p.Name)
+			}
+			assert.Equal(t, tt.wantPlugins, names)
+			if tt.wantLog == "" {
+				assert.Empty(t, logs.String())
+				return
+			}
+			require.Contains(t, logs.String(), tt.wantLog)
+			assert.Contains(t, logs.String(), "plugin_name=request-transformer")
+			assert.Contains(t, logs.String(), "route_name=default.foo.00")
+			assert.Contains(t, logs.String(), "max_config_size=64")
+			assert.NotContains(t, logs.String(), "plugin_name=cors")
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package kongstate
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PluginChange describes a plugin instance which differs between two KongStates.
+// Previous is nil for added plugins and Current is nil for removed ones.
+type PluginChange struct {
+	// Key identifies the plugin instance by its name and the entities it's attached to,
+	// e.g. "rate-limiting service:default.foo.80 route:default.foo.00".
+	Key      string
+	Previous *Plugin
+	Current  *Plugin
+}
+
+// PluginDiff holds the plugin instances added, removed or changed between two KongStates.
+// Each list is sorted by PluginChange.Key.
+type PluginDiff struct {
+	Added   []PluginChange
+	Removed []PluginChange
+	Changed []PluginChange
+}
+
+// IsEmpty reports whether the diff holds no changes.
+func (d PluginDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffPlugins returns the plugin instances which were added, removed or changed since
+// the previous KongState, which can be nil if there's none. Fields generated by Kong
+// (ID and creation time) are ignored when comparing plugins.
+func (ks *KongState) DiffPlugins(previous *KongState) PluginDiff {
+	var prevPlugins []Plugin
+	if previous != nil {
+		prevPlugins = previous.Plugins
+	}
+	prevIndex := indexPlugins(prevPlugins)
+	currIndex := indexPlugins(ks.Plugins)
+
+	var diff PluginDiff
+	for _, key := range sortedPluginKeys(currIndex) {
+		curr := currIndex[key]
+		prev, ok := prevIndex[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, PluginChange{Key: key, Current: curr})
+		case !pluginsEqual(prev, curr):
+			diff.Changed = append(diff.Changed, PluginChange{Key: key, Previous: prev, Current: curr})
+		}
+	}
+	for _, key := range sortedPluginKeys(prevIndex) {
+		if _, ok := currIndex[key]; !ok {
+			diff.Removed = append(diff.Removed, PluginChange{Key: key, Previous: prevIndex[key]})
+		}
+	}
+	return diff
+}
+
+// pluginKey identifies a plugin instance by its name and the entities it's attached to.
+func pluginKey(p Plugin) string {
+	parts := []string{stringValue(p.Name)}
+	if p.Service != nil {
+		parts = append(parts, "service:"+stringValue(p.Service.ID))
+	}
+	if p.Route != nil {
+		parts = append(parts, "route:"+stringValue(p.Route.ID))
+	}
+	if p.Consumer != nil {
+		parts = append(parts, "consumer:"+stringValue(p.Consumer.ID))
+	}
+	if p.ConsumerGroup != nil {
+		parts = append(parts, "consumer_group:"+*p.ConsumerGroup)
+	}
+	return strings.Join(parts, " ")
+}
+
+// indexPlugins indexes plugins by pluginKey. Kong doesn't accept several instances of
+// a plugin attached to the same entities, so the last one wins if there are any.
+func indexPlugins(plugins []Plugin) map[string]*Plugin {
+	index := make(map[string]*Plugin, len(plugins))
+	for i := range plugins {
+		index[pluginKey(plugins[i])] = &plugins[i]
+	}
+	return index
+}
+
+func sortedPluginKeys(index map[string]*Plugin) []string {
+	keys := make([]string, 0, len(index))
+	for key := range index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pluginsEqual compares two plugins, ignoring the fields generated by Kong.
+func pluginsEqual(a, b *Plugin) bool {
+	ac, bc := a.Plugin, b.Plugin
+	ac.ID, bc.ID = nil, nil
+	ac.CreatedAt, bc.CreatedAt = nil, nil
+	return reflect.DeepEqual(ac, bc)
+}
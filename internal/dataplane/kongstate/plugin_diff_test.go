@@ -0,0 +1,92 @@
+package kongstate
+
+import (
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKongState_DiffPlugins(t *testing.T) {
+	servicePlugin := func(name, service string, config kong.Configuration) Plugin {
+		return Plugin{Plugin: kong.Plugin{
+			Name:    kong.String(name),
+			Service: &kong.Service{ID: kong.String(service)},
+			Config:  config,
+		}}
+	}
+	withServerFields := func(p Plugin) Plugin {
+		p.ID = kong.String("8b5d8f1e-6b73-4c6c-9b5b-0c7f2f6d4e21")
+		createdAt := 1
+		p.CreatedAt = &createdAt
+		return p
+	}
+	keyAuth := servicePlugin("key-auth", "default.foo.80", nil)
+	keyAuthBar := servicePlugin("key-auth", "default.bar.80", nil)
+	rateLimiting := servicePlugin("rate-limiting", "default.foo.80", kong.Configuration{"minute": 10})
+	rateLimitingChanged := servicePlugin("rate-limiting", "default.foo.80", kong.Configuration{"minute": 20})
+
+	for _, tt := range []struct {
+		name     string
+		previous *KongState
+		current  KongState
+		want     PluginDiff
+	}{
+		{
+			name:    "all plugins are added without a previous state",
+			current: KongState{Plugins: []Plugin{rateLimiting, keyAuth}},
+			want: PluginDiff{
+				Added: []PluginChange{
+					{Key: "key-auth service:default.foo.80", Current: &keyAuth},
+					{Key: "rate-limiting service:default.foo.80", Current: &rateLimiting},
+				},
+			},
+		},
+		{
+			name:     "plugin attached to another entity is added",
+			previous: &KongState{Plugins: []Plugin{keyAuth}},
+			current:  KongState{Plugins: []Plugin{keyAuth, keyAuthBar}},
+			want: PluginDiff{
+				Added: []PluginChange{
+					{Key: "key-auth service:default.bar.80", Current: &keyAuthBar},
+				},
+			},
+		},
+		{
+			name:     "plugin is removed",
+			previous: &KongState{Plugins: []Plugin{keyAuth, rateLimiting}},
+			current:  KongState{Plugins: []Plugin{keyAuth}},
+			want: PluginDiff{
+				Removed: []PluginChange{
+					{Key: "rate-limiting service:default.foo.80", Previous: &rateLimiting},
+				},
+			},
+		},
+		{
+			name:     "plugin config is changed",
+			previous: &KongState{Plugins: []Plugin{rateLimiting}},
+			current:  KongState{Plugins: []Plugin{rateLimitingChanged}},
+			want: PluginDiff{
+				Changed: []PluginChange{
+					{
+						Key:      "rate-limiting service:default.foo.80",
+						Previous: &rateLimiting,
+						Current:  &rateLimitingChanged,
+					},
+				},
+			},
+		},
+		{
+			name:     "server generated fields are ignored",
+			previous: &KongState{Plugins: []Plugin{withServerFields(rateLimiting)}},
+			current:  KongState{Plugins: []Plugin{rateLimiting}},
+			want:     PluginDiff{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.current.DiffPlugins(tt.previous)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, len(tt.want.Added)+len(tt.want.Removed)+len(tt.want.Changed) == 0, got.IsEmpty())
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package kongstate
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+)
+
+// pluginIDNamespace is the namespace of the name-based UUIDs generated for plugins.
+var pluginIDNamespace = uuid.MustParse("ec455c05-d06a-443d-8ad7-17eaa9560e1b")
+
+// pluginIDSource holds the fields a deterministic plugin ID is derived from.
+type pluginIDSource struct {
+	Name          string             `json:"name"`
+	Service       string             `json:"service,omitempty"`
+	Route         string             `json:"route,omitempty"`
+	Consumer      string             `json:"consumer,omitempty"`
+	ConsumerGroup string             `json:"consumer_group,omitempty"`
+	Config        kong.Configuration `json:"config,omitempty"`
+}
+
+// AssignDeterministicPluginIDs sets the ID of every plugin without one to a UUID derived from
+// its name, the IDs of the entities it's attached to and its configuration, so that the same
+// plugin gets the same ID on every translation and a different one when any of them changes.
+// Plugins whose configuration can't be serialized are logged and left without an ID.
+func (ks *KongState) AssignDeterministicPluginIDs(log logrus.FieldLogger) {
+	for i := range ks.Plugins {
+		plugin := &ks.Plugins[i]
+		if plugin.ID != nil {
+			continue
+		}
+		id, err := deterministicPluginID(plugin)
+		if err != nil {
+			log.WithField("plugin_name", stringValue(plugin.Name)).WithError(err).
+				Error("failed to generate plugin ID, leaving it to Kong")
+			continue
+		}
+		plugin.ID = kong.String(id)
+	}
+}
+
+// deterministicPluginID derives the ID of a plugin from its name, attachments and configuration.
+func deterministicPluginID(plugin *Plugin) (string, error) {
+	src := pluginIDSource{
+		Name:          stringValue(plugin.Name),
+		ConsumerGroup: stringValue(plugin.ConsumerGroup),
+		Config:        plugin.Config,
+	}
+	if plugin.Service != nil {
+		src.Service = stringValue(plugin.Service.ID)
+	}
+	if plugin.Route != nil {
+		src.Route = stringValue(plugin.Route.ID)
+	}
+	if plugin.Consumer != nil {
+		src.Consumer = stringValue(plugin.Consumer.ID)
+	}
+	// maps are marshaled with sorted keys, so equal configurations give the same data
+	data, err := json.Marshal(src)
+	if err != nil {
+		return "", err
+	}
+	return uuid.NewSHA1(pluginIDNamespace, data).String(), nil
+}
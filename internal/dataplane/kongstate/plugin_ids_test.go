@@ -0,0 +1,72 @@
+package kongstate
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKongState_AssignDeterministicPluginIDs(t *testing.T) {
+	plugins := func(minute float64) []Plugin {
+		return []Plugin{
+			{Plugin: kong.Plugin{
+				Name:    kong.String("rate-limiting"),
+				Service: &kong.Service{ID: kong.String("foo-service")},
+				Config:  kong.Configuration{"minute": minute, "policy": "local"},
+			}},
+			{Plugin: kong.Plugin{
+				Name:   kong.String("rate-limiting"),
+				Route:  &kong.Route{ID: kong.String("foo-route")},
+				Config: kong.Configuration{"minute": minute, "policy": "local"},
+			}},
+			{Plugin: kong.Plugin{
+				Name: kong.String("prometheus"),
+			}},
+		}
+	}
+	ids := func(state KongState) []string {
+		var res []string
+		for _, p := range state.Plugins {
+			require.NotNil(t, p.ID)
+			res = append(res, *p.ID)
+		}
+		return res
+	}
+
+	first := KongState{Plugins: plugins(10)}
+	first.AssignDeterministicPluginIDs(logrus.New())
+	firstIDs := ids(first)
+	for _, id := range firstIDs {
+		_, err := uuid.Parse(id)
+		assert.NoError(t, err)
+	}
+	assert.NotEqual(t, firstIDs[0], firstIDs[1], "plugins attached to different entities should get different IDs")
+
+	t.Run("identical input gives identical IDs", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			state := KongState{Plugins: plugins(10)}
+			state.AssignDeterministicPluginIDs(logrus.New())
+			assert.Equal(t, firstIDs, ids(state))
+		}
+	})
+
+	t.Run("configuration changes give different IDs", func(t *testing.T) {
+		state := KongState{Plugins: plugins(20)}
+		state.AssignDeterministicPluginIDs(logrus.New())
+		got := ids(state)
+		assert.NotEqual(t, firstIDs[0], got[0])
+		assert.NotEqual(t, firstIDs[1], got[1])
+		assert.Equal(t, firstIDs[2], got[2], "the unchanged plugin should keep its ID")
+	})
+
+	t.Run("existing IDs are kept", func(t *testing.T) {
+		state := KongState{Plugins: plugins(10)}
+		state.Plugins[0].ID = kong.String("custom")
+		state.AssignDeterministicPluginIDs(logrus.New())
+		assert.Equal(t, "custom", *state.Plugins[0].ID)
+	})
+}
@@ -0,0 +1,84 @@
+package kongstate
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// pluginScope identifies the plugins of a name attached to a service, or to a route,
+// for a consumer and a consumer group.
+type pluginScope struct {
+	name, service, route, consumer, consumerGroup string
+}
+
+// DetectOverlappingPlugins finds plugins attached to a route while a plugin with the same name is
+// attached to the service of the route (for the same consumer and consumer group, if any). Kong
+// applies the most specific one, the route plugin, so the service plugin is silently ignored for
+// the requests of the route. Every overlap is logged; in strict mode, the returned error
+// aggregates them. Disabled plugins are ignored.
+func (ks *KongState) DetectOverlappingPlugins(log logrus.FieldLogger, strict bool) error {
+	routeServices := make(map[string]string)
+	for _, service := range ks.Services {
+		for _, route := range service.Routes {
+			if service.Name != nil && route.Name != nil {
+				routeServices[*route.Name] = *service.Name
+			}
+		}
+	}
+
+	servicePlugins := make(map[pluginScope]struct{})
+	for _, plugin := range ks.Plugins {
+		if scope, ok := pluginScopeOf(plugin); ok && scope.service != "" && scope.route == "" {
+			servicePlugins[scope] = struct{}{}
+		}
+	}
+
+	var errs []error
+	for _, plugin := range ks.Plugins {
+		scope, ok := pluginScopeOf(plugin)
+		if !ok || scope.route == "" || scope.service != "" {
+			continue
+		}
+		scope.service = routeServices[scope.route]
+		scope.route = ""
+		if _, ok := servicePlugins[scope]; !ok || scope.service == "" {
+			continue
+		}
+		log := log.WithFields(logrus.Fields{
+			"plugin_name":  scope.name,
+			"service_name": scope.service,
+			"route_name":   stringValue(plugin.Route.ID),
+		})
+		if strict {
+			log.Error("plugin is attached to both a route and its service, only the route one applies to the route")
+			errs = append(errs, fmt.Errorf("plugin %s is attached to both route %s and its service %s",
+				scope.name, stringValue(plugin.Route.ID), scope.service))
+			continue
+		}
+		log.Info("plugin is attached to both a route and its service, only the route one applies to the route")
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// pluginScopeOf returns the scope of an enabled plugin, false for disabled plugins.
+func pluginScopeOf(plugin Plugin) (pluginScope, bool) {
+	if plugin.Enabled != nil && !*plugin.Enabled {
+		return pluginScope{}, false
+	}
+	scope := pluginScope{
+		name:          stringValue(plugin.Name),
+		consumerGroup: stringValue(plugin.ConsumerGroup),
+	}
+	if plugin.Service != nil {
+		scope.service = stringValue(plugin.Service.ID)
+	}
+	if plugin.Route != nil {
+		scope.route = stringValue(plugin.Route.ID)
+	}
+	if plugin.Consumer != nil {
+		scope.consumer = stringValue(plugin.Consumer.ID)
+	}
+	return scope, true
+}
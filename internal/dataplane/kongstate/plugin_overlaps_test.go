@@ -0,0 +1,101 @@
+package kongstate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKongState_DetectOverlappingPlugins(t *testing.T) {
+	services := []Service{
+		{
+			Service: kong.Service{Name: kong.String("default.foo.80")},
+			Routes:  []Route{{Route: kong.Route{Name: kong.String("default.foo.00")}}},
+		},
+		{
+			Service: kong.Service{Name: kong.String("default.bar.80")},
+			Routes:  []Route{{Route: kong.Route{Name: kong.String("default.bar.00")}}},
+		},
+	}
+	servicePlugin := func(name, service string) Plugin {
+		return Plugin{Plugin: kong.Plugin{
+			Name:    kong.String(name),
+			Service: &kong.Service{ID: kong.String(service)},
+			Config:  kong.Configuration{"minute": float64(10)},
+		}}
+	}
+	routePlugin := func(name, route string) Plugin {
+		return Plugin{Plugin: kong.Plugin{
+			Name:   kong.String(name),
+			Route:  &kong.Route{ID: kong.String(route)},
+			Config: kong.Configuration{"minute": float64(20)},
+		}}
+	}
+
+	for _, tt := range []struct {
+		name         string
+		plugins      func() []Plugin
+		wantOverlaps int
+	}{
+		{
+			name: "same plugin on a route and its service",
+			plugins: func() []Plugin {
+				return []Plugin{servicePlugin("rate-limiting", "default.foo.80"), routePlugin("rate-limiting", "default.foo.00")}
+			},
+			wantOverlaps: 1,
+		},
+		{
+			name: "same plugin on a route of another service",
+			plugins: func() []Plugin {
+				return []Plugin{servicePlugin("rate-limiting", "default.foo.80"), routePlugin("rate-limiting", "default.bar.00")}
+			},
+		},
+		{
+			name: "different plugins on a route and its service",
+			plugins: func() []Plugin {
+				return []Plugin{servicePlugin("rate-limiting", "default.foo.80"), routePlugin("cors", "default.foo.00")}
+			},
+		},
+		{
+			name: "same plugin for different consumers",
+			plugins: func() []Plugin {
+				route := routePlugin("rate-limiting", "default.foo.00")
+				route.Consumer = &kong.Consumer{ID: kong.String("alice")}
+				return []Plugin{servicePlugin("rate-limiting", "default.foo.80"), route}
+			},
+		},
+		{
+			name: "disabled plugins are ignored",
+			plugins: func() []Plugin {
+				service := servicePlugin("rate-limiting", "default.foo.80")
+				service.Enabled = kong.Bool(false)
+				return []Plugin{service, routePlugin("rate-limiting", "default.foo.00")}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := KongState{Services: services, Plugins: tt.plugins()}
+
+			var logs bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&logs)
+			require.NoError(t, ks.DetectOverlappingPlugins(log, false))
+			assert.Equal(t, tt.wantOverlaps, strings.Count(logs.String(), "level=info"))
+			assert.Len(t, ks.Plugins, 2, "plugins should be kept")
+
+			logs.Reset()
+			err := ks.DetectOverlappingPlugins(log, true)
+			assert.Equal(t, tt.wantOverlaps, strings.Count(logs.String(), "level=error"))
+			if tt.wantOverlaps == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, "plugin rate-limiting is attached to both route default.foo.00 and its service default.foo.80")
+		})
+	}
+}
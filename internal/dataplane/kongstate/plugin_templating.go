@@ -0,0 +1,95 @@
+package kongstate
+
+import (
+	"regexp"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+)
+
+// Placeholders which can be used in the string values of the configuration of KongPlugins
+// attached to objects, resolved for every plugin generated from the KongPlugin:
+//
+//   - {namespace}: the namespace of the objects the KongPlugin is attached to
+//   - {service_name}: the name of the Kong service the plugin is attached to
+//   - {route_name}: the name of the Kong route the plugin is attached to
+//   - {consumer}: the username (or custom ID) of the Kong consumer the plugin is attached to
+//   - {consumer_group}: the name of the Kong consumer group the plugin is attached to
+//
+// Other placeholders, and placeholders of entities the plugin isn't attached to, are left
+// intact and logged.
+const (
+	PluginPlaceholderNamespace     = "namespace"
+	PluginPlaceholderServiceName   = "service_name"
+	PluginPlaceholderRouteName     = "route_name"
+	PluginPlaceholderConsumer      = "consumer"
+	PluginPlaceholderConsumerGroup = "consumer_group"
+)
+
+var pluginPlaceholderRegex = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// pluginPlaceholderValues returns the values of the placeholders for a plugin attached to the
+// entities of rel, from objects of namespace. Placeholders of entities the plugin isn't attached
+// to are omitted.
+func pluginPlaceholderValues(namespace string, rel util.Rel) map[string]string {
+	values := map[string]string{PluginPlaceholderNamespace: namespace}
+	for placeholder, value := range map[string]string{
+		PluginPlaceholderServiceName:   rel.Service,
+		PluginPlaceholderRouteName:     rel.Route,
+		PluginPlaceholderConsumer:      rel.Consumer,
+		PluginPlaceholderConsumerGroup: rel.ConsumerGroup,
+	} {
+		if value != "" {
+			values[placeholder] = value
+		}
+	}
+	return values
+}
+
+// resolvePluginPlaceholders returns a copy of config with the placeholders of its string values,
+// including the ones nested in objects and arrays, replaced by their values. Placeholders without
+// a value are left intact and logged, once per placeholder.
+func resolvePluginPlaceholders(log logrus.FieldLogger, config kong.Configuration, values map[string]string) kong.Configuration {
+	if config == nil {
+		return nil
+	}
+	unresolved := make(map[string]struct{})
+	var resolve func(v interface{}) interface{}
+	resolve = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case string:
+			return pluginPlaceholderRegex.ReplaceAllStringFunc(v, func(match string) string {
+				placeholder := match[1 : len(match)-1]
+				if value, ok := values[placeholder]; ok {
+					return value
+				}
+				if _, ok := unresolved[placeholder]; !ok {
+					unresolved[placeholder] = struct{}{}
+					log.WithField("placeholder", match).Warn("unknown placeholder in plugin configuration, leaving it intact")
+				}
+				return match
+			})
+		case map[string]interface{}:
+			res := make(map[string]interface{}, len(v))
+			for k, field := range v {
+				res[k] = resolve(field)
+			}
+			return res
+		case []interface{}:
+			res := make([]interface{}, len(v))
+			for i, elem := range v {
+				res[i] = resolve(elem)
+			}
+			return res
+		default:
+			return v
+		}
+	}
+	res := make(kong.Configuration, len(config))
+	for k, v := range config {
+		res[k] = resolve(v)
+	}
+	return res
+}
@@ -0,0 +1,89 @@
+package kongstate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+func Test_buildPlugins_ResolvesPlaceholders(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "add-headers", Namespace: "team-a"},
+				PluginName: "request-transformer",
+				Config: apiextensionsv1.JSON{
+					Raw: []byte(`{"add": {"headers": ["x-namespace:{namespace}", "x-service:{service_name}"]}}`),
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	state := KongState{
+		Services: []Service{{
+			Service: kong.Service{Name: kong.String("team-a.foo.80")},
+			K8sServices: map[string]*corev1.Service{
+				"foo": {ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "team-a",
+					Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.PluginsKey: "add-headers",
+					},
+				}},
+			},
+		}},
+	}
+
+	plugins, unresolved := buildPlugins(logrus.New(), s, state.getPluginRelations(logrus.New()), nil, false)
+	assert.Empty(t, unresolved)
+	require.Len(t, plugins, 1)
+	assert.Equal(t, kong.Configuration{
+		"add": map[string]interface{}{
+			"headers": []interface{}{"x-namespace:team-a", "x-service:team-a.foo.80"},
+		},
+	}, plugins[0].Config)
+}
+
+func Test_resolvePluginPlaceholders(t *testing.T) {
+	values := pluginPlaceholderValues("default", util.Rel{Route: "foo-route"})
+
+	t.Run("known placeholders are resolved", func(t *testing.T) {
+		config := kong.Configuration{
+			"path":  "/{namespace}/{route_name}",
+			"count": float64(1),
+		}
+		assert.Equal(t, kong.Configuration{
+			"path":  "/default/foo-route",
+			"count": float64(1),
+		}, resolvePluginPlaceholders(logrus.New(), config, values))
+		assert.Equal(t, "/{namespace}/{route_name}", config["path"], "the original configuration must not be modified")
+	})
+
+	t.Run("unknown placeholders are left intact", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log := logrus.New()
+		log.SetOutput(buf)
+
+		config := kong.Configuration{"value": "{namespace}-{service_name}-{unknown}", "regex": `\d{3}`}
+		assert.Equal(t, kong.Configuration{
+			"value": "default-{service_name}-{unknown}",
+			"regex": `\d{3}`,
+		}, resolvePluginPlaceholders(log, config, values))
+		assert.Contains(t, buf.String(), "unknown placeholder in plugin configuration")
+		assert.Contains(t, buf.String(), "placeholder=\"{unknown}\"")
+		assert.Contains(t, buf.String(), "placeholder=\"{service_name}\"")
+	})
+}
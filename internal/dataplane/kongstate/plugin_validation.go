@@ -0,0 +1,279 @@
+package kongstate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// PluginSchemaGetter retrieves the schema of a plugin for a Kong version.
+type PluginSchemaGetter interface {
+	PluginSchema(ctx context.Context, version semver.Version, pluginName string) (map[string]interface{}, error)
+}
+
+// validatePlugins drops the plugins whose configuration is invalid according to their schema
+// for the Kong version of the state, and the protocols their schema doesn't accept. Plugins
+// whose schema can't be retrieved are kept, leaving their validation to Kong. Configurations
+// read from Secrets are first coerced to the types expected by the schema. Plugins attached
+// to stream routes (e.g. routes of TCPIngresses and UDPIngresses) are dropped if their schema
+// accepts none of the protocols of the route.
+func (ks *KongState) validatePlugins(log logrus.FieldLogger, schemas PluginSchemaGetter) {
+	streamRoutes := ks.streamRouteProtocols()
+	var plugins []Plugin
+	for _, plugin := range ks.Plugins {
+		if plugin.Name == nil {
+			plugins = append(plugins, plugin)
+			continue
+		}
+		pluginLog := log.WithFields(logrus.Fields{
+			"plugin_name":  *plugin.Name,
+			"kong_version": ks.Version.String(),
+		})
+		schema, err := schemas.PluginSchema(context.Background(), ks.Version, *plugin.Name)
+		if err != nil {
+			pluginLog.WithError(err).Warn("failed to fetch plugin schema, skipping plugin configuration validation")
+			plugins = append(plugins, plugin)
+			continue
+		}
+		fields := schemaFields(schema)
+		if plugin.Route != nil {
+			if routeProtocols, ok := streamRoutes[stringValue(plugin.Route.ID)]; ok &&
+				!acceptsAnyProtocol(fields["protocols"], routeProtocols) {
+				pluginLog.WithFields(logrus.Fields{
+					"route_name":      stringValue(plugin.Route.ID),
+					"route_protocols": routeProtocols,
+				}).Error("plugin doesn't support the stream protocols of its route, the plugin will not be applied")
+				continue
+			}
+		}
+		plugin.Protocols = validProtocols(pluginLog, fields["protocols"], plugin.Protocols)
+		configSchema, ok := fields["config"]
+		if !ok {
+			plugins = append(plugins, plugin)
+			continue
+		}
+		if plugin.configFromSource {
+			// the configuration may be shared with plugins built for other targets
+			plugin.Config = coerceRecord(configSchema, plugin.Config)
+		}
+		if err := validateRecord("config", configSchema, plugin.Config); err != nil {
+			pluginLog.WithError(err).Error("invalid plugin configuration, the plugin will not be applied")
+			continue
+		}
+		plugins = append(plugins, plugin)
+	}
+	ks.Plugins = plugins
+}
+
+// streamProtocols are the protocols of Kong stream routes.
+var streamProtocols = map[string]struct{}{"tcp": {}, "tls": {}, "tls_passthrough": {}, "udp": {}}
+
+// streamRouteProtocols returns the protocols of the stream routes of the state, keyed by route name.
+// Routes are stream routes when all their protocols are stream protocols.
+func (ks *KongState) streamRouteProtocols() map[string][]string {
+	res := make(map[string][]string)
+	for _, service := range ks.Services {
+		for _, route := range service.Routes {
+			if route.Name == nil || len(route.Protocols) == 0 {
+				continue
+			}
+			var protocols []string
+			for _, protocol := range route.Protocols {
+				if protocol == nil {
+					continue
+				}
+				if _, ok := streamProtocols[*protocol]; !ok {
+					protocols = nil
+					break
+				}
+				protocols = append(protocols, *protocol)
+			}
+			if len(protocols) > 0 {
+				res[*route.Name] = protocols
+			}
+		}
+	}
+	return res
+}
+
+// acceptedProtocols returns the protocols accepted by the definition of the protocols field of
+// a plugin schema, or nil if the definition doesn't restrict them.
+func acceptedProtocols(def map[string]interface{}) map[string]struct{} {
+	elements, _ := def["elements"].(map[string]interface{})
+	oneOf, ok := elements["one_of"].([]interface{})
+	if !ok {
+		return nil
+	}
+	accepted := make(map[string]struct{}, len(oneOf))
+	for _, v := range oneOf {
+		if protocol, ok := v.(string); ok {
+			accepted[protocol] = struct{}{}
+		}
+	}
+	return accepted
+}
+
+// acceptsAnyProtocol reports whether the definition of the protocols field of a plugin schema
+// accepts any of protocols. Definitions which don't restrict protocols accept all of them.
+func acceptsAnyProtocol(def map[string]interface{}, protocols []string) bool {
+	accepted := acceptedProtocols(def)
+	if accepted == nil {
+		return true
+	}
+	for _, protocol := range protocols {
+		if _, ok := accepted[protocol]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validProtocols returns the protocols accepted by the definition of the protocols field of
+// a plugin schema, logging the other ones. If no protocol is accepted, nil is returned so that
+// the default protocols of the plugin apply. Protocols are kept as is if the definition doesn't
+// restrict them.
+func validProtocols(log logrus.FieldLogger, def map[string]interface{}, protocols []*string) []*string {
+	if len(protocols) == 0 {
+		return protocols
+	}
+	accepted := acceptedProtocols(def)
+	if accepted == nil {
+		return protocols
+	}
+
+	var res []*string
+	for _, protocol := range protocols {
+		if protocol == nil {
+			continue
+		}
+		if _, ok := accepted[*protocol]; !ok {
+			log.WithField("protocol", *protocol).Warn("protocol is not supported by the plugin, ignoring it")
+			continue
+		}
+		res = append(res, protocol)
+	}
+	if len(res) == 0 {
+		log.Warn("none of the plugin protocols is supported by the plugin, using its default protocols")
+	}
+	return res
+}
+
+// schemaFields returns the definitions of the fields of a Kong schema record, keyed by
+// field name. Kong schemas list fields as an array of single-key objects, e.g.
+// {"fields": [{"name": {"type": "string", ...}}, ...]}.
+func schemaFields(record map[string]interface{}) map[string]map[string]interface{} {
+	res := map[string]map[string]interface{}{}
+	fields, ok := record["fields"].([]interface{})
+	if !ok {
+		return res
+	}
+	for _, field := range fields {
+		f, ok := field.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, def := range f {
+			if d, ok := def.(map[string]interface{}); ok {
+				res[name] = d
+			}
+		}
+	}
+	return res
+}
+
+// coerceRecord returns a copy of value whose string fields are converted to the boolean,
+// integer or number type expected by the definition of a record schema field, the way
+// credential Secret values are. Values which can't be converted are kept as is, leaving
+// their reporting to validateRecord.
+func coerceRecord(def map[string]interface{}, value map[string]interface{}) map[string]interface{} {
+	fields := schemaFields(def)
+	res := make(map[string]interface{}, len(value))
+	for name, v := range value {
+		res[name] = coerceField(fields[name], v)
+	}
+	return res
+}
+
+// coerceField converts value to the type of a schema field definition if it's a string
+// holding a boolean or a number, and coerces the fields of records.
+func coerceField(def map[string]interface{}, value interface{}) interface{} {
+	fieldType, _ := def["type"].(string)
+	switch v := value.(type) {
+	case string:
+		switch fieldType {
+		case "boolean", "integer", "number":
+			if coerced, err := credentialFieldValue(fieldType, []byte(v)); err == nil {
+				return coerced
+			}
+		}
+	case map[string]interface{}:
+		if fieldType == "record" {
+			return coerceRecord(def, v)
+		}
+	}
+	return value
+}
+
+// validateRecord checks the fields of value against the definition of a record schema field.
+// path is the name of the record, used to point at the failing field in errors.
+func validateRecord(path string, def map[string]interface{}, value map[string]interface{}) error {
+	fields := schemaFields(def)
+	// shorthand fields are accepted by Kong and translated into regular fields
+	shorthands := schemaFields(map[string]interface{}{"fields": def["shorthand_fields"]})
+	for name, v := range value {
+		fieldPath := path + "." + name
+		fieldDef, ok := fields[name]
+		if !ok {
+			if _, ok := shorthands[name]; ok {
+				continue
+			}
+			return fmt.Errorf("unknown field %s", fieldPath)
+		}
+		if err := validateField(fieldPath, fieldDef, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateField checks that value matches the type of a schema field definition.
+func validateField(path string, def map[string]interface{}, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	fieldType, _ := def["type"].(string)
+	valid := true
+	switch fieldType {
+	case "string":
+		_, valid = value.(string)
+	case "boolean":
+		_, valid = value.(bool)
+	case "integer", "number":
+		switch value.(type) {
+		case int, int32, int64, float32, float64:
+		default:
+			valid = false
+		}
+	case "array", "set":
+		switch value.(type) {
+		case []interface{}, []string:
+		default:
+			valid = false
+		}
+	case "map":
+		_, valid = value.(map[string]interface{})
+	case "record":
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			valid = false
+			break
+		}
+		return validateRecord(path, def, record)
+	}
+	if !valid {
+		return fmt.Errorf("field %s must be of type %s, got %T", path, fieldType, value)
+	}
+	return nil
+}
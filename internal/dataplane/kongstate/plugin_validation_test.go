@@ -0,0 +1,305 @@
+package kongstate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+// fakePluginSchemas holds plugin schemas keyed by Kong version and plugin name.
+type fakePluginSchemas map[string]map[string]map[string]interface{}
+
+func (f fakePluginSchemas) PluginSchema(_ context.Context, version semver.Version, pluginName string) (map[string]interface{}, error) {
+	schema, ok := f[version.String()][pluginName]
+	if !ok {
+		return nil, fmt.Errorf("no schema for %s in Kong %s", pluginName, version)
+	}
+	return schema, nil
+}
+
+func pluginSchemaWithConfigFields(fields ...map[string]interface{}) map[string]interface{} {
+	configFields := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		configFields = append(configFields, f)
+	}
+	return map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"protocols": map[string]interface{}{"type": "set"}},
+			map[string]interface{}{"config": map[string]interface{}{
+				"type":   "record",
+				"fields": configFields,
+			}},
+		},
+	}
+}
+
+func TestKongState_validatePlugins(t *testing.T) {
+	minute := map[string]interface{}{"minute": map[string]interface{}{"type": "integer"}}
+	policy := map[string]interface{}{"policy": map[string]interface{}{"type": "string"}}
+	redis := map[string]interface{}{"redis": map[string]interface{}{
+		"type": "record",
+		"fields": []interface{}{
+			map[string]interface{}{"host": map[string]interface{}{"type": "string"}},
+		},
+	}}
+	schemas := fakePluginSchemas{
+		"2.8.0": {
+			"rate-limiting": pluginSchemaWithConfigFields(minute, policy),
+		},
+		"3.0.0": {
+			"rate-limiting": pluginSchemaWithConfigFields(minute, policy, redis),
+		},
+	}
+	rateLimiting := func(config kong.Configuration) Plugin {
+		return Plugin{Plugin: kong.Plugin{Name: kong.String("rate-limiting"), Config: config}}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		version semver.Version
+		plugins []Plugin
+		want    []Plugin
+	}{
+		{
+			name:    "valid configuration is kept",
+			version: semver.MustParse("2.8.0"),
+			plugins: []Plugin{rateLimiting(kong.Configuration{"minute": float64(5), "policy": "local"})},
+			want:    []Plugin{rateLimiting(kong.Configuration{"minute": float64(5), "policy": "local"})},
+		},
+		{
+			name:    "configuration with an unknown field is dropped",
+			version: semver.MustParse("2.8.0"),
+			plugins: []Plugin{
+				rateLimiting(kong.Configuration{"minute": float64(5), "made_up": true}),
+				rateLimiting(kong.Configuration{"policy": "local"}),
+			},
+			want: []Plugin{rateLimiting(kong.Configuration{"policy": "local"})},
+		},
+		{
+			name:    "configuration with a field of the wrong type is dropped",
+			version: semver.MustParse("2.8.0"),
+			plugins: []Plugin{rateLimiting(kong.Configuration{"minute": "five"})},
+			want:    nil,
+		},
+		{
+			name:    "field which is valid for the Kong version is kept",
+			version: semver.MustParse("3.0.0"),
+			plugins: []Plugin{rateLimiting(kong.Configuration{"redis": map[string]interface{}{"host": "redis"}})},
+			want:    []Plugin{rateLimiting(kong.Configuration{"redis": map[string]interface{}{"host": "redis"}})},
+		},
+		{
+			name:    "field which is not valid for the Kong version is dropped",
+			version: semver.MustParse("2.8.0"),
+			plugins: []Plugin{rateLimiting(kong.Configuration{"redis": map[string]interface{}{"host": "redis"}})},
+			want:    nil,
+		},
+		{
+			name:    "unknown nested field is dropped",
+			version: semver.MustParse("3.0.0"),
+			plugins: []Plugin{rateLimiting(kong.Configuration{"redis": map[string]interface{}{"made_up": "redis"}})},
+			want:    nil,
+		},
+		{
+			name:    "plugin without a schema is kept",
+			version: semver.MustParse("2.8.0"),
+			plugins: []Plugin{{Plugin: kong.Plugin{Name: kong.String("custom"), Config: kong.Configuration{"made_up": true}}}},
+			want:    []Plugin{{Plugin: kong.Plugin{Name: kong.String("custom"), Config: kong.Configuration{"made_up": true}}}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := KongState{Version: tt.version, Plugins: tt.plugins}
+			ks.validatePlugins(logrus.New(), schemas)
+			assert.Equal(t, tt.want, ks.Plugins)
+		})
+	}
+}
+
+func Test_validateRecord_NamesFailingField(t *testing.T) {
+	schema := pluginSchemaWithConfigFields(map[string]interface{}{"minute": map[string]interface{}{"type": "integer"}})
+	err := validateRecord("config", schemaFields(schema)["config"], map[string]interface{}{"made_up": true})
+	assert.EqualError(t, err, "unknown field config.made_up")
+}
+
+func TestKongState_validatePlugins_Protocols(t *testing.T) {
+	schema := map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"protocols": map[string]interface{}{
+				"type": "set",
+				"elements": map[string]interface{}{
+					"type":   "string",
+					"one_of": []interface{}{"grpc", "grpcs", "http", "https"},
+				},
+			}},
+			map[string]interface{}{"config": map[string]interface{}{"type": "record"}},
+		},
+	}
+	schemas := fakePluginSchemas{"3.0.0": {"key-auth": schema}}
+	keyAuth := func(protocols ...string) Plugin {
+		p := Plugin{Plugin: kong.Plugin{Name: kong.String("key-auth"), Config: kong.Configuration{}}}
+		if len(protocols) > 0 {
+			p.Protocols = kong.StringSlice(protocols...)
+		}
+		return p
+	}
+
+	for _, tt := range []struct {
+		name        string
+		protocols   []string
+		want        Plugin
+		wantWarning string
+	}{
+		{
+			name:      "valid protocols are kept",
+			protocols: []string{"http", "grpc"},
+			want:      keyAuth("http", "grpc"),
+		},
+		{
+			name:        "invalid protocols are dropped",
+			protocols:   []string{"https", "tcp"},
+			want:        keyAuth("https"),
+			wantWarning: "protocol is not supported by the plugin",
+		},
+		{
+			name:        "default protocols are used if no protocol is valid",
+			protocols:   []string{"tcp", "udp"},
+			want:        keyAuth(),
+			wantWarning: "using its default protocols",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&logs)
+
+			ks := KongState{Version: semver.MustParse("3.0.0"), Plugins: []Plugin{keyAuth(tt.protocols...)}}
+			ks.validatePlugins(log, schemas)
+			assert.Equal(t, []Plugin{tt.want}, ks.Plugins)
+			if tt.wantWarning != "" {
+				assert.Contains(t, logs.String(), tt.wantWarning)
+			} else {
+				assert.Empty(t, logs.String())
+			}
+		})
+	}
+}
+
+func TestKongState_FillPlugins_StreamRoutes(t *testing.T) {
+	schemaWithProtocols := func(protocols ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"fields": []interface{}{
+				map[string]interface{}{"protocols": map[string]interface{}{
+					"type": "set",
+					"elements": map[string]interface{}{
+						"type":   "string",
+						"one_of": protocols,
+					},
+				}},
+				map[string]interface{}{"config": map[string]interface{}{"type": "record"}},
+			},
+		}
+	}
+	schemas := fakePluginSchemas{"3.0.0": {
+		"key-auth":       schemaWithProtocols("grpc", "grpcs", "http", "https"),
+		"ip-restriction": schemaWithProtocols("http", "https", "tcp", "tls"),
+	}}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{
+			{ObjectMeta: metav1.ObjectMeta{Name: "key-auth", Namespace: "default"}, PluginName: "key-auth"},
+			{ObjectMeta: metav1.ObjectMeta{Name: "ip-restriction", Namespace: "default"}, PluginName: "ip-restriction"},
+		},
+	})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+
+	ks := KongState{
+		Version: semver.MustParse("3.0.0"),
+		Services: []Service{{
+			Service: kong.Service{Name: kong.String("default.tcp.9000"), Protocol: kong.String("tcp")},
+			Routes: []Route{{
+				Route: kong.Route{
+					Name:      kong.String("default.tcpingress.00"),
+					Protocols: kong.StringSlice("tcp", "tls"),
+				},
+				Ingress: util.K8sObjectInfo{
+					Name:      "tcpingress",
+					Namespace: "default",
+					Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.PluginsKey: "key-auth,ip-restriction",
+					},
+				},
+			}},
+		}},
+	}
+	ks.FillPlugins(log, s, nil, schemas, false, nil)
+
+	require.Len(t, ks.Plugins, 1, "the HTTP-only plugin should be dropped")
+	assert.Equal(t, "ip-restriction", *ks.Plugins[0].Name)
+	assert.Equal(t, &kong.Route{ID: kong.String("default.tcpingress.00")}, ks.Plugins[0].Route)
+	assert.Contains(t, logs.String(), "plugin doesn't support the stream protocols of its route")
+	assert.Contains(t, logs.String(), "plugin_name=key-auth")
+}
+
+func TestKongState_FillPlugins_CoercesConfigFromSecret(t *testing.T) {
+	schemas := fakePluginSchemas{"3.0.0": {"rate-limiting": pluginSchemaWithConfigFields(
+		map[string]interface{}{"minute": map[string]interface{}{"type": "integer"}},
+		map[string]interface{}{"fault_tolerant": map[string]interface{}{"type": "boolean"}},
+		map[string]interface{}{"policy": map[string]interface{}{"type": "string"}},
+	)}}
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongPlugins: []*configurationv1.KongPlugin{{
+			ObjectMeta: metav1.ObjectMeta{Name: "rate-limiting", Namespace: "default"},
+			PluginName: "rate-limiting",
+			ConfigFrom: &configurationv1.ConfigSource{
+				SecretValue: configurationv1.SecretValueFromSource{Secret: "conf", Key: "config"},
+			},
+		}},
+		Secrets: []*corev1.Secret{{
+			ObjectMeta: metav1.ObjectMeta{Name: "conf", Namespace: "default"},
+			Data: map[string][]byte{
+				"config": []byte("minute: \"5\"\nfault_tolerant: \"false\"\npolicy: \"100\"\n"),
+			},
+		}},
+	})
+	require.NoError(t, err)
+
+	state := KongState{
+		Version: semver.MustParse("3.0.0"),
+		Services: []Service{{
+			Service: kong.Service{Name: kong.String("foo-service")},
+			K8sServices: map[string]*corev1.Service{
+				"foo-service": {
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "default",
+						Annotations: map[string]string{
+							annotations.AnnotationPrefix + annotations.PluginsKey: "rate-limiting",
+						},
+					},
+				},
+			},
+		}},
+	}
+	state.FillPlugins(logrus.New(), s, nil, schemas, false, nil)
+	require.Len(t, state.Plugins, 1)
+	assert.Equal(t, kong.Configuration{
+		"minute":         5,
+		"fault_tolerant": false,
+		"policy":         "100",
+	}, state.Plugins[0].Config)
+}
@@ -21,6 +21,10 @@ type Route struct {
 
 	Ingress util.K8sObjectInfo
 	Plugins []kong.Plugin
+
+	// PluginRefs are the names of the KongPlugins of the namespace of Ingress attached to the
+	// route by other means than the plugins annotation, such as HTTPRoute extensionRef filters.
+	PluginRefs []string
 }
 
 var (
@@ -30,8 +34,14 @@ var (
 	// TODO if the Kong core adds support for wildcard SNI route match criteria, this should change.
 	validSNIs  = regexp.MustCompile(`^([a-zA-Z0-9]+(-[a-zA-Z0-9]+)*)+(\.([a-zA-Z0-9]+(-[a-zA-Z0-9]+)*))*$`)
 	validHosts = regexp.MustCompile(`^(\*\.)?([a-zA-Z0-9]+(-[a-zA-Z0-9]+)*)+(\.([a-zA-Z0-9]+(-[a-zA-Z0-9]+)*))*?(\.\*)?$`)
+
+	// header names are HTTP tokens, see RFC 7230 section 3.2.6
+	validHeaderNames = regexp.MustCompile(`^[a-zA-Z0-9!#$%&'*+.^_|~-]+$`)
 )
 
+// validPathHandlings are the path_handling values accepted by Kong.
+var validPathHandlings = map[string]struct{}{"v0": {}, "v1": {}}
+
 // normalizeProtocols prevents users from mismatching grpc/http.
 func (r *Route) normalizeProtocols() {
 	protocols := r.Protocols
@@ -158,13 +168,16 @@ func (r *Route) overridePreserveHost(anns map[string]string) {
 	}
 }
 
-func (r *Route) overrideRegexPriority(anns map[string]string) {
+// overrideRegexPriority sets the regex priority of the route from the regex-priority annotation.
+// Values which aren't non-negative integers are logged and ignored.
+func (r *Route) overrideRegexPriority(log logrus.FieldLogger, anns map[string]string) {
 	priority := annotations.ExtractRegexPriority(anns)
 	if priority == "" {
 		return
 	}
 	regexPriority, err := strconv.Atoi(priority)
-	if err != nil {
+	if err != nil || regexPriority < 0 {
+		log.WithField("kongroute", stringValue(r.Name)).Warnf("invalid regex priority %q, expected a non-negative integer", priority)
 		return
 	}
 
@@ -184,7 +197,7 @@ func (r *Route) overrideMethods(log logrus.FieldLogger, anns map[string]string)
 		} else {
 			// if any method is invalid (not an uppercase alpha string),
 			// discard everything
-			log.WithField("kongroute", r.Name).Errorf("invalid method: %v", method)
+			log.WithField("kongroute", stringValue(r.Name)).Errorf("invalid method: %v", method)
 			return
 		}
 	}
@@ -208,7 +221,7 @@ func (r *Route) overrideSNIs(log logrus.FieldLogger, anns map[string]string) {
 			snis = append(snis, kong.String(sanitizedSNI))
 		} else {
 			// SNI is not a valid hostname
-			log.WithField("kongroute", r.Name).Errorf("invalid SNI: %v", sni)
+			log.WithField("kongroute", stringValue(r.Name)).Warnf("invalid SNI %q, ignoring the snis annotation", sni)
 			return
 		}
 	}
@@ -222,16 +235,21 @@ func (r *Route) overrideByAnnotation(log logrus.FieldLogger) {
 	r.overrideStripPath(r.Ingress.Annotations)
 	r.overrideHTTPSRedirectCode(r.Ingress.Annotations)
 	r.overridePreserveHost(r.Ingress.Annotations)
-	r.overrideRegexPriority(r.Ingress.Annotations)
+	r.overrideRegexPriority(log, r.Ingress.Annotations)
 	r.overrideMethods(log, r.Ingress.Annotations)
 	r.overrideSNIs(log, r.Ingress.Annotations)
 	r.overrideRequestBuffering(log, r.Ingress.Annotations)
 	r.overrideResponseBuffering(log, r.Ingress.Annotations)
 	r.overrideHosts(log, r.Ingress.Annotations)
+	r.overrideTags(log, r.Ingress.Annotations)
+	r.overrideHeaders(log, r.Ingress.Annotations)
+	r.overridePathHandling(log, r.Ingress.Annotations)
 }
 
-// override sets Route fields by KongIngress first, then by annotation.
-func (r *Route) override(log logrus.FieldLogger, kongIngress *configurationv1.KongIngress) {
+// override sets Route fields by KongIngress first, then by annotation. Routes of gRPC services,
+// whose protocol is serviceProtocol, use gRPC protocols unless their protocols are set explicitly.
+// serviceProtocol may be nil.
+func (r *Route) override(log logrus.FieldLogger, kongIngress *configurationv1.KongIngress, serviceProtocol *string) {
 	if r == nil {
 		return
 	}
@@ -252,16 +270,95 @@ func (r *Route) override(log logrus.FieldLogger, kongIngress *configurationv1.Ko
 
 	r.overrideByKongIngress(log, kongIngress)
 	r.overrideByAnnotation(log)
+	if isGRPCProtocol(serviceProtocol) && !r.hasExplicitProtocols(kongIngress) {
+		r.useGRPCProtocols()
+	}
 	r.normalizeProtocols()
+	r.dropSNIsWithoutTLSProtocol(log)
 	for _, val := range r.Protocols {
-		if *val == "grpc" || *val == "grpcs" {
+		if isGRPCProtocol(val) {
 			// grpc(s) doesn't accept strip_path
+			if r.StripPath != nil && r.hasExplicitStripPath(kongIngress) {
+				log.WithFields(logrus.Fields{
+					"kongroute":         stringValue(r.Name),
+					"ingress_namespace": r.Ingress.Namespace,
+					"ingress_name":      r.Ingress.Name,
+				}).Warn("strip_path is not supported by routes using gRPC protocols, ignoring it")
+			}
 			r.StripPath = nil
 			break
 		}
 	}
 }
 
+// sniProtocols are the route protocols Kong matches on SNI.
+var sniProtocols = map[string]struct{}{"https": {}, "grpcs": {}, "tls": {}, "tls_passthrough": {}}
+
+// dropSNIsWithoutTLSProtocol drops the SNIs of routes none of whose protocols is matched on SNI,
+// e.g. plain HTTP or TCP routes, as Kong rejects them. Routes with no protocols use Kong's
+// default protocols, http and https, so they keep their SNIs.
+func (r *Route) dropSNIsWithoutTLSProtocol(log logrus.FieldLogger) {
+	if len(r.SNIs) == 0 || len(r.Protocols) == 0 {
+		return
+	}
+	for _, protocol := range r.Protocols {
+		if _, ok := sniProtocols[stringValue(protocol)]; ok {
+			return
+		}
+	}
+	log.WithFields(logrus.Fields{
+		"kongroute":         stringValue(r.Name),
+		"ingress_namespace": r.Ingress.Namespace,
+		"ingress_name":      r.Ingress.Name,
+	}).Warn("SNIs are only supported by routes using TLS protocols, ignoring them")
+	r.SNIs = nil
+}
+
+// isGRPCProtocol reports whether protocol is grpc or grpcs.
+func isGRPCProtocol(protocol *string) bool {
+	return protocol != nil && (*protocol == "grpc" || *protocol == "grpcs")
+}
+
+// hasExplicitProtocols reports whether the protocols of the route are set by its KongIngress
+// or its protocols annotation.
+func (r *Route) hasExplicitProtocols(kongIngress *configurationv1.KongIngress) bool {
+	if kongIngress != nil && kongIngress.Route != nil && len(kongIngress.Route.Protocols) != 0 {
+		return true
+	}
+	return len(annotations.ExtractProtocolNames(r.Ingress.Annotations)) != 0
+}
+
+// hasExplicitStripPath reports whether the strip_path of the route is set by its KongIngress
+// or its strip-path annotation.
+func (r *Route) hasExplicitStripPath(kongIngress *configurationv1.KongIngress) bool {
+	if kongIngress != nil && kongIngress.Route != nil && kongIngress.Route.StripPath != nil {
+		return true
+	}
+	return annotations.ExtractStripPath(r.Ingress.Annotations) != ""
+}
+
+// useGRPCProtocols replaces the HTTP protocols of the route with their gRPC counterparts,
+// http with grpc and https with grpcs. Routes without protocols get both gRPC protocols.
+// Routes using other protocols, e.g. stream routes, are left unchanged.
+func (r *Route) useGRPCProtocols() {
+	if len(r.Protocols) == 0 {
+		r.Protocols = kong.StringSlice("grpc", "grpcs")
+		return
+	}
+	protocols := make([]*string, 0, len(r.Protocols))
+	for _, protocol := range r.Protocols {
+		switch stringValue(protocol) {
+		case "http":
+			protocols = append(protocols, kong.String("grpc"))
+		case "https":
+			protocols = append(protocols, kong.String("grpcs"))
+		default:
+			return
+		}
+	}
+	r.Protocols = protocols
+}
+
 // overrideByKongIngress sets Route fields by KongIngress.
 func (r *Route) overrideByKongIngress(log logrus.FieldLogger, kongIngress *configurationv1.KongIngress) {
 	if kongIngress == nil || kongIngress.Route == nil {
@@ -319,7 +416,7 @@ func (r *Route) overrideByKongIngress(log logrus.FieldLogger, kongIngress *confi
 				SNIs = append(SNIs, kong.String(SNI))
 			} else {
 				// SNI is not a valid hostname
-				log.WithField("kongroute", r.Name).Errorf("invalid SNI: %v", unsanitizedSNI)
+				log.WithField("kongroute", stringValue(r.Name)).Errorf("invalid SNI: %v", unsanitizedSNI)
 				return
 			}
 		}
@@ -344,7 +441,7 @@ func (r *Route) overrideRequestBuffering(log logrus.FieldLogger, anns map[string
 	isEnabled, err := strconv.ParseBool(strings.ToLower(annotationValue))
 	if err != nil {
 		// the value provided is not a parseable boolean, quit
-		log.WithField("kongroute", r.Name).Errorf("invalid request_buffering value: %s", err)
+		log.WithField("kongroute", stringValue(r.Name)).Warnf("invalid request_buffering value, ignoring it: %s", err)
 		return
 	}
 
@@ -362,7 +459,7 @@ func (r *Route) overrideResponseBuffering(log logrus.FieldLogger, anns map[strin
 	isEnabled, err := strconv.ParseBool(strings.ToLower(annotationValue))
 	if err != nil {
 		// the value provided is not a parseable boolean, quit
-		log.WithField("kongroute", r.Name).Errorf("invalid response_buffering value: %s", err)
+		log.WithField("kongroute", stringValue(r.Name)).Warnf("invalid response_buffering value, ignoring it: %s", err)
 		return
 	}
 
@@ -398,10 +495,60 @@ func (r *Route) overrideHosts(log logrus.FieldLogger, anns map[string]string) {
 			hosts = appendIfMissing(hosts, sanitizedHost)
 		} else {
 			// Host Alias is not a valid hostname
-			log.WithField("kongroute", r.Name).Errorf("invalid host: %v", hostAlias)
+			log.WithField("kongroute", stringValue(r.Name)).Errorf("invalid host: %v", hostAlias)
 			return
 		}
 	}
 
 	r.Hosts = hosts
 }
+
+// overrideHeaders sets the header values matched by the route from the headers annotations,
+// replacing the values already set for the same headers. Headers whose name isn't valid,
+// or which Kong doesn't allow matching on (the host header, matched by the route hosts),
+// are logged and skipped.
+func (r *Route) overrideHeaders(log logrus.FieldLogger, anns map[string]string) {
+	annHeaders := annotations.ExtractHeaders(anns)
+	if len(annHeaders) == 0 {
+		return
+	}
+
+	headers := make(map[string][]string, len(r.Headers)+len(annHeaders))
+	for name, values := range r.Headers {
+		headers[name] = values
+	}
+	for name, values := range annHeaders {
+		if !validHeaderNames.MatchString(name) || strings.EqualFold(name, "host") {
+			log.WithFields(logrus.Fields{
+				"kongroute": stringValue(r.Name),
+				"header":    name,
+			}).Warn("invalid route header, ignoring it")
+			continue
+		}
+		headers[name] = values
+	}
+	if len(headers) > 0 {
+		r.Headers = headers
+	}
+}
+
+// overridePathHandling sets the path handling of the route from the path-handling annotation.
+// Values other than v0 and v1 are logged and ignored.
+func (r *Route) overridePathHandling(log logrus.FieldLogger, anns map[string]string) {
+	pathHandling := strings.ToLower(strings.TrimSpace(annotations.ExtractPathHandling(anns)))
+	if pathHandling == "" {
+		return
+	}
+	if _, ok := validPathHandlings[pathHandling]; !ok {
+		log.WithField("kongroute", stringValue(r.Name)).Warnf("invalid path handling %q, expected v0 or v1", pathHandling)
+		return
+	}
+
+	r.PathHandling = kong.String(pathHandling)
+}
+
+// overrideTags appends the tags set with the tags annotation to the tags of the Route.
+// Duplicate tags are removed and tags which Kong would reject are logged and dropped.
+func (r *Route) overrideTags(log logrus.FieldLogger, anns map[string]string) {
+	r.Tags = mergeTags(log.WithField("kongroute", stringValue(r.Name)), "route", r.Tags, annotations.ExtractTags(anns))
+}
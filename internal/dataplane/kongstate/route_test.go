@@ -1,9 +1,12 @@
 package kongstate
 
 import (
+	"bytes"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/blang/semver/v4"
 	"github.com/kong/go-kong/kong"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -214,13 +217,13 @@ func TestOverrideRoute(t *testing.T) {
 	}
 
 	for _, testcase := range testTable {
-		testcase.inRoute.override(logrus.New(), &testcase.inKongIngresss)
+		testcase.inRoute.override(logrus.New(), &testcase.inKongIngresss, nil)
 		assert.Equal(testcase.inRoute, testcase.outRoute)
 	}
 
 	assert.NotPanics(func() {
 		var nilRoute *Route
-		nilRoute.override(logrus.New(), nil)
+		nilRoute.override(logrus.New(), nil, nil)
 	})
 }
 
@@ -250,7 +253,7 @@ func TestOverrideRoutePriority(t *testing.T) {
 		},
 		Ingress: ingMeta,
 	}
-	route.override(logrus.New(), &kongIngress)
+	route.override(logrus.New(), &kongIngress, nil)
 	assert.Equal(route.Hosts, kong.StringSlice("foo.com", "bar.com"))
 	assert.Equal(route.Protocols, kong.StringSlice("grpc", "grpcs"))
 }
@@ -272,7 +275,7 @@ func TestOverrideRouteByKongIngress(t *testing.T) {
 	assert.Equal(route.Protocols, kong.StringSlice("http"))
 	assert.NotPanics(func() {
 		var nilRoute *Route
-		nilRoute.override(logrus.New(), nil)
+		nilRoute.override(logrus.New(), nil, nil)
 	})
 }
 
@@ -303,7 +306,7 @@ func TestOverrideRouteByAnnotation(t *testing.T) {
 
 	assert.NotPanics(func() {
 		var nilRoute *Route
-		nilRoute.override(logrus.New(), nil)
+		nilRoute.override(logrus.New(), nil, nil)
 	})
 }
 
@@ -694,9 +697,10 @@ func Test_overrideRouteRegexPriority(t *testing.T) {
 		anns  map[string]string
 	}
 	tests := []struct {
-		name string
-		args args
-		want Route
+		name        string
+		args        args
+		want        Route
+		wantWarning bool
 	}{
 		{name: "basic empty route"},
 		{
@@ -713,18 +717,27 @@ func Test_overrideRouteRegexPriority(t *testing.T) {
 			},
 		},
 		{
-			name: "negative integer",
+			name: "zero",
 			args: args{
 				anns: map[string]string{
-					"konghq.com/regex-priority": "-10",
+					"konghq.com/regex-priority": "0",
 				},
 			},
 			want: Route{
 				Route: kong.Route{
-					RegexPriority: kong.Int(-10),
+					RegexPriority: kong.Int(0),
 				},
 			},
 		},
+		{
+			name: "negative integer",
+			args: args{
+				anns: map[string]string{
+					"konghq.com/regex-priority": "-10",
+				},
+			},
+			wantWarning: true,
+		},
 		{
 			name: "random float value",
 			args: args{
@@ -732,6 +745,7 @@ func Test_overrideRouteRegexPriority(t *testing.T) {
 					"konghq.com/regex-priority": "42.42",
 				},
 			},
+			wantWarning: true,
 		},
 		{
 			name: "random string",
@@ -740,14 +754,24 @@ func Test_overrideRouteRegexPriority(t *testing.T) {
 					"konghq.com/regex-priority": "foo",
 				},
 			},
+			wantWarning: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.args.route.overrideRegexPriority(tt.args.anns)
+			var logs bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&logs)
+
+			tt.args.route.overrideRegexPriority(log, tt.args.anns)
 			if !reflect.DeepEqual(tt.args.route, tt.want) {
 				t.Errorf("overrideRouteRegexPriority() got = %v, want %v", tt.args.route, tt.want)
 			}
+			if tt.wantWarning {
+				assert.Contains(t, logs.String(), "expected a non-negative integer")
+			} else {
+				assert.Empty(t, logs.String())
+			}
 		})
 	}
 }
@@ -1055,3 +1079,377 @@ func Test_overrideHosts(t *testing.T) {
 		})
 	}
 }
+
+func Test_overrideRouteTags(t *testing.T) {
+	longTag := strings.Repeat("a", maxTagLength+1)
+
+	for _, tt := range []struct {
+		name        string
+		tags        []*string
+		anns        map[string]string
+		want        []*string
+		wantWarning bool
+	}{
+		{
+			name: "no annotation keeps the route tags",
+			tags: kong.StringSlice("managed"),
+			want: kong.StringSlice("managed"),
+		},
+		{
+			name: "annotation tags are set on a route without tags",
+			anns: map[string]string{"konghq.com/tags": "team-a,prod"},
+			want: kong.StringSlice("team-a", "prod"),
+		},
+		{
+			name: "annotation tags are merged with the route tags without duplicates",
+			tags: kong.StringSlice("managed", "prod"),
+			anns: map[string]string{"konghq.com/tags": "team-a, prod, team-a"},
+			want: kong.StringSlice("managed", "prod", "team-a"),
+		},
+		{
+			name:        "invalid tags are dropped",
+			tags:        kong.StringSlice("managed"),
+			anns:        map[string]string{"konghq.com/tags": "team/a," + longTag + ",prod"},
+			want:        kong.StringSlice("managed", "prod"),
+			wantWarning: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+
+			route := Route{Route: kong.Route{Tags: tt.tags}}
+			route.overrideTags(log, tt.anns)
+			assert.Equal(t, tt.want, route.Tags)
+			assert.Equal(t, tt.wantWarning, strings.Contains(buf.String(), "invalid route tag"))
+		})
+	}
+}
+
+func Test_overrideRouteHeaders(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		headers     map[string][]string
+		anns        map[string]string
+		want        map[string][]string
+		wantWarning bool
+	}{
+		{
+			name:    "no annotation keeps the route headers",
+			headers: map[string][]string{"x-version": {"v1"}},
+			want:    map[string][]string{"x-version": {"v1"}},
+		},
+		{
+			name: "single header annotation",
+			anns: map[string]string{"konghq.com/headers.x-custom": "v1,v2"},
+			want: map[string][]string{"x-custom": {"v1", "v2"}},
+		},
+		{
+			name: "multiple header annotations are merged",
+			anns: map[string]string{
+				"konghq.com/headers.x-custom": "v1, v2",
+				"konghq.com/headers.x-team":   "a",
+			},
+			want: map[string][]string{
+				"x-custom": {"v1", "v2"},
+				"x-team":   {"a"},
+			},
+		},
+		{
+			name:    "annotations replace the values of the same header",
+			headers: map[string][]string{"x-custom": {"v0"}, "x-version": {"v1"}},
+			anns:    map[string]string{"konghq.com/headers.x-custom": "v1"},
+			want:    map[string][]string{"x-custom": {"v1"}, "x-version": {"v1"}},
+		},
+		{
+			name: "invalid header names are skipped",
+			anns: map[string]string{
+				"konghq.com/headers.x custom": "v1",
+				"konghq.com/headers.Host":     "example.com",
+				"konghq.com/headers.x-team":   "a",
+			},
+			want:        map[string][]string{"x-team": {"a"}},
+			wantWarning: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+
+			route := Route{Route: kong.Route{Headers: tt.headers}}
+			route.overrideHeaders(log, tt.anns)
+			assert.Equal(t, tt.want, route.Headers)
+			assert.Equal(t, tt.wantWarning, strings.Contains(buf.String(), "invalid route header"))
+		})
+	}
+}
+
+func Test_overrideRoutePathHandling(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		pathHandling *string
+		anns         map[string]string
+		want         *string
+		wantWarning  bool
+	}{
+		{
+			name:         "no annotation keeps the route path handling",
+			pathHandling: kong.String("v1"),
+			want:         kong.String("v1"),
+		},
+		{
+			name: "v0",
+			anns: map[string]string{"konghq.com/path-handling": "v0"},
+			want: kong.String("v0"),
+		},
+		{
+			name:         "v1 replaces the path handling set by KongIngress",
+			pathHandling: kong.String("v0"),
+			anns:         map[string]string{"konghq.com/path-handling": "V1"},
+			want:         kong.String("v1"),
+		},
+		{
+			name:         "invalid value is ignored",
+			pathHandling: kong.String("v0"),
+			anns:         map[string]string{"konghq.com/path-handling": "v2"},
+			want:         kong.String("v0"),
+			wantWarning:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+
+			route := Route{Route: kong.Route{PathHandling: tt.pathHandling}}
+			route.overridePathHandling(log, tt.anns)
+			assert.Equal(t, tt.want, route.PathHandling)
+			assert.Equal(t, tt.wantWarning, strings.Contains(buf.String(), "invalid path handling"))
+		})
+	}
+}
+
+func TestKongState_dropUnsupportedPathHandling(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		want    *string
+	}{
+		{version: "1.5.0", want: nil},
+		{version: "2.0.0", want: kong.String("v1")},
+		{version: "3.4.0", want: kong.String("v1")},
+	} {
+		t.Run(tt.version, func(t *testing.T) {
+			state := KongState{
+				Version: semver.MustParse(tt.version),
+				Services: []Service{{
+					Routes: []Route{{Route: kong.Route{
+						Name:         kong.String("foo"),
+						PathHandling: kong.String("v1"),
+					}}},
+				}},
+			}
+			state.dropUnsupportedPathHandling(logrus.New())
+			assert.Equal(t, tt.want, state.Services[0].Routes[0].PathHandling)
+		})
+	}
+}
+
+func Test_overrideRouteBuffering_InvalidValues(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := logrus.New()
+	log.SetOutput(buf)
+
+	route := Route{Route: kong.Route{
+		Name:              kong.String("foo"),
+		RequestBuffering:  kong.Bool(true),
+		ResponseBuffering: kong.Bool(false),
+	}}
+	anns := map[string]string{
+		"konghq.com/request-buffering":  "sometimes",
+		"konghq.com/response-buffering": "1.5",
+	}
+	route.overrideRequestBuffering(log, anns)
+	route.overrideResponseBuffering(log, anns)
+
+	assert.Equal(t, kong.Bool(true), route.RequestBuffering, "invalid values should be ignored")
+	assert.Equal(t, kong.Bool(false), route.ResponseBuffering, "invalid values should be ignored")
+	assert.Equal(t, 2, strings.Count(buf.String(), "level=warning"))
+	assert.Contains(t, buf.String(), "invalid request_buffering value, ignoring it")
+	assert.Contains(t, buf.String(), "invalid response_buffering value, ignoring it")
+	assert.Contains(t, buf.String(), "kongroute=foo")
+}
+
+func TestKongState_dropUnsupportedRouteBuffering(t *testing.T) {
+	for _, tt := range []struct {
+		version semver.Version
+		want    *bool
+	}{
+		{version: semver.Version{}, want: kong.Bool(false)},
+		{version: semver.MustParse("2.2.1"), want: nil},
+		{version: semver.MustParse("2.3.0"), want: kong.Bool(false)},
+		{version: semver.MustParse("3.4.0"), want: kong.Bool(false)},
+	} {
+		t.Run(tt.version.String(), func(t *testing.T) {
+			state := KongState{
+				Version: tt.version,
+				Services: []Service{{
+					Routes: []Route{{Route: kong.Route{
+						Name:              kong.String("foo"),
+						RequestBuffering:  kong.Bool(false),
+						ResponseBuffering: kong.Bool(false),
+					}}},
+				}},
+			}
+			state.dropUnsupportedRouteBuffering(logrus.New())
+			assert.Equal(t, tt.want, state.Services[0].Routes[0].RequestBuffering)
+			assert.Equal(t, tt.want, state.Services[0].Routes[0].ResponseBuffering)
+		})
+	}
+}
+
+func TestOverrideRoute_GRPCService(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		route           Route
+		kongIngress     *configurationv1.KongIngress
+		serviceProtocol *string
+		wantProtocols   []*string
+		wantStripPath   *bool
+		wantWarning     bool
+	}{
+		{
+			name: "routes of gRPC services use gRPC protocols without strip_path",
+			route: Route{Route: kong.Route{
+				Protocols: kong.StringSlice("http", "https"),
+				StripPath: kong.Bool(false),
+			}},
+			serviceProtocol: kong.String("grpc"),
+			wantProtocols:   kong.StringSlice("grpc", "grpcs"),
+		},
+		{
+			name:            "https routes of gRPC services use grpcs",
+			route:           Route{Route: kong.Route{Protocols: kong.StringSlice("https")}},
+			serviceProtocol: kong.String("grpcs"),
+			wantProtocols:   kong.StringSlice("grpcs"),
+		},
+		{
+			name: "explicit protocols are kept",
+			route: Route{
+				Route: kong.Route{Protocols: kong.StringSlice("http", "https")},
+				Ingress: util.K8sObjectInfo{Annotations: map[string]string{
+					"konghq.com/protocols": "https",
+				}},
+			},
+			serviceProtocol: kong.String("grpc"),
+			wantProtocols:   kong.StringSlice("https"),
+		},
+		{
+			name: "strip_path set with an annotation conflicts with gRPC protocols",
+			route: Route{
+				Route: kong.Route{Protocols: kong.StringSlice("http", "https")},
+				Ingress: util.K8sObjectInfo{Annotations: map[string]string{
+					"konghq.com/strip-path": "true",
+				}},
+			},
+			serviceProtocol: kong.String("grpc"),
+			wantProtocols:   kong.StringSlice("grpc", "grpcs"),
+			wantWarning:     true,
+		},
+		{
+			name:  "strip_path set with a KongIngress conflicts with gRPC protocols",
+			route: Route{Route: kong.Route{Protocols: kong.StringSlice("http", "https")}},
+			kongIngress: &configurationv1.KongIngress{
+				Route: &configurationv1.KongIngressRoute{
+					Protocols: configurationv1.ProtocolSlice("grpcs"),
+					StripPath: kong.Bool(true),
+				},
+			},
+			wantProtocols: kong.StringSlice("grpcs"),
+			wantWarning:   true,
+		},
+		{
+			name: "routes of HTTP services are left unchanged",
+			route: Route{Route: kong.Route{
+				Protocols: kong.StringSlice("http", "https"),
+				StripPath: kong.Bool(true),
+			}},
+			serviceProtocol: kong.String("http"),
+			wantProtocols:   kong.StringSlice("http", "https"),
+			wantStripPath:   kong.Bool(true),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+
+			tt.route.override(log, tt.kongIngress, tt.serviceProtocol)
+			assert.Equal(t, tt.wantProtocols, tt.route.Protocols)
+			assert.Equal(t, tt.wantStripPath, tt.route.StripPath)
+			assert.Equal(t, tt.wantWarning, strings.Contains(buf.String(), "strip_path is not supported"))
+		})
+	}
+}
+
+func TestOverrideRoute_SNIs(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		anns        map[string]string
+		wantSNIs    []*string
+		wantWarning string
+	}{
+		{
+			name: "tls routes match on SNIs",
+			anns: map[string]string{
+				"konghq.com/protocols": "tls",
+				"konghq.com/snis":      "foo.example.com, bar.example.com",
+			},
+			wantSNIs: kong.StringSlice("foo.example.com", "bar.example.com"),
+		},
+		{
+			name: "tls_passthrough routes match on SNIs",
+			anns: map[string]string{
+				"konghq.com/protocols": "tls_passthrough",
+				"konghq.com/snis":      "foo.example.com",
+			},
+			wantSNIs: kong.StringSlice("foo.example.com"),
+		},
+		{
+			name: "SNIs of routes without TLS protocols are ignored",
+			anns: map[string]string{
+				"konghq.com/protocols": "http",
+				"konghq.com/snis":      "foo.example.com",
+			},
+			wantWarning: "SNIs are only supported by routes using TLS protocols, ignoring them",
+		},
+		{
+			name: "invalid SNIs are ignored",
+			anns: map[string]string{
+				"konghq.com/protocols": "tls",
+				"konghq.com/snis":      "foo.example.com,foo_bar",
+			},
+			wantWarning: `invalid SNI \"foo_bar\", ignoring the snis annotation`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(buf)
+
+			route := Route{
+				Route:   kong.Route{Name: kong.String("default.foo.00")},
+				Ingress: util.K8sObjectInfo{Namespace: "default", Name: "foo", Annotations: tt.anns},
+			}
+			route.override(log, nil, nil)
+			assert.Equal(t, tt.wantSNIs, route.SNIs)
+			if tt.wantWarning == "" {
+				assert.NotContains(t, buf.String(), "level=warning")
+				return
+			}
+			assert.Contains(t, buf.String(), tt.wantWarning)
+			assert.Contains(t, buf.String(), "kongroute=default.foo.00")
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package kongstate
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+)
+
+// secretIndex wraps a store.Storer to serve Secrets from an index of all the Secrets of the
+// store, built by listing them once on the first lookup, instead of looking each of them up in
+// the store. Secrets missing from the index are looked up in the store, so that lookups fail
+// in the same way. Like lookupCache, it must only be used for a single Fill pass.
+// It's not safe for concurrent use.
+type secretIndex struct {
+	store.Storer
+
+	secrets map[string]*corev1.Secret
+}
+
+// NewSecretIndex returns a store.Storer serving Secrets from an index of the Secrets of s,
+// built on the first lookup. It trades one lookup per Secret for a single listing, which
+// lowers the pressure on the informer cache when many credentials are read in a row.
+// A new one should be created for every Fill pass, so that Secrets aren't served stale.
+func NewSecretIndex(s store.Storer) store.Storer {
+	return &secretIndex{Storer: s}
+}
+
+// GetSecret returns the 'name' Secret resource in namespace.
+func (i *secretIndex) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	if i.secrets == nil {
+		i.secrets = make(map[string]*corev1.Secret)
+		// if listing fails, every lookup falls back to the store
+		secrets, err := i.Storer.ListSecrets()
+		if err == nil {
+			for _, secret := range secrets {
+				i.secrets[secret.Namespace+"/"+secret.Name] = secret
+			}
+		}
+	}
+	if secret, ok := i.secrets[namespace+"/"+name]; ok {
+		return secret, nil
+	}
+	return i.Storer.GetSecret(namespace, name)
+}
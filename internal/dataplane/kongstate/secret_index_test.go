@@ -0,0 +1,102 @@
+package kongstate
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+// secretCountingStorer counts the Secret lookups and listings made against the wrapped store.Storer.
+type secretCountingStorer struct {
+	store.Storer
+	secretLookups  int
+	secretListings int
+}
+
+func (s *secretCountingStorer) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	s.secretLookups++
+	return s.Storer.GetSecret(namespace, name)
+}
+
+func (s *secretCountingStorer) ListSecrets() ([]*corev1.Secret, error) {
+	s.secretListings++
+	return s.Storer.ListSecrets()
+}
+
+// storeWithKeyAuthConsumers returns a store with consumers KongConsumers each referencing
+// a key-auth credential Secret, and a reference to a missing Secret for the first one.
+func storeWithKeyAuthConsumers(t testing.TB, consumers int) *secretCountingStorer {
+	objects := store.FakeObjects{}
+	for i := 0; i < consumers; i++ {
+		name := fmt.Sprintf("consumer-%d", i)
+		objects.Secrets = append(objects.Secrets, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data: map[string][]byte{
+				"kongCredType": []byte("key-auth"),
+				"key":          []byte(name + "-key"),
+			},
+		})
+		credentials := []string{name}
+		if i == 0 {
+			credentials = append(credentials, "missing")
+		}
+		objects.KongConsumers = append(objects.KongConsumers, &configurationv1.KongConsumer{
+			ObjectMeta:  metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Username:    name,
+			Credentials: credentials,
+		})
+	}
+	s, err := store.NewFakeStore(objects)
+	require.NoError(t, err)
+	return &secretCountingStorer{Storer: s}
+}
+
+func TestNewSecretIndex(t *testing.T) {
+	s := storeWithKeyAuthConsumers(t, 10)
+
+	var direct KongState
+	directErr := direct.FillConsumersAndCredentials(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{})
+	assert.Equal(t, 11, s.secretLookups)
+
+	s.secretLookups = 0
+	var indexed KongState
+	indexedErr := indexed.FillConsumersAndCredentials(logrus.New(), NewSecretIndex(s), nil, nil, nil, ConsumerFillOptions{})
+	assert.Equal(t, 1, s.secretListings, "Secrets should be listed once")
+	assert.Equal(t, 1, s.secretLookups, "only the missing Secret should be looked up in the store")
+
+	assert.Equal(t, direct.Consumers, indexed.Consumers)
+	require.Error(t, directErr)
+	assert.EqualError(t, indexedErr, directErr.Error())
+}
+
+func BenchmarkSecretLookups(b *testing.B) {
+	for _, bb := range []struct {
+		name    string
+		indexed bool
+	}{
+		{name: "store"},
+		{name: "secretIndex", indexed: true},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			s := storeWithKeyAuthConsumers(b, 1000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var storer store.Storer = s
+				if bb.indexed {
+					storer = NewSecretIndex(s)
+				}
+				var state KongState
+				_ = state.FillConsumersAndCredentials(logrus.New(), storer, nil, nil, nil, ConsumerFillOptions{})
+			}
+			b.ReportMetric(float64(s.secretLookups+s.secretListings)/float64(b.N), "store-calls/op")
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package kongstate
+
+import (
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+// newUpdatableStore returns a store holding objs along with its cache stores, through
+// which objects can be updated between fills like informers do.
+func newUpdatableStore(t *testing.T, objs ...runtime.Object) (store.Storer, store.CacheStores) {
+	cs := store.NewCacheStores()
+	for _, obj := range objs {
+		require.NoError(t, cs.Add(obj))
+	}
+	return store.New(cs, annotations.DefaultIngressClass, true, true, true, logrus.New()), cs
+}
+
+// rotateSecret replaces the data of a Secret in cs, bumping its resource version.
+func rotateSecret(t *testing.T, cs store.CacheStores, secret *corev1.Secret, resourceVersion string, data map[string][]byte) {
+	rotated := secret.DeepCopy()
+	rotated.ResourceVersion = resourceVersion
+	rotated.Data = data
+	require.NoError(t, cs.Add(rotated))
+}
+
+func Test_FillConsumersAndCredentials_SecretRotation(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-key", Namespace: "default", ResourceVersion: "1"},
+		Data: map[string][]byte{
+			"kongCredType": []byte("key-auth"),
+			"key":          []byte("old-key"),
+		},
+	}
+	s, cs := newUpdatableStore(t, secret, &configurationv1.KongConsumer{
+		ObjectMeta:  metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+		Username:    "foo",
+		Credentials: []string{"foo-key"},
+	})
+
+	fill := func() string {
+		var state KongState
+		require.NoError(t, state.FillConsumersAndCredentials(logrus.New(), s, nil, nil, nil, ConsumerFillOptions{}))
+		require.Len(t, state.Consumers, 1)
+		require.Len(t, state.Consumers[0].KeyAuths, 1)
+		return *state.Consumers[0].KeyAuths[0].Key
+	}
+	assert.Equal(t, "old-key", fill())
+
+	rotateSecret(t, cs, secret, "2", map[string][]byte{
+		"kongCredType": []byte("key-auth"),
+		"key":          []byte("new-key"),
+	})
+	assert.Equal(t, "new-key", fill())
+}
+
+func TestKongState_FillPlugins_SecretRotation(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "rate-limit-config", Namespace: "default", ResourceVersion: "1"},
+		Data:       map[string][]byte{"config": []byte(`{"minute": 5}`)},
+	}
+	s, cs := newUpdatableStore(t, secret, &configurationv1.KongPlugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "rate-limit", Namespace: "default"},
+		PluginName: "rate-limiting",
+		ConfigFrom: &configurationv1.ConfigSource{
+			SecretValue: configurationv1.SecretValueFromSource{Secret: "rate-limit-config", Key: "config"},
+		},
+	})
+	newState := func() KongState {
+		return KongState{
+			Services: []Service{{
+				Service: kong.Service{Name: kong.String("foo-service")},
+				Routes: []Route{{
+					Route: kong.Route{Name: kong.String("foo-route")},
+					Ingress: util.K8sObjectInfo{Name: "foo", Namespace: "default", Annotations: map[string]string{
+						annotations.AnnotationPrefix + annotations.PluginsKey: "rate-limit",
+					}},
+				}},
+			}},
+		}
+	}
+
+	prev := newState()
+	prev.FillPlugins(logrus.New(), s, nil, nil, false, nil)
+	require.Len(t, prev.Plugins, 1)
+	assert.Equal(t, kong.Configuration{"minute": float64(5)}, prev.Plugins[0].Config)
+
+	rotateSecret(t, cs, secret, "2", map[string][]byte{"config": []byte(`{"minute": 10}`)})
+
+	t.Run("full fill reads the rotated Secret", func(t *testing.T) {
+		state := newState()
+		state.FillPlugins(logrus.New(), s, nil, nil, false, nil)
+		require.Len(t, state.Plugins, 1)
+		assert.Equal(t, kong.Configuration{"minute": float64(10)}, state.Plugins[0].Config)
+	})
+
+	t.Run("incremental fill detects the rotated Secret by its resource version", func(t *testing.T) {
+		state := newState()
+		state.FillPluginsIncremental(logrus.New(), s, nil, nil, false, nil, &prev)
+		require.Len(t, state.Plugins, 1)
+		assert.Equal(t, kong.Configuration{"minute": float64(10)}, state.Plugins[0].Config)
+	})
+}
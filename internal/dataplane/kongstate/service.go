@@ -106,6 +106,67 @@ func (s *Service) overrideProtocol(anns map[string]string) {
 	s.Protocol = kong.String(protocol)
 }
 
+// appProtocolProtocols maps the Kubernetes Service port appProtocol values to the Kong service
+// protocols they imply. They're all in the HTTP family, which is the only one appProtocol can
+// change the protocol within.
+var appProtocolProtocols = map[string]string{
+	"http":  "http",
+	"https": "https",
+	"grpc":  "grpc",
+	"grpcs": "grpcs",
+}
+
+// overrideByAppProtocol sets the protocol of the Kong service from the appProtocol of the port of
+// the Kubernetes service it's backed by, if it's one of appProtocolProtocols. Only services using a
+// protocol of the HTTP family are changed: the tcp, tls and udp services of TCPIngresses,
+// UDPIngresses and TLS routes keep their protocol, as their routes depend on it.
+func (s *Service) overrideByAppProtocol(svc *corev1.Service) {
+	if s == nil || svc == nil || s.Protocol == nil {
+		return
+	}
+	if _, ok := appProtocolProtocols[*s.Protocol]; !ok {
+		return
+	}
+	port := s.backendPort(svc)
+	if port == nil || port.AppProtocol == nil {
+		return
+	}
+	if protocol, ok := appProtocolProtocols[*port.AppProtocol]; ok {
+		s.Protocol = kong.String(protocol)
+	}
+}
+
+// backendPort returns the port of a Kubernetes service used by a backend of the Kong service,
+// nil if no backend uses the Kubernetes service or if the port doesn't exist.
+func (s *Service) backendPort(svc *corev1.Service) *corev1.ServicePort {
+	for _, backend := range s.Backends {
+		namespace := backend.Namespace
+		if namespace == "" {
+			namespace = s.Namespace
+		}
+		if backend.Name != svc.Name || namespace != svc.Namespace {
+			continue
+		}
+		for i, port := range svc.Spec.Ports {
+			switch backend.PortDef.Mode {
+			case PortModeByNumber:
+				if port.Port == backend.PortDef.Number {
+					return &svc.Spec.Ports[i]
+				}
+			case PortModeByName:
+				if port.Name == backend.PortDef.Name {
+					return &svc.Spec.Ports[i]
+				}
+			case PortModeImplicit:
+				if len(svc.Spec.Ports) == 1 {
+					return &svc.Spec.Ports[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // overrideByAnnotation modifies the Kong service based on annotations
 // on the Kubernetes service.
 func (s *Service) overrideByAnnotation(anns map[string]string) {
@@ -116,7 +177,8 @@ func (s *Service) overrideByAnnotation(anns map[string]string) {
 	s.overridePath(anns)
 }
 
-// override sets Service fields by KongIngress first, then by k8s Service's annotations.
+// override sets Service fields by the appProtocol of the k8s Service port first, then by KongIngress,
+// then by k8s Service's annotations, so that explicit overrides take precedence over appProtocol.
 func (s *Service) override(
 	log logrus.FieldLogger,
 	kongIngress *configurationv1.KongIngress,
@@ -126,6 +188,8 @@ func (s *Service) override(
 		return
 	}
 
+	s.overrideByAppProtocol(svc)
+
 	if s.Parent != nil && kongIngress != nil {
 		kongIngressFromSvcAnnotation := annotations.ExtractConfigurationName(svc.Annotations)
 		if kongIngressFromSvcAnnotation != "" {
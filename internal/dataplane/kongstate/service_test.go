@@ -8,6 +8,8 @@ import (
 	"github.com/kong/go-kong/kong"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
 )
@@ -382,6 +384,114 @@ func TestOverrideService(t *testing.T) {
 	})
 }
 
+func TestOverrideService_AppProtocol(t *testing.T) {
+	k8sService := func(appProtocol string, anns map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Annotations: anns},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{
+					{Name: "metrics", Port: 9090},
+					{Name: "api", Port: 80, AppProtocol: kong.String(appProtocol)},
+				},
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name         string
+		svc          *corev1.Service
+		portDef      PortDef
+		kongIngress  *configurationv1.KongIngress
+		wantProtocol string
+		wantPath     *string
+	}{
+		{
+			name:         "appProtocol grpc infers grpc",
+			svc:          k8sService("grpc", nil),
+			portDef:      PortDef{Mode: PortModeByNumber, Number: 80},
+			wantProtocol: "grpc",
+		},
+		{
+			name:         "appProtocol of a port referenced by name",
+			svc:          k8sService("https", nil),
+			portDef:      PortDef{Mode: PortModeByName, Name: "api"},
+			wantProtocol: "https",
+			wantPath:     kong.String("/"),
+		},
+		{
+			name:         "appProtocol of another port is ignored",
+			svc:          k8sService("grpc", nil),
+			portDef:      PortDef{Mode: PortModeByNumber, Number: 9090},
+			wantProtocol: "http",
+			wantPath:     kong.String("/"),
+		},
+		{
+			name:         "unknown appProtocol is ignored",
+			svc:          k8sService("kubernetes.io/h2c", nil),
+			portDef:      PortDef{Mode: PortModeByNumber, Number: 80},
+			wantProtocol: "http",
+			wantPath:     kong.String("/"),
+		},
+		{
+			name:         "annotation overrides appProtocol",
+			svc:          k8sService("grpc", map[string]string{"konghq.com/protocol": "https"}),
+			portDef:      PortDef{Mode: PortModeByNumber, Number: 80},
+			wantProtocol: "https",
+			wantPath:     kong.String("/"),
+		},
+		{
+			name:    "KongIngress overrides appProtocol",
+			svc:     k8sService("grpc", nil),
+			portDef: PortDef{Mode: PortModeByNumber, Number: 80},
+			kongIngress: &configurationv1.KongIngress{
+				Proxy: &configurationv1.KongIngressService{Protocol: kong.String("https")},
+			},
+			wantProtocol: "https",
+			wantPath:     kong.String("/"),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logrus.New()
+			log.SetOutput(io.Discard)
+
+			service := Service{
+				Service: kong.Service{
+					Name:     kong.String("default.foo.80"),
+					Protocol: kong.String("http"),
+					Path:     kong.String("/"),
+				},
+				Namespace: "default",
+				Backends:  []ServiceBackend{{Name: "foo", PortDef: tt.portDef}},
+			}
+			service.override(log, tt.kongIngress, tt.svc)
+			assert.Equal(t, tt.wantProtocol, *service.Protocol)
+			assert.Equal(t, tt.wantPath, service.Path)
+		})
+	}
+}
+
+func TestOverrideService_AppProtocolOfL4Services(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	// the service of a TCPIngress backend, as built by the parser
+	service := Service{
+		Service: kong.Service{
+			Name:     kong.String("default.foo.80"),
+			Protocol: kong.String("tcp"),
+		},
+		Namespace: "default",
+		Backends:  []ServiceBackend{{Name: "foo", PortDef: PortDef{Mode: PortModeByNumber, Number: 80}}},
+	}
+	service.override(log, nil, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "api", Port: 80, AppProtocol: kong.String("http")}},
+		},
+	})
+	assert.Equal(t, "tcp", *service.Protocol)
+}
+
 func Test_overrideServicePath(t *testing.T) {
 	type args struct {
 		service Service
@@ -0,0 +1,71 @@
+package kongstate
+
+// Stats reports the size of a KongState, e.g. for capacity planning dashboards.
+// Fields are only ever added, so that serialized stats stay comparable.
+type Stats struct {
+	Services       int `json:"services"`
+	Routes         int `json:"routes"`
+	Upstreams      int `json:"upstreams"`
+	Targets        int `json:"targets"`
+	Certificates   int `json:"certificates"`
+	SNIs           int `json:"snis"`
+	CACertificates int `json:"ca_certificates"`
+	Consumers      int `json:"consumers"`
+	ConsumerGroups int `json:"consumer_groups"`
+	Vaults         int `json:"vaults"`
+
+	// Plugins is the number of plugins of the state. ServicePlugins, RoutePlugins,
+	// ConsumerPlugins and ConsumerGroupPlugins are the numbers of plugins attached to
+	// services, routes, consumers and consumer groups, a plugin attached to several entities
+	// being counted in each of them, and GlobalPlugins the number of plugins not attached
+	// to any entity.
+	Plugins              int `json:"plugins"`
+	ServicePlugins       int `json:"service_plugins"`
+	RoutePlugins         int `json:"route_plugins"`
+	ConsumerPlugins      int `json:"consumer_plugins"`
+	ConsumerGroupPlugins int `json:"consumer_group_plugins"`
+	GlobalPlugins        int `json:"global_plugins"`
+
+	// Credentials is the number of credentials of all types held by consumers, ACL groups
+	// included, and ConsumersWithCredentials the number of consumers holding at least one.
+	Credentials              int `json:"credentials"`
+	ConsumersWithCredentials int `json:"consumers_with_credentials"`
+}
+
+// Stats computes the counts of the entities of the state.
+func (ks *KongState) Stats() Stats {
+	plugins := summarizePlugins(ks.Plugins, nil)
+	stats := Stats{
+		Services:             len(ks.Services),
+		Upstreams:            len(ks.Upstreams),
+		Certificates:         len(ks.Certificates),
+		CACertificates:       len(ks.CACertificates),
+		Consumers:            len(ks.Consumers),
+		ConsumerGroups:       len(ks.ConsumerGroups),
+		Vaults:               len(ks.Vaults),
+		Plugins:              len(ks.Plugins),
+		ServicePlugins:       plugins.ServicePlugins,
+		RoutePlugins:         plugins.RoutePlugins,
+		ConsumerPlugins:      plugins.ConsumerPlugins,
+		ConsumerGroupPlugins: plugins.ConsumerGroupPlugins,
+		GlobalPlugins:        plugins.GlobalPlugins,
+	}
+	for _, s := range ks.Services {
+		stats.Routes += len(s.Routes)
+	}
+	for _, u := range ks.Upstreams {
+		stats.Targets += len(u.Targets)
+	}
+	for _, c := range ks.Certificates {
+		stats.SNIs += len(c.SNIs)
+	}
+	for _, c := range ks.Consumers {
+		credentials := len(c.KeyAuths) + len(c.HMACAuths) + len(c.JWTAuths) + len(c.BasicAuths) +
+			len(c.ACLGroups) + len(c.Oauth2Creds) + len(c.MTLSAuths)
+		stats.Credentials += credentials
+		if credentials > 0 {
+			stats.ConsumersWithCredentials++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,87 @@
+package kongstate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKongState_Stats(t *testing.T) {
+	state := KongState{
+		Services: []Service{
+			{
+				Service: kong.Service{Name: kong.String("a")},
+				Routes:  []Route{{Route: kong.Route{Name: kong.String("a-1")}}, {Route: kong.Route{Name: kong.String("a-2")}}},
+			},
+			{
+				Service: kong.Service{Name: kong.String("b")},
+				Routes:  []Route{{Route: kong.Route{Name: kong.String("b-1")}}},
+			},
+		},
+		Upstreams: []Upstream{
+			{Targets: []Target{{}, {}}},
+			{Targets: []Target{{}}},
+		},
+		Certificates: []Certificate{
+			{Certificate: kong.Certificate{SNIs: kong.StringSlice("a.example.com", "b.example.com")}},
+		},
+		CACertificates: []kong.CACertificate{{}},
+		Plugins: []Plugin{
+			{Plugin: kong.Plugin{Name: kong.String("prometheus")}},
+			{Plugin: kong.Plugin{Name: kong.String("key-auth"), Service: &kong.Service{ID: kong.String("a")}}},
+			{Plugin: kong.Plugin{
+				Name:     kong.String("rate-limiting"),
+				Route:    &kong.Route{ID: kong.String("a-1")},
+				Consumer: &kong.Consumer{ID: kong.String("alice")},
+			}},
+		},
+		Consumers: []Consumer{
+			{
+				Consumer:   kong.Consumer{Username: kong.String("alice")},
+				KeyAuths:   []*KeyAuth{{}, {}},
+				ACLGroups:  []*ACLGroup{{}},
+				BasicAuths: []*BasicAuth{{}},
+			},
+			{Consumer: kong.Consumer{Username: kong.String("bob")}},
+		},
+		ConsumerGroups: []ConsumerGroup{{}},
+		Vaults:         []Vault{{Name: "env", Prefix: "env"}},
+	}
+
+	want := Stats{
+		Services:                 2,
+		Routes:                   3,
+		Upstreams:                2,
+		Targets:                  3,
+		Certificates:             1,
+		SNIs:                     2,
+		CACertificates:           1,
+		Consumers:                2,
+		ConsumerGroups:           1,
+		Vaults:                   1,
+		Plugins:                  3,
+		ServicePlugins:           1,
+		RoutePlugins:             1,
+		ConsumerPlugins:          1,
+		GlobalPlugins:            1,
+		Credentials:              4,
+		ConsumersWithCredentials: 1,
+	}
+	assert.Equal(t, want, state.Stats())
+
+	b, err := json.Marshal(state.Stats())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"services": 2, "routes": 3, "upstreams": 2, "targets": 3,
+		"certificates": 1, "snis": 2, "ca_certificates": 1,
+		"consumers": 2, "consumer_groups": 1, "vaults": 1,
+		"plugins": 3, "service_plugins": 1, "route_plugins": 1, "consumer_plugins": 1,
+		"consumer_group_plugins": 0, "global_plugins": 1,
+		"credentials": 4, "consumers_with_credentials": 1
+	}`, string(b))
+
+	assert.Equal(t, Stats{}, (&KongState{}).Stats())
+}
@@ -0,0 +1,87 @@
+package kongstate
+
+import (
+	"errors"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+)
+
+// StoreRetryPolicy bounds the retries of the store lookups failing with a transient error,
+// e.g. while an informer cache is momentarily inconsistent. Lookups of objects which don't
+// exist are not retried.
+type StoreRetryPolicy struct {
+	// Attempts is the maximum number of times a lookup is made, including the first one.
+	// Values lower than 2 disable retries.
+	Attempts int
+	// Backoff is the delay before the first retry. It's doubled before every following retry.
+	Backoff time.Duration
+}
+
+// Enabled reports whether the policy retries failed lookups.
+func (p StoreRetryPolicy) Enabled() bool {
+	return p.Attempts > 1
+}
+
+// retryingStore wraps a store.Storer to retry the lookups of the Secrets and plugins the
+// Fill methods depend on according to a StoreRetryPolicy.
+type retryingStore struct {
+	store.Storer
+
+	policy StoreRetryPolicy
+	sleep  func(time.Duration)
+}
+
+// NewRetryingStore returns a store.Storer retrying the Secret, KongPlugin and KongClusterPlugin
+// lookups of s which fail with an error other than store.ErrNotFound, as allowed by policy.
+// Other lookups are served by s as-is.
+func NewRetryingStore(s store.Storer, policy StoreRetryPolicy) store.Storer {
+	return &retryingStore{Storer: s, policy: policy, sleep: time.Sleep}
+}
+
+// GetSecret returns the 'name' Secret resource in namespace.
+func (r *retryingStore) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	var secret *corev1.Secret
+	err := r.retry(func() (err error) {
+		secret, err = r.Storer.GetSecret(namespace, name)
+		return err
+	})
+	return secret, err
+}
+
+// GetKongPlugin returns the 'name' KongPlugin resource in namespace.
+func (r *retryingStore) GetKongPlugin(namespace, name string) (*configurationv1.KongPlugin, error) {
+	var plugin *configurationv1.KongPlugin
+	err := r.retry(func() (err error) {
+		plugin, err = r.Storer.GetKongPlugin(namespace, name)
+		return err
+	})
+	return plugin, err
+}
+
+// GetKongClusterPlugin returns the 'name' KongClusterPlugin resource.
+func (r *retryingStore) GetKongClusterPlugin(name string) (*configurationv1.KongClusterPlugin, error) {
+	var plugin *configurationv1.KongClusterPlugin
+	err := r.retry(func() (err error) {
+		plugin, err = r.Storer.GetKongClusterPlugin(name)
+		return err
+	})
+	return plugin, err
+}
+
+// retry calls lookup until it succeeds, fails with store.ErrNotFound, or the attempts of the
+// policy are exhausted. It returns the error of the last call.
+func (r *retryingStore) retry(lookup func() error) error {
+	backoff := r.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := lookup()
+		if err == nil || errors.As(err, &store.ErrNotFound{}) || attempt >= r.policy.Attempts {
+			return err
+		}
+		r.sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,72 @@
+package kongstate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+)
+
+// flakyStore wraps a store.Storer to fail its first failures Secret lookups with a transient
+// error, and counts the Secret lookups.
+type flakyStore struct {
+	store.Storer
+
+	failures int
+	lookups  int
+}
+
+func (f *flakyStore) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	f.lookups++
+	if f.lookups <= f.failures {
+		return nil, errors.New("informer cache is not synced")
+	}
+	return f.Storer.GetSecret(namespace, name)
+}
+
+func TestRetryingStore(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{
+			{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}},
+		},
+	})
+	require.NoError(t, err)
+
+	newStore := func(failures int) (*retryingStore, *flakyStore, *[]time.Duration) {
+		flaky := &flakyStore{Storer: s, failures: failures}
+		retrying := NewRetryingStore(flaky, StoreRetryPolicy{Attempts: 3, Backoff: time.Millisecond}).(*retryingStore)
+		var sleeps []time.Duration
+		retrying.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+		return retrying, flaky, &sleeps
+	}
+
+	t.Run("transient errors are retried", func(t *testing.T) {
+		retrying, flaky, sleeps := newStore(2)
+		secret, err := retrying.GetSecret("default", "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", secret.Name)
+		assert.Equal(t, 3, flaky.lookups)
+		assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, *sleeps)
+	})
+
+	t.Run("retries are bounded", func(t *testing.T) {
+		retrying, flaky, _ := newStore(5)
+		_, err := retrying.GetSecret("default", "foo")
+		assert.EqualError(t, err, "informer cache is not synced")
+		assert.Equal(t, 3, flaky.lookups)
+	})
+
+	t.Run("missing objects are not retried", func(t *testing.T) {
+		retrying, flaky, sleeps := newStore(0)
+		_, err := retrying.GetSecret("default", "missing")
+		assert.True(t, errors.As(err, &store.ErrNotFound{}))
+		assert.Equal(t, 1, flaky.lookups)
+		assert.Empty(t, *sleeps)
+	})
+}
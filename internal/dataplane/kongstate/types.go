@@ -2,6 +2,7 @@ package kongstate
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/kong/go-kong/kong"
 )
@@ -59,12 +60,15 @@ type Target struct {
 // Certificate represents the certificate object in Kong.
 type Certificate struct {
 	kong.Certificate
+
+	// names caches the names parsed from Cert by Names.
+	names *certificateNames
 }
 
 // SanitizedCopy returns a shallow copy with sensitive values redacted best-effort.
 func (c *Certificate) SanitizedCopy() *Certificate {
 	return &Certificate{
-		kong.Certificate{
+		Certificate: kong.Certificate{
 			ID:        c.ID,
 			Cert:      c.Cert,
 			Key:       redactedString,
@@ -75,7 +79,114 @@ func (c *Certificate) SanitizedCopy() *Certificate {
 	}
 }
 
+// SensitiveTagPrefixes holds the prefixes of entity tags which are removed by
+// KongState.SanitizedCopy, e.g. tags carrying internal identifiers. It's empty by default.
+var SensitiveTagPrefixes []string
+
+// sanitizedCACertificate returns a copy of a CA certificate with the tags matching
+// any of the sensitiveTagPrefixes removed. The certificate itself is public and is kept.
+func sanitizedCACertificate(c kong.CACertificate, sensitiveTagPrefixes []string) kong.CACertificate {
+	return kong.CACertificate{
+		ID:         c.ID,
+		Cert:       c.Cert,
+		CertDigest: c.CertDigest,
+		CreatedAt:  c.CreatedAt,
+		Tags:       sanitizedTags(c.Tags, sensitiveTagPrefixes),
+	}
+}
+
+// sanitizedTags returns the tags which don't start with any of the sensitiveTagPrefixes.
+func sanitizedTags(tags []*string, sensitiveTagPrefixes []string) []*string {
+	if tags == nil {
+		return nil
+	}
+	res := make([]*string, 0, len(tags))
+	for _, tag := range tags {
+		if tag != nil && hasAnyPrefix(*tag, sensitiveTagPrefixes) {
+			continue
+		}
+		res = append(res, tag)
+	}
+	return res
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // Plugin represetns a plugin Object in Kong.
 type Plugin struct {
 	kong.Plugin
+
+	// ConsumerGroup is the name of the consumer group the plugin is scoped to. It's kept
+	// outside of kong.Plugin as the go-kong version in use doesn't support consumer groups yet.
+	ConsumerGroup *string
+
+	// configFromSource is set for plugins whose configuration was read from a Secret
+	// or a ConfigMap with ConfigFrom, whose values may be strings where the plugin
+	// schema expects other types.
+	configFromSource bool
+}
+
+// SensitivePluginConfigKeys holds the names of plugin configuration fields whose values
+// are redacted by KongState.SanitizedCopy. The defaults cover the secret-bearing fields of
+// plugins bundled with Kong; fields of custom plugins can be appended to it.
+var SensitivePluginConfigKeys = []string{
+	"access_token",
+	"api_key",
+	"aws_key",
+	"aws_secret",
+	"client_secret",
+	"password",
+	"private_key",
+	"redis_password",
+	"refresh_token",
+	"secret",
+	"session_secret",
+	"token",
+}
+
+// SanitizedCopy returns a deep copy with the values of the sensitiveKeys configuration
+// fields redacted, at any nesting level.
+func (p *Plugin) SanitizedCopy(sensitiveKeys []string) *Plugin {
+	sensitive := make(map[string]struct{}, len(sensitiveKeys))
+	for _, k := range sensitiveKeys {
+		sensitive[k] = struct{}{}
+	}
+	res := *p
+	res.Plugin = *p.Plugin.DeepCopy()
+	if res.Config != nil {
+		res.Config = redactConfig(res.Config, sensitive).(map[string]interface{})
+	}
+	return &res
+}
+
+// redactConfig replaces the values of sensitive keys in a plugin configuration
+// in place and returns the result.
+func redactConfig(v interface{}, sensitive map[string]struct{}) interface{} {
+	switch v := v.(type) {
+	case kong.Configuration:
+		return redactConfig(map[string]interface{}(v), sensitive)
+	case map[string]interface{}:
+		for k, value := range v {
+			if _, ok := sensitive[k]; ok && value != nil {
+				v[k] = *redactedString
+				continue
+			}
+			v[k] = redactConfig(value, sensitive)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactConfig(v[i], sensitive)
+		}
+		return v
+	default:
+		return v
+	}
 }
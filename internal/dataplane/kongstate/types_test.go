@@ -39,3 +39,118 @@ func TestCertificate_SanitizedCopy(t *testing.T) {
 		})
 	}
 }
+
+func TestPlugin_SanitizedCopy(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		in            Plugin
+		sensitiveKeys []string
+		want          Plugin
+	}{
+		{
+			name: "redacts sensitive config keys and keeps other ones",
+			in: Plugin{Plugin: kong.Plugin{
+				ID:   kong.String("1"),
+				Name: kong.String("aws-lambda"),
+				Config: kong.Configuration{
+					"aws_key":       "key",
+					"aws_secret":    "secret",
+					"aws_region":    "us-east-1",
+					"function_name": "foo",
+				},
+			}},
+			sensitiveKeys: SensitivePluginConfigKeys,
+			want: Plugin{Plugin: kong.Plugin{
+				ID:   kong.String("1"),
+				Name: kong.String("aws-lambda"),
+				Config: kong.Configuration{
+					"aws_key":       *redactedString,
+					"aws_secret":    *redactedString,
+					"aws_region":    "us-east-1",
+					"function_name": "foo",
+				},
+			}},
+		},
+		{
+			name: "redacts nested config keys",
+			in: Plugin{Plugin: kong.Plugin{
+				Name: kong.String("custom"),
+				Config: kong.Configuration{
+					"redis": map[string]interface{}{
+						"host":     "redis",
+						"password": "secret",
+					},
+					"upstreams": []interface{}{
+						map[string]interface{}{"token": "secret"},
+					},
+				},
+			}},
+			sensitiveKeys: []string{"password", "token"},
+			want: Plugin{Plugin: kong.Plugin{
+				Name: kong.String("custom"),
+				Config: kong.Configuration{
+					"redis": map[string]interface{}{
+						"host":     "redis",
+						"password": *redactedString,
+					},
+					"upstreams": []interface{}{
+						map[string]interface{}{"token": *redactedString},
+					},
+				},
+			}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := *tt.in.SanitizedCopy(tt.sensitiveKeys)
+			assert.Equal(t, tt.want, got)
+			assert.NotEqual(t, tt.want.Config, tt.in.Config, "the original plugin must not be modified")
+		})
+	}
+}
+
+func Test_sanitizedCACertificate(t *testing.T) {
+	createdAt := int64(1)
+	for _, tt := range []struct {
+		name                 string
+		in                   kong.CACertificate
+		sensitiveTagPrefixes []string
+		want                 kong.CACertificate
+	}{
+		{
+			name: "keeps the certificate and removes sensitive tags",
+			in: kong.CACertificate{
+				ID:         kong.String("1"),
+				Cert:       kong.String("-----BEGIN CERTIFICATE-----"),
+				CertDigest: kong.String("digest"),
+				CreatedAt:  &createdAt,
+				Tags:       kong.StringSlice("managed-by-ingress-controller", "internal:team-a"),
+			},
+			sensitiveTagPrefixes: []string{"internal:"},
+			want: kong.CACertificate{
+				ID:         kong.String("1"),
+				Cert:       kong.String("-----BEGIN CERTIFICATE-----"),
+				CertDigest: kong.String("digest"),
+				CreatedAt:  &createdAt,
+				Tags:       kong.StringSlice("managed-by-ingress-controller"),
+			},
+		},
+		{
+			name: "keeps all tags without sensitive tag prefixes",
+			in: kong.CACertificate{
+				ID:   kong.String("1"),
+				Cert: kong.String("-----BEGIN CERTIFICATE-----"),
+				Tags: kong.StringSlice("internal:team-a"),
+			},
+			want: kong.CACertificate{
+				ID:   kong.String("1"),
+				Cert: kong.String("-----BEGIN CERTIFICATE-----"),
+				Tags: kong.StringSlice("internal:team-a"),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizedCACertificate(tt.in, tt.sensitiveTagPrefixes)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -2,11 +2,13 @@ package kongstate
 
 import (
 	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
 	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+	configurationv1beta1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1beta1"
 )
 
 // Upstream is a wrapper around Upstream object in Kong.
@@ -17,6 +19,19 @@ type Upstream struct {
 	Service Service
 }
 
+// SanitizedCopy returns a shallow copy with sensitive values redacted best-effort.
+// The key of the client certificate is redacted. Healthchecks are kept as is: the
+// healthcheck settings supported by go-kong don't include request headers, which is
+// the only place where they could carry credentials.
+func (u *Upstream) SanitizedCopy() *Upstream {
+	res := *u
+	if u.ClientCertificate != nil {
+		cert := Certificate{*u.ClientCertificate}
+		res.ClientCertificate = &cert.SanitizedCopy().Certificate
+	}
+	return &res
+}
+
 func (u *Upstream) overrideHostHeader(anns map[string]string) {
 	if u == nil {
 		return
@@ -81,8 +96,66 @@ func (u *Upstream) overrideByKongIngress(kongIngress *configurationv1.KongIngres
 	// TODO https://github.com/Kong/kubernetes-ingress-controller/issues/2075
 }
 
+// overrideByUpstreamPolicy modifies the Kong upstream based on the KongUpstreamPolicy
+// attached to the Kubernetes service.
+func (u *Upstream) overrideByUpstreamPolicy(policy *configurationv1beta1.KongUpstreamPolicy) {
+	if u == nil || policy == nil {
+		return
+	}
+
+	p := policy.Spec
+	if p.Algorithm != nil {
+		u.Algorithm = kong.String(*p.Algorithm)
+	}
+	if p.Slots != nil {
+		u.Slots = kong.Int(*p.Slots)
+	}
+	if p.Healthchecks != nil {
+		u.Healthchecks = p.Healthchecks
+	}
+	if p.HashOn != nil {
+		u.HashOn, u.HashOnHeader = upstreamHash(p.HashOn)
+		if p.HashOn.Cookie != nil {
+			u.HashOnCookie = kong.String(*p.HashOn.Cookie)
+			if p.HashOn.CookiePath != nil {
+				u.HashOnCookiePath = kong.String(*p.HashOn.CookiePath)
+			}
+		}
+	}
+	if p.HashOnFallback != nil {
+		u.HashFallback, u.HashFallbackHeader = upstreamHash(p.HashOnFallback)
+	}
+	if p.TargetWeight != nil {
+		// weights set by Services and backend references take precedence
+		for i := range u.Targets {
+			if u.Targets[i].Weight == nil {
+				u.Targets[i].Weight = kong.Int(*p.TargetWeight)
+			}
+		}
+	}
+}
+
+// upstreamHash translates a KongUpstreamHash into the Kong hash_on (or hash_fallback)
+// value and, for header hashes, the name of the header.
+func upstreamHash(h *configurationv1beta1.KongUpstreamHash) (hashOn *string, header *string) {
+	switch {
+	case h.Header != nil:
+		return kong.String("header"), kong.String(*h.Header)
+	case h.Cookie != nil:
+		return kong.String("cookie"), nil
+	case h.Input != nil:
+		return kong.String(*h.Input), nil
+	default:
+		return nil, nil
+	}
+}
+
 // override sets Upstream fields by KongIngress first, then by k8s Service's annotations.
+// The host header sent to the targets is an upstream setting (Kong services only hold the
+// upstream name in their host): the konghq.com/host-header annotation of the Service takes
+// precedence over the host_header of the KongIngress, and a warning is logged when they differ.
 func (u *Upstream) override(
+	log logrus.FieldLogger,
 	kongIngress *configurationv1.KongIngress,
 	svc *corev1.Service,
 ) {
@@ -107,6 +180,26 @@ func (u *Upstream) override(
 
 	u.overrideByKongIngress(kongIngress)
 	if svc != nil {
+		warnConflictingHostHeaders(log, kongIngress, svc)
 		u.overrideByAnnotation(svc.Annotations)
 	}
 }
+
+// warnConflictingHostHeaders logs a warning when the host header set by the konghq.com/host-header
+// annotation of a Service differs from the host_header of its KongIngress.
+func warnConflictingHostHeaders(log logrus.FieldLogger, kongIngress *configurationv1.KongIngress, svc *corev1.Service) {
+	if kongIngress == nil || kongIngress.Upstream == nil || kongIngress.Upstream.HostHeader == nil {
+		return
+	}
+	host := annotations.ExtractHostHeader(svc.Annotations)
+	if host == "" || host == *kongIngress.Upstream.HostHeader {
+		return
+	}
+	log.WithFields(logrus.Fields{
+		"service_name":            svc.Name,
+		"service_namespace":       svc.Namespace,
+		"kongingress_name":        kongIngress.Name,
+		"annotation_host_header":  host,
+		"kongingress_host_header": *kongIngress.Upstream.HostHeader,
+	}).Warn("conflicting upstream host headers, the konghq.com/host-header annotation takes precedence over the KongIngress host_header")
+}
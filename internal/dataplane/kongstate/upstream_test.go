@@ -1,13 +1,18 @@
 package kongstate
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
 	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
 )
 
@@ -74,12 +79,114 @@ func TestOverrideUpstream(t *testing.T) {
 	}
 
 	for _, testcase := range testTable {
-		testcase.inUpstream.override(testcase.inKongIngresss, testcase.svc)
+		testcase.inUpstream.override(logrus.New(), testcase.inKongIngresss, testcase.svc)
 		assert.Equal(testcase.inUpstream, testcase.outUpstream)
 	}
 
 	assert.NotPanics(func() {
 		var nilUpstream *Upstream
-		nilUpstream.override(nil, nil)
+		nilUpstream.override(logrus.New(), nil, nil)
 	})
 }
+
+func TestKongState_FillOverrides_UpstreamHostHeader(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		KongIngresses: []*configurationv1.KongIngress{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "host-header", Namespace: "default"},
+				Upstream: &configurationv1.KongIngressUpstream{
+					HostHeader: kong.String("kongingress.example.com"),
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	stateWithAnnotations := func(anns map[string]string) KongState {
+		service := Service{
+			Service: kong.Service{
+				Name:     kong.String("default.foo.80"),
+				Host:     kong.String("foo.default.80.svc"),
+				Protocol: kong.String("http"),
+			},
+			K8sServices: map[string]*corev1.Service{
+				"foo": {ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Annotations: anns}},
+			},
+		}
+		return KongState{
+			Services: []Service{service},
+			Upstreams: []Upstream{{
+				Upstream: kong.Upstream{Name: kong.String("foo.default.80.svc")},
+				Service:  service,
+			}},
+		}
+	}
+
+	t.Run("the KongIngress host header lands on the upstream", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log := logrus.New()
+		log.SetOutput(buf)
+
+		state := stateWithAnnotations(map[string]string{
+			annotations.AnnotationPrefix + annotations.ConfigurationKey: "host-header",
+		})
+		require.NoError(t, state.FillOverrides(log, s))
+		assert.Equal(t, kong.String("kongingress.example.com"), state.Upstreams[0].HostHeader)
+		assert.Equal(t, kong.String("foo.default.80.svc"), state.Services[0].Host,
+			"the service should keep pointing to its upstream")
+		assert.NotContains(t, buf.String(), "conflicting upstream host headers")
+	})
+
+	t.Run("the annotation takes precedence over a conflicting KongIngress host header", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log := logrus.New()
+		log.SetOutput(buf)
+
+		state := stateWithAnnotations(map[string]string{
+			annotations.AnnotationPrefix + annotations.ConfigurationKey: "host-header",
+			annotations.AnnotationPrefix + annotations.HostHeaderKey:    "annotation.example.com",
+		})
+		require.NoError(t, state.FillOverrides(log, s))
+		assert.Equal(t, kong.String("annotation.example.com"), state.Upstreams[0].HostHeader)
+		assert.Contains(t, buf.String(), "conflicting upstream host headers")
+		assert.Contains(t, buf.String(), "kongingress_host_header=kongingress.example.com")
+	})
+}
+
+func TestUpstream_SanitizedCopy(t *testing.T) {
+	in := Upstream{
+		Upstream: kong.Upstream{
+			Name: kong.String("foo.com"),
+			ClientCertificate: &kong.Certificate{
+				ID:   kong.String("1"),
+				Cert: kong.String("cert"),
+				Key:  kong.String("secret"),
+			},
+			Healthchecks: &kong.Healthcheck{
+				Active: &kong.ActiveHealthcheck{
+					HTTPPath: kong.String("/healthz"),
+				},
+			},
+		},
+		Targets: []Target{{Target: kong.Target{Target: kong.String("10.0.0.1:80")}}},
+	}
+
+	got := in.SanitizedCopy()
+	assert.Equal(t, &Upstream{
+		Upstream: kong.Upstream{
+			Name: kong.String("foo.com"),
+			ClientCertificate: &kong.Certificate{
+				ID:   kong.String("1"),
+				Cert: kong.String("cert"),
+				Key:  redactedString,
+			},
+			Healthchecks: &kong.Healthcheck{
+				Active: &kong.ActiveHealthcheck{
+					HTTPPath: kong.String("/healthz"),
+				},
+			},
+		},
+		Targets: []Target{{Target: kong.Target{Target: kong.String("10.0.0.1:80")}}},
+	}, got)
+	assert.Equal(t, "secret", *in.ClientCertificate.Key, "the original upstream should not be modified")
+}
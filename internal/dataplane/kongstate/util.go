@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/ghodss/yaml"
 	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
@@ -14,6 +17,7 @@ import (
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
 	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+	configurationv1beta1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1beta1"
 )
 
 func getKongIngressForServices(
@@ -24,8 +28,9 @@ func getKongIngressForServices(
 	// there can only be one KongIngress for a group of services: either one of
 	// them is configured with a KongIngress and this configures the Kong Service
 	// or Upstream OR all of them can be configured but they must be configured
-	// with the same KongIngress.
-	for _, svc := range services {
+	// with the same KongIngress. Services are sorted so that the same KongIngress
+	// is picked on every run if they're not.
+	for _, svc := range sortedServices(services) {
 		// check if the service is even configured with a KongIngress
 		confName := annotations.ExtractConfigurationName(svc.Annotations)
 		if confName == "" {
@@ -48,6 +53,159 @@ func getKongIngressForServices(
 	return nil, nil
 }
 
+// getKongUpstreamPolicyForServices returns the KongUpstreamPolicy attached to a group of
+// Kubernetes Services. As with KongIngress, the services are expected to be annotated with the
+// same policy, so the first one found in namespace/name order of the services is used.
+func getKongUpstreamPolicyForServices(
+	s store.Storer,
+	services map[string]*corev1.Service,
+) (*configurationv1beta1.KongUpstreamPolicy, error) {
+	for _, svc := range sortedServices(services) {
+		policyName := annotations.ExtractUpstreamPolicy(svc.Annotations)
+		if policyName == "" {
+			continue
+		}
+		return s.GetKongUpstreamPolicy(svc.Namespace, policyName)
+	}
+	return nil, nil
+}
+
+// getMergedKongIngressForServices returns the KongIngress configuring the Kong Service backed
+// by a group of Kubernetes Services. Unlike getKongIngressForServices, it considers the KongIngress
+// resources of all the services: the proxy settings are merged, in namespace/name order of the
+// services, so that the first value set for a field wins. Services setting a different value for
+// a field already set by another service are reported with a warning.
+func getMergedKongIngressForServices(
+	log logrus.FieldLogger,
+	s store.Storer,
+	services map[string]*corev1.Service,
+) (*configurationv1.KongIngress, error) {
+	var result *configurationv1.KongIngress
+	// setBy holds the service which set the value of each proxy field in the result.
+	setBy := make(map[string]*corev1.Service)
+	for _, svc := range sortedServices(services) {
+		confName := annotations.ExtractConfigurationName(svc.Annotations)
+		if confName == "" {
+			continue
+		}
+		kongIngress, err := s.GetKongIngress(svc.Namespace, confName)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = kongIngress.DeepCopy()
+		}
+		if kongIngress.Proxy == nil {
+			continue
+		}
+		for _, field := range kongIngressProxyFields {
+			value := field.value(kongIngress.Proxy)
+			if value == nil {
+				continue
+			}
+			appliedBy, ok := setBy[field.name]
+			if !ok {
+				if result.Proxy == nil {
+					result.Proxy = &configurationv1.KongIngressService{}
+				}
+				field.set(result.Proxy, kongIngress.Proxy)
+				setBy[field.name] = svc
+				continue
+			}
+			if applied := field.value(result.Proxy); applied != value {
+				log.WithFields(logrus.Fields{
+					"field":                         field.name,
+					"applied_value":                 applied,
+					"applied_service_name":          appliedBy.Name,
+					"applied_service_namespace":     appliedBy.Namespace,
+					"conflicting_value":             value,
+					"conflicting_service_name":      svc.Name,
+					"conflicting_service_namespace": svc.Namespace,
+				}).Warnf("conflicting KongIngress %s settings for Services backing the same Kong Service,"+
+					" using the value of Service %s/%s", field.name, appliedBy.Namespace, appliedBy.Name)
+			}
+		}
+	}
+	return result, nil
+}
+
+// kongIngressProxyFields lists the KongIngress proxy fields, named after their JSON keys.
+// value returns the value of a field, or nil if it's not set, and set copies a field
+// from one proxy section to another.
+var kongIngressProxyFields = []struct {
+	name  string
+	value func(p *configurationv1.KongIngressService) interface{}
+	set   func(dst, src *configurationv1.KongIngressService)
+}{
+	{
+		name:  "protocol",
+		value: func(p *configurationv1.KongIngressService) interface{} { return derefString(p.Protocol) },
+		set:   func(dst, src *configurationv1.KongIngressService) { dst.Protocol = kong.String(*src.Protocol) },
+	},
+	{
+		name:  "path",
+		value: func(p *configurationv1.KongIngressService) interface{} { return derefString(p.Path) },
+		set:   func(dst, src *configurationv1.KongIngressService) { dst.Path = kong.String(*src.Path) },
+	},
+	{
+		name:  "retries",
+		value: func(p *configurationv1.KongIngressService) interface{} { return derefInt(p.Retries) },
+		set:   func(dst, src *configurationv1.KongIngressService) { dst.Retries = kong.Int(*src.Retries) },
+	},
+	{
+		name:  "connect_timeout",
+		value: func(p *configurationv1.KongIngressService) interface{} { return derefInt(p.ConnectTimeout) },
+		set:   func(dst, src *configurationv1.KongIngressService) { dst.ConnectTimeout = kong.Int(*src.ConnectTimeout) },
+	},
+	{
+		name:  "read_timeout",
+		value: func(p *configurationv1.KongIngressService) interface{} { return derefInt(p.ReadTimeout) },
+		set:   func(dst, src *configurationv1.KongIngressService) { dst.ReadTimeout = kong.Int(*src.ReadTimeout) },
+	},
+	{
+		name:  "write_timeout",
+		value: func(p *configurationv1.KongIngressService) interface{} { return derefInt(p.WriteTimeout) },
+		set:   func(dst, src *configurationv1.KongIngressService) { dst.WriteTimeout = kong.Int(*src.WriteTimeout) },
+	},
+}
+
+// stringValue returns the value of a string pointer, or an empty string if it's nil.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefString(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func derefInt(i *int) interface{} {
+	if i == nil {
+		return nil
+	}
+	return *i
+}
+
+// sortedServices returns services sorted by namespace and name.
+func sortedServices(services map[string]*corev1.Service) []*corev1.Service {
+	res := make([]*corev1.Service, 0, len(services))
+	for _, svc := range services {
+		res = append(res, svc)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Namespace != res[j].Namespace {
+			return res[i].Namespace < res[j].Namespace
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
 func getKongIngressFromObjectMeta(
 	s store.Storer,
 	obj util.K8sObjectInfo,
@@ -78,37 +236,44 @@ func getKongIngressFromObjAnnotations(
 	return nil, nil
 }
 
-// getPlugin constructs a plugins from a KongPlugin resource.
-func getPlugin(s store.Storer, namespace, name string) (kong.Plugin, error) {
-	var plugin kong.Plugin
+// getPlugin constructs a plugin from the KongPlugin referenced by name from an object in
+// namespace. If there's no such KongPlugin, the KongClusterPlugin with the same name is used,
+// so that KongClusterPlugins can be attached to specific services, routes and consumers
+// without being global. It also returns the namespace of the KongPlugin the plugin was
+// built from, which is empty for KongClusterPlugins.
+func getPlugin(s store.Storer, namespace, name string) (Plugin, string, error) {
+	var plugin Plugin
 	k8sPlugin, err := s.GetKongPlugin(namespace, name)
 	if err != nil {
 		// if no namespaced plugin definition, then
 		// search for cluster level-plugin definition
+		if !errors.As(err, &store.ErrNotFound{}) {
+			return plugin, "", err
+		}
+		clusterPlugin, err := s.GetKongClusterPlugin(name)
+		// not found
 		if errors.As(err, &store.ErrNotFound{}) {
-			clusterPlugin, err := s.GetKongClusterPlugin(name)
-			// not found
-			if errors.As(err, &store.ErrNotFound{}) {
-				return plugin, errors.New(
-					"no KongPlugin or KongClusterPlugin was found")
-			}
-			if err != nil {
-				return plugin, err
-			}
-			if clusterPlugin.PluginName == "" {
-				return plugin, fmt.Errorf("invalid empty 'plugin' property")
-			}
-			plugin, err = kongPluginFromK8SClusterPlugin(s, *clusterPlugin)
-			return plugin, err
+			return plugin, "", errors.New(
+				"no KongPlugin or KongClusterPlugin was found")
 		}
+		if err != nil {
+			return plugin, "", err
+		}
+		if clusterPlugin.PluginName == "" {
+			return plugin, "", fmt.Errorf("invalid empty 'plugin' property")
+		}
+		plugin.Plugin, err = kongPluginFromK8SClusterPlugin(s, *clusterPlugin)
+		plugin.configFromSource = clusterPlugin.ConfigFrom != nil
+		return plugin, "", err
 	}
 	// ignore plugins with no name
 	if k8sPlugin.PluginName == "" {
-		return plugin, fmt.Errorf("invalid empty 'plugin' property")
+		return plugin, k8sPlugin.Namespace, fmt.Errorf("invalid empty 'plugin' property")
 	}
 
-	plugin, err = kongPluginFromK8SPlugin(s, *k8sPlugin)
-	return plugin, err
+	plugin.Plugin, err = kongPluginFromK8SPlugin(s, *k8sPlugin)
+	plugin.configFromSource = k8sPlugin.ConfigFrom != nil
+	return plugin, k8sPlugin.Namespace, err
 }
 
 func kongPluginFromK8SClusterPlugin(
@@ -128,9 +293,7 @@ func kongPluginFromK8SClusterPlugin(
 	}
 	if k8sPlugin.ConfigFrom != nil {
 		var err error
-		config, err = namespacedSecretToConfiguration(
-			s,
-			(*k8sPlugin.ConfigFrom).SecretValue)
+		config, err = NamespacedConfigSourceToConfiguration(s, s, *k8sPlugin.ConfigFrom)
 		if err != nil {
 			return kong.Plugin{},
 				fmt.Errorf("error parsing config for KongClusterPlugin %v: %w",
@@ -181,8 +344,7 @@ func kongPluginFromK8SPlugin(
 	}
 	if k8sPlugin.ConfigFrom != nil {
 		var err error
-		config, err = SecretToConfiguration(s,
-			(*k8sPlugin.ConfigFrom).SecretValue, k8sPlugin.Namespace)
+		config, err = ConfigSourceToConfiguration(s, s, *k8sPlugin.ConfigFrom, k8sPlugin.Namespace)
 		if err != nil {
 			return kong.Plugin{},
 				fmt.Errorf("error parsing config for KongPlugin '%v/%v': %w",
@@ -213,22 +375,101 @@ func RawConfigToConfiguration(config apiextensionsv1.JSON) (kong.Configuration,
 	return kongConfig, nil
 }
 
-func namespacedSecretToConfiguration(
-	s store.Storer,
-	reference configurationv1.NamespacedSecretValueFromSource) (
-	kong.Configuration, error,
-) {
-	bareReference := configurationv1.SecretValueFromSource{
-		Secret: reference.Secret,
-		Key:    reference.Key,
+// ConfigSourceToConfiguration reads a plugin configuration from the Secret and the ConfigMap
+// of source, in namespace. When both are set, their configurations are merged and an error is
+// returned if they set the same keys. When none is set, the Secret is looked up, and not found.
+func ConfigSourceToConfiguration(
+	secrets SecretGetter,
+	configMaps ConfigMapGetter,
+	source configurationv1.ConfigSource,
+	namespace string,
+) (kong.Configuration, error) {
+	var secretConfig, configMapConfig kong.Configuration
+	hasConfigMap := source.ConfigMapValue != configurationv1.ConfigMapValueFromSource{}
+	if !hasConfigMap || source.SecretValue != (configurationv1.SecretValueFromSource{}) {
+		var err error
+		secretConfig, err = SecretToConfiguration(secrets, source.SecretValue, namespace)
+		if err != nil {
+			return kong.Configuration{}, err
+		}
+	}
+	if hasConfigMap {
+		var err error
+		configMapConfig, err = ConfigMapToConfiguration(configMaps, source.ConfigMapValue, namespace)
+		if err != nil {
+			return kong.Configuration{}, err
+		}
 	}
-	return SecretToConfiguration(s, bareReference, reference.Namespace)
+	return mergeConfigSources(secretConfig, configMapConfig, hasConfigMap)
+}
+
+// NamespacedConfigSourceToConfiguration works like ConfigSourceToConfiguration for the
+// sources of KongClusterPlugins, which set the namespaces of the Secret and the ConfigMap.
+func NamespacedConfigSourceToConfiguration(
+	secrets SecretGetter,
+	configMaps ConfigMapGetter,
+	source configurationv1.NamespacedConfigSource,
+) (kong.Configuration, error) {
+	var secretConfig, configMapConfig kong.Configuration
+	hasConfigMap := source.ConfigMapValue != configurationv1.NamespacedConfigMapValueFromSource{}
+	if !hasConfigMap || source.SecretValue != (configurationv1.NamespacedSecretValueFromSource{}) {
+		var err error
+		secretConfig, err = SecretToConfiguration(secrets, configurationv1.SecretValueFromSource{
+			Secret: source.SecretValue.Secret,
+			Key:    source.SecretValue.Key,
+		}, source.SecretValue.Namespace)
+		if err != nil {
+			return kong.Configuration{}, err
+		}
+	}
+	if hasConfigMap {
+		var err error
+		configMapConfig, err = ConfigMapToConfiguration(configMaps, configurationv1.ConfigMapValueFromSource{
+			ConfigMap: source.ConfigMapValue.ConfigMap,
+			Key:       source.ConfigMapValue.Key,
+		}, source.ConfigMapValue.Namespace)
+		if err != nil {
+			return kong.Configuration{}, err
+		}
+	}
+	return mergeConfigSources(secretConfig, configMapConfig, hasConfigMap)
+}
+
+// mergeConfigSources merges the configurations read from a Secret and a ConfigMap. Each key
+// may only be set by one of them.
+func mergeConfigSources(secretConfig, configMapConfig kong.Configuration, hasConfigMap bool) (kong.Configuration, error) {
+	if !hasConfigMap {
+		return secretConfig, nil
+	}
+	var conflicts []string
+	config := kong.Configuration{}
+	for key, value := range secretConfig {
+		config[key] = value
+	}
+	for key, value := range configMapConfig {
+		if _, ok := config[key]; ok {
+			conflicts = append(conflicts, key)
+			continue
+		}
+		config[key] = value
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return kong.Configuration{}, fmt.Errorf("keys %s are set by both the Secret and the ConfigMap",
+			strings.Join(conflicts, ", "))
+	}
+	return config, nil
 }
 
 type SecretGetter interface {
 	GetSecret(namespace, name string) (*corev1.Secret, error)
 }
 
+// ConfigMapGetter is the counterpart of SecretGetter for ConfigMaps.
+type ConfigMapGetter interface {
+	GetConfigMap(namespace, name string) (*corev1.ConfigMap, error)
+}
+
 func SecretToConfiguration(
 	s SecretGetter,
 	reference configurationv1.SecretValueFromSource, namespace string) (
@@ -258,6 +499,36 @@ func SecretToConfiguration(
 	return config, nil
 }
 
+// ConfigMapToConfiguration reads a plugin configuration, in JSON or YAML, from a ConfigMap key.
+func ConfigMapToConfiguration(
+	s ConfigMapGetter,
+	reference configurationv1.ConfigMapValueFromSource, namespace string) (
+	kong.Configuration, error,
+) {
+	configMap, err := s.GetConfigMap(namespace, reference.ConfigMap)
+	if err != nil {
+		return kong.Configuration{}, fmt.Errorf(
+			"error fetching plugin configuration ConfigMap '%v/%v': %w",
+			namespace, reference.ConfigMap, err)
+	}
+	configMapVal, ok := configMap.Data[reference.Key]
+	if !ok {
+		return kong.Configuration{},
+			fmt.Errorf("no key '%v' in ConfigMap '%v/%v'",
+				reference.Key, namespace, reference.ConfigMap)
+	}
+	var config kong.Configuration
+	if err := json.Unmarshal([]byte(configMapVal), &config); err != nil {
+		if err := yaml.Unmarshal([]byte(configMapVal), &config); err != nil {
+			return kong.Configuration{},
+				fmt.Errorf("key '%v' in ConfigMap '%v/%v' contains neither "+
+					"valid JSON nor valid YAML",
+					reference.Key, namespace, reference.ConfigMap)
+		}
+	}
+	return config, nil
+}
+
 // PrettyPrintServiceList makes a clean printable list of a map of Kubernetes
 // services for the purpose of logging (errors, info, e.t.c.).
 func PrettyPrintServiceList(services map[string]*corev1.Service) string {
@@ -1,10 +1,13 @@
 package kongstate
 
 import (
+	"bytes"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -33,6 +36,18 @@ func TestKongPluginFromK8SClusterPlugin(t *testing.T) {
 				},
 			},
 		},
+		ConfigMaps: []*corev1.ConfigMap{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "conf-configmap",
+					Namespace: "default",
+				},
+				Data: map[string]string{
+					"correlation-id-config": "generator: uuid\necho_downstream: true\n",
+					"conflicting-config":    `{"header_name": "bar"}`,
+				},
+			},
+		},
 	})
 	type args struct {
 		plugin configurationv1.KongClusterPlugin
@@ -87,6 +102,85 @@ func TestKongPluginFromK8SClusterPlugin(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "ConfigMap configuration",
+			args: args{
+				plugin: configurationv1.KongClusterPlugin{
+					Protocols:  []configurationv1.KongProtocol{"http"},
+					PluginName: "correlation-id",
+					ConfigFrom: &configurationv1.NamespacedConfigSource{
+						ConfigMapValue: configurationv1.NamespacedConfigMapValueFromSource{
+							Key:       "correlation-id-config",
+							ConfigMap: "conf-configmap",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+			want: kong.Plugin{
+				Name: kong.String("correlation-id"),
+				Config: kong.Configuration{
+					"generator":       "uuid",
+					"echo_downstream": true,
+				},
+				Protocols: kong.StringSlice("http"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Secret and ConfigMap configuration",
+			args: args{
+				plugin: configurationv1.KongClusterPlugin{
+					Protocols:  []configurationv1.KongProtocol{"http"},
+					PluginName: "correlation-id",
+					ConfigFrom: &configurationv1.NamespacedConfigSource{
+						SecretValue: configurationv1.NamespacedSecretValueFromSource{
+							Key:       "correlation-id-config",
+							Secret:    "conf-secret",
+							Namespace: "default",
+						},
+						ConfigMapValue: configurationv1.NamespacedConfigMapValueFromSource{
+							Key:       "correlation-id-config",
+							ConfigMap: "conf-configmap",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+			want: kong.Plugin{
+				Name: kong.String("correlation-id"),
+				Config: kong.Configuration{
+					"header_name":     "foo",
+					"generator":       "uuid",
+					"echo_downstream": true,
+				},
+				Protocols: kong.StringSlice("http"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Secret and ConfigMap setting the same key",
+			args: args{
+				plugin: configurationv1.KongClusterPlugin{
+					Protocols:  []configurationv1.KongProtocol{"http"},
+					PluginName: "correlation-id",
+					ConfigFrom: &configurationv1.NamespacedConfigSource{
+						SecretValue: configurationv1.NamespacedSecretValueFromSource{
+							Key:       "correlation-id-config",
+							Secret:    "conf-secret",
+							Namespace: "default",
+						},
+						ConfigMapValue: configurationv1.NamespacedConfigMapValueFromSource{
+							Key:       "conflicting-config",
+							ConfigMap: "conf-configmap",
+							Namespace: "default",
+						},
+					},
+				},
+			},
+			want:    kong.Plugin{},
+			wantErr: true,
+		},
 		{
 			name: "missing secret configuration",
 			args: args{
@@ -167,6 +261,18 @@ func TestKongPluginFromK8SPlugin(t *testing.T) {
 				},
 			},
 		},
+		ConfigMaps: []*corev1.ConfigMap{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "conf-configmap",
+					Namespace: "default",
+				},
+				Data: map[string]string{
+					"correlation-id-config": "generator: uuid\necho_downstream: true\n",
+					"conflicting-config":    `{"header_name": "bar"}`,
+				},
+			},
+		},
 	})
 	type args struct {
 		plugin configurationv1.KongPlugin
@@ -224,6 +330,113 @@ func TestKongPluginFromK8SPlugin(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "ConfigMap configuration",
+			args: args{
+				plugin: configurationv1.KongPlugin{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Protocols:  []configurationv1.KongProtocol{"http"},
+					PluginName: "correlation-id",
+					ConfigFrom: &configurationv1.ConfigSource{
+						ConfigMapValue: configurationv1.ConfigMapValueFromSource{
+							Key:       "correlation-id-config",
+							ConfigMap: "conf-configmap",
+						},
+					},
+				},
+			},
+			want: kong.Plugin{
+				Name: kong.String("correlation-id"),
+				Config: kong.Configuration{
+					"generator":       "uuid",
+					"echo_downstream": true,
+				},
+				Protocols: kong.StringSlice("http"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Secret and ConfigMap configuration",
+			args: args{
+				plugin: configurationv1.KongPlugin{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Protocols:  []configurationv1.KongProtocol{"http"},
+					PluginName: "correlation-id",
+					ConfigFrom: &configurationv1.ConfigSource{
+						SecretValue: configurationv1.SecretValueFromSource{
+							Key:    "correlation-id-config",
+							Secret: "conf-secret",
+						},
+						ConfigMapValue: configurationv1.ConfigMapValueFromSource{
+							Key:       "correlation-id-config",
+							ConfigMap: "conf-configmap",
+						},
+					},
+				},
+			},
+			want: kong.Plugin{
+				Name: kong.String("correlation-id"),
+				Config: kong.Configuration{
+					"header_name":     "foo",
+					"generator":       "uuid",
+					"echo_downstream": true,
+				},
+				Protocols: kong.StringSlice("http"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Secret and ConfigMap setting the same key",
+			args: args{
+				plugin: configurationv1.KongPlugin{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Protocols:  []configurationv1.KongProtocol{"http"},
+					PluginName: "correlation-id",
+					ConfigFrom: &configurationv1.ConfigSource{
+						SecretValue: configurationv1.SecretValueFromSource{
+							Key:    "correlation-id-config",
+							Secret: "conf-secret",
+						},
+						ConfigMapValue: configurationv1.ConfigMapValueFromSource{
+							Key:       "conflicting-config",
+							ConfigMap: "conf-configmap",
+						},
+					},
+				},
+			},
+			want:    kong.Plugin{},
+			wantErr: true,
+		},
+		{
+			name: "missing ConfigMap configuration",
+			args: args{
+				plugin: configurationv1.KongPlugin{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Protocols:  []configurationv1.KongProtocol{"http"},
+					PluginName: "correlation-id",
+					ConfigFrom: &configurationv1.ConfigSource{
+						ConfigMapValue: configurationv1.ConfigMapValueFromSource{
+							Key:       "correlation-id-config",
+							ConfigMap: "missing",
+						},
+					},
+				},
+			},
+			want:    kong.Plugin{},
+			wantErr: true,
+		},
 		{
 			name: "missing secret configuration",
 			args: args{
@@ -292,6 +505,26 @@ func TestKongPluginFromK8SPlugin(t *testing.T) {
 	}
 }
 
+func TestConfigSourceToConfiguration_Conflicts(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{
+		Secrets: []*corev1.Secret{{
+			ObjectMeta: metav1.ObjectMeta{Name: "conf-secret", Namespace: "default"},
+			Data:       map[string][]byte{"config": []byte(`{"minute": 10, "policy": "redis"}`)},
+		}},
+		ConfigMaps: []*corev1.ConfigMap{{
+			ObjectMeta: metav1.ObjectMeta{Name: "conf-configmap", Namespace: "default"},
+			Data:       map[string]string{"config": `{"policy": "local", "minute": 20, "hour": 100}`},
+		}},
+	})
+	require.NoError(t, err)
+
+	_, err = ConfigSourceToConfiguration(s, s, configurationv1.ConfigSource{
+		SecretValue:    configurationv1.SecretValueFromSource{Secret: "conf-secret", Key: "config"},
+		ConfigMapValue: configurationv1.ConfigMapValueFromSource{ConfigMap: "conf-configmap", Key: "config"},
+	}, "default")
+	assert.EqualError(t, err, "keys minute, policy are set by both the Secret and the ConfigMap")
+}
+
 func Test_getKongIngressForServices(t *testing.T) {
 	for _, tt := range []struct {
 		name                string
@@ -629,3 +862,86 @@ func Test_prettyPrintServiceList(t *testing.T) {
 		})
 	}
 }
+
+func Test_getMergedKongIngressForServices(t *testing.T) {
+	service := func(name, kongIngressName string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: corev1.NamespaceDefault,
+				Annotations: map[string]string{
+					annotations.AnnotationPrefix + annotations.ConfigurationKey: kongIngressName,
+				},
+			},
+		}
+	}
+	kongIngress := func(name string, proxy *configurationv1.KongIngressService) *configurationv1.KongIngress {
+		return &configurationv1.KongIngress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: corev1.NamespaceDefault,
+			},
+			Proxy: proxy,
+		}
+	}
+	storer, err := store.NewFakeStore(store.FakeObjects{
+		KongIngresses: []*configurationv1.KongIngress{
+			kongIngress("timeout-100", &configurationv1.KongIngressService{ConnectTimeout: kong.Int(100)}),
+			kongIngress("timeout-200", &configurationv1.KongIngressService{ConnectTimeout: kong.Int(200)}),
+			kongIngress("retries-5", &configurationv1.KongIngressService{Retries: kong.Int(5)}),
+		},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name          string
+		services      map[string]*corev1.Service
+		wantProxy     *configurationv1.KongIngressService
+		wantConflicts int
+	}{
+		{
+			name: "conflicting values are resolved in favor of the first service",
+			services: map[string]*corev1.Service{
+				"b": service("b", "timeout-200"),
+				"a": service("a", "timeout-100"),
+			},
+			wantProxy:     &configurationv1.KongIngressService{ConnectTimeout: kong.Int(100)},
+			wantConflicts: 1,
+		},
+		{
+			name: "different fields are merged without conflicts",
+			services: map[string]*corev1.Service{
+				"a": service("a", "timeout-100"),
+				"b": service("b", "retries-5"),
+			},
+			wantProxy:     &configurationv1.KongIngressService{ConnectTimeout: kong.Int(100), Retries: kong.Int(5)},
+			wantConflicts: 0,
+		},
+		{
+			name: "services sharing a KongIngress don't conflict",
+			services: map[string]*corev1.Service{
+				"a": service("a", "timeout-200"),
+				"b": service("b", "timeout-200"),
+			},
+			wantProxy:     &configurationv1.KongIngressService{ConnectTimeout: kong.Int(200)},
+			wantConflicts: 0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			log := logrus.New()
+			log.SetOutput(buf)
+
+			got, err := getMergedKongIngressForServices(log, storer, tt.services)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantProxy, got.Proxy)
+
+			out := buf.String()
+			assert.Equal(t, tt.wantConflicts, strings.Count(out, "conflicting KongIngress connect_timeout settings"))
+			if tt.wantConflicts > 0 {
+				assert.Contains(t, out, "applied_service_name=a")
+				assert.Contains(t, out, "conflicting_service_name=b")
+			}
+		})
+	}
+}
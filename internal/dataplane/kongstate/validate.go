@@ -0,0 +1,130 @@
+package kongstate
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// ValidationError lists the problems found by KongState.Validate.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid Kong state: %s", strings.Join(e.Problems, "; "))
+}
+
+// Validate runs structural checks on the state before it's turned into Kong configuration:
+// services must have a name, consumer usernames must be unique, plugins must be attached to
+// services, routes, consumers and consumer groups of the state, and certificates and their
+// keys must be valid PEM. All problems found are reported at once in a *ValidationError.
+func (ks *KongState) Validate() error {
+	var problems []string
+	problems = append(problems, ks.validateServices()...)
+	problems = append(problems, ks.validateConsumers()...)
+	problems = append(problems, ks.validatePluginScopes()...)
+	problems = append(problems, ks.validateCertificates()...)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (ks *KongState) validateServices() (problems []string) {
+	for i, s := range ks.Services {
+		if stringValue(s.Name) == "" {
+			problems = append(problems, fmt.Sprintf("service #%d in namespace %q has no name", i, s.Namespace))
+		}
+	}
+	return
+}
+
+func (ks *KongState) validateConsumers() (problems []string) {
+	seen := make(map[string]struct{}, len(ks.Consumers))
+	for _, c := range ks.Consumers {
+		username := stringValue(c.Username)
+		if username == "" {
+			continue
+		}
+		if _, ok := seen[username]; ok {
+			problems = append(problems, fmt.Sprintf("consumer username %q is used more than once", username))
+			continue
+		}
+		seen[username] = struct{}{}
+	}
+	return
+}
+
+func (ks *KongState) validatePluginScopes() (problems []string) {
+	services := map[string]struct{}{}
+	routes := map[string]struct{}{}
+	for _, s := range ks.Services {
+		addKeys(services, s.ID, s.Name)
+		for _, r := range s.Routes {
+			addKeys(routes, r.ID, r.Name)
+		}
+	}
+	consumers := map[string]struct{}{}
+	for _, c := range ks.Consumers {
+		addKeys(consumers, c.ID, c.Username, c.CustomID)
+	}
+	consumerGroups := map[string]struct{}{}
+	for _, cg := range ks.ConsumerGroups {
+		addKeys(consumerGroups, cg.Name)
+	}
+
+	for _, p := range ks.Plugins {
+		if p.Service != nil {
+			if _, ok := services[stringValue(p.Service.ID)]; !ok {
+				problems = append(problems, fmt.Sprintf("plugin %q is attached to unknown service %q",
+					stringValue(p.Name), stringValue(p.Service.ID)))
+			}
+		}
+		if p.Route != nil {
+			if _, ok := routes[stringValue(p.Route.ID)]; !ok {
+				problems = append(problems, fmt.Sprintf("plugin %q is attached to unknown route %q",
+					stringValue(p.Name), stringValue(p.Route.ID)))
+			}
+		}
+		if p.Consumer != nil {
+			if _, ok := consumers[stringValue(p.Consumer.ID)]; !ok {
+				problems = append(problems, fmt.Sprintf("plugin %q is attached to unknown consumer %q",
+					stringValue(p.Name), stringValue(p.Consumer.ID)))
+			}
+		}
+		if p.ConsumerGroup != nil {
+			if _, ok := consumerGroups[*p.ConsumerGroup]; !ok {
+				problems = append(problems, fmt.Sprintf("plugin %q is attached to unknown consumer group %q",
+					stringValue(p.Name), *p.ConsumerGroup))
+			}
+		}
+	}
+	return
+}
+
+// addKeys adds the non-empty values to keys.
+func addKeys(keys map[string]struct{}, values ...*string) {
+	for _, v := range values {
+		if v := stringValue(v); v != "" {
+			keys[v] = struct{}{}
+		}
+	}
+}
+
+func (ks *KongState) validateCertificates() (problems []string) {
+	for _, c := range ks.Certificates {
+		id := stringValue(c.ID)
+		block, _ := pem.Decode([]byte(stringValue(c.Cert)))
+		if block == nil {
+			problems = append(problems, fmt.Sprintf("certificate %q has no PEM encoded certificate", id))
+		} else if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			problems = append(problems, fmt.Sprintf("certificate %q has an invalid certificate: %v", id, err))
+		}
+		if block, _ := pem.Decode([]byte(stringValue(c.Key))); block == nil {
+			problems = append(problems, fmt.Sprintf("certificate %q has no PEM encoded key", id))
+		}
+	}
+	return
+}
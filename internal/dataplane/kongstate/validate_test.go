@@ -0,0 +1,113 @@
+package kongstate
+
+import (
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKongState_Validate(t *testing.T) {
+	cert, key := selfSignedKeyPair(t, "example.com")
+	validState := func() KongState {
+		return KongState{
+			Services: []Service{{
+				Service: kong.Service{Name: kong.String("foo-service")},
+				Routes:  []Route{{Route: kong.Route{Name: kong.String("foo-route")}}},
+			}},
+			Consumers: []Consumer{
+				{Consumer: kong.Consumer{Username: kong.String("alice")}},
+				{Consumer: kong.Consumer{CustomID: kong.String("bob-id")}},
+			},
+			Plugins: []Plugin{
+				{Plugin: kong.Plugin{Name: kong.String("cors"), Service: &kong.Service{ID: kong.String("foo-service")}}},
+				{Plugin: kong.Plugin{Name: kong.String("key-auth"), Route: &kong.Route{ID: kong.String("foo-route")}}},
+				{Plugin: kong.Plugin{Name: kong.String("acl"), Consumer: &kong.Consumer{ID: kong.String("bob-id")}}},
+				{Plugin: kong.Plugin{Name: kong.String("prometheus")}},
+			},
+			Certificates: []Certificate{{Certificate: kong.Certificate{
+				ID:   kong.String("cert"),
+				Cert: kong.String(string(cert)),
+				Key:  kong.String(string(key)),
+			}}},
+		}
+	}
+
+	t.Run("valid state", func(t *testing.T) {
+		state := validState()
+		assert.NoError(t, state.Validate())
+	})
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*KongState)
+		want   []string
+	}{
+		{
+			name: "service without a name",
+			modify: func(ks *KongState) {
+				ks.Services = append(ks.Services, Service{Namespace: "default"})
+			},
+			want: []string{`service #1 in namespace "default" has no name`},
+		},
+		{
+			name: "duplicate consumer usernames",
+			modify: func(ks *KongState) {
+				ks.Consumers = append(ks.Consumers, Consumer{Consumer: kong.Consumer{Username: kong.String("alice")}})
+			},
+			want: []string{`consumer username "alice" is used more than once`},
+		},
+		{
+			name: "plugins attached to unknown entities",
+			modify: func(ks *KongState) {
+				ks.Plugins = append(ks.Plugins,
+					Plugin{Plugin: kong.Plugin{Name: kong.String("cors"), Service: &kong.Service{ID: kong.String("bar-service")}}},
+					Plugin{Plugin: kong.Plugin{Name: kong.String("cors"), Route: &kong.Route{ID: kong.String("bar-route")}}},
+					Plugin{Plugin: kong.Plugin{Name: kong.String("acl"), Consumer: &kong.Consumer{ID: kong.String("carol")}}},
+				)
+			},
+			want: []string{
+				`plugin "cors" is attached to unknown service "bar-service"`,
+				`plugin "cors" is attached to unknown route "bar-route"`,
+				`plugin "acl" is attached to unknown consumer "carol"`,
+			},
+		},
+		{
+			name: "certificates with invalid PEM",
+			modify: func(ks *KongState) {
+				ks.Certificates = append(ks.Certificates, Certificate{Certificate: kong.Certificate{
+					ID:   kong.String("broken"),
+					Cert: kong.String("not a certificate"),
+					Key:  kong.String("not a key"),
+				}})
+			},
+			want: []string{
+				`certificate "broken" has no PEM encoded certificate`,
+				`certificate "broken" has no PEM encoded key`,
+			},
+		},
+		{
+			name: "all problems are reported",
+			modify: func(ks *KongState) {
+				ks.Services[0].Name = nil
+				ks.Consumers[1].Username = kong.String("alice")
+			},
+			want: []string{
+				`service #0 in namespace "" has no name`,
+				`consumer username "alice" is used more than once`,
+				`plugin "cors" is attached to unknown service "foo-service"`,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			state := validState()
+			tt.modify(&state)
+			err := state.Validate()
+			require.Error(t, err)
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.want, validationErr.Problems)
+		})
+	}
+}
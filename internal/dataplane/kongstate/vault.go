@@ -0,0 +1,104 @@
+package kongstate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+)
+
+// Vault represents a vault in Kong, which configuration values can reference secrets from
+// with {vault://<prefix>/<resource>} references. It's modeled here as the go-kong version
+// in use has no vault entity.
+type Vault struct {
+	// Name is the name of the vault implementation, e.g. env or aws.
+	Name string
+	// Prefix is the prefix used by references to the vault.
+	Prefix string
+}
+
+// builtinVaultPrefixes holds the vaults which are available in Kong without being configured.
+var builtinVaultPrefixes = []string{"env"}
+
+// vaultReferencePattern matches Kong vault references, capturing the vault prefix.
+var vaultReferencePattern = regexp.MustCompile(`^\{vault://([^/}]+)/[^}]*\}$`)
+
+// UnresolvedVaultReference is a vault reference found in the configuration of a plugin
+// which doesn't match any vault of the state.
+type UnresolvedVaultReference struct {
+	PluginName string
+	Field      string
+	Reference  string
+}
+
+// CheckVaultReferences scans the configuration of plugins for vault references and logs
+// a warning for every reference whose vault prefix matches neither the prefix or name of
+// a vault of the state nor a vault built into Kong. It returns the unresolved references.
+func (ks *KongState) CheckVaultReferences(log logrus.FieldLogger) []UnresolvedVaultReference {
+	known := make(map[string]struct{}, len(ks.Vaults)+len(builtinVaultPrefixes))
+	for _, prefix := range builtinVaultPrefixes {
+		known[prefix] = struct{}{}
+	}
+	for _, v := range ks.Vaults {
+		for _, prefix := range []string{v.Name, v.Prefix} {
+			if prefix != "" {
+				known[prefix] = struct{}{}
+			}
+		}
+	}
+
+	var unresolved []UnresolvedVaultReference
+	for _, plugin := range ks.Plugins {
+		pluginName := stringValue(plugin.Name)
+		walkConfigStrings("config", plugin.Config, func(field, value string) {
+			match := vaultReferencePattern.FindStringSubmatch(value)
+			if match == nil {
+				return
+			}
+			if _, ok := known[match[1]]; ok {
+				return
+			}
+			log.WithFields(logrus.Fields{
+				"plugin_name": pluginName,
+				"field":       field,
+				"vault":       match[1],
+			}).Warn("plugin configuration references a vault which is not configured")
+			unresolved = append(unresolved, UnresolvedVaultReference{
+				PluginName: pluginName,
+				Field:      field,
+				Reference:  value,
+			})
+		})
+	}
+	return unresolved
+}
+
+// walkConfigStrings calls fn with the path and value of every string in a plugin
+// configuration, at any nesting level. Map keys are visited in sorted order.
+func walkConfigStrings(path string, v interface{}, fn func(path, value string)) {
+	switch v := v.(type) {
+	case kong.Configuration:
+		walkConfigStrings(path, map[string]interface{}(v), fn)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkConfigStrings(path+"."+k, v[k], fn)
+		}
+	case []interface{}:
+		for i := range v {
+			walkConfigStrings(fmt.Sprintf("%s[%d]", path, i), v[i], fn)
+		}
+	case []string:
+		for i := range v {
+			fn(fmt.Sprintf("%s[%d]", path, i), v[i])
+		}
+	case string:
+		fn(path, v)
+	}
+}
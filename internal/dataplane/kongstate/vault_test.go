@@ -0,0 +1,48 @@
+package kongstate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKongState_CheckVaultReferences(t *testing.T) {
+	state := KongState{
+		Vaults: []Vault{{Name: "aws", Prefix: "aws-eu"}},
+		Plugins: []Plugin{
+			{Plugin: kong.Plugin{
+				Name: kong.String("openid-connect"),
+				Config: kong.Configuration{
+					"client_secret":  []interface{}{"{vault://aws-eu/oidc/client-secret}"},
+					"session_secret": "{vault://env/session-secret}",
+					"redis": map[string]interface{}{
+						"password": "{vault://hcv/redis/password}",
+						"host":     "redis.example.com",
+					},
+				},
+			}},
+			{Plugin: kong.Plugin{
+				Name:   kong.String("key-auth"),
+				Config: kong.Configuration{"key_names": []interface{}{"apikey"}},
+			}},
+		},
+	}
+
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+
+	assert.Equal(t, []UnresolvedVaultReference{{
+		PluginName: "openid-connect",
+		Field:      "config.redis.password",
+		Reference:  "{vault://hcv/redis/password}",
+	}}, state.CheckVaultReferences(log))
+	assert.Contains(t, logs.String(), "plugin configuration references a vault which is not configured")
+	assert.Contains(t, logs.String(), "vault=hcv")
+
+	state.Vaults = append(state.Vaults, Vault{Name: "hcv"})
+	assert.Empty(t, state.CheckVaultReferences(log))
+}
@@ -0,0 +1,37 @@
+package kongstate
+
+import "sync"
+
+// WarnedSet keeps track of warnings which have already been logged, so that warnings
+// about the same condition are not repeated on every reconciliation. A nil WarnedSet
+// doesn't deduplicate anything.
+type WarnedSet struct {
+	lock   sync.Mutex
+	warned map[string]string
+}
+
+// NewWarnedSet creates an empty WarnedSet.
+func NewWarnedSet() *WarnedSet {
+	return &WarnedSet{warned: make(map[string]string)}
+}
+
+// ShouldWarn reports whether the warning identified by key should be logged for the given
+// subject. It returns true the first time it's called for a key, and afterwards only if
+// the subject changed since the last warning. An empty subject clears the key, so the
+// warning is emitted again if the condition reappears.
+func (w *WarnedSet) ShouldWarn(key, subject string) bool {
+	if w == nil {
+		return subject != ""
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if subject == "" {
+		delete(w.warned, key)
+		return false
+	}
+	if last, ok := w.warned[key]; ok && last == subject {
+		return false
+	}
+	w.warned[key] = subject
+	return true
+}
@@ -69,20 +69,23 @@ func (ir *ingressRules) populateServices(log logrus.FieldLogger, s store.Storer)
 			secretName := annotations.ExtractClientCertificate(k8sService.Annotations)
 			if secretName != "" {
 				secret, err := s.GetSecret(k8sService.Namespace, secretName)
-				secretKey := k8sService.Namespace + "/" + secretName
+				if err != nil {
+					// without the Secret, the Kong service is left without a client certificate
+					log.WithFields(logrus.Fields{
+						"secret_name":      secretName,
+						"secret_namespace": k8sService.Namespace,
+						"service_name":     k8sService.Name,
+					}).WithError(err).Error("failed to fetch the client certificate Secret of the Service, " +
+						"the Kong service will not present a client certificate to the upstream")
+					continue
+				}
 				// ensure that the cert is loaded into Kong
+				secretKey := k8sService.Namespace + "/" + secretName
 				if _, ok := ir.SecretNameToSNIs[secretKey]; !ok {
 					ir.SecretNameToSNIs[secretKey] = []string{}
 				}
-				if err == nil {
-					service.ClientCertificate = &kong.Certificate{
-						ID: kong.String(string(secret.UID)),
-					}
-				} else {
-					log.WithFields(logrus.Fields{
-						"secret_name":      secretName,
-						"secret_namespace": k8sService.Namespace,
-					}).Errorf("failed to fetch secret: %v", err)
+				service.ClientCertificate = &kong.Certificate{
+					ID: kong.String(string(secret.UID)),
 				}
 			}
 		}
@@ -1,21 +1,24 @@
 package parser
 
 import (
-	"bytes"
-	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"reflect"
-	"sort"
-	"strings"
+	"strconv"
+	"time"
 
+	"github.com/blang/semver/v4"
 	"github.com/kong/go-kong/kong"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	netv1beta1 "k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	knative "knative.dev/networking/pkg/apis/networking/v1alpha1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
@@ -42,6 +45,48 @@ type Parser struct {
 
 	featureEnabledReportConfiguredKubernetesObjects bool
 	featureEnabledCombinedServiceRoutes             bool
+
+	credentialSchemas          kongstate.CredentialSchemaGetter
+	eventRecorder              record.EventRecorder
+	credentialMetrics          kongstate.CredentialMetrics
+	consumerNamespaces         *kongstate.NamespaceFilter
+	consumerSelector           labels.Selector
+	credentialTypeKey          string
+	dropConflictingCredentials bool
+	strictConsumerIdentifiers  bool
+	allowedCredTypes           sets.String
+	indexCredentialSecrets     bool
+	warned                     *kongstate.WarnedSet
+	pluginSchemas              kongstate.PluginSchemaGetter
+	kongVersion                semver.Version
+
+	overridesConcurrency int
+
+	storeRetryPolicy kongstate.StoreRetryPolicy
+
+	validateCertificateSNIs bool
+	strictCertificateSNIs   bool
+
+	certificateMetrics                kongstate.CertificateMetrics
+	certificateExpiryWarningThreshold time.Duration
+
+	pluginNamespaceIsolation bool
+	globalPluginSelector     labels.Selector
+	deterministicPluginIDs   bool
+
+	detectPluginOverlaps bool
+	strictPluginOverlaps bool
+
+	maxPluginConfigSize    int
+	strictPluginConfigSize bool
+
+	instanceTag string
+
+	stateTransformers []namedStateTransformer
+
+	// fillErrors holds the failures of the last Build which left the configuration
+	// incomplete without failing the whole translation.
+	fillErrors []error
 }
 
 // NewParser produces a new Parser object provided a logging mechanism
@@ -51,8 +96,9 @@ func NewParser(
 	storer store.Storer,
 ) *Parser {
 	return &Parser{
-		logger: logger,
-		storer: storer,
+		logger:                            logger,
+		storer:                            storer,
+		certificateExpiryWarningThreshold: kongstate.DefaultCertificateExpiryWarningThreshold,
 	}
 }
 
@@ -62,8 +108,11 @@ func NewParser(
 
 // Build creates a Kong configuration from Ingress and Custom resources
 // defined in Kuberentes.
-// It throws an error if there is an error returned from client-go.
+// It throws an error if there is an error returned from client-go. Failures which
+// only leave the configuration incomplete are reported by FillErrors instead.
 func (p *Parser) Build() (*kongstate.KongState, error) {
+	p.fillErrors = nil
+
 	// parse and merge all rules together from all Kubernetes API sources
 	ingressRules := mergeIngressRules(
 		p.ingressRulesFromIngressV1beta1(),
@@ -83,7 +132,7 @@ func (p *Parser) Build() (*kongstate.KongState, error) {
 	}
 
 	// add the routes and services to the state
-	var result kongstate.KongState
+	result := kongstate.KongState{Version: p.kongVersion}
 	for _, service := range ingressRules.ServiceNameToServices {
 		result.Services = append(result.Services, service)
 	}
@@ -92,29 +141,85 @@ func (p *Parser) Build() (*kongstate.KongState, error) {
 	result.Upstreams = getUpstreams(p.logger, p.storer, ingressRules.ServiceNameToServices)
 
 	// merge KongIngress with Routes, Services and Upstream
-	result.FillOverrides(p.logger, p.storer)
+	if err := result.FillOverridesWithConcurrency(p.logger, p.storer, p.overridesConcurrency); err != nil {
+		p.fillErrors = append(p.fillErrors, err)
+	}
+
+	// populate CA certificates in Kong, before the credentials referencing them
+	caCertSecrets, err := p.storer.ListCACerts()
+	if err != nil {
+		return nil, err
+	}
+	result.CACertificates = toCACerts(p.logger, caCertSecrets)
+
+	// Secrets and plugins looked up by the Fill methods below may be retried
+	fillStorer := p.storer
+	if p.storeRetryPolicy.Enabled() {
+		fillStorer = kongstate.NewRetryingStore(p.storer, p.storeRetryPolicy)
+	}
 
 	// generate consumers and credentials
-	result.FillConsumersAndCredentials(p.logger, p.storer)
+	credentialStorer := fillStorer
+	if p.indexCredentialSecrets {
+		credentialStorer = kongstate.NewSecretIndex(fillStorer)
+	}
+	if err := result.FillConsumersAndCredentials(
+		p.logger,
+		credentialStorer,
+		p.credentialSchemas,
+		p.eventRecorder,
+		p.credentialMetrics,
+		kongstate.ConsumerFillOptions{
+			Namespaces:                 p.consumerNamespaces,
+			Selector:                   p.consumerSelector,
+			CredTypeKey:                p.credentialTypeKey,
+			DropConflictingCredentials: p.dropConflictingCredentials,
+			StrictConsumerIdentifiers:  p.strictConsumerIdentifiers,
+			AllowedCredTypes:           p.allowedCredTypes,
+			Warned:                     p.warned,
+		},
+	); err != nil {
+		p.fillErrors = append(p.fillErrors, err)
+	}
+
+	// associate consumers with consumer groups
+	result.FillConsumerGroups(p.logger, p.storer)
 
 	// process annotation plugins
-	result.FillPlugins(p.logger, p.storer)
+	result.FillPlugins(p.logger, fillStorer, p.warned, p.pluginSchemas, p.pluginNamespaceIsolation, p.globalPluginSelector)
+	result.CheckPluginConfigSizes(p.logger, p.maxPluginConfigSize, p.strictPluginConfigSize)
+	// CA certificates are deduplicated once all the entities referencing them are filled
+	result.DedupCACertificates(p.logger)
+	if p.detectPluginOverlaps {
+		if err := result.DetectOverlappingPlugins(p.logger, p.strictPluginOverlaps); err != nil {
+			return nil, err
+		}
+	}
+	if p.deterministicPluginIDs {
+		result.AssignDeterministicPluginIDs(p.logger)
+	}
 
 	// generate Certificates and SNIs
-	ingressCerts := getCerts(p.logger, p.storer, ingressRules.SecretNameToSNIs)
-	gatewayCerts := getGatewayCerts(p.logger, p.storer)
+	gatewaySecretsToSNIs := getGatewaySecretsToSNIs(p.logger, p.storer)
 	// note that ingress-derived certificates will take precedence over gateway-derived certificates for SNI assignment
-	result.Certificates = mergeCerts(p.logger, ingressCerts, gatewayCerts)
+	result.FillCertificates(p.logger, p.storer, ingressRules.SecretNameToSNIs, gatewaySecretsToSNIs)
+	if p.validateCertificateSNIs {
+		result.ValidateCertificateSNIs(p.logger, p.strictCertificateSNIs)
+	}
+	result.CheckCertificateExpiry(p.logger, p.certificateMetrics, p.certificateExpiryWarningThreshold, time.Now())
 
-	// populate CA certificates in Kong
-	var err error
-	caCertSecrets, err := p.storer.ListCACerts()
-	if err != nil {
-		return nil, err
+	if p.instanceTag != "" {
+		result.AddInstanceTag(p.instanceTag)
 	}
-	result.CACertificates = toCACerts(p.logger, caCertSecrets)
 
-	return &result, nil
+	return p.transformState(&result)
+}
+
+// FillErrors returns the failures of the last Build which left the configuration
+// incomplete, e.g. credentials or KongIngress overrides which could not be fetched,
+// aggregated into a single error. It returns nil if the configuration is complete.
+func (p *Parser) FillErrors() error {
+	return utilerrors.NewAggregate(p.fillErrors)
 }
 
 // -----------------------------------------------------------------------------
@@ -160,6 +265,160 @@ func (p *Parser) EnableCombinedServiceRoutes() {
 	p.featureEnabledCombinedServiceRoutes = true
 }
 
+// EnableCredentialSchemas makes the parser use the provided Kong credential
+// schemas to determine the types of credential fields read from KongConsumer
+// credential Secrets, instead of relying on a hardcoded list of non-string fields.
+func (p *Parser) EnableCredentialSchemas(schemas kongstate.CredentialSchemaGetter) {
+	p.credentialSchemas = schemas
+}
+
+// EnableEventRecording makes the parser emit Kubernetes events on objects
+// which could not be translated into Kong configuration.
+func (p *Parser) EnableEventRecording(recorder record.EventRecorder) {
+	p.eventRecorder = recorder
+}
+
+// EnableCredentialMetrics makes the parser record the outcome of provisioning
+// every KongConsumer credential in the provided metrics.
+func (p *Parser) EnableCredentialMetrics(credMetrics kongstate.CredentialMetrics) {
+	p.credentialMetrics = credMetrics
+}
+
+// EnableParallelOverrides makes the parser compute the KongIngress overrides of up to
+// concurrency Kong Services at the same time. Overrides are computed serially by default.
+func (p *Parser) EnableParallelOverrides(concurrency int) {
+	p.overridesConcurrency = concurrency
+}
+
+// EnableStoreRetries makes the parser retry the lookups of credential Secrets and plugins
+// which fail with a transient error, as allowed by policy. Failed lookups are not retried
+// by default.
+func (p *Parser) EnableStoreRetries(policy kongstate.StoreRetryPolicy) {
+	p.storeRetryPolicy = policy
+}
+
+// EnableConsumerNamespaceFilter makes the parser skip KongConsumers from
+// namespaces which don't pass the filter.
+func (p *Parser) EnableConsumerNamespaceFilter(filter *kongstate.NamespaceFilter) {
+	p.consumerNamespaces = filter
+}
+
+// EnableConsumerSelector makes the parser skip KongConsumers whose labels
+// don't match the selector.
+func (p *Parser) EnableConsumerSelector(selector labels.Selector) {
+	p.consumerSelector = selector
+}
+
+// EnableGlobalPluginSelector makes the parser skip global KongClusterPlugins whose
+// labels don't match the selector.
+func (p *Parser) EnableGlobalPluginSelector(selector labels.Selector) {
+	p.globalPluginSelector = selector
+}
+
+// SetCredentialTypeKey sets the key of credential Secrets holding the credential type.
+// It defaults to kongCredType.
+func (p *Parser) SetCredentialTypeKey(key string) {
+	p.credentialTypeKey = key
+}
+
+// EnableConflictingCredentialDropping makes the parser keep only the credential of the
+// oldest KongConsumer when credentials of several KongConsumers share a value Kong
+// requires to be unique. Such conflicts are logged either way.
+func (p *Parser) EnableConflictingCredentialDropping() {
+	p.dropConflictingCredentials = true
+}
+
+// EnableStrictConsumerIdentifiers makes the parser report KongConsumers with neither a
+// username nor a custom ID as errors. Such KongConsumers are skipped with a warning either way.
+func (p *Parser) EnableStrictConsumerIdentifiers() {
+	p.strictConsumerIdentifiers = true
+}
+
+// SetAllowedCredentialTypes makes the parser skip the KongConsumer credentials whose type is
+// not one of credTypes. All the supported credential types are provisioned by default.
+func (p *Parser) SetAllowedCredentialTypes(credTypes []string) {
+	p.allowedCredTypes = sets.NewString(credTypes...)
+}
+
+// EnableCredentialSecretIndexing makes the parser list credential Secrets once and serve them
+// from an index, instead of looking each of them up in the store. The result is the same.
+func (p *Parser) EnableCredentialSecretIndexing() {
+	p.indexCredentialSecrets = true
+}
+
+// EnableWarningDeduplication makes the parser skip deprecation warnings which were
+// already logged, and Warning events about KongConsumers which were already emitted, as
+// recorded in the provided set. The set should outlive the parser so that warnings are
+// not repeated on every reconciliation.
+func (p *Parser) EnableWarningDeduplication(warned *kongstate.WarnedSet) {
+	p.warned = warned
+}
+
+// EnablePluginSchemaValidation makes the parser validate plugin configurations against
+// the plugin schemas of the Kong version set with SetKongVersion, dropping plugins with
+// invalid configurations instead of letting Kong reject the whole configuration update.
+func (p *Parser) EnablePluginSchemaValidation(schemas kongstate.PluginSchemaGetter) {
+	p.pluginSchemas = schemas
+}
+
+// EnableCertificateSNIValidation makes the parser check that the SNIs of certificates are
+// covered by the names of the certificates, logging the ones which aren't. In strict mode,
+// such SNIs are also removed from the certificates.
+func (p *Parser) EnableCertificateSNIValidation(strict bool) {
+	p.validateCertificateSNIs = true
+	p.strictCertificateSNIs = strict
+}
+
+// EnableCertificateMetrics makes the parser record the certificates which are expired,
+// about to expire or can't be parsed in the provided metrics.
+func (p *Parser) EnableCertificateMetrics(certMetrics kongstate.CertificateMetrics) {
+	p.certificateMetrics = certMetrics
+}
+
+// SetCertificateExpiryWarningThreshold sets the remaining validity below which a warning
+// is logged for certificates. It defaults to kongstate.DefaultCertificateExpiryWarningThreshold.
+func (p *Parser) SetCertificateExpiryWarningThreshold(threshold time.Duration) {
+	p.certificateExpiryWarningThreshold = threshold
+}
+
+// EnablePluginNamespaceIsolation makes the parser only attach KongPlugins to objects
+// from their own namespace, dropping cross-namespace attachments.
+func (p *Parser) EnablePluginNamespaceIsolation() {
+	p.pluginNamespaceIsolation = true
+}
+
+// EnablePluginOverlapDetection makes the parser log the plugins attached to a route while a plugin
+// with the same name is attached to its service. In strict mode, such overlaps fail the build.
+func (p *Parser) EnablePluginOverlapDetection(strict bool) {
+	p.detectPluginOverlaps = true
+	p.strictPluginOverlaps = strict
+}
+
+// EnablePluginConfigSizeCheck makes the parser log the plugins whose serialized configuration is
+// larger than maxSize bytes. In strict mode, such plugins are also dropped.
+func (p *Parser) EnablePluginConfigSizeCheck(maxSize int, strict bool) {
+	p.maxPluginConfigSize = maxSize
+	p.strictPluginConfigSize = strict
+}
+
+// EnableDeterministicPluginIDs makes the parser set the ID of plugins to a UUID derived from
+// their name, attachments and configuration, so that a plugin keeps its ID between translations.
+func (p *Parser) EnableDeterministicPluginIDs() {
+	p.deterministicPluginIDs = true
+}
+
+// SetInstanceTag sets a tag added to all the entities of the configuration, identifying
+// the controller instance which generated them. An empty tag adds no tag.
+func (p *Parser) SetInstanceTag(tag string) {
+	p.instanceTag = tag
+}
+
+// SetKongVersion sets the version of Kong the configuration is generated for.
+// Features which are not supported by this version are left out of the configuration.
+func (p *Parser) SetKongVersion(kongVersion semver.Version) {
+	p.kongVersion = kongVersion
+}
+
 // -----------------------------------------------------------------------------
 // Parser - Private Methods
 // -----------------------------------------------------------------------------
@@ -297,6 +556,8 @@ func getUpstreams(
 		if _, exists := upstreamDedup[name]; !exists {
 			// populate all the kong targets for the upstream given all the backends
 			var targets []kongstate.Target
+			// annotated tells which targets were weighted with the weight annotation of their service
+			var annotated []bool
 			for _, backend := range service.Backends {
 				// gather the Kubernetes service for the backend
 				k8sService, ok := service.K8sServices[backend.Name]
@@ -321,6 +582,8 @@ func getUpstreams(
 
 				// if weights were set for the backend then that weight needs to be
 				// distributed equally among all the targets.
+				var fromAnnotation bool
+				backend.Weight, fromAnnotation = backendWeight(log, backend, k8sService)
 				if backend.Weight != nil && len(newTargets) != 0 {
 					// initialize the weight of the target based on the weight of the backend
 					// which governs that target (and potentially more). If the weight of the
@@ -347,7 +610,11 @@ func getUpstreams(
 
 				// add the new targets to the existing pool of targets for the Upstream.
 				targets = append(targets, newTargets...)
+				for range newTargets {
+					annotated = append(annotated, fromAnnotation)
+				}
 			}
+			targets = mergeDuplicateTargets(targets, annotated)
 
 			// warn if an upstream was created with 0 targets
 			if len(targets) == 0 {
@@ -370,45 +637,74 @@ func getUpstreams(
 	return upstreams
 }
 
-func getCertFromSecret(secret *corev1.Secret) (string, string, error) {
-	certData, okcert := secret.Data[corev1.TLSCertKey]
-	keyData, okkey := secret.Data[corev1.TLSPrivateKeyKey]
+// maxTargetWeight is the highest weight Kong accepts for targets.
+const maxTargetWeight = 65535
 
-	if !okcert || !okkey {
-		return "", "", fmt.Errorf("no keypair could be found in"+
-			" secret '%v/%v'", secret.Namespace, secret.Name)
+// backendWeight returns the weight of a backend or, if it has none, the weight set with the weight
+// annotation of the Kubernetes service of the backend, and whether the weight comes from the
+// annotation. Invalid annotation values are logged and ignored.
+func backendWeight(log logrus.FieldLogger, backend kongstate.ServiceBackend, svc *corev1.Service) (*int32, bool) {
+	if backend.Weight != nil {
+		return backend.Weight, false
 	}
-
-	cert := strings.TrimSpace(bytes.NewBuffer(certData).String())
-	key := strings.TrimSpace(bytes.NewBuffer(keyData).String())
-
-	_, err := tls.X509KeyPair([]byte(cert), []byte(key))
-	if err != nil {
-		return "", "", fmt.Errorf("parsing TLS key-pair in secret '%v/%v': %w",
-			secret.Namespace, secret.Name, err)
+	value := annotations.ExtractWeight(svc.Annotations)
+	if value == "" {
+		return nil, false
 	}
-
-	return cert, key, nil
+	weight, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || weight < 0 || weight > maxTargetWeight {
+		log.WithFields(logrus.Fields{
+			"service_name":      svc.Name,
+			"service_namespace": svc.Namespace,
+		}).Warnf("invalid weight %q, expected an integer between 0 and %d, ignoring it", value, maxTargetWeight)
+		return nil, false
+	}
+	res := int32(weight)
+	return &res, true
 }
 
-type certWrapper struct {
-	identifier        string
-	cert              kong.Certificate
-	snis              []string
-	CreationTimestamp metav1.Time
+// mergeDuplicateTargets merges the targets weighted with the weight annotation that share the
+// same address, e.g. when several backends use the same annotated Kubernetes service, as Kong
+// rejects upstreams with duplicate targets. The weights of merged targets are summed, up to the
+// highest weight Kong accepts. Targets keep the position of their first occurrence, and the
+// targets not weighted with the annotation, as told by annotated, are left as is.
+func mergeDuplicateTargets(targets []kongstate.Target, annotated []bool) []kongstate.Target {
+	indexes := make(map[string]int, len(targets))
+	merged := make([]kongstate.Target, 0, len(targets))
+	for n, target := range targets {
+		if !annotated[n] {
+			merged = append(merged, target)
+			continue
+		}
+		address := *target.Target.Target
+		i, ok := indexes[address]
+		if !ok {
+			indexes[address] = len(merged)
+			merged = append(merged, target)
+			continue
+		}
+		weight := *merged[i].Weight + *target.Weight
+		if weight > maxTargetWeight {
+			weight = maxTargetWeight
+		}
+		merged[i].Weight = kong.Int(weight)
+	}
+	return merged
 }
 
-func getGatewayCerts(log logrus.FieldLogger, s store.Storer) []certWrapper {
-	certs := []certWrapper{}
+// getGatewaySecretsToSNIs returns the SNIs requested for the TLS Secrets referenced by
+// Gateway Listeners, keyed by "namespace/name".
+func getGatewaySecretsToSNIs(log logrus.FieldLogger, s store.Storer) map[string][]string {
+	secretsToSNIs := make(map[string][]string)
 	gateways, err := s.ListGateways()
 	if err != nil {
 		log.WithError(err).Error("failed to list Gateways")
-		return certs
+		return secretsToSNIs
 	}
 	policies, err := s.ListReferencePolicies()
 	if err != nil {
 		log.WithError(err).Error("failed to list ReferencePolicies")
-		return certs
+		return secretsToSNIs
 	}
 	for _, gateway := range gateways {
 		statuses := make(map[gatewayv1alpha2.SectionName]gatewayv1alpha2.ListenerStatus, len(gateway.Status.Listeners))
@@ -480,141 +776,19 @@ func getGatewayCerts(log logrus.FieldLogger, s store.Storer) []certWrapper {
 						}
 					}
 
-					// retrieve the Secret and extract the PEM strings
-					secret, err := s.GetSecret(namespace, string(ref.Name))
-					if err != nil {
-						log.WithFields(logrus.Fields{
-							"gateway":          gateway.Name,
-							"listener":         listener.Name,
-							"secret_name":      string(ref.Name),
-							"secret_namespace": namespace,
-						}).WithError(err).Error("failed to fetch secret")
-						continue
-					}
-					cert, key, err := getCertFromSecret(secret)
-					if err != nil {
-						log.WithFields(logrus.Fields{
-							"gateway":          gateway.Name,
-							"listener":         listener.Name,
-							"secret_name":      string(ref.Name),
-							"secret_namespace": namespace,
-						}).WithError(err).Error("failed to construct certificate from secret")
-						continue
-					}
-
 					// determine the SNI
 					hostname := "*"
 					if listener.Hostname != nil {
 						hostname = string(*listener.Hostname)
 					}
 
-					// create a Kong certificate, wrap it in metadata, and add it to the certs slice
-					certs = append(certs, certWrapper{
-						identifier: cert + key,
-						cert: kong.Certificate{
-							ID:   kong.String(string(secret.UID)),
-							Cert: kong.String(cert),
-							Key:  kong.String(key),
-						},
-						CreationTimestamp: secret.CreationTimestamp,
-						snis:              []string{hostname},
-					})
-				}
-			}
-		}
-	}
-	return certs
-}
-
-func getCerts(log logrus.FieldLogger, s store.Storer, secretsToSNIs map[string][]string) []certWrapper {
-	certs := []certWrapper{}
-
-	for secretKey, SNIs := range secretsToSNIs {
-		namespaceName := strings.Split(secretKey, "/")
-		secret, err := s.GetSecret(namespaceName[0], namespaceName[1])
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"secret_name":      namespaceName[1],
-				"secret_namespace": namespaceName[0],
-			}).WithError(err).Error("failed to fetch secret")
-			continue
-		}
-		cert, key, err := getCertFromSecret(secret)
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"secret_name":      namespaceName[1],
-				"secret_namespace": namespaceName[0],
-			}).WithError(err).Error("failed to construct certificate from secret")
-			continue
-		}
-		certs = append(certs, certWrapper{
-			identifier: cert + key,
-			cert: kong.Certificate{
-				ID:   kong.String(string(secret.UID)),
-				Cert: kong.String(cert),
-				Key:  kong.String(key),
-			},
-			CreationTimestamp: secret.CreationTimestamp,
-			snis:              SNIs,
-		})
-	}
-
-	return certs
-}
-
-func mergeCerts(log logrus.FieldLogger, certLists ...[]certWrapper) []kongstate.Certificate {
-	snisSeen := make(map[string]string)
-	certsSeen := make(map[string]certWrapper)
-	for _, cl := range certLists {
-		for _, cw := range cl {
-			current, ok := certsSeen[cw.identifier]
-			if !ok {
-				current = cw
-			} else {
-				// multiple Secrets that contain identical certificates are collapsed, because we only create one
-				// Kong resource for a given cert+key pair. however, because we reuse the Secret ID and creation time
-				// for the Kong resource equivalents, the selection of those needs to be deterministic to avoid
-				// pointless configuration updates
-				if current.CreationTimestamp.After(cw.CreationTimestamp.Time) {
-					current.cert.ID = cw.cert.ID
-					current.CreationTimestamp = cw.CreationTimestamp
-				} else if current.CreationTimestamp.Time.Equal(cw.CreationTimestamp.Time) && (current.cert.ID == nil || *current.cert.ID > *cw.cert.ID) {
-					current.cert.ID = cw.cert.ID
-					current.CreationTimestamp = cw.CreationTimestamp
+					secretKey := namespace + "/" + string(ref.Name)
+					secretsToSNIs[secretKey] = append(secretsToSNIs[secretKey], hostname)
 				}
-				current.snis = append(current.snis, cw.snis...)
 			}
-
-			// although we use current in the end, we only warn/exclude on new ones here. SNIs already in the slice
-			// have already been vetted by some previous iteration and /are/ in the seen list, but they're in the seen
-			// list because the current we retrieved from certsSeen added them
-			for _, sni := range cw.snis {
-				if seen, ok := snisSeen[sni]; !ok {
-					snisSeen[sni] = *current.cert.ID
-					current.cert.SNIs = append(current.cert.SNIs, kong.String(sni))
-				} else {
-					// TODO this should really log information about the requesting Listener or Ingress-like, which is
-					// what binds the SNI to a given Secret. Knowing the Secret ID isn't of great use beyond knowing
-					// what cert will be served. however, the secretToSNIs input to getCerts does not provide this info
-					// https://github.com/Kong/kubernetes-ingress-controller/issues/2605
-					log.WithFields(logrus.Fields{
-						"served_secret_cert":    seen,
-						"requested_secret_cert": *current.cert.ID,
-						"sni":                   sni,
-					}).Error("same SNI requested for multiple certs, can only serve one cert")
-				}
-			}
-			certsSeen[current.identifier] = current
 		}
 	}
-	var res []kongstate.Certificate
-	for _, cw := range certsSeen {
-		sort.SliceStable(cw.cert.SNIs, func(i, j int) bool {
-			return strings.Compare(*cw.cert.SNIs[i], *cw.cert.SNIs[j]) < 0
-		})
-		res = append(res, kongstate.Certificate{Certificate: cw.cert})
-	}
-	return res
+	return secretsToSNIs
 }
 
 func getServiceEndpoints(
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"sort"
@@ -11,6 +12,7 @@ import (
 	"github.com/kong/go-kong/kong"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
 	netv1beta1 "k8s.io/api/networking/v1beta1"
@@ -1104,7 +1106,10 @@ func TestServiceClientCertificate(t *testing.T) {
 			Services:         services,
 		})
 		assert.Nil(err)
-		p := NewParser(logrus.New(), store)
+		var logs bytes.Buffer
+		log := logrus.New()
+		log.SetOutput(&logs)
+		p := NewParser(log, store)
 		state, err := p.Build()
 		assert.Nil(err)
 		assert.NotNil(state)
@@ -1113,6 +1118,7 @@ func TestServiceClientCertificate(t *testing.T) {
 
 		assert.Equal(1, len(state.Services))
 		assert.Nil(state.Services[0].ClientCertificate)
+		assert.Contains(logs.String(), "failed to fetch the client certificate Secret of the Service")
 	})
 }
 
@@ -4808,3 +4814,132 @@ func TestCertificate(t *testing.T) {
 		assert.Equal(state.Certificates[0], fooCertificate)
 	})
 }
+
+func TestGetUpstreams_Weights(t *testing.T) {
+	upstreamService := func(name, weight string) *corev1.Service {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					"ingress.kubernetes.io/service-upstream": "true",
+				},
+			},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}},
+		}
+		if weight != "" {
+			svc.Annotations["konghq.com/weight"] = weight
+		}
+		return svc
+	}
+	backend := func(name string, weight *int32) kongstate.ServiceBackend {
+		return kongstate.ServiceBackend{
+			Name:    name,
+			PortDef: kongstate.PortDef{Mode: kongstate.PortModeByNumber, Number: 80},
+			Weight:  weight,
+		}
+	}
+	weight := func(w int32) *int32 { return &w }
+	targetWeights := func(upstreams []kongstate.Upstream) map[string]*int {
+		require.Len(t, upstreams, 1)
+		res := make(map[string]*int)
+		for _, target := range upstreams[0].Targets {
+			res[*target.Target.Target] = target.Weight
+		}
+		return res
+	}
+
+	for _, tt := range []struct {
+		name     string
+		services []*corev1.Service
+		backends kongstate.ServiceBackends
+		want     map[string]*int
+	}{
+		{
+			name:     "weight annotations split the traffic between services",
+			services: []*corev1.Service{upstreamService("stable", "80"), upstreamService("canary", "20")},
+			backends: kongstate.ServiceBackends{backend("stable", nil), backend("canary", nil)},
+			want: map[string]*int{
+				"stable.default.svc:80": kong.Int(80),
+				"canary.default.svc:80": kong.Int(20),
+			},
+		},
+		{
+			name:     "backend weights take precedence over weight annotations",
+			services: []*corev1.Service{upstreamService("stable", "80"), upstreamService("canary", "20")},
+			backends: kongstate.ServiceBackends{backend("stable", weight(50)), backend("canary", weight(50))},
+			want: map[string]*int{
+				"stable.default.svc:80": kong.Int(50),
+				"canary.default.svc:80": kong.Int(50),
+			},
+		},
+		{
+			name:     "invalid weight annotations are ignored",
+			services: []*corev1.Service{upstreamService("stable", "heavy"), upstreamService("canary", "-1")},
+			backends: kongstate.ServiceBackends{backend("stable", nil), backend("canary", nil)},
+			want: map[string]*int{
+				"stable.default.svc:80": nil,
+				"canary.default.svc:80": nil,
+			},
+		},
+		{
+			name:     "annotated weights of backends sharing a target are summed",
+			services: []*corev1.Service{upstreamService("stable", "30")},
+			backends: kongstate.ServiceBackends{backend("stable", nil), backend("stable", nil)},
+			want: map[string]*int{
+				"stable.default.svc:80": kong.Int(60),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := store.NewFakeStore(store.FakeObjects{Services: tt.services})
+			require.NoError(t, err)
+			k8sServices := make(map[string]*corev1.Service, len(tt.services))
+			for _, svc := range tt.services {
+				k8sServices[svc.Name] = svc
+			}
+			serviceMap := map[string]kongstate.Service{
+				"default.canary.80": {
+					Service: kong.Service{
+						Name: kong.String("default.canary.80"),
+						Host: kong.String("canary.default.80.svc"),
+					},
+					Namespace:   "default",
+					Backends:    tt.backends,
+					K8sServices: k8sServices,
+				},
+			}
+			assert.Equal(t, tt.want, targetWeights(getUpstreams(logrus.New(), s, serviceMap)))
+		})
+	}
+}
+
+func TestMergeDuplicateTargets(t *testing.T) {
+	target := func(address string, weight *int) kongstate.Target {
+		return kongstate.Target{Target: kong.Target{Target: kong.String(address), Weight: weight}}
+	}
+
+	t.Run("targets without the weight annotation are unchanged", func(t *testing.T) {
+		targets := []kongstate.Target{
+			target("10.0.0.1:80", nil),
+			target("10.0.0.1:80", nil),
+			target("10.0.0.2:80", kong.Int(30)),
+			target("10.0.0.2:80", kong.Int(20)),
+		}
+		assert.Equal(t, targets, mergeDuplicateTargets(targets, []bool{false, false, false, false}))
+	})
+
+	t.Run("targets with the weight annotation are merged", func(t *testing.T) {
+		targets := []kongstate.Target{
+			target("10.0.0.1:80", kong.Int(40)),
+			target("10.0.0.2:80", nil),
+			target("10.0.0.1:80", kong.Int(65500)),
+			target("10.0.0.2:80", nil),
+		}
+		assert.Equal(t, []kongstate.Target{
+			target("10.0.0.1:80", kong.Int(65535)),
+			target("10.0.0.2:80", nil),
+			target("10.0.0.2:80", nil),
+		}, mergeDuplicateTargets(targets, []bool{true, false, true, false}))
+	})
+}
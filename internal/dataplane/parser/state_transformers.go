@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/kongstate"
+)
+
+// StateTransformer modifies the KongState built by the parser, e.g. to add default plugins
+// or tag all entities, and returns the resulting state.
+type StateTransformer func(*kongstate.KongState) (*kongstate.KongState, error)
+
+// namedStateTransformer is a StateTransformer along with the name it was registered with.
+type namedStateTransformer struct {
+	name      string
+	transform StateTransformer
+}
+
+// RegisterStateTransformer adds a transformer run at the end of every Build, once plugins
+// are built. Transformers run in registration order, each receiving the state returned by
+// the previous one. The name is used to identify the transformer in errors.
+func (p *Parser) RegisterStateTransformer(name string, transformer StateTransformer) {
+	p.stateTransformers = append(p.stateTransformers, namedStateTransformer{
+		name:      name,
+		transform: transformer,
+	})
+}
+
+// transformState runs the registered transformers on the state. It stops at the first
+// transformer failing or returning no state.
+func (p *Parser) transformState(state *kongstate.KongState) (*kongstate.KongState, error) {
+	for _, t := range p.stateTransformers {
+		transformed, err := t.transform(state)
+		if err != nil {
+			return nil, fmt.Errorf("state transformer %s failed: %w", t.name, err)
+		}
+		if transformed == nil {
+			return nil, fmt.Errorf("state transformer %s returned no state", t.name)
+		}
+		state = transformed
+	}
+	return state, nil
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kong/go-kong/kong"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/kongstate"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
+)
+
+func TestParser_StateTransformers(t *testing.T) {
+	s, err := store.NewFakeStore(store.FakeObjects{})
+	require.NoError(t, err)
+
+	addPlugin := func(name string) StateTransformer {
+		return func(state *kongstate.KongState) (*kongstate.KongState, error) {
+			state.Plugins = append(state.Plugins, kongstate.Plugin{Plugin: kong.Plugin{Name: kong.String(name)}})
+			return state, nil
+		}
+	}
+
+	t.Run("transformers run in registration order", func(t *testing.T) {
+		p := NewParser(logrus.New(), s)
+		p.RegisterStateTransformer("rate-limiting", addPlugin("rate-limiting"))
+		p.RegisterStateTransformer("prometheus", addPlugin("prometheus"))
+		state, err := p.Build()
+		require.NoError(t, err)
+		require.Len(t, state.Plugins, 2)
+		assert.Equal(t, "rate-limiting", *state.Plugins[0].Name)
+		assert.Equal(t, "prometheus", *state.Plugins[1].Name)
+	})
+
+	t.Run("errors abort the build", func(t *testing.T) {
+		p := NewParser(logrus.New(), s)
+		p.RegisterStateTransformer("broken", func(*kongstate.KongState) (*kongstate.KongState, error) {
+			return nil, errors.New("boom")
+		})
+		p.RegisterStateTransformer("never-run", func(*kongstate.KongState) (*kongstate.KongState, error) {
+			t.Error("transformers registered after a failing one must not run")
+			return nil, nil
+		})
+		state, err := p.Build()
+		assert.Nil(t, state)
+		assert.EqualError(t, err, "state transformer broken failed: boom")
+	})
+
+	t.Run("transformers must return a state", func(t *testing.T) {
+		p := NewParser(logrus.New(), s)
+		p.RegisterStateTransformer("empty", func(*kongstate.KongState) (*kongstate.KongState, error) {
+			return nil, nil
+		})
+		_, err := p.Build()
+		assert.EqualError(t, err, "state transformer empty returned no state")
+	})
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/kongstate"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
 )
 
 // -----------------------------------------------------------------------------
@@ -108,6 +109,24 @@ func getHTTPRouteHostnamesAsSliceOfStringPointers(httproute *gatewayv1alpha2.HTT
 	return hostnames
 }
 
+// getHTTPRouteRulePluginRefs returns the names of the KongPlugins an HTTPRoute rule
+// references with extensionRef filters. These plugins are attached to all the Kong
+// Routes generated for the rule. Extensions of other kinds are ignored.
+func getHTTPRouteRulePluginRefs(rule gatewayv1alpha2.HTTPRouteRule) []string {
+	var pluginRefs []string
+	for _, filter := range rule.Filters {
+		if filter.Type != gatewayv1alpha2.HTTPRouteFilterExtensionRef || filter.ExtensionRef == nil {
+			continue
+		}
+		ref := filter.ExtensionRef
+		if string(ref.Group) != configurationv1.GroupVersion.Group || ref.Kind != "KongPlugin" {
+			continue
+		}
+		pluginRefs = append(pluginRefs, string(ref.Name))
+	}
+	return pluginRefs
+}
+
 // generateKongRoutesFromHTTPRouteRule converts an HTTPRoute rule to one or more
 // Kong Route objects to route traffic to services. This function will accept an
 // HTTPRoute that does not include any matches as long as it includes hostnames
@@ -119,6 +138,7 @@ func generateKongRoutesFromHTTPRouteRule(httproute *gatewayv1alpha2.HTTPRoute, r
 	// gather the k8s object information and hostnames from the httproute
 	objectInfo := util.FromK8sObject(httproute)
 	hostnames := getHTTPRouteHostnamesAsSliceOfStringPointers(httproute)
+	pluginRefs := getHTTPRouteRulePluginRefs(rule)
 
 	// the HTTPRoute specification upstream specifically defines matches as
 	// independent (e.g. each match is an OR with other matches, not an AND).
@@ -144,7 +164,8 @@ func generateKongRoutesFromHTTPRouteRule(httproute *gatewayv1alpha2.HTTPRoute, r
 
 			// build the route object using the method and pathing information
 			r := kongstate.Route{
-				Ingress: objectInfo,
+				Ingress:    objectInfo,
+				PluginRefs: pluginRefs,
 				Route: kong.Route{
 					Name:         routeName,
 					Protocols:    kong.StringSlice("http", "https"),
@@ -194,7 +215,8 @@ func generateKongRoutesFromHTTPRouteRule(httproute *gatewayv1alpha2.HTTPRoute, r
 		// match all traffic based on the hostname and leave all other routing
 		// options default.
 		r := kongstate.Route{
-			Ingress: objectInfo,
+			Ingress:    objectInfo,
+			PluginRefs: pluginRefs,
 			Route: kong.Route{
 				Name:         kong.String(fmt.Sprintf("httproute.%s.%s.0.0", httproute.Namespace, httproute.Name)),
 				Protocols:    kong.StringSlice("http", "https"),
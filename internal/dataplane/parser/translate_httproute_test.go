@@ -7,6 +7,7 @@ import (
 	"github.com/kong/go-kong/kong"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -15,6 +16,7 @@ import (
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/kongstate"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/store"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
+	configurationv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
 )
 
 // httprouteGVK is the GVK for HTTPRoutes, needed in unit tests because
@@ -694,3 +696,43 @@ func Test_getHTTPRouteHostnamesAsSliceOfStringPointers(t *testing.T) {
 		})
 	}
 }
+
+func Test_generateKongRoutesFromHTTPRouteRule_PluginRefs(t *testing.T) {
+	httproute := &gatewayv1alpha2.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-httproute", Namespace: "default"},
+		Spec: gatewayv1alpha2.HTTPRouteSpec{
+			Hostnames: []gatewayv1alpha2.Hostname{"konghq.com"},
+		},
+	}
+	rule := gatewayv1alpha2.HTTPRouteRule{
+		Filters: []gatewayv1alpha2.HTTPRouteFilter{
+			{
+				Type: gatewayv1alpha2.HTTPRouteFilterExtensionRef,
+				ExtensionRef: &gatewayv1alpha2.LocalObjectReference{
+					Group: gatewayv1alpha2.Group(configurationv1.GroupVersion.Group),
+					Kind:  gatewayv1alpha2.Kind("KongPlugin"),
+					Name:  gatewayv1alpha2.ObjectName("key-auth"),
+				},
+			},
+			{
+				// extensions other than KongPlugins are ignored
+				Type: gatewayv1alpha2.HTTPRouteFilterExtensionRef,
+				ExtensionRef: &gatewayv1alpha2.LocalObjectReference{
+					Group: gatewayv1alpha2.Group("example.com"),
+					Kind:  gatewayv1alpha2.Kind("KongPlugin"),
+					Name:  gatewayv1alpha2.ObjectName("other"),
+				},
+			},
+			{
+				Type: gatewayv1alpha2.HTTPRouteFilterRequestHeaderModifier,
+			},
+		},
+	}
+
+	routes, err := generateKongRoutesFromHTTPRouteRule(httproute, 0, rule)
+	require.NoError(t, err)
+	require.Len(t, routes, 1)
+	assert.Equal(t, "httproute.default.basic-httproute.0.0", *routes[0].Name)
+	assert.Equal(t, []string{"key-auth"}, routes[0].PluginRefs)
+	assert.Equal(t, "default", routes[0].Ingress.Namespace)
+}
@@ -17,8 +17,9 @@ type Kong struct {
 	FilterTags []string
 	// Headers are injected into every request to Kong's Admin API
 	// to help with authorization/authentication.
-	Client            *kong.Client
-	PluginSchemaStore *util.PluginSchemaStore
+	Client                *kong.Client
+	PluginSchemaStore     *util.PluginSchemaStore
+	CredentialSchemaStore *util.CredentialSchemaStore
 
 	InMemory bool
 	// DeprecatedHasTagSupport is not used in KIC 2.x.
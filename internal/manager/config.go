@@ -16,6 +16,8 @@ import (
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/admission"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/annotations"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/kongstate"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/validation/consumers/credentials"
 )
 
 // -----------------------------------------------------------------------------
@@ -43,15 +45,28 @@ type Config struct {
 	SkipCACertificates                bool
 
 	// Kong Proxy configurations
-	APIServerHost            string
-	APIServerQPS             int
-	APIServerBurst           int
-	MetricsAddr              string
-	ProbeAddr                string
-	KongAdminURL             string
-	ProxySyncSeconds         float32
-	ProxyTimeoutSeconds      float32
-	KongCustomEntitiesSecret string
+	APIServerHost                     string
+	APIServerQPS                      int
+	APIServerBurst                    int
+	MetricsAddr                       string
+	ProbeAddr                         string
+	KongAdminURL                      string
+	ProxySyncSeconds                  float32
+	ProxyTimeoutSeconds               float32
+	KongCustomEntitiesSecret          string
+	OverridesConcurrency              int
+	StoreLookupAttempts               int
+	StoreLookupBackoff                time.Duration
+	ValidateCertificateSNIs           bool
+	StrictCertificateSNIs             bool
+	CertificateExpiryWarningThreshold time.Duration
+	PluginNamespaceIsolation          bool
+	DeterministicPluginIDs            bool
+	DetectPluginOverlaps              bool
+	StrictPluginOverlaps              bool
+	MaxPluginConfigSize               int
+	StrictPluginConfigSize            bool
+	GlobalPluginSelector              string
 
 	// Kubernetes configurations
 	KubeconfigPath          string
@@ -61,26 +76,40 @@ type Config struct {
 	LeaderElectionID        string
 	Concurrency             int
 	FilterTags              []string
+	InstanceTag             string
 	WatchNamespaces         []string
 
+	// KongConsumer namespace filtering
+	ConsumerNamespacesAllowlist []string
+	ConsumerNamespacesDenylist  []string
+	ConsumerSelector            string
+	CredentialTypeKey           string
+	DropConflictingCredentials  bool
+	StrictConsumerIdentifiers   bool
+	AllowedCredentialTypes      []string
+	IndexCredentialSecrets      bool
+
 	// Ingress status
 	PublishService       string
 	PublishStatusAddress []string
 	UpdateStatus         bool
 
 	// Kubernetes API toggling
-	IngressExtV1beta1Enabled bool
-	IngressNetV1beta1Enabled bool
-	IngressNetV1Enabled      bool
-	IngressClassNetV1Enabled bool
-	UDPIngressEnabled        bool
-	TCPIngressEnabled        bool
-	KongIngressEnabled       bool
-	KnativeIngressEnabled    bool
-	KongClusterPluginEnabled bool
-	KongPluginEnabled        bool
-	KongConsumerEnabled      bool
-	ServiceEnabled           bool
+	IngressExtV1beta1Enabled  bool
+	IngressNetV1beta1Enabled  bool
+	IngressNetV1Enabled       bool
+	IngressClassNetV1Enabled  bool
+	UDPIngressEnabled         bool
+	TCPIngressEnabled         bool
+	KongIngressEnabled        bool
+	KnativeIngressEnabled     bool
+	KongClusterPluginEnabled  bool
+	KongPluginEnabled         bool
+	KongConsumerEnabled       bool
+	KongConsumerGroupEnabled  bool
+	KongUpstreamPolicyEnabled bool
+	ServiceEnabled            bool
+	ConfigMapEnabled          bool
 
 	// Admission Webhook server config
 	AdmissionServer admission.ServerConfig
@@ -149,6 +178,45 @@ func (c *Config) FlagSet() *pflag.FlagSet {
 		"Sets the timeout (in seconds) for all requests to Kong's Admin API.",
 	)
 	flagSet.StringVar(&c.KongCustomEntitiesSecret, "kong-custom-entities-secret", "", `A Secret containing custom entities for DB-less mode, in "namespace/name" format`)
+	flagSet.IntVar(&c.OverridesConcurrency, "overrides-concurrency", 1,
+		"Max number of Kong Services whose KongIngress overrides are computed concurrently when translating Kubernetes objects.",
+	)
+	flagSet.IntVar(&c.StoreLookupAttempts, "store-lookup-attempts", 1,
+		"Max number of attempts to look up a credential Secret or a plugin failing with a transient error when translating Kubernetes objects. Lookups of missing objects are not retried.",
+	)
+	flagSet.DurationVar(&c.StoreLookupBackoff, "store-lookup-backoff", 10*time.Millisecond,
+		"Delay before the first retry of a lookup allowed by --store-lookup-attempts, doubled before every following retry.",
+	)
+	flagSet.BoolVar(&c.ValidateCertificateSNIs, "validate-certificate-snis", false,
+		"Log a warning for every certificate SNI which is not covered by the DNS names of the certificate.",
+	)
+	flagSet.BoolVar(&c.StrictCertificateSNIs, "strict-certificate-snis", false,
+		"Drop certificate SNIs which are not covered by the DNS names of the certificate. Implies --validate-certificate-snis.",
+	)
+	flagSet.DurationVar(&c.CertificateExpiryWarningThreshold, "certificate-expiry-warning-threshold", kongstate.DefaultCertificateExpiryWarningThreshold,
+		"Log a warning for every certificate which expires within this duration or has already expired.",
+	)
+	flagSet.BoolVar(&c.PluginNamespaceIsolation, "plugin-namespace-isolation", false,
+		"Only attach KongPlugins to objects from the same namespace, dropping cross-namespace attachments.",
+	)
+	flagSet.BoolVar(&c.DetectPluginOverlaps, "detect-plugin-overlaps", false,
+		"Log plugins attached to a route while a plugin with the same name is attached to its service, the route one taking precedence.",
+	)
+	flagSet.BoolVar(&c.StrictPluginOverlaps, "strict-plugin-overlaps", false,
+		"Reject configurations with plugins attached to both a route and its service. Implies --detect-plugin-overlaps.",
+	)
+	flagSet.IntVar(&c.MaxPluginConfigSize, "max-plugin-config-size", 0,
+		"Log a warning for every plugin whose configuration, serialized to JSON, is larger than this number of bytes. 0 disables the check.",
+	)
+	flagSet.BoolVar(&c.StrictPluginConfigSize, "strict-plugin-config-size", false,
+		"Drop the plugins whose configuration is larger than --max-plugin-config-size.",
+	)
+	flagSet.BoolVar(&c.DeterministicPluginIDs, "deterministic-plugin-ids", false,
+		"Derive the IDs of plugins from their name, the entities they're attached to and their configuration, so that they are stable between configuration updates.",
+	)
+	flagSet.StringVar(&c.GlobalPluginSelector, "global-plugin-selector", "",
+		`Label selector of the global KongClusterPlugins which are applied, e.g. to only honor the global plugins of a platform team. Defaults to all global KongClusterPlugins.`,
+	)
 
 	// Kubernetes configurations
 	flagSet.StringVar(&c.KubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file.")
@@ -157,10 +225,27 @@ func (c *Config) FlagSet() *pflag.FlagSet {
 	flagSet.StringVar(&c.LeaderElectionID, "election-id", "5b374a9e.konghq.com", `Election id to use for status update.`)
 	flagSet.StringVar(&c.LeaderElectionNamespace, "election-namespace", "", `Leader election namespace to use when running outside a cluster`)
 	flagSet.StringSliceVar(&c.FilterTags, "kong-admin-filter-tag", []string{"managed-by-ingress-controller"}, "The tag used to manage and filter entities in Kong. This flag can be specified multiple times to specify multiple tags. This setting will be silently ignored if the Kong instance has no tags support.")
+	flagSet.StringVar(&c.InstanceTag, "kong-instance-tag", "", `Tag added to all the Kong entities generated by this controller instance (e.g. "managed-by:instance-a"), so that controllers sharing the same Kong can tell their entities apart.`)
 	flagSet.IntVar(&c.Concurrency, "kong-admin-concurrency", 10, "Max number of concurrent requests sent to Kong's Admin API.")
 	flagSet.StringSliceVar(&c.WatchNamespaces, "watch-namespace", nil,
 		`Namespace(s) to watch for Kubernetes resources. Defaults to all namespaces. To watch multiple namespaces, use
 		a comma-separated list of namespaces.`)
+	flagSet.StringSliceVar(&c.ConsumerNamespacesAllowlist, "kong-consumer-namespaces-allowlist", nil,
+		`Namespace(s) whose KongConsumers are translated into Kong consumers. Defaults to all namespaces.`)
+	flagSet.StringSliceVar(&c.ConsumerNamespacesDenylist, "kong-consumer-namespaces-denylist", nil,
+		`Namespace(s) whose KongConsumers are ignored. Takes precedence over --kong-consumer-namespaces-allowlist.`)
+	flagSet.StringVar(&c.ConsumerSelector, "kong-consumer-selector", "",
+		`Label selector of the KongConsumers translated into Kong consumers. Defaults to all KongConsumers.`)
+	flagSet.StringVar(&c.CredentialTypeKey, "kong-credential-type-key", credentials.TypeKey,
+		`Key of KongConsumer credential Secrets holding the credential type. Secrets without this key can set the type with the "`+credentials.TypeLabel+`" label, or a "`+credentials.SecretTypePrefix+`<type>" Secret type, instead.`)
+	flagSet.BoolVar(&c.DropConflictingCredentials, "drop-conflicting-credentials", false,
+		`Drop KongConsumer credentials whose unique value (e.g. a key-auth key) is already used by a credential of an older KongConsumer. Such conflicts are logged either way.`)
+	flagSet.BoolVar(&c.StrictConsumerIdentifiers, "strict-consumer-identifiers", false,
+		`Report KongConsumers with neither a username nor a custom ID as configuration errors. Such KongConsumers are skipped with a warning either way.`)
+	flagSet.StringSliceVar(&c.AllowedCredentialTypes, "allowed-credential-types", nil,
+		`Types of the KongConsumer credentials to provision (e.g. "key-auth,jwt"). Credentials of other types are skipped with a warning. Defaults to all the supported types.`)
+	flagSet.BoolVar(&c.IndexCredentialSecrets, "index-credential-secrets", false,
+		`List the Secrets once per configuration update and index them, instead of looking up each KongConsumer credential Secret. Reduces the pressure on the informer cache in clusters with many credentials.`)
 
 	// Ingress status
 	flagSet.StringVar(&c.PublishService, "publish-service", "", `Service fronting Ingress resources in "namespace/name"
@@ -183,7 +268,13 @@ func (c *Config) FlagSet() *pflag.FlagSet {
 	flagSet.BoolVar(&c.KongClusterPluginEnabled, "enable-controller-kongclusterplugin", true, "Enable the KongClusterPlugin controller.")
 	flagSet.BoolVar(&c.KongPluginEnabled, "enable-controller-kongplugin", true, "Enable the KongPlugin controller.")
 	flagSet.BoolVar(&c.KongConsumerEnabled, "enable-controller-kongconsumer", true, "Enable the KongConsumer controller. ")
+	flagSet.BoolVar(&c.KongConsumerGroupEnabled, "enable-controller-kongconsumergroup", false, "Enable the KongConsumerGroup controller. "+
+		"Consumer groups are not sent to Kong yet, so it's disabled by default.")
+	flagSet.BoolVar(&c.KongUpstreamPolicyEnabled, "enable-controller-kongupstreampolicy", true, "Enable the KongUpstreamPolicy controller.")
 	flagSet.BoolVar(&c.ServiceEnabled, "enable-controller-service", true, "Enable the Service controller.")
+	flagSet.BoolVar(&c.ConfigMapEnabled, "enable-controller-configmap", false, "Enable the ConfigMap controller, "+
+		"required by KongPlugins and KongClusterPlugins reading their configuration from a ConfigMap with configFrom.configMapKeyRef. "+
+		"It caches all the ConfigMaps of the watched namespaces and requires the list and watch permissions on ConfigMaps.")
 
 	// Admission Webhook server config
 	flagSet.StringVar(&c.AdmissionServer.ListenAddr, "admission-webhook-listen", "off",
@@ -19,3 +19,7 @@ const MetricsPort = 10255
 
 // DiagnosticsPort is the default port of the manager's diagnostics service listens on.
 const DiagnosticsPort = 10256
+
+// KongClientEventRecorderComponentName is the name of the component reported on
+// Kubernetes events emitted by the data-plane client.
+const KongClientEventRecorderComponentName = "kong-client"
@@ -17,6 +17,7 @@ import (
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/util/kubernetes/object/status"
 	konghqcomv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
+	konghqcomv1beta1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1beta1"
 )
 
 // -----------------------------------------------------------------------------
@@ -163,6 +164,15 @@ func setupControllers(
 				DataplaneClient: dataplaneClient,
 			},
 		},
+		{
+			Enabled: c.ConfigMapEnabled,
+			Controller: &configuration.CoreV1ConfigMapReconciler{
+				Client:          mgr.GetClient(),
+				Log:             ctrl.Log.WithName("controllers").WithName("ConfigMaps"),
+				Scheme:          mgr.GetScheme(),
+				DataplaneClient: dataplaneClient,
+			},
+		},
 		// ---------------------------------------------------------------------------
 		// Kong API Controllers
 		// ---------------------------------------------------------------------------
@@ -230,6 +240,36 @@ func setupControllers(
 				DisableIngressClassLookups: !c.IngressClassNetV1Enabled,
 			},
 		},
+		{
+			Enabled: c.KongConsumerGroupEnabled,
+			AutoHandler: crdExistsChecker{GVR: schema.GroupVersionResource{
+				Group:    konghqcomv1beta1.SchemeGroupVersion.Group,
+				Version:  konghqcomv1beta1.SchemeGroupVersion.Version,
+				Resource: "kongconsumergroups",
+			}}.CRDExists,
+			Controller: &configuration.KongV1Beta1KongConsumerGroupReconciler{
+				Client:                     mgr.GetClient(),
+				Log:                        ctrl.Log.WithName("controllers").WithName("KongConsumerGroup"),
+				Scheme:                     mgr.GetScheme(),
+				DataplaneClient:            dataplaneClient,
+				IngressClassName:           c.IngressClassName,
+				DisableIngressClassLookups: !c.IngressClassNetV1Enabled,
+			},
+		},
+		{
+			Enabled: c.KongUpstreamPolicyEnabled,
+			AutoHandler: crdExistsChecker{GVR: schema.GroupVersionResource{
+				Group:    konghqcomv1beta1.SchemeGroupVersion.Group,
+				Version:  konghqcomv1beta1.SchemeGroupVersion.Version,
+				Resource: "kongupstreampolicies",
+			}}.CRDExists,
+			Controller: &configuration.KongV1Beta1KongUpstreamPolicyReconciler{
+				Client:          mgr.GetClient(),
+				Log:             ctrl.Log.WithName("controllers").WithName("KongUpstreamPolicy"),
+				Scheme:          mgr.GetScheme(),
+				DataplaneClient: dataplaneClient,
+			},
+		},
 		{
 			Enabled: c.KongClusterPluginEnabled,
 			AutoHandler: crdExistsChecker{GVR: schema.GroupVersionResource{
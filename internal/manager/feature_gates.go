@@ -26,6 +26,10 @@ const (
 	// IngressClassParameters CRD support.
 	ingressClassParametersFeature = "IngressClassParameters"
 
+	// pluginConfigValidationFeature is the name of the feature-gate for validating
+	// plugin configurations against the Kong plugin schemas during translation.
+	pluginConfigValidationFeature = "PluginConfigValidation"
+
 	// featureGatesDocsURL provides a link to the documentation for feature gates in the KIC repository.
 	featureGatesDocsURL = "https://github.com/Kong/kubernetes-ingress-controller/blob/main/FEATURE_GATES.md"
 )
@@ -59,5 +63,6 @@ func getFeatureGatesDefaults() map[string]bool {
 		gatewayFeature:                false,
 		combinedRoutesFeature:         false,
 		ingressClassParametersFeature: false,
+		pluginConfigValidationFeature: false,
 	}
 }
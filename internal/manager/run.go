@@ -10,6 +10,7 @@ import (
 
 	"github.com/avast/retry-go/v4"
 	"github.com/kong/go-kong/kong"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -19,10 +20,12 @@ import (
 	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/dataplane/kongstate"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/manager/metadata"
 	mgrutils "github.com/kong/kubernetes-ingress-controller/v2/internal/manager/utils"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/util"
 	"github.com/kong/kubernetes-ingress-controller/v2/internal/util/kubernetes/object/status"
+	"github.com/kong/kubernetes-ingress-controller/v2/internal/validation/consumers/credentials"
 	konghqcomv1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1"
 	configurationv1beta1 "github.com/kong/kubernetes-ingress-controller/v2/pkg/apis/configuration/v1beta1"
 )
@@ -142,11 +145,77 @@ func Run(ctx context.Context, c *Config, diagnostic util.ConfigDumpDiagnostic) e
 		return fmt.Errorf("unable to initialize dataplane synchronizer: %w", err)
 	}
 
+	dataplaneClient.EnableEventRecording(mgr.GetEventRecorderFor(KongClientEventRecorderComponentName))
+	dataplaneClient.SetOverridesConcurrency(c.OverridesConcurrency)
+	dataplaneClient.SetStoreRetryPolicy(kongstate.StoreRetryPolicy{
+		Attempts: c.StoreLookupAttempts,
+		Backoff:  c.StoreLookupBackoff,
+	})
+	dataplaneClient.SetCredentialTypeKey(c.CredentialTypeKey)
+	if c.InstanceTag != "" {
+		if err := kongstate.ValidateInstanceTag(c.InstanceTag); err != nil {
+			return fmt.Errorf("invalid Kong instance tag: %w", err)
+		}
+		dataplaneClient.SetInstanceTag(c.InstanceTag)
+	}
+	dataplaneClient.SetCertificateSNIValidation(c.ValidateCertificateSNIs || c.StrictCertificateSNIs, c.StrictCertificateSNIs)
+	dataplaneClient.SetCertificateExpiryWarningThreshold(c.CertificateExpiryWarningThreshold)
+	if c.PluginNamespaceIsolation {
+		dataplaneClient.EnablePluginNamespaceIsolation()
+	}
+	dataplaneClient.SetPluginOverlapDetection(c.DetectPluginOverlaps || c.StrictPluginOverlaps, c.StrictPluginOverlaps)
+	dataplaneClient.SetPluginConfigSizeLimit(c.MaxPluginConfigSize, c.StrictPluginConfigSize)
+	if c.DeterministicPluginIDs {
+		dataplaneClient.EnableDeterministicPluginIDs()
+	}
+	if c.GlobalPluginSelector != "" {
+		selector, err := labels.Parse(c.GlobalPluginSelector)
+		if err != nil {
+			return fmt.Errorf("invalid global KongClusterPlugin selector %q: %w", c.GlobalPluginSelector, err)
+		}
+		dataplaneClient.SetGlobalPluginSelector(selector)
+	}
+	if len(c.ConsumerNamespacesAllowlist) > 0 || len(c.ConsumerNamespacesDenylist) > 0 {
+		dataplaneClient.SetConsumerNamespaceFilter(&kongstate.NamespaceFilter{
+			Allow: c.ConsumerNamespacesAllowlist,
+			Deny:  c.ConsumerNamespacesDenylist,
+		})
+	}
+	if c.ConsumerSelector != "" {
+		selector, err := labels.Parse(c.ConsumerSelector)
+		if err != nil {
+			return fmt.Errorf("invalid KongConsumer selector %q: %w", c.ConsumerSelector, err)
+		}
+		dataplaneClient.SetConsumerSelector(selector)
+	}
+	if c.DropConflictingCredentials {
+		dataplaneClient.EnableConflictingCredentialDropping()
+	}
+	if c.StrictConsumerIdentifiers {
+		dataplaneClient.EnableStrictConsumerIdentifiers()
+	}
+	if len(c.AllowedCredentialTypes) > 0 {
+		for _, credType := range c.AllowedCredentialTypes {
+			if !credentials.SupportedTypes.Has(credType) {
+				return fmt.Errorf("invalid allowed credential type: %s", credType)
+			}
+		}
+		dataplaneClient.SetAllowedCredentialTypes(c.AllowedCredentialTypes)
+	}
+	if c.IndexCredentialSecrets {
+		dataplaneClient.EnableCredentialSecretIndexing()
+	}
+
 	if enabled, ok := featureGates[combinedRoutesFeature]; ok && enabled {
 		dataplaneClient.EnableCombinedServiceRoutes()
 		setupLog.Info("combined routes mode has been enabled")
 	}
 
+	if enabled, ok := featureGates[pluginConfigValidationFeature]; ok && enabled {
+		dataplaneClient.EnablePluginSchemaValidation()
+		setupLog.Info("plugin configuration validation has been enabled")
+	}
+
 	var kubernetesStatusQueue *status.Queue
 	if c.UpdateStatus {
 		setupLog.Info("Starting Status Updater")
@@ -123,11 +123,12 @@ func setupKongConfig(ctx context.Context, kongClient *kong.Client, logger logr.L
 	}
 
 	return sendconfig.Kong{
-		URL:               c.KongAdminURL,
-		FilterTags:        filterTags,
-		Concurrency:       c.Concurrency,
-		Client:            kongClient,
-		PluginSchemaStore: util.NewPluginSchemaStore(kongClient),
+		URL:                   c.KongAdminURL,
+		FilterTags:            filterTags,
+		Concurrency:           c.Concurrency,
+		Client:                kongClient,
+		PluginSchemaStore:     util.NewPluginSchemaStore(kongClient),
+		CredentialSchemaStore: util.NewCredentialSchemaStore(kongClient),
 	}
 }
 
@@ -192,6 +193,7 @@ func setupAdmissionServer(ctx context.Context, managerConfig *Config, managerCli
 			log,
 			managerClient,
 			managerConfig.IngressClassName,
+			managerConfig.ConfigMapEnabled,
 		),
 		Logger: logger,
 	}, log)
@@ -14,6 +14,12 @@ type CtrlFuncMetrics struct {
 
 	// ConfigPushDuration is a Prometheus metric with semantics defined by its help string in NewCtrlFuncMetrics().
 	ConfigPushDuration *prometheus.HistogramVec
+
+	// CredentialProvisioningCount is a Prometheus metric with semantics defined by its help string in NewCtrlFuncMetrics().
+	CredentialProvisioningCount *prometheus.CounterVec
+
+	// CertificateExpiryCount is a Prometheus metric with semantics defined by its help string in NewCtrlFuncMetrics().
+	CertificateExpiryCount *prometheus.CounterVec
 }
 
 const (
@@ -37,9 +43,25 @@ const (
 )
 
 const (
-	MetricNameConfigPushCount    = "ingress_controller_configuration_push_count"
-	MetricNameTranslationCount   = "ingress_controller_translation_count"
-	MetricNameConfigPushDuration = "ingress_controller_configuration_push_duration_milliseconds"
+	// CredentialOutcomeKey defines the key of the metric label indicating whether a credential was provisioned
+	// or, if not, why it was skipped.
+	CredentialOutcomeKey string = "outcome"
+	// CredentialTypeKey defines the key of the metric label indicating the type of a credential, if known.
+	CredentialTypeKey string = "cred_type"
+)
+
+const (
+	// CertificateStatusKey defines the key of the metric label indicating whether a certificate is expired,
+	// about to expire or can't be parsed.
+	CertificateStatusKey string = "status"
+)
+
+const (
+	MetricNameConfigPushCount             = "ingress_controller_configuration_push_count"
+	MetricNameTranslationCount            = "ingress_controller_translation_count"
+	MetricNameConfigPushDuration          = "ingress_controller_configuration_push_duration_milliseconds"
+	MetricNameCredentialProvisioningCount = "ingress_controller_credential_provisioning_count"
+	MetricNameCertificateExpiryCount      = "ingress_controller_certificate_expiry_warning_count"
 )
 
 func NewCtrlFuncMetrics() *CtrlFuncMetrics {
@@ -80,7 +102,53 @@ func NewCtrlFuncMetrics() *CtrlFuncMetrics {
 		[]string{SuccessKey, ProtocolKey},
 	)
 
-	metrics.Registry.MustRegister(controllerMetrics.ConfigPushCount, controllerMetrics.TranslationCount, controllerMetrics.ConfigPushDuration)
+	controllerMetrics.CredentialProvisioningCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: MetricNameCredentialProvisioningCount,
+			Help: "Count of KongConsumer credentials processed during translations. `" +
+				CredentialOutcomeKey + "` describes whether the credential was provisioned or why it was skipped. `" +
+				CredentialTypeKey + "` describes the credential type, empty if the credential Secret could not be fetched.",
+		},
+		[]string{CredentialOutcomeKey, CredentialTypeKey},
+	)
+
+	controllerMetrics.CertificateExpiryCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: MetricNameCertificateExpiryCount,
+			Help: "Count of certificates found expired, about to expire or unparsable during translations. `" +
+				CertificateStatusKey + "` describes which of these applies to the certificate.",
+		},
+		[]string{CertificateStatusKey},
+	)
+
+	metrics.Registry.MustRegister(
+		controllerMetrics.ConfigPushCount,
+		controllerMetrics.TranslationCount,
+		controllerMetrics.ConfigPushDuration,
+		controllerMetrics.CredentialProvisioningCount,
+		controllerMetrics.CertificateExpiryCount,
+	)
 
 	return controllerMetrics
 }
+
+// RecordCredentialProvisioning increments the count of credentials processed with the given outcome and type.
+func (m *CtrlFuncMetrics) RecordCredentialProvisioning(outcome, credType string) {
+	if m == nil {
+		return
+	}
+	m.CredentialProvisioningCount.With(prometheus.Labels{
+		CredentialOutcomeKey: outcome,
+		CredentialTypeKey:    credType,
+	}).Inc()
+}
+
+// RecordCertificateExpiry increments the count of certificates found with the given expiry status.
+func (m *CtrlFuncMetrics) RecordCertificateExpiry(status string) {
+	if m == nil {
+		return
+	}
+	m.CertificateExpiryCount.With(prometheus.Labels{
+		CertificateStatusKey: status,
+	}).Inc()
+}
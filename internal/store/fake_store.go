@@ -46,10 +46,13 @@ type FakeObjects struct {
 	Services                       []*corev1.Service
 	Endpoints                      []*corev1.Endpoints
 	Secrets                        []*corev1.Secret
+	ConfigMaps                     []*corev1.ConfigMap
 	KongPlugins                    []*configurationv1.KongPlugin
 	KongClusterPlugins             []*configurationv1.KongClusterPlugin
 	KongIngresses                  []*configurationv1.KongIngress
 	KongConsumers                  []*configurationv1.KongConsumer
+	KongConsumerGroups             []*configurationv1beta1.KongConsumerGroup
+	KongUpstreamPolicies           []*configurationv1beta1.KongUpstreamPolicy
 
 	KnativeIngresses []*knative.Ingress
 }
@@ -151,6 +154,13 @@ func NewFakeStore(
 			return nil, err
 		}
 	}
+	configMapsStore := cache.NewStore(keyFunc)
+	for _, c := range objects.ConfigMaps {
+		err := configMapsStore.Add(c)
+		if err != nil {
+			return nil, err
+		}
+	}
 	endpointStore := cache.NewStore(keyFunc)
 	for _, e := range objects.Endpoints {
 		err := endpointStore.Add(e)
@@ -172,6 +182,20 @@ func NewFakeStore(
 			return nil, err
 		}
 	}
+	consumerGroupStore := cache.NewStore(keyFunc)
+	for _, cg := range objects.KongConsumerGroups {
+		err := consumerGroupStore.Add(cg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	upstreamPolicyStore := cache.NewStore(keyFunc)
+	for _, p := range objects.KongUpstreamPolicies {
+		err := upstreamPolicyStore.Add(p)
+		if err != nil {
+			return nil, err
+		}
+	}
 	kongPluginsStore := cache.NewStore(keyFunc)
 	for _, p := range objects.KongPlugins {
 		err := kongPluginsStore.Add(p)
@@ -210,10 +234,13 @@ func NewFakeStore(
 			Service:         serviceStore,
 			Endpoint:        endpointStore,
 			Secret:          secretsStore,
+			ConfigMap:       configMapsStore,
 
 			Plugin:                         kongPluginsStore,
 			ClusterPlugin:                  kongClusterPluginsStore,
 			Consumer:                       consumerStore,
+			ConsumerGroup:                  consumerGroupStore,
+			UpstreamPolicy:                 upstreamPolicyStore,
 			KongIngress:                    kongIngressStore,
 			IngressClassParametersV1alpha1: IngressClassParametersV1alpha1Store,
 
@@ -650,6 +650,31 @@ func TestFakeStoreSecret(t *testing.T) {
 	assert.True(errors.As(err, &ErrNotFound{}))
 }
 
+func TestFakeStoreConfigMap(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	configMaps := []*corev1.ConfigMap{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: "default",
+			},
+		},
+	}
+	store, err := NewFakeStore(FakeObjects{ConfigMaps: configMaps})
+	require.Nil(err)
+	require.NotNil(store)
+	configMap, err := store.GetConfigMap("default", "foo")
+	assert.Nil(err)
+	assert.NotNil(configMap)
+
+	configMap, err = store.GetConfigMap("default", "does-not-exist")
+	assert.Nil(configMap)
+	assert.NotNil(err)
+	assert.True(errors.As(err, &ErrNotFound{}))
+}
+
 func TestFakeKongIngress(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
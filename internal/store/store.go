@@ -72,12 +72,14 @@ func (e ErrNotFound) Error() string {
 // about ingresses, services, secrets and ingress annotations.
 type Storer interface {
 	GetSecret(namespace, name string) (*corev1.Secret, error)
+	GetConfigMap(namespace, name string) (*corev1.ConfigMap, error)
 	GetService(namespace, name string) (*corev1.Service, error)
 	GetEndpointsForService(namespace, name string) (*corev1.Endpoints, error)
 	GetKongIngress(namespace, name string) (*kongv1.KongIngress, error)
 	GetKongPlugin(namespace, name string) (*kongv1.KongPlugin, error)
 	GetKongClusterPlugin(name string) (*kongv1.KongClusterPlugin, error)
 	GetKongConsumer(namespace, name string) (*kongv1.KongConsumer, error)
+	GetKongUpstreamPolicy(namespace, name string) (*kongv1beta1.KongUpstreamPolicy, error)
 	GetIngressClassV1(name string) (*netv1.IngressClass, error)
 	GetIngressClassParametersV1Alpha1() (*kongv1alpha1.IngressClassParameters, error)
 
@@ -95,9 +97,12 @@ type Storer interface {
 	ListUDPIngresses() ([]*kongv1beta1.UDPIngress, error)
 	ListKnativeIngresses() ([]*knative.Ingress, error)
 	ListGlobalKongPlugins() ([]*kongv1.KongPlugin, error)
+	ListKongPlugins() ([]*kongv1.KongPlugin, error)
 	ListGlobalKongClusterPlugins() ([]*kongv1.KongClusterPlugin, error)
 	ListKongConsumers() []*kongv1.KongConsumer
+	ListKongConsumerGroups() []*kongv1beta1.KongConsumerGroup
 	ListCACerts() ([]*corev1.Secret, error)
+	ListSecrets() ([]*corev1.Secret, error)
 }
 
 // Store implements Storer and can be used to list Ingress, Services
@@ -128,6 +133,7 @@ type CacheStores struct {
 	IngressClassV1 cache.Store
 	Service        cache.Store
 	Secret         cache.Store
+	ConfigMap      cache.Store
 	Endpoint       cache.Store
 
 	// Gateway API Stores
@@ -142,6 +148,8 @@ type CacheStores struct {
 	Plugin                         cache.Store
 	ClusterPlugin                  cache.Store
 	Consumer                       cache.Store
+	ConsumerGroup                  cache.Store
+	UpstreamPolicy                 cache.Store
 	KongIngress                    cache.Store
 	TCPIngress                     cache.Store
 	UDPIngress                     cache.Store
@@ -162,6 +170,7 @@ func NewCacheStores() CacheStores {
 		IngressClassV1: cache.NewStore(clusterResourceKeyFunc),
 		Service:        cache.NewStore(keyFunc),
 		Secret:         cache.NewStore(keyFunc),
+		ConfigMap:      cache.NewStore(keyFunc),
 		Endpoint:       cache.NewStore(keyFunc),
 		// Gateway API Stores
 		HTTPRoute:       cache.NewStore(keyFunc),
@@ -174,6 +183,8 @@ func NewCacheStores() CacheStores {
 		Plugin:                         cache.NewStore(keyFunc),
 		ClusterPlugin:                  cache.NewStore(clusterResourceKeyFunc),
 		Consumer:                       cache.NewStore(keyFunc),
+		ConsumerGroup:                  cache.NewStore(keyFunc),
+		UpstreamPolicy:                 cache.NewStore(keyFunc),
 		KongIngress:                    cache.NewStore(keyFunc),
 		TCPIngress:                     cache.NewStore(keyFunc),
 		UDPIngress:                     cache.NewStore(keyFunc),
@@ -248,6 +259,8 @@ func (c CacheStores) Get(obj runtime.Object) (item interface{}, exists bool, err
 		return c.Service.Get(obj)
 	case *corev1.Secret:
 		return c.Secret.Get(obj)
+	case *corev1.ConfigMap:
+		return c.ConfigMap.Get(obj)
 	case *corev1.Endpoints:
 		return c.Endpoint.Get(obj)
 	// ----------------------------------------------------------------------------
@@ -274,6 +287,10 @@ func (c CacheStores) Get(obj runtime.Object) (item interface{}, exists bool, err
 		return c.ClusterPlugin.Get(obj)
 	case *kongv1.KongConsumer:
 		return c.Consumer.Get(obj)
+	case *kongv1beta1.KongConsumerGroup:
+		return c.ConsumerGroup.Get(obj)
+	case *kongv1beta1.KongUpstreamPolicy:
+		return c.UpstreamPolicy.Get(obj)
 	case *kongv1.KongIngress:
 		return c.KongIngress.Get(obj)
 	case *kongv1beta1.TCPIngress:
@@ -313,6 +330,8 @@ func (c CacheStores) Add(obj runtime.Object) error {
 		return c.Service.Add(obj)
 	case *corev1.Secret:
 		return c.Secret.Add(obj)
+	case *corev1.ConfigMap:
+		return c.ConfigMap.Add(obj)
 	case *corev1.Endpoints:
 		return c.Endpoint.Add(obj)
 	// ----------------------------------------------------------------------------
@@ -339,6 +358,10 @@ func (c CacheStores) Add(obj runtime.Object) error {
 		return c.ClusterPlugin.Add(obj)
 	case *kongv1.KongConsumer:
 		return c.Consumer.Add(obj)
+	case *kongv1beta1.KongConsumerGroup:
+		return c.ConsumerGroup.Add(obj)
+	case *kongv1beta1.KongUpstreamPolicy:
+		return c.UpstreamPolicy.Add(obj)
 	case *kongv1.KongIngress:
 		return c.KongIngress.Add(obj)
 	case *kongv1beta1.TCPIngress:
@@ -379,6 +402,8 @@ func (c CacheStores) Delete(obj runtime.Object) error {
 		return c.Service.Delete(obj)
 	case *corev1.Secret:
 		return c.Secret.Delete(obj)
+	case *corev1.ConfigMap:
+		return c.ConfigMap.Delete(obj)
 	case *corev1.Endpoints:
 		return c.Endpoint.Delete(obj)
 	// ----------------------------------------------------------------------------
@@ -405,6 +430,10 @@ func (c CacheStores) Delete(obj runtime.Object) error {
 		return c.ClusterPlugin.Delete(obj)
 	case *kongv1.KongConsumer:
 		return c.Consumer.Delete(obj)
+	case *kongv1beta1.KongConsumerGroup:
+		return c.ConsumerGroup.Delete(obj)
+	case *kongv1beta1.KongUpstreamPolicy:
+		return c.UpstreamPolicy.Delete(obj)
 	case *kongv1.KongIngress:
 		return c.KongIngress.Delete(obj)
 	case *kongv1beta1.TCPIngress:
@@ -470,6 +499,19 @@ func (s Store) GetSecret(namespace, name string) (*corev1.Secret, error) {
 	return secret.(*corev1.Secret), nil
 }
 
+// GetConfigMap returns a ConfigMap using the namespace and name as key.
+func (s Store) GetConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	key := fmt.Sprintf("%v/%v", namespace, name)
+	configMap, exists, err := s.stores.ConfigMap.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound{fmt.Sprintf("ConfigMap %v not found", key)}
+	}
+	return configMap.(*corev1.ConfigMap), nil
+}
+
 // GetService returns a Service using the namespace and name as key.
 func (s Store) GetService(namespace, name string) (*corev1.Service, error) {
 	key := fmt.Sprintf("%v/%v", namespace, name)
@@ -821,6 +863,19 @@ func (s Store) GetKongConsumer(namespace, name string) (*kongv1.KongConsumer, er
 	return p.(*kongv1.KongConsumer), nil
 }
 
+// GetKongUpstreamPolicy returns the 'name' KongUpstreamPolicy resource in namespace.
+func (s Store) GetKongUpstreamPolicy(namespace, name string) (*kongv1beta1.KongUpstreamPolicy, error) {
+	key := fmt.Sprintf("%v/%v", namespace, name)
+	p, exists, err := s.stores.UpstreamPolicy.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound{fmt.Sprintf("KongUpstreamPolicy %v not found", key)}
+	}
+	return p.(*kongv1beta1.KongUpstreamPolicy), nil
+}
+
 // GetIngressClassV1 returns the 'name' IngressClass resource.
 func (s Store) GetIngressClassV1(name string) (*netv1.IngressClass, error) {
 	p, exists, err := s.stores.IngressClassV1.GetByKey(name)
@@ -888,6 +943,20 @@ func (s Store) ListKongConsumers() []*kongv1.KongConsumer {
 	return consumers
 }
 
+// ListKongConsumerGroups returns all KongConsumerGroups filtered by the ingress.class
+// annotation.
+func (s Store) ListKongConsumerGroups() []*kongv1beta1.KongConsumerGroup {
+	var consumerGroups []*kongv1beta1.KongConsumerGroup
+	for _, item := range s.stores.ConsumerGroup.List() {
+		cg, ok := item.(*kongv1beta1.KongConsumerGroup)
+		if ok && s.isValidIngressClass(&cg.ObjectMeta, annotations.IngressClassKey, s.getIngressClassHandling()) {
+			consumerGroups = append(consumerGroups, cg)
+		}
+	}
+
+	return consumerGroups
+}
+
 // ListGlobalKongPlugins returns all KongPlugin resources
 // filtered by the ingress.class annotation and with the
 // label global:"true".
@@ -914,6 +983,24 @@ func (s Store) ListGlobalKongPlugins() ([]*kongv1.KongPlugin, error) {
 	return plugins, nil
 }
 
+// ListKongPlugins returns all KongPlugin resources
+// filtered by the ingress.class annotation.
+func (s Store) ListKongPlugins() ([]*kongv1.KongPlugin, error) {
+	var plugins []*kongv1.KongPlugin
+	err := cache.ListAll(s.stores.Plugin,
+		labels.NewSelector(),
+		func(ob interface{}) {
+			p, ok := ob.(*kongv1.KongPlugin)
+			if ok && s.isValidIngressClass(&p.ObjectMeta, annotations.IngressClassKey, s.getIngressClassHandling()) {
+				plugins = append(plugins, p)
+			}
+		})
+	if err != nil {
+		return nil, err
+	}
+	return plugins, nil
+}
+
 // ListGlobalKongClusterPlugins returns all KongClusterPlugin resources
 // filtered by the ingress.class annotation and with the
 // label global:"true".
@@ -961,6 +1048,21 @@ func (s Store) ListCACerts() ([]*corev1.Secret, error) {
 	return secrets, nil
 }
 
+// ListSecrets returns all the Secrets of the store.
+func (s Store) ListSecrets() ([]*corev1.Secret, error) {
+	var secrets []*corev1.Secret
+	err := cache.ListAll(s.stores.Secret, labels.NewSelector(),
+		func(ob interface{}) {
+			if p, ok := ob.(*corev1.Secret); ok {
+				secrets = append(secrets, p)
+			}
+		})
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
 func (s Store) networkingIngressV1Beta1(obj interface{}) *netv1beta1.Ingress {
 	switch obj := obj.(type) {
 	case *netv1beta1.Ingress:
@@ -1042,6 +1144,8 @@ func mkObjFromGVK(gvk schema.GroupVersionKind) (runtime.Object, error) {
 		return &corev1.Service{}, nil
 	case corev1.SchemeGroupVersion.WithKind("Secret"):
 		return &corev1.Secret{}, nil
+	case corev1.SchemeGroupVersion.WithKind("ConfigMap"):
+		return &corev1.ConfigMap{}, nil
 	case corev1.SchemeGroupVersion.WithKind("Endpoints"):
 		return &corev1.Endpoints{}, nil
 	// ----------------------------------------------------------------------------
@@ -1062,6 +1166,10 @@ func mkObjFromGVK(gvk schema.GroupVersionKind) (runtime.Object, error) {
 		return &kongv1.KongClusterPlugin{}, nil
 	case kongv1.SchemeGroupVersion.WithKind("KongConsumer"):
 		return &kongv1.KongConsumer{}, nil
+	case kongv1beta1.SchemeGroupVersion.WithKind("KongConsumerGroup"):
+		return &kongv1beta1.KongConsumerGroup{}, nil
+	case kongv1beta1.SchemeGroupVersion.WithKind("KongUpstreamPolicy"):
+		return &kongv1beta1.KongUpstreamPolicy{}, nil
 	case kongv1alpha1.SchemeGroupVersion.WithKind("IngressClassParameters"):
 		return &kongv1alpha1.IngressClassParameters{}, nil
 	// ----------------------------------------------------------------------------
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kong/go-kong/kong"
+)
+
+// credentialTypeToEntity maps the credential types accepted in KongConsumer
+// credential Secrets to the names of the corresponding Kong entities.
+var credentialTypeToEntity = map[string]string{
+	"key-auth":             "keyauth_credentials",
+	"keyauth_credential":   "keyauth_credentials",
+	"basic-auth":           "basicauth_credentials",
+	"basicauth_credential": "basicauth_credentials",
+	"hmac-auth":            "hmacauth_credentials",
+	"hmacauth_credential":  "hmacauth_credentials",
+	"oauth2":               "oauth2_credentials",
+	"jwt":                  "jwt_secrets",
+	"jwt_secret":           "jwt_secrets",
+	"acl":                  "acls",
+	"mtls-auth":            "mtls_auth_credentials",
+}
+
+// CredentialSchemaStore retrieves schemas of credential entities from Kong.
+type CredentialSchemaStore struct {
+	client  *kong.Client
+	schemas *schemaCache
+}
+
+// NewCredentialSchemaStore creates a CredentialSchemaStore.
+func NewCredentialSchemaStore(client *kong.Client) *CredentialSchemaStore {
+	return &CredentialSchemaStore{
+		client:  client,
+		schemas: newSchemaCache(),
+	}
+}
+
+// Schema retrieves the schema of the Kong entity backing a credential type.
+// A cache is used to save the responses and subsequent queries are served from
+// the cache, until its entries expire after SchemaCacheTTL. It's safe for concurrent use.
+func (c *CredentialSchemaStore) Schema(ctx context.Context, credType string) (map[string]interface{}, error) {
+	entity, ok := credentialTypeToEntity[credType]
+	if !ok {
+		return nil, fmt.Errorf("unknown credential type %q", credType)
+	}
+
+	// lookup in cache
+	if schema, ok := c.schemas.get(entity); ok {
+		return schema, nil
+	}
+
+	// not present in cache, lookup
+	schema, err := c.client.Schemas.Get(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	c.schemas.set(entity, schema)
+	return schema, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/blang/semver/v4"
 	"github.com/kong/go-kong/kong"
 )
 
@@ -13,27 +14,27 @@ import (
 // PluginSchemaStore retrives a schema of a Plugin from Kong.
 type PluginSchemaStore struct {
 	client  *kong.Client
-	schemas map[string]map[string]interface{}
+	schemas *schemaCache
 }
 
 // NewPluginSchemaStore creates a PluginSchemaStore.
 func NewPluginSchemaStore(client *kong.Client) *PluginSchemaStore {
 	return &PluginSchemaStore{
 		client:  client,
-		schemas: make(map[string]map[string]interface{}),
+		schemas: newSchemaCache(),
 	}
 }
 
 // Schema retrives schema of a plugin.
 // A cache is used to save the responses and subsequent queries are served from
-// the cache.
+// the cache, until its entries expire after SchemaCacheTTL. It's safe for concurrent use.
 func (p *PluginSchemaStore) Schema(ctx context.Context, pluginName string) (map[string]interface{}, error) {
 	if pluginName == "" {
 		return nil, fmt.Errorf("pluginName can not be empty")
 	}
 
 	// lookup in cache
-	if schema, ok := p.schemas[pluginName]; ok {
+	if schema, ok := p.schemas.get(pluginName); ok {
 		return schema, nil
 	}
 
@@ -42,6 +43,19 @@ func (p *PluginSchemaStore) Schema(ctx context.Context, pluginName string) (map[
 	if err != nil {
 		return nil, err
 	}
-	p.schemas[pluginName] = schema
+	p.schemas.set(pluginName, schema)
 	return schema, nil
 }
+
+// PluginSchema retrieves the schema of a plugin for a Kong version. Only the schemas
+// of the running Kong are available, so an error is returned for other versions.
+func (p *PluginSchemaStore) PluginSchema(
+	ctx context.Context,
+	version semver.Version,
+	pluginName string,
+) (map[string]interface{}, error) {
+	if running := GetKongVersion(); !version.EQ(running) {
+		return nil, fmt.Errorf("schemas are not available for Kong %s, the running version is %s", version, running)
+	}
+	return p.Schema(ctx, pluginName)
+}
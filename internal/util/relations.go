@@ -1,40 +1,26 @@
 package util
 
 type ForeignRelations struct {
-	Consumer, Route, Service []string
+	Consumer, ConsumerGroup, Route, Service []string
 }
 
 type Rel struct {
-	Consumer, Route, Service string
+	Consumer, ConsumerGroup, Route, Service string
 }
 
+// GetCombinations returns the entities a plugin is attached to for each of its instances.
+// Consumers and consumer groups can't be combined, so plugins related to both get separate
+// instances for each of them, scoped to each service and route if there are any.
 func (relations *ForeignRelations) GetCombinations() []Rel {
 	var cartesianProduct []Rel
 
-	if len(relations.Consumer) > 0 {
-		consumers := relations.Consumer
-		if len(relations.Route)+len(relations.Service) > 0 {
-			for _, service := range relations.Service {
-				for _, consumer := range consumers {
-					cartesianProduct = append(cartesianProduct, Rel{
-						Service:  service,
-						Consumer: consumer,
-					})
-				}
-			}
-			for _, route := range relations.Route {
-				for _, consumer := range consumers {
-					cartesianProduct = append(cartesianProduct, Rel{
-						Route:    route,
-						Consumer: consumer,
-					})
-				}
-			}
-		} else {
-			for _, consumer := range relations.Consumer {
-				cartesianProduct = append(cartesianProduct, Rel{Consumer: consumer})
-			}
-		}
+	if len(relations.Consumer) > 0 || len(relations.ConsumerGroup) > 0 {
+		cartesianProduct = append(cartesianProduct, relations.scopedCombinations(relations.Consumer, func(rel *Rel, id string) {
+			rel.Consumer = id
+		})...)
+		cartesianProduct = append(cartesianProduct, relations.scopedCombinations(relations.ConsumerGroup, func(rel *Rel, id string) {
+			rel.ConsumerGroup = id
+		})...)
 	} else {
 		for _, service := range relations.Service {
 			cartesianProduct = append(cartesianProduct, Rel{Service: service})
@@ -46,3 +32,32 @@ func (relations *ForeignRelations) GetCombinations() []Rel {
 
 	return cartesianProduct
 }
+
+// scopedCombinations combines each of the consumers or consumer groups ids, set on the
+// relations with set, with every service and route of the relations, if there are any.
+func (relations *ForeignRelations) scopedCombinations(ids []string, set func(rel *Rel, id string)) []Rel {
+	var res []Rel
+	if len(relations.Route)+len(relations.Service) == 0 {
+		for _, id := range ids {
+			rel := Rel{}
+			set(&rel, id)
+			res = append(res, rel)
+		}
+		return res
+	}
+	for _, service := range relations.Service {
+		for _, id := range ids {
+			rel := Rel{Service: service}
+			set(&rel, id)
+			res = append(res, rel)
+		}
+	}
+	for _, route := range relations.Route {
+		for _, id := range ids {
+			rel := Rel{Route: route}
+			set(&rel, id)
+			res = append(res, rel)
+		}
+	}
+	return res
+}
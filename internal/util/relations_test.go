@@ -190,6 +190,62 @@ func Test_GetCombinations(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "plugins on consumer groups only",
+			args: args{
+				relations: ForeignRelations{
+					ConsumerGroup: []string{"g1", "g2"},
+				},
+			},
+			want: []Rel{
+				{
+					ConsumerGroup: "g1",
+				},
+				{
+					ConsumerGroup: "g2",
+				},
+			},
+		},
+		{
+			name: "plugins on consumer groups, services and routes",
+			args: args{
+				relations: ForeignRelations{
+					ConsumerGroup: []string{"g1"},
+					Route:         []string{"r1"},
+					Service:       []string{"s1"},
+				},
+			},
+			want: []Rel{
+				{
+					ConsumerGroup: "g1",
+					Service:       "s1",
+				},
+				{
+					ConsumerGroup: "g1",
+					Route:         "r1",
+				},
+			},
+		},
+		{
+			name: "plugins on consumers and consumer groups are never combined",
+			args: args{
+				relations: ForeignRelations{
+					Consumer:      []string{"c1"},
+					ConsumerGroup: []string{"g1"},
+					Service:       []string{"s1"},
+				},
+			},
+			want: []Rel{
+				{
+					Consumer: "c1",
+					Service:  "s1",
+				},
+				{
+					ConsumerGroup: "g1",
+					Service:       "s1",
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
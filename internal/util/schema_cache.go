@@ -0,0 +1,50 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// SchemaCacheTTL is how long schemas retrieved from Kong are cached. Kong may be upgraded
+// while the controller runs, which may change the schemas, so they are eventually retrieved
+// again.
+const SchemaCacheTTL = 10 * time.Minute
+
+type schemaCacheEntry struct {
+	schema    map[string]interface{}
+	fetchedAt time.Time
+}
+
+// schemaCache caches schemas by name for SchemaCacheTTL. It's safe for concurrent use.
+type schemaCache struct {
+	lock    sync.RWMutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]schemaCacheEntry
+}
+
+func newSchemaCache() *schemaCache {
+	return &schemaCache{
+		ttl:     SchemaCacheTTL,
+		now:     time.Now,
+		entries: make(map[string]schemaCacheEntry),
+	}
+}
+
+// get returns the schema cached under name, unless it's missing or expired.
+func (c *schemaCache) get(name string) (map[string]interface{}, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	entry, ok := c.entries[name]
+	if !ok || c.now().Sub(entry.fetchedAt) >= c.ttl {
+		return nil, false
+	}
+	return entry.schema, true
+}
+
+// set caches schema under name.
+func (c *schemaCache) set(name string, schema map[string]interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[name] = schemaCacheEntry{schema: schema, fetchedAt: c.now()}
+}
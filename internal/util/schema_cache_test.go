@@ -0,0 +1,49 @@
+package util
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaCache(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newSchemaCache()
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.get("key-auth")
+	assert.False(t, ok, "missing schemas should not be returned")
+
+	schema := map[string]interface{}{"fields": []interface{}{}}
+	cache.set("key-auth", schema)
+	got, ok := cache.get("key-auth")
+	require.True(t, ok)
+	assert.Equal(t, schema, got)
+
+	now = now.Add(SchemaCacheTTL - time.Second)
+	_, ok = cache.get("key-auth")
+	assert.True(t, ok, "schemas should be cached until they expire")
+
+	now = now.Add(time.Second)
+	_, ok = cache.get("key-auth")
+	assert.False(t, ok, "expired schemas should not be returned")
+}
+
+func TestSchemaCache_Concurrency(t *testing.T) {
+	cache := newSchemaCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.set("key-auth", map[string]interface{}{})
+			_, _ = cache.get("key-auth")
+		}()
+	}
+	wg.Wait()
+	_, ok := cache.get("key-auth")
+	assert.True(t, ok)
+}
@@ -10,6 +10,15 @@ import "k8s.io/apimachinery/pkg/util/sets"
 // of credential that is being provided for the consumer.
 const TypeKey = "kongCredType"
 
+// TypeLabel indicates the label of a consumer secret which identifies the type
+// of credential when the secret has no TypeKey key.
+const TypeLabel = "konghq.com/credential"
+
+// SecretTypePrefix is the prefix of the consumer secret types which identify the type of
+// credential when the secret has neither a TypeKey key nor a TypeLabel label,
+// e.g. konghq.com/key-auth.
+const SecretTypePrefix = "konghq.com/"
+
 // SupportedTypes indicates all the "kongCredType"s which are supported for KongConsumer credentials.
 var SupportedTypes = sets.NewString(
 	"basic-auth",
@@ -1,15 +1,20 @@
 package v1
 
-// ConfigSource is a wrapper around SecretValueFromSource
+// ConfigSource is a wrapper around SecretValueFromSource and ConfigMapValueFromSource.
+// When both are set, the configurations they hold are merged and must not set the same keys.
 //+kubebuilder:object:generate=true
 type ConfigSource struct {
-	SecretValue SecretValueFromSource `json:"secretKeyRef,omitempty"`
+	SecretValue    SecretValueFromSource    `json:"secretKeyRef,omitempty"`
+	ConfigMapValue ConfigMapValueFromSource `json:"configMapKeyRef,omitempty"`
 }
 
-// NamespacedConfigSource is a wrapper around NamespacedSecretValueFromSource
+// NamespacedConfigSource is a wrapper around NamespacedSecretValueFromSource and
+// NamespacedConfigMapValueFromSource. When both are set, the configurations they hold
+// are merged and must not set the same keys.
 //+kubebuilder:object:generate=true
 type NamespacedConfigSource struct {
-	SecretValue NamespacedSecretValueFromSource `json:"secretKeyRef,omitempty"`
+	SecretValue    NamespacedSecretValueFromSource    `json:"secretKeyRef,omitempty"`
+	ConfigMapValue NamespacedConfigMapValueFromSource `json:"configMapKeyRef,omitempty"`
 }
 
 // SecretValueFromSource represents the source of a secret value
@@ -36,3 +41,28 @@ type NamespacedSecretValueFromSource struct {
 	//+kubebuilder:validation:Required
 	Key string `json:"key,omitempty"`
 }
+
+// ConfigMapValueFromSource represents the source of a ConfigMap value
+//+kubebuilder:object:generate=true
+type ConfigMapValueFromSource struct {
+	// the ConfigMap containing the key
+	//+kubebuilder:validation:Required
+	ConfigMap string `json:"name,omitempty"`
+	// the key containing the value
+	//+kubebuilder:validation:Required
+	Key string `json:"key,omitempty"`
+}
+
+// NamespacedConfigMapValueFromSource represents the source of a ConfigMap value specifying the ConfigMap namespace
+//+kubebuilder:object:generate=true
+type NamespacedConfigMapValueFromSource struct {
+	// The namespace containing the ConfigMap
+	//+kubebuilder:validation:Required
+	Namespace string `json:"namespace,omitempty"`
+	// the ConfigMap containing the key
+	//+kubebuilder:validation:Required
+	ConfigMap string `json:"name,omitempty"`
+	// the key containing the value
+	//+kubebuilder:validation:Required
+	Key string `json:"key,omitempty"`
+}
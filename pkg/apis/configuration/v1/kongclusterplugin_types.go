@@ -50,7 +50,7 @@ type KongClusterPlugin struct {
 	//+kubebuilder:validation:Type=object
 	Config apiextensionsv1.JSON `json:"config,omitempty"`
 
-	// ConfigFrom references a secret containing the plugin configuration.
+	// ConfigFrom references a Secret and/or a ConfigMap containing the plugin configuration.
 	ConfigFrom *NamespacedConfigSource `json:"configFrom,omitempty"`
 
 	// PluginName is the name of the plugin to which to apply the config
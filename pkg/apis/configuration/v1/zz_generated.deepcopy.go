@@ -26,10 +26,26 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapValueFromSource) DeepCopyInto(out *ConfigMapValueFromSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapValueFromSource.
+func (in *ConfigMapValueFromSource) DeepCopy() *ConfigMapValueFromSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapValueFromSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigSource) DeepCopyInto(out *ConfigSource) {
 	*out = *in
 	out.SecretValue = in.SecretValue
+	out.ConfigMapValue = in.ConfigMapValue
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigSource.
@@ -530,10 +546,26 @@ func (in *KongPluginList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedConfigMapValueFromSource) DeepCopyInto(out *NamespacedConfigMapValueFromSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedConfigMapValueFromSource.
+func (in *NamespacedConfigMapValueFromSource) DeepCopy() *NamespacedConfigMapValueFromSource {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedConfigMapValueFromSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NamespacedConfigSource) DeepCopyInto(out *NamespacedConfigSource) {
 	*out = *in
 	out.SecretValue = in.SecretValue
+	out.ConfigMapValue = in.ConfigMapValue
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedConfigSource.
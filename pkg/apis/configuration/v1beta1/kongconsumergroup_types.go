@@ -0,0 +1,51 @@
+/*
+Copyright 2022 Kong, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&KongConsumerGroup{}, &KongConsumerGroupList{})
+}
+
+//+kubebuilder:object:root=true
+
+// KongConsumerGroupList contains a list of KongConsumerGroup
+type KongConsumerGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KongConsumerGroup `json:"items"`
+}
+
+//+genclient
+//+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=kcg,categories=kong-ingress-controller
+//+kubebuilder:storageversion
+//+kubebuilder:validation:Optional
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="Age"
+
+// KongConsumerGroup is the Schema for the kongconsumergroups API.
+// KongConsumers are made members of a group with the konghq.com/consumer-groups
+// annotation, which lists the names of the KongConsumerGroups in the
+// consumer's namespace.
+type KongConsumerGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
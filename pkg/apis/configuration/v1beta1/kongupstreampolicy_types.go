@@ -0,0 +1,98 @@
+/*
+Copyright 2022 Kong, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/kong/go-kong/kong"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&KongUpstreamPolicy{}, &KongUpstreamPolicyList{})
+}
+
+//+kubebuilder:object:root=true
+
+// KongUpstreamPolicyList contains a list of KongUpstreamPolicy
+type KongUpstreamPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KongUpstreamPolicy `json:"items"`
+}
+
+//+genclient
+//+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=kup,categories=kong-ingress-controller
+//+kubebuilder:storageversion
+//+kubebuilder:validation:Optional
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="Age"
+
+// KongUpstreamPolicy is the Schema for the kongupstreampolicies API.
+// It configures the Kong Upstreams generated for the Services annotated
+// with konghq.com/upstream-policy set to the name of the policy.
+type KongUpstreamPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec contains the Upstream configuration.
+	Spec KongUpstreamPolicySpec `json:"spec,omitempty"`
+}
+
+// KongUpstreamPolicySpec contains the configuration applied to Kong Upstreams.
+type KongUpstreamPolicySpec struct {
+	// Algorithm is the load balancing algorithm to use.
+	//+kubebuilder:validation:Enum=round-robin;consistent-hashing;least-connections
+	Algorithm *string `json:"algorithm,omitempty"`
+
+	// Slots is the number of slots in the load balancer algorithm.
+	//+kubebuilder:validation:Minimum=10
+	Slots *int `json:"slots,omitempty"`
+
+	// HashOn defines how to calculate hash for consistent-hashing load balancing algorithm.
+	HashOn *KongUpstreamHash `json:"hashOn,omitempty"`
+
+	// HashOnFallback defines how to calculate hash for consistent-hashing load balancing algorithm
+	// if the primary hash does not return a hash.
+	HashOnFallback *KongUpstreamHash `json:"hashOnFallback,omitempty"`
+
+	// Healthchecks defines the health check configurations in Kong.
+	Healthchecks *kong.Healthcheck `json:"healthchecks,omitempty"`
+
+	// TargetWeight is the initial weight of the targets of the Upstream whose Service or
+	// backend reference doesn't set one. Kong gives such targets a weight of 100 if it's unset.
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=65535
+	TargetWeight *int `json:"targetWeight,omitempty"`
+}
+
+// KongUpstreamHash defines how to calculate hash for consistent-hashing load balancing algorithm.
+// Only one of the fields should be set, along with CookiePath when Cookie is set.
+type KongUpstreamHash struct {
+	// Input allows using one of the predefined inputs (ip, consumer, none).
+	//+kubebuilder:validation:Enum=ip;consumer;none
+	Input *string `json:"input,omitempty"`
+
+	// Header is the name of the header to use as hash input.
+	Header *string `json:"header,omitempty"`
+
+	// Cookie is the name of the cookie to use as hash input.
+	Cookie *string `json:"cookie,omitempty"`
+
+	// CookiePath is cookie path to set in the response headers.
+	CookiePath *string `json:"cookiePath,omitempty"`
+}
@@ -22,6 +22,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	"github.com/kong/go-kong/kong"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -76,6 +77,201 @@ func (in *IngressTLS) DeepCopy() *IngressTLS {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KongConsumerGroup) DeepCopyInto(out *KongConsumerGroup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KongConsumerGroup.
+func (in *KongConsumerGroup) DeepCopy() *KongConsumerGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(KongConsumerGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KongConsumerGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KongConsumerGroupList) DeepCopyInto(out *KongConsumerGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KongConsumerGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KongConsumerGroupList.
+func (in *KongConsumerGroupList) DeepCopy() *KongConsumerGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(KongConsumerGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KongConsumerGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KongUpstreamHash) DeepCopyInto(out *KongUpstreamHash) {
+	*out = *in
+	if in.Input != nil {
+		in, out := &in.Input, &out.Input
+		*out = new(string)
+		**out = **in
+	}
+	if in.Header != nil {
+		in, out := &in.Header, &out.Header
+		*out = new(string)
+		**out = **in
+	}
+	if in.Cookie != nil {
+		in, out := &in.Cookie, &out.Cookie
+		*out = new(string)
+		**out = **in
+	}
+	if in.CookiePath != nil {
+		in, out := &in.CookiePath, &out.CookiePath
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KongUpstreamHash.
+func (in *KongUpstreamHash) DeepCopy() *KongUpstreamHash {
+	if in == nil {
+		return nil
+	}
+	out := new(KongUpstreamHash)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KongUpstreamPolicy) DeepCopyInto(out *KongUpstreamPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KongUpstreamPolicy.
+func (in *KongUpstreamPolicy) DeepCopy() *KongUpstreamPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(KongUpstreamPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KongUpstreamPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KongUpstreamPolicyList) DeepCopyInto(out *KongUpstreamPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KongUpstreamPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KongUpstreamPolicyList.
+func (in *KongUpstreamPolicyList) DeepCopy() *KongUpstreamPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(KongUpstreamPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KongUpstreamPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KongUpstreamPolicySpec) DeepCopyInto(out *KongUpstreamPolicySpec) {
+	*out = *in
+	if in.Algorithm != nil {
+		in, out := &in.Algorithm, &out.Algorithm
+		*out = new(string)
+		**out = **in
+	}
+	if in.Slots != nil {
+		in, out := &in.Slots, &out.Slots
+		*out = new(int)
+		**out = **in
+	}
+	if in.HashOn != nil {
+		in, out := &in.HashOn, &out.HashOn
+		*out = new(KongUpstreamHash)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HashOnFallback != nil {
+		in, out := &in.HashOnFallback, &out.HashOnFallback
+		*out = new(KongUpstreamHash)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Healthchecks != nil {
+		in, out := &in.Healthchecks, &out.Healthchecks
+		*out = new(kong.Healthcheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TargetWeight != nil {
+		in, out := &in.TargetWeight, &out.TargetWeight
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KongUpstreamPolicySpec.
+func (in *KongUpstreamPolicySpec) DeepCopy() *KongUpstreamPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KongUpstreamPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TCPIngress) DeepCopyInto(out *TCPIngress) {
 	*out = *in
@@ -28,6 +28,8 @@ import (
 
 type ConfigurationV1beta1Interface interface {
 	RESTClient() rest.Interface
+	KongConsumerGroupsGetter
+	KongUpstreamPoliciesGetter
 	TCPIngressesGetter
 	UDPIngressesGetter
 }
@@ -37,6 +39,14 @@ type ConfigurationV1beta1Client struct {
 	restClient rest.Interface
 }
 
+func (c *ConfigurationV1beta1Client) KongConsumerGroups(namespace string) KongConsumerGroupInterface {
+	return newKongConsumerGroups(c, namespace)
+}
+
+func (c *ConfigurationV1beta1Client) KongUpstreamPolicies(namespace string) KongUpstreamPolicyInterface {
+	return newKongUpstreamPolicies(c, namespace)
+}
+
 func (c *ConfigurationV1beta1Client) TCPIngresses(namespace string) TCPIngressInterface {
 	return newTCPIngresses(c, namespace)
 }